@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
+)
+
+// AutomaticPersistedQueryStore is the PersistedOperationStore of the Automatic
+// Persisted Queries (APQ) protocol. A client first sends only the hash of its
+// query; when the engine answers with PersistedQueryNotFound, the client sends
+// the query along with the hash and the engine registers it. The queries are
+// kept in a caching.Cache for a TTL, after which clients register them again.
+type AutomaticPersistedQueryStore struct {
+	cache caching.Cache
+	ttl   time.Duration
+}
+
+var (
+	_ PersistedOperationStore    = (*AutomaticPersistedQueryStore)(nil)
+	_ PersistedOperationRegistry = (*AutomaticPersistedQueryStore)(nil)
+)
+
+// NewAutomaticPersistedQueryStore returns a store that keeps every registered
+// query in cache for ttl, which must be positive.
+func NewAutomaticPersistedQueryStore(cache caching.Cache, ttl time.Duration) (*AutomaticPersistedQueryStore, error) {
+	if ttl <= 0 {
+		return nil, caching.ErrMissingTTL
+	}
+	return &AutomaticPersistedQueryStore{cache: cache, ttl: ttl}, nil
+}
+
+func (s *AutomaticPersistedQueryStore) PersistedOperation(ctx context.Context, sha256Hash string) (string, bool, error) {
+	key := automaticPersistedQueryKey(sha256Hash)
+	items, err := s.cache.GetMany(ctx, []string{key})
+	if err != nil {
+		return "", false, err
+	}
+	item, ok := items[key]
+	if !ok {
+		return "", false, nil
+	}
+	return string(item.Value), true, nil
+}
+
+func (s *AutomaticPersistedQueryStore) RegisterPersistedOperation(ctx context.Context, sha256Hash string, document string) error {
+	return s.cache.SetMany(ctx, []caching.Item{{
+		Key:   automaticPersistedQueryKey(sha256Hash),
+		Value: []byte(document),
+		TTL:   s.ttl,
+	}})
+}
+
+// automaticPersistedQueryKey prefixes the hash, so a cache shared with the
+// response cache never mixes queries up with entities.
+func automaticPersistedQueryKey(sha256Hash string) string {
+	return "apq:" + sha256Hash
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/errorcodes"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+func newAutomaticPersistedQueryStore(t *testing.T) *AutomaticPersistedQueryStore {
+	t.Helper()
+
+	cache, err := caching.NewMemoryCache(caching.MemoryCacheOptions{MaxBytes: 1 << 20})
+	require.NoError(t, err)
+	store, err := NewAutomaticPersistedQueryStore(cache, time.Minute)
+	require.NoError(t, err)
+	return store
+}
+
+func TestExecutionEngine_AutomaticPersistedQueries(t *testing.T) {
+	const (
+		meQuery    = `query Me { me { id username } }`
+		meResponse = `{"data":{"me":{"id":"1234","username":"Me"}}}`
+	)
+
+	execute := func(t *testing.T, h *harness, request *graphql.Request) (string, error) {
+		t.Helper()
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), request, &writer)
+		return writer.String(), err
+	}
+
+	t.Run("a client registers its query after the hash alone is not found", func(t *testing.T) {
+		store := newAutomaticPersistedQueryStore(t)
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(store) })
+		h.users.answers(meAnswer)
+
+		_, err := execute(t, h, persistedOperationRequest(t, meQuery, ""))
+		require.ErrorIs(t, err, ErrPersistedOperationNotFound)
+		assert.Equal(t, graphqlerrors.RequestErrors{
+			{Message: "PersistedQueryNotFound", Extensions: &graphqlerrors.Extensions{Code: errorcodes.PersistedQueryNotFound}},
+		}, graphqlerrors.RequestErrorsFromError(err))
+
+		request := persistedOperationRequest(t, meQuery, "")
+		request.Query = meQuery
+		out, err := execute(t, h, request)
+		require.NoError(t, err)
+		assert.Equal(t, meResponse, out)
+
+		document, ok, err := store.PersistedOperation(t.Context(), persistedOperationHash(meQuery))
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, meQuery, document)
+
+		out, err = execute(t, h, persistedOperationRequest(t, meQuery, ""))
+		require.NoError(t, err)
+		assert.Equal(t, meResponse, out)
+	})
+
+	t.Run("a query that does not match its hash is not registered", func(t *testing.T) {
+		store := newAutomaticPersistedQueryStore(t)
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(store) })
+
+		request := persistedOperationRequest(t, meQuery, "")
+		request.Query = `{ me { id } }`
+		_, err := execute(t, h, request)
+		assert.ErrorIs(t, err, ErrPersistedQueryHashMismatch)
+
+		_, ok, err := store.PersistedOperation(t.Context(), persistedOperationHash(meQuery))
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("queries are not registered when only persisted operations are executed", func(t *testing.T) {
+		store := newAutomaticPersistedQueryStore(t)
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(store)
+			c.EnablePersistedOperationsOnly()
+		})
+
+		request := persistedOperationRequest(t, meQuery, "")
+		request.Query = meQuery
+		_, err := execute(t, h, request)
+		assert.ErrorIs(t, err, ErrOperationNotPersisted)
+
+		_, ok, err := store.PersistedOperation(t.Context(), persistedOperationHash(meQuery))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("the store requires a ttl", func(t *testing.T) {
+		cache, err := caching.NewMemoryCache(caching.MemoryCacheOptions{MaxBytes: 1 << 20})
+		require.NoError(t, err)
+
+		_, err = NewAutomaticPersistedQueryStore(cache, 0)
+		assert.ErrorIs(t, err, caching.ErrMissingTTL)
+	})
+}
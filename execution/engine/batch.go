@@ -0,0 +1,202 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+var (
+	// ErrBatchTooLarge is returned for a batch with more operations than
+	// BatchConfiguration.MaxBatchSize allows.
+	ErrBatchTooLarge = errors.New("the batch holds more operations than allowed")
+	// ErrBatchCostExceeded is the error of an operation whose estimated cost
+	// does not fit in what is left of the cost budget of its batch.
+	ErrBatchCostExceeded = errors.New("the operation exceeds the cost budget of its batch")
+	// ErrSubscriptionInBatch is the error of a subscription in a batch.
+	ErrSubscriptionInBatch = errors.New("subscriptions are not supported in a batch")
+	// ErrIncrementalDeliveryInBatch is the error of an operation in a batch
+	// that has to be delivered incrementally, such as one using @defer.
+	ErrIncrementalDeliveryInBatch = errors.New("incremental delivery is not supported in a batch")
+)
+
+// BatchConfiguration limits the execution of a batch of operations.
+type BatchConfiguration struct {
+	// MaxBatchSize is the number of operations a batch may hold. Zero means
+	// no limit.
+	MaxBatchSize int
+	// MaxConcurrency is the number of operations of a batch executed at the
+	// same time. Zero means all of them.
+	MaxConcurrency int
+	// MaxCost is the budget the operations of a batch share. Every operation
+	// takes its estimated cost out of it once planned, and fails with
+	// ErrBatchCostExceeded when that is more than what is left. The estimated
+	// cost is zero unless cost computation is enabled. Zero means no budget.
+	MaxCost int
+}
+
+// batchExecution is the state the operations of one batch share.
+type batchExecution struct {
+	// remainingCost is nil when the batch has no cost budget.
+	remainingCost *atomic.Int64
+}
+
+// consumeCost takes cost out of the budget of the batch, and reports false,
+// taking nothing, when the budget has less than that left.
+func (b *batchExecution) consumeCost(cost int) bool {
+	if b.remainingCost == nil {
+		return true
+	}
+	for {
+		remaining := b.remainingCost.Load()
+		if int64(cost) > remaining {
+			return false
+		}
+		if b.remainingCost.CompareAndSwap(remaining, remaining-int64(cost)) {
+			return true
+		}
+	}
+}
+
+// refundCost gives cost back to the budget of the batch, for an operation that
+// consumed it but was rejected before it was executed.
+func (b *batchExecution) refundCost(cost int) {
+	if b.remainingCost == nil {
+		return
+	}
+	b.remainingCost.Add(int64(cost))
+}
+
+func withBatch(batch *batchExecution) ExecutionOptions {
+	return func(ctx *internalExecutionContext) {
+		ctx.batch = batch
+	}
+}
+
+// ExecuteBatch executes a batch of operations concurrently, within the limits
+// of the BatchConfiguration, and writes their responses to writer as a JSON
+// array in the order of the operations.
+//
+// Every operation fails on its own: an operation that is rejected or fails
+// takes the place of its response with the errors it failed with, and the
+// others are executed all the same. The errors are those RequestErrorsFrom
+// returns, and any other failure is logged and answered as an internal error.
+// Subscriptions and operations that have to be delivered incrementally cannot
+// be batched and fail that way too. An error is returned only for a batch that
+// is not executed at all.
+//
+// The operations are executed with the same options, and consume the cost
+// budget of the batch in the order they are planned in. An operation that is
+// rate limited afterwards gives its cost back.
+func (e *ExecutionEngine) ExecuteBatch(ctx context.Context, operations []*graphql.Request, writer io.Writer, options ...ExecutionOptions) error {
+	if len(operations) == 0 {
+		return graphql.ErrEmptyBatch
+	}
+	config := e.config.batchConfiguration
+	if config.MaxBatchSize > 0 && len(operations) > config.MaxBatchSize {
+		return ErrBatchTooLarge
+	}
+
+	batch := &batchExecution{}
+	if config.MaxCost > 0 {
+		batch.remainingCost = &atomic.Int64{}
+		batch.remainingCost.Store(int64(config.MaxCost))
+	}
+	operationOptions := append(options[:len(options):len(options)], withBatch(batch))
+
+	concurrency := len(operations)
+	if config.MaxConcurrency > 0 {
+		concurrency = min(concurrency, config.MaxConcurrency)
+	}
+	slots := make(chan struct{}, concurrency)
+
+	results := make([]batchResultWriter, len(operations))
+	var wg sync.WaitGroup
+	for i, operation := range operations {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i].execute(e, ctx, operation, operationOptions)
+		}()
+	}
+	wg.Wait()
+
+	if _, err := writer.Write(lBrack); err != nil {
+		return err
+	}
+	for i := range results {
+		if i > 0 {
+			if _, err := writer.Write(comma); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.Write(results[i].Bytes()); err != nil {
+			return err
+		}
+	}
+	_, err := writer.Write(rBrack)
+	return err
+}
+
+var (
+	lBrack = []byte("[")
+	rBrack = []byte("]")
+	comma  = []byte(",")
+)
+
+// batchResultWriter buffers the response of one operation of a batch. A batch
+// is answered with one array, so a response that the resolver flushes, as it
+// does every payload of an incremental response, cannot be written.
+type batchResultWriter struct {
+	bytes.Buffer
+	flushed bool
+}
+
+var _ resolve.SubscriptionResponseWriter = (*batchResultWriter)(nil)
+
+func (w *batchResultWriter) execute(e *ExecutionEngine, ctx context.Context, operation *graphql.Request, options []ExecutionOptions) {
+	err := e.Execute(ctx, operation, w, options...)
+	if err == nil && w.flushed {
+		err = ErrIncrementalDeliveryInBatch
+	}
+	if err == nil {
+		return
+	}
+	requestErrors, ok := RequestErrorsFrom(err)
+	if !ok {
+		e.logger.Error("engine.ExecutionEngine.ExecuteBatch: executing operation", abstractlogger.Error(err))
+		requestErrors = graphqlerrors.RequestErrors{{Message: http.StatusText(http.StatusInternalServerError)}}
+	}
+	w.Reset()
+	_, _ = requestErrors.WriteResponse(w)
+}
+
+func (w *batchResultWriter) Flush() error {
+	w.flushed = true
+	return ErrIncrementalDeliveryInBatch
+}
+
+func (w *batchResultWriter) Complete() {}
+
+func (w *batchResultWriter) Heartbeat() error {
+	return nil
+}
+
+func (w *batchResultWriter) Error(data []byte) {
+	w.Reset()
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,117 @@
+package engine
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func TestExecutionEngine_ExecuteBatch(t *testing.T) {
+	const meQuery = `{ me { id username } }`
+
+	executeBatch := func(t *testing.T, h *harness, queries ...string) (string, error) {
+		t.Helper()
+		operations := make([]*graphql.Request, 0, len(queries))
+		for _, query := range queries {
+			operations = append(operations, &graphql.Request{Query: query})
+		}
+		var out bytes.Buffer
+		err := h.engine.ExecuteBatch(t.Context(), operations, &out)
+		return out.String(), err
+	}
+
+	t.Run("the responses are written in the order of the operations, each failing on its own", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		out, err := executeBatch(t, h, meQuery, `{ me { unknown } }`, `{ me { id } }`)
+		require.NoError(t, err)
+		assert.Equal(t, `[`+
+			`{"data":{"me":{"id":"1234","username":"Me"}}},`+
+			`{"errors":[{"message":"Cannot query field \"unknown\" on type \"User\".","path":["query","me"]}]},`+
+			`{"data":{"me":{"id":"1234"}}}`+
+			`]`, out)
+		assert.Equal(t, int64(2), h.users.calls())
+	})
+
+	t.Run("a batch larger than allowed is not executed", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetBatchConfiguration(BatchConfiguration{MaxBatchSize: 1}) })
+
+		_, err := executeBatch(t, h, meQuery, meQuery)
+		assert.ErrorIs(t, err, ErrBatchTooLarge)
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("an operation that has to be delivered incrementally fails", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		out, err := executeBatch(t, h, meQuery, `{ me { id ... @defer { username } } }`)
+		require.NoError(t, err)
+		assert.Equal(t, `[`+
+			`{"data":{"me":{"id":"1234","username":"Me"}}},`+
+			`{"errors":[{"message":"incremental delivery is not supported in a batch"}]}`+
+			`]`, out)
+	})
+
+	t.Run("the operations share the cost budget of the batch", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.EnableCostComputation()
+			c.SetBatchConfiguration(BatchConfiguration{MaxConcurrency: 1, MaxCost: 1})
+		})
+		h.users.answers(meAnswer)
+
+		out, err := executeBatch(t, h, meQuery, meQuery)
+		require.NoError(t, err)
+		assert.Equal(t, `[`+
+			`{"data":{"me":{"id":"1234","username":"Me"}}},`+
+			`{"errors":[{"message":"the operation exceeds the cost budget of its batch"}]}`+
+			`]`, out)
+		assert.Equal(t, int64(1), h.users.calls())
+	})
+
+	t.Run("an operation that is rate limited gives its cost back to the batch", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.SetCostControlConfiguration(CostControlConfiguration{})
+			c.SetBatchConfiguration(BatchConfiguration{MaxConcurrency: 1, MaxCost: 3})
+		})
+		h.users.answers(meAnswer)
+		limiter := &costBudgetRateLimiter{budget: 1}
+
+		operations := []*graphql.Request{{Query: singleEntityQuery}, {Query: meQuery}}
+		var out bytes.Buffer
+		err := h.engine.ExecuteBatch(t.Context(), operations, &out, withRateLimitOptions(limiter, resolve.RateLimitOptions{}))
+		require.NoError(t, err)
+		assert.Equal(t, `[`+
+			`{"errors":[{"message":"Rate limit exceeded for the operation, Reason: cost budget exhausted."}]},`+
+			`{"data":{"me":{"id":"1234","username":"Me"}}}`+
+			`]`, out.String())
+	})
+
+	t.Run("a failure of the engine is not shown to the client", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetCostControlConfiguration(CostControlConfiguration{}) })
+		limiter := &failingRateLimiter{err: errors.New("rate limiter store unavailable")}
+
+		var out bytes.Buffer
+		err := h.engine.ExecuteBatch(t.Context(), []*graphql.Request{{Query: meQuery}}, &out, withRateLimitOptions(limiter, resolve.RateLimitOptions{}))
+		require.NoError(t, err)
+		assert.Equal(t, `[{"errors":[{"message":"Internal Server Error"}]}]`, out.String())
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+}
+
+// failingRateLimiter fails to rate limit operations by cost.
+type failingRateLimiter struct {
+	costBudgetRateLimiter
+	err error
+}
+
+func (l *failingRateLimiter) RateLimitOperation(*resolve.Context, *resolve.OperationCost) (*resolve.RateLimitDeny, error) {
+	return nil, l.err
+}
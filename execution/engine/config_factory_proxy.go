@@ -110,8 +110,9 @@ func (p *ProxyEngineConfigFactory) EngineConfiguration() (Configuration, error)
 			Header: p.proxyUpstreamConfig.StaticHeaders,
 		},
 		Subscription: &graphqlDataSource.SubscriptionConfiguration{
-			URL:    p.proxyUpstreamConfig.URL,
-			UseSSE: p.proxyUpstreamConfig.SubscriptionType == SubscriptionTypeSSE,
+			URL:          p.proxyUpstreamConfig.URL,
+			UseSSE:       p.proxyUpstreamConfig.SubscriptionType == SubscriptionTypeSSE,
+			UseMultipart: p.proxyUpstreamConfig.SubscriptionType == SubscriptionTypeMultipart,
 		},
 		SchemaConfiguration: schemaConfiguration,
 	})
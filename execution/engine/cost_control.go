@@ -0,0 +1,112 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/errorcodes"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+// CostControlConfiguration enforces limits on the cost of operations (demand
+// control), computed from the @cost and @listSize directives of the schema.
+type CostControlConfiguration struct {
+	// MaxEstimatedCost rejects an operation whose estimated cost is more than
+	// it with a CostEstimatedTooExpensiveError, before any fetch runs. Zero
+	// means no limit.
+	MaxEstimatedCost int
+	// IncludeCostInResponseExtension adds the estimated and actual cost of an
+	// operation and MaxEstimatedCost to its response, in the cost extension.
+	IncludeCostInResponseExtension bool
+}
+
+// CostEstimatedTooExpensiveError is returned for an operation whose estimated
+// cost is more than CostControlConfiguration.MaxEstimatedCost. It converts to
+// graphqlerrors.RequestErrors with the COST_ESTIMATED_TOO_EXPENSIVE code.
+type CostEstimatedTooExpensiveError struct {
+	EstimatedCost int
+	MaxCost       int
+}
+
+func (e *CostEstimatedTooExpensiveError) Error() string {
+	return fmt.Sprintf("The estimated cost %d of the operation exceeds the maximum cost %d.", e.EstimatedCost, e.MaxCost)
+}
+
+func (e *CostEstimatedTooExpensiveError) As(target any) bool {
+	requestErrors, ok := target.(*graphqlerrors.RequestErrors)
+	if !ok {
+		return false
+	}
+	*requestErrors = graphqlerrors.RequestErrors{
+		{Message: e.Error(), Extensions: &graphqlerrors.Extensions{Code: errorcodes.CostEstimatedTooExpensive}},
+	}
+	return true
+}
+
+// OperationRateLimitedError is returned for an operation whose cost a
+// resolve.CostRateLimiter denied. It converts to graphqlerrors.RequestErrors
+// with the code of RateLimitOptions.ErrorExtensionCode, when that is enabled.
+type OperationRateLimitedError struct {
+	Reason string
+	Code   string
+}
+
+func (e *OperationRateLimitedError) Error() string {
+	if e.Reason == "" {
+		return "Rate limit exceeded for the operation."
+	}
+	return fmt.Sprintf("Rate limit exceeded for the operation, Reason: %s.", e.Reason)
+}
+
+func (e *OperationRateLimitedError) As(target any) bool {
+	requestErrors, ok := target.(*graphqlerrors.RequestErrors)
+	if !ok {
+		return false
+	}
+	requestError := graphqlerrors.RequestError{Message: e.Error()}
+	if e.Code != "" {
+		requestError.Extensions = &graphqlerrors.Extensions{Code: e.Code}
+	}
+	*requestErrors = graphqlerrors.RequestErrors{requestError}
+	return true
+}
+
+// checkEstimatedCost rejects an operation whose estimated cost is over the limit.
+func (e *ExecutionEngine) checkEstimatedCost(operation *graphql.Request) error {
+	maxCost := e.config.costControl.MaxEstimatedCost
+	if maxCost <= 0 || operation.EstimatedCost() <= maxCost {
+		return nil
+	}
+	return &CostEstimatedTooExpensiveError{EstimatedCost: operation.EstimatedCost(), MaxCost: maxCost}
+}
+
+// rateLimitCost sets the cost of the operation on the resolve context, where
+// the rate limiter and the cost extension pick it up, and has a
+// resolve.CostRateLimiter consume it.
+func (e *ExecutionEngine) rateLimitCost(ctx *resolve.Context, operation *graphql.Request, costCalculator *plan.CostCalculator, vars resolve.VariablesView) error {
+	ctx.SetOperationCost(&resolve.OperationCost{
+		Estimated: operation.EstimatedCost(),
+		Limit:     e.config.costControl.MaxEstimatedCost,
+		Actual: func(typeNameStats map[string]resolve.TypeNameStats) int {
+			return costCalculator.ActualCost(vars, typeNameStats)
+		},
+	})
+	if e.config.costControl.IncludeCostInResponseExtension {
+		ctx.ExecutionOptions.IncludeCostInResponse = true
+	}
+
+	deny, err := ctx.RateLimitOperation()
+	if err != nil {
+		return err
+	}
+	if deny == nil {
+		return nil
+	}
+	rateLimitErr := &OperationRateLimitedError{Reason: deny.Reason}
+	if ctx.RateLimitOptions.ErrorExtensionCode.Enabled {
+		rateLimitErr.Code = ctx.RateLimitOptions.ErrorExtensionCode.Code
+	}
+	return rateLimitErr
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/telemetry"
 )
 
 const (
@@ -23,6 +24,17 @@ type Configuration struct {
 	plannerConfig            plan.Configuration
 	websocketBeforeStartHook WebsocketBeforeStartHook
 	enableScheduleFetches    bool
+
+	persistedOperationStore PersistedOperationStore
+	persistedOperationsOnly bool
+
+	batchConfiguration BatchConfiguration
+	costControl        CostControlConfiguration
+
+	telemetry *telemetry.Telemetry
+
+	executionPlanCacheMaxBytes int64
+	normalizationCacheSize     int
 }
 
 func NewConfiguration(schema *graphql.Schema) Configuration {
@@ -85,6 +97,62 @@ func (e *Configuration) EnableScheduleFetches() {
 	e.enableScheduleFetches = true
 }
 
+// SetPersistedOperationStore sets the store the engine looks up persisted
+// operations in. A request that carries the sha256 hash of a persisted
+// operation in its persistedQuery extension may then omit the query. The
+// normalized document of a persisted operation is cached per hash, but the
+// store is still asked for the operation on every request.
+func (e *Configuration) SetPersistedOperationStore(store PersistedOperationStore) {
+	e.persistedOperationStore = store
+}
+
+// EnablePersistedOperationsOnly rejects every operation that is not in the
+// persisted operation store, turning the store into an allowlist.
+func (e *Configuration) EnablePersistedOperationsOnly() {
+	e.persistedOperationsOnly = true
+}
+
+// EnableCostComputation has the planner compute the estimated cost of every
+// operation from the @cost and @listSize directives of the schema.
+func (e *Configuration) EnableCostComputation() {
+	e.plannerConfig.ComputeCosts = true
+}
+
+// SetCostControlConfiguration enforces limits on the cost of operations. It
+// enables cost computation, and the cost of an operation is passed on to a
+// resolve.CostRateLimiter, which consumes its budget by cost.
+func (e *Configuration) SetCostControlConfiguration(costControl CostControlConfiguration) {
+	e.costControl = costControl
+	e.plannerConfig.ComputeCosts = true
+}
+
+// SetBatchConfiguration sets the limits for executing batches of operations,
+// see ExecutionEngine.ExecuteBatch.
+func (e *Configuration) SetBatchConfiguration(batchConfiguration BatchConfiguration) {
+	e.batchConfiguration = batchConfiguration
+}
+
+// SetTelemetry records OpenTelemetry spans and metrics for every operation:
+// a span per operation with child spans for its phases and fetches. The
+// telemetry is set as LoaderHooks of the operation, an ExecutionOptions
+// setting other LoaderHooks replaces it for the fetches.
+func (e *Configuration) SetTelemetry(t *telemetry.Telemetry) {
+	e.telemetry = t
+}
+
+// SetExecutionPlanCacheMaxBytes bounds the estimated memory held by the cache
+// of execution plans, which is DefaultExecutionPlanCacheMaxBytes otherwise.
+func (e *Configuration) SetExecutionPlanCacheMaxBytes(maxBytes int64) {
+	e.executionPlanCacheMaxBytes = maxBytes
+}
+
+// SetNormalizationCacheSize bounds the number of entries the cache of
+// normalized operations holds, and the cache of persisted operations too,
+// which is DefaultNormalizationCacheSize otherwise.
+func (e *Configuration) SetNormalizationCacheSize(size int) {
+	e.normalizationCacheSize = size
+}
+
 type dataSourceGeneratorOptions struct {
 	streamingClient           *http.Client
 	subscriptionType          SubscriptionType
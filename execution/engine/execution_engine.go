@@ -22,14 +22,17 @@ import (
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/postprocess"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/telemetry"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/variablesvalidation"
 )
 
 type internalExecutionContext struct {
-	resolveContext *resolve.Context
-	postProcessor  *postprocess.Processor
+	resolveContext   *resolve.Context
+	postProcessor    *postprocess.Processor
+	withoutMutations bool
+	batch            *batchExecution
 }
 
 func newInternalExecutionContext(postProcessorOptions ...postprocess.ProcessorOption) *internalExecutionContext {
@@ -57,7 +60,9 @@ type ExecutionEngine struct {
 	logger                   abstractlogger.Logger
 	config                   Configuration
 	resolver                 *resolve.Resolver
-	executionPlanCache       *lru.Cache
+	executionPlanCache       *executionPlanCache
+	normalizationCache       *lru.Cache
+	persistedOperationCache  *lru.Cache
 	apolloCompatibilityFlags apollocompatibility.Flags
 	validationOptions        []astvalidation.Option
 	postProcessorOptions     []postprocess.ProcessorOption
@@ -120,12 +125,36 @@ func WithPreFetchFieldAuthorizer(authorizer resolve.BatchAuthorizer) ExecutionOp
 	}
 }
 
+// ErrMutationNotAllowed is returned for a mutation executed with WithoutMutations.
+var ErrMutationNotAllowed = errors.New("mutations are not allowed")
+
+// WithoutMutations rejects the operation with ErrMutationNotAllowed when it is
+// a mutation, such as one sent in a GET request. The check runs after a
+// persisted operation was loaded, so it covers operations sent by hash too.
+func WithoutMutations() ExecutionOptions {
+	return func(ctx *internalExecutionContext) {
+		ctx.withoutMutations = true
+	}
+}
+
 func NewExecutionEngine(ctx context.Context, logger abstractlogger.Logger, engineConfig Configuration, resolverOptions resolve.ResolverOptions) (*ExecutionEngine, error) {
-	executionPlanCache, err := lru.New(1024)
+	normalizationCacheSize := engineConfig.normalizationCacheSize
+	if normalizationCacheSize <= 0 {
+		normalizationCacheSize = DefaultNormalizationCacheSize
+	}
+	normalizationCache, err := lru.New(normalizationCacheSize)
 	if err != nil {
 		return nil, err
 	}
 
+	var persistedOperationCache *lru.Cache
+	if engineConfig.persistedOperationStore != nil {
+		persistedOperationCache, err = lru.New(normalizationCacheSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	introspectionCfg, err := introspection_datasource.NewIntrospectionConfigFactory(engineConfig.schema.Document())
 	if err != nil {
 		return nil, err
@@ -162,11 +191,23 @@ func NewExecutionEngine(ctx context.Context, logger abstractlogger.Logger, engin
 		postProcessorOptions = append(postProcessorOptions, postprocess.EnableScheduleFetches())
 	}
 
+	if logger == nil {
+		logger = abstractlogger.NoopLogger
+	}
+
+	// The actual cost in the cost extension is computed from the stats the
+	// resolver collects with cost control.
+	if engineConfig.costControl.IncludeCostInResponseExtension {
+		resolverOptions.ResolvableOptions.EnableCostControl = true
+	}
+
 	return &ExecutionEngine{
-		logger:             logger,
-		config:             engineConfig,
-		resolver:           resolve.New(ctx, resolverOptions),
-		executionPlanCache: executionPlanCache,
+		logger:                  logger,
+		config:                  engineConfig,
+		resolver:                resolve.New(ctx, resolverOptions),
+		executionPlanCache:      newExecutionPlanCache(engineConfig.executionPlanCacheMaxBytes),
+		normalizationCache:      normalizationCache,
+		persistedOperationCache: persistedOperationCache,
 		apolloCompatibilityFlags: apollocompatibility.Flags{
 			ReplaceInvalidVarError: resolverOptions.ResolvableOptions.ApolloCompatibilityReplaceInvalidVarError,
 		},
@@ -176,69 +217,36 @@ func NewExecutionEngine(ctx context.Context, logger abstractlogger.Logger, engin
 }
 
 func (e *ExecutionEngine) Execute(ctx context.Context, operation *graphql.Request, writer resolve.SubscriptionResponseWriter, options ...ExecutionOptions) error {
-	normalize := !operation.IsNormalized()
-	if normalize {
-		// Normalize the operation, but extract variables later so ValidateForSchema can return correct error messages for bad arguments.
-		result, err := operation.Normalize(e.config.schema,
-			astnormalization.WithRemoveFragmentDefinitions(),
-			astnormalization.WithRemoveUnusedVariables(),
-			astnormalization.WithInlineFragmentSpreads(),
-			astnormalization.WithEnableDefer(),
-			astnormalization.WithPrevalidationRules(
-				astvalidation.DeferStreamOnValidOperations(),
-				astvalidation.DeferStreamHaveUniqueLabels(),
-				astvalidation.DirectivesAreInValidLocations(),
-				astvalidation.StreamAppliedToListFieldsOnly()),
-		)
-		if err != nil {
-			return err
-		} else if !result.Successful {
-			return result.Errors
-		}
-		normalize = true
+	if e.config.telemetry == nil {
+		return e.execute(ctx, operation, writer, options...)
 	}
 
-	// Validate the operation against the schema.
-	if result, err := operation.ValidateForSchema(e.config.schema, e.validationOptions...); err != nil {
-		return err
-	} else if !result.Valid {
-		return result.Errors
-	}
-
-	if normalize {
-		// Normalize the operation again, this time just extracting additional variables from arguments.
-		result, err := operation.Normalize(e.config.schema,
-			astnormalization.WithExtractVariables(),
-		)
-		if err != nil {
-			return err
-		} else if !result.Successful {
-			return result.Errors
-		}
+	ctx, op := e.config.telemetry.StartOperation(ctx, operation.OperationName)
+	err := e.execute(ctx, operation, writer, options...)
+	if operationType, typeErr := operation.OperationType(); typeErr == nil && operationType != graphql.OperationTypeUnknown {
+		op.SetType(ast.OperationType(operationType).Name())
 	}
+	op.End(err)
+	return err
+}
 
-	// Remap operation variables to canonical names. This mirrors what the cosmo
-	// router does so that downstream code (planner, cost calc, resolver) always
-	// goes through VariablesView/RemapVariables when reading variables.
-	var remapVariables map[string]string
-	if normalize {
-		var remapReport operationreport.Report
-		remapVariables = astnormalization.NewVariablesMapper().NormalizeOperation(
-			operation.Document(), e.config.schema.Document(), &remapReport,
-		)
-		if remapReport.HasErrors() {
-			return remapReport
-		}
+func (e *ExecutionEngine) execute(ctx context.Context, operation *graphql.Request, writer resolve.SubscriptionResponseWriter, options ...ExecutionOptions) error {
+	remapVariables, err := e.prepareOperation(ctx, operation)
+	if err != nil {
+		return err
 	}
 
 	// Validate user-supplied and extracted variables against the (remapped) operation.
 	// ValidateWithRemap translates renamed names back to originals for both JSON lookup
 	// and error messages, so users still see their declared variable names in errors.
 	if len(operation.Variables) > 0 && operation.Variables[0] == '{' {
-		validator := variablesvalidation.NewVariablesValidator(variablesvalidation.VariablesValidatorOptions{
-			ApolloCompatibilityFlags: e.apolloCompatibilityFlags,
+		err := e.phase(ctx, telemetry.PhaseValidate, func() error {
+			validator := variablesvalidation.NewVariablesValidator(variablesvalidation.VariablesValidatorOptions{
+				ApolloCompatibilityFlags: e.apolloCompatibilityFlags,
+			})
+			return validator.ValidateWithRemap(operation.Document(), e.config.schema.Document(), operation.Variables, remapVariables)
 		})
-		if err := validator.ValidateWithRemap(operation.Document(), e.config.schema.Document(), operation.Variables, remapVariables); err != nil {
+		if err != nil {
 			return err
 		}
 	}
@@ -248,11 +256,24 @@ func (e *ExecutionEngine) Execute(ctx context.Context, operation *graphql.Reques
 	execContext.setVariables(operation.Variables)
 	execContext.setRequest(operation.InternalRequest())
 	execContext.resolveContext.RemapVariables = remapVariables
+	if e.config.telemetry != nil {
+		execContext.resolveContext.SetEngineLoaderHooks(e.config.telemetry)
+	}
 
 	for i := range options {
 		options[i](execContext)
 	}
 
+	if execContext.withoutMutations || execContext.batch != nil {
+		operationType, _ := operation.OperationType()
+		if execContext.withoutMutations && operationType == graphql.OperationTypeMutation {
+			return ErrMutationNotAllowed
+		}
+		if execContext.batch != nil && operationType == graphql.OperationTypeSubscription {
+			return ErrSubscriptionInBatch
+		}
+	}
+
 	if execContext.resolveContext.TracingOptions.Enable {
 		traceCtx := resolve.SetTraceStart(execContext.resolveContext.Context(), execContext.resolveContext.TracingOptions.EnablePredictableDebugTimings)
 		execContext.setContext(traceCtx)
@@ -263,19 +284,44 @@ func (e *ExecutionEngine) Execute(ctx context.Context, operation *graphql.Reques
 		tracePlanStart = resolve.GetDurationNanoSinceTraceStart(execContext.resolveContext.Context())
 	}
 
-	var report operationreport.Report
-	cachedPlan, costCalculator := e.getCachedPlan(execContext, operation.Document(), e.config.schema.Document(), operation.OperationName, &report)
-	if report.HasErrors() {
-		return report
-	}
-	varsView := execContext.resolveContext.VariablesView()
-	if costCalculator != nil {
-		costCalculator.ValidateSliceArguments(varsView, &report)
+	var (
+		cachedPlan     plan.Plan
+		costCalculator *plan.CostCalculator
+		varsView       resolve.VariablesView
+	)
+	err = e.phase(execContext.resolveContext.Context(), telemetry.PhasePlan, func() error {
+		var report operationreport.Report
+		cachedPlan, costCalculator = e.getCachedPlan(execContext, operation.Document(), e.config.schema.Document(), operation.OperationName, &report)
 		if report.HasErrors() {
 			return report
 		}
+		varsView = execContext.resolveContext.VariablesView()
+		if costCalculator != nil {
+			costCalculator.ValidateSliceArguments(varsView, &report)
+			if report.HasErrors() {
+				return report
+			}
+		}
+		operation.ComputeEstimatedCost(costCalculator, varsView)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = e.checkEstimatedCost(operation); err != nil {
+		return err
+	}
+	if execContext.batch != nil && !execContext.batch.consumeCost(operation.EstimatedCost()) {
+		return ErrBatchCostExceeded
+	}
+	if costCalculator != nil {
+		if err = e.rateLimitCost(execContext.resolveContext, operation, costCalculator, varsView); err != nil {
+			if execContext.batch != nil {
+				execContext.batch.refundCost(operation.EstimatedCost())
+			}
+			return err
+		}
 	}
-	operation.ComputeEstimatedCost(costCalculator, varsView)
 
 	if execContext.resolveContext.TracingOptions.Enable && !execContext.resolveContext.TracingOptions.ExcludePlannerStats {
 		planningTime := resolve.GetDurationNanoSinceTraceStart(execContext.resolveContext.Context()) - tracePlanStart
@@ -307,22 +353,147 @@ func (e *ExecutionEngine) Execute(ctx context.Context, operation *graphql.Reques
 	}
 }
 
+// prepareOperation loads, parses, normalizes and validates the operation and
+// extracts its argument values into variables, which leaves it ready to be
+// planned. It returns the canonical names the variables were remapped to.
+func (e *ExecutionEngine) prepareOperation(ctx context.Context, operation *graphql.Request) (remapVariables map[string]string, err error) {
+	lookup, normalized, err := e.loadPersistedOperation(ctx, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Operations other than persisted ones are looked up in the normalization cache.
+	if lookup == nil && normalized == nil && !operation.IsNormalized() {
+		lookup, normalized, err = e.loadNormalizedOperation(operation)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// An operation served from the persisted operation or the normalization
+	// cache is normalized and validated already.
+	if normalized != nil {
+		return normalized.load(operation, e.config.schema)
+	}
+
+	if err := e.phase(ctx, telemetry.PhaseParse, operation.Parse); err != nil {
+		return nil, err
+	}
+	if lookup != nil {
+		lookup.inspect(operation)
+	}
+
+	normalize := !operation.IsNormalized()
+	if normalize {
+		// Normalize the operation, but extract variables later so ValidateForSchema can return correct error messages for bad arguments.
+		err := e.phase(ctx, telemetry.PhaseNormalize, func() error {
+			result, err := operation.Normalize(e.config.schema,
+				astnormalization.WithRemoveFragmentDefinitions(),
+				astnormalization.WithRemoveUnusedVariables(),
+				astnormalization.WithInlineFragmentSpreads(),
+				astnormalization.WithEnableDefer(),
+				astnormalization.WithPrevalidationRules(
+					astvalidation.DeferStreamOnValidOperations(),
+					astvalidation.DeferStreamHaveUniqueLabels(),
+					astvalidation.DirectivesAreInValidLocations(),
+					astvalidation.StreamAppliedToListFieldsOnly()),
+			)
+			if err != nil {
+				return err
+			} else if !result.Successful {
+				return result.Errors
+			}
+			return nil
+		})
+		if err != nil {
+			lookup.addErrors(err)
+			return nil, err
+		}
+	}
+
+	// Validate the operation against the schema.
+	err = e.phase(ctx, telemetry.PhaseValidate, func() error {
+		if result, err := operation.ValidateForSchema(e.config.schema, e.validationOptions...); err != nil {
+			return err
+		} else if !result.Valid {
+			return result.Errors
+		}
+		return nil
+	})
+	if err != nil {
+		lookup.addErrors(err)
+		return nil, err
+	}
+	if !normalize {
+		return nil, nil
+	}
+	if lookup != nil {
+		if err := lookup.inspectValidated(operation); err != nil {
+			return nil, err
+		}
+	}
+
+	err = e.phase(ctx, telemetry.PhaseNormalize, func() error {
+		// Normalize the operation again, this time just extracting additional variables from arguments.
+		result, err := operation.Normalize(e.config.schema,
+			astnormalization.WithExtractVariables(),
+		)
+		if err != nil {
+			return err
+		} else if !result.Successful {
+			return result.Errors
+		}
+
+		// Remap operation variables to canonical names. This mirrors what the cosmo
+		// router does so that downstream code (planner, cost calc, resolver) always
+		// goes through VariablesView/RemapVariables when reading variables.
+		var remapReport operationreport.Report
+		remapVariables = astnormalization.NewVariablesMapper().NormalizeOperation(
+			operation.Document(), e.config.schema.Document(), &remapReport,
+		)
+		if remapReport.HasErrors() {
+			return remapReport
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lookup != nil {
+		lookup.add(operation, remapVariables)
+	}
+
+	return remapVariables, nil
+}
+
+// phase runs fn in the span of the phase when telemetry is set.
+func (e *ExecutionEngine) phase(ctx context.Context, phase telemetry.Phase, fn func() error) error {
+	if e.config.telemetry == nil {
+		return fn()
+	}
+	end := e.config.telemetry.StartPhase(ctx, phase)
+	err := fn()
+	end(err)
+	return err
+}
+
 func (e *ExecutionEngine) getCachedPlan(ctx *internalExecutionContext, operation, definition *ast.Document, operationName string, report *operationreport.Report) (plan.Plan, *plan.CostCalculator) {
-	hash := pool.Hash64.Get()
-	hash.Reset()
-	defer pool.Hash64.Put(hash)
-	err := astprinter.Print(operation, hash)
+	printed := pool.BytesBuffer.Get()
+	defer pool.BytesBuffer.Put(printed)
+	err := astprinter.Print(operation, printed)
 	if err != nil {
 		report.AddInternalError(err)
 		return nil, nil
 	}
 
+	hash := pool.Hash64.Get()
+	hash.Reset()
+	defer pool.Hash64.Put(hash)
+	_, _ = hash.Write(printed.Bytes())
 	cacheKey := hash.Sum64()
 
-	if cached, ok := e.executionPlanCache.Get(cacheKey); ok {
-		if p, ok := cached.(plan.Plan); ok {
-			return p, p.GetCostCalculator()
-		}
+	if p, ok := e.executionPlanCache.Get(cacheKey); ok {
+		return p, p.GetCostCalculator()
 	}
 
 	planner, _ := plan.NewPlanner(e.config.plannerConfig)
@@ -332,7 +503,7 @@ func (e *ExecutionEngine) getCachedPlan(ctx *internalExecutionContext, operation
 	}
 
 	ctx.postProcessor.Process(planResult)
-	e.executionPlanCache.Add(cacheKey, planResult)
+	e.executionPlanCache.Add(cacheKey, CachedOperation{OperationName: operationName, Query: printed.String()}, planResult)
 	return planResult, planResult.GetCostCalculator()
 }
 
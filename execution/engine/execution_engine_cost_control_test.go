@@ -0,0 +1,120 @@
+package engine
+
+import (
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/errorcodes"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+// costBudgetRateLimiter consumes its budget by the estimated cost of operations.
+type costBudgetRateLimiter struct {
+	budget   int
+	preFetch int
+}
+
+func (l *costBudgetRateLimiter) RateLimitPreFetch(*resolve.Context, *resolve.FetchInfo, json.RawMessage) (*resolve.RateLimitDeny, error) {
+	l.preFetch++
+	return nil, nil
+}
+
+func (l *costBudgetRateLimiter) RenderResponseExtension(*resolve.Context, io.Writer) error {
+	return nil
+}
+
+func (l *costBudgetRateLimiter) RateLimitOperation(_ *resolve.Context, cost *resolve.OperationCost) (*resolve.RateLimitDeny, error) {
+	if cost.Estimated > l.budget {
+		return &resolve.RateLimitDeny{Reason: "cost budget exhausted"}, nil
+	}
+	l.budget -= cost.Estimated
+	return nil, nil
+}
+
+func TestExecutionEngine_CostControl(t *testing.T) {
+	// The estimated cost of singleEntityQuery is 3, with a single review assumed
+	// for the list of reviews. The reviews subgraph answers with two, which
+	// makes the actual cost 4.
+	newCostHarness := func(t *testing.T, costControl CostControlConfiguration) *harness {
+		t.Helper()
+
+		h := newHarness(t, func(c *Configuration) { c.SetCostControlConfiguration(costControl) })
+		h.users.answers(meAnswer)
+		h.reviews.answers(`{"data":{"_entities":[{"reviews":[{"body":"A review"},{"body":"Another review"}]}]}}`)
+		return h
+	}
+
+	t.Run("an operation over the maximum estimated cost is rejected before any fetch", func(t *testing.T) {
+		h := newCostHarness(t, CostControlConfiguration{MaxEstimatedCost: 2})
+
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), &graphql.Request{Query: singleEntityQuery}, &writer)
+
+		var tooExpensive *CostEstimatedTooExpensiveError
+		require.ErrorAs(t, err, &tooExpensive)
+		assert.Equal(t, &CostEstimatedTooExpensiveError{EstimatedCost: 3, MaxCost: 2}, tooExpensive)
+
+		var requestErrors graphqlerrors.RequestErrors
+		require.ErrorAs(t, err, &requestErrors)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, errorcodes.CostEstimatedTooExpensive, requestErrors[0].Extensions.Code)
+
+		assert.Empty(t, writer.String())
+		assert.EqualValues(t, 0, h.users.calls())
+		assert.EqualValues(t, 0, h.reviews.calls())
+	})
+
+	t.Run("an operation within the maximum estimated cost is executed", func(t *testing.T) {
+		h := newCostHarness(t, CostControlConfiguration{MaxEstimatedCost: 3})
+
+		out := h.execute(t, singleEntityQuery)
+		assert.Equal(t, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A review"},{"body":"Another review"}]}}}`, out)
+	})
+
+	t.Run("cost extension", func(t *testing.T) {
+		h := newCostHarness(t, CostControlConfiguration{MaxEstimatedCost: 10, IncludeCostInResponseExtension: true})
+
+		out := h.execute(t, singleEntityQuery)
+		assert.Equal(t, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A review"},{"body":"Another review"}]}},"extensions":{"cost":{"estimated":3,"actual":4,"limit":10}}}`, out)
+	})
+
+	t.Run("cost extension without a limit", func(t *testing.T) {
+		h := newCostHarness(t, CostControlConfiguration{IncludeCostInResponseExtension: true})
+
+		out := h.execute(t, singleEntityQuery)
+		assert.Equal(t, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A review"},{"body":"Another review"}]}},"extensions":{"cost":{"estimated":3,"actual":4}}}`, out)
+	})
+
+	t.Run("a cost rate limiter consumes its budget by cost", func(t *testing.T) {
+		h := newCostHarness(t, CostControlConfiguration{})
+		limiter := &costBudgetRateLimiter{budget: 5}
+
+		h.execute(t, singleEntityQuery, withRateLimiter(limiter))
+		assert.Equal(t, 2, limiter.budget)
+		assert.Equal(t, 2, limiter.preFetch)
+
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), &graphql.Request{Query: singleEntityQuery}, &writer, withRateLimiter(limiter), func(execCtx *internalExecutionContext) {
+			execCtx.resolveContext.RateLimitOptions.ErrorExtensionCode = resolve.RateLimitErrorExtensionCode{Enabled: true, Code: "RATE_LIMIT_EXCEEDED"}
+		})
+
+		var rateLimited *OperationRateLimitedError
+		require.ErrorAs(t, err, &rateLimited)
+		assert.Equal(t, "Rate limit exceeded for the operation, Reason: cost budget exhausted.", rateLimited.Error())
+
+		var requestErrors graphqlerrors.RequestErrors
+		require.ErrorAs(t, err, &requestErrors)
+		require.Len(t, requestErrors, 1)
+		assert.Equal(t, "RATE_LIMIT_EXCEEDED", requestErrors[0].Extensions.Code)
+
+		assert.Equal(t, 2, limiter.budget)
+		assert.Equal(t, 2, limiter.preFetch)
+		assert.EqualValues(t, 1, h.users.calls())
+	})
+}
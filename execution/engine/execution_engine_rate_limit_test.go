@@ -0,0 +1,56 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/ratelimit"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func withRateLimitOptions(limiter resolve.RateLimiter, options resolve.RateLimitOptions) ExecutionOptions {
+	return func(execCtx *internalExecutionContext) {
+		options.Enable = true
+		execCtx.resolveContext.RateLimitOptions = options
+		execCtx.resolveContext.SetRateLimiter(limiter)
+	}
+}
+
+func TestExecutionEngine_RateLimit(t *testing.T) {
+	t.Run("fetches take tokens from the buckets of their subgraphs", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+		store := ratelimit.NewMemoryStore()
+		options := resolve.RateLimitOptions{Rate: 2, Period: time.Hour, RateLimitKey: "client", IncludeStatsInResponseExtension: true}
+
+		limiter := ratelimit.New(store, ratelimit.Options{})
+		out := h.execute(t, singleEntityQuery, withRateLimitOptions(limiter, options))
+		assert.Contains(t, out, `"extensions":{"rateLimit":{"requestRate":2,"remaining":1,`)
+
+		h.execute(t, singleEntityQuery, withRateLimitOptions(ratelimit.New(store, ratelimit.Options{}), options))
+		out = h.execute(t, singleEntityQuery, withRateLimitOptions(ratelimit.New(store, ratelimit.Options{}), options))
+		assert.Contains(t, out, `"errors":[{"message":"Rate limit exceeded for Subgraph '0'."}]`)
+		assert.EqualValues(t, 2, h.users.calls())
+	})
+
+	t.Run("operations take their estimated cost from the bucket of the key", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetCostControlConfiguration(CostControlConfiguration{}) })
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+		store := ratelimit.NewMemoryStore()
+		options := resolve.RateLimitOptions{Rate: 5, Period: time.Hour, RateLimitKey: "client"}
+
+		h.execute(t, singleEntityQuery, withRateLimitOptions(ratelimit.New(store, ratelimit.Options{ByCost: true}), options))
+
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), &graphql.Request{Query: singleEntityQuery}, &writer, withRateLimitOptions(ratelimit.New(store, ratelimit.Options{ByCost: true}), options))
+		var rateLimited *OperationRateLimitedError
+		require.ErrorAs(t, err, &rateLimited)
+		assert.EqualValues(t, 1, h.users.calls())
+	})
+}
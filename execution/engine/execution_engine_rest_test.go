@@ -0,0 +1,225 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/rest_datasource"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+const restSchema = `
+	type Query {
+		user(id: ID!): User
+		users(name: String): [User!]!
+	}
+
+	type Mutation {
+		createUser(input: CreateUserInput!): User!
+	}
+
+	input CreateUserInput {
+		name: String!
+	}
+
+	type User {
+		id: ID!
+		name: String!
+		reviews: [Review!]!
+	}
+
+	type Review {
+		body: String!
+		stars: Int!
+	}
+`
+
+// newRESTBackend serves the users and reviews of the REST data sources.
+func newRESTBackend(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	users := map[string]string{
+		"1": `{"id":"1","profile":{"full_name":"Jane"}}`,
+		"2": `{"id":"2","profile":{"full_name":"John"}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/users":
+			body, _ := io.ReadAll(r.Body)
+			_, _ = io.WriteString(w, `{"id":"3","profile":{"full_name":`+strings.TrimSuffix(strings.TrimPrefix(string(body), `{"name":`), "}")+`}}`)
+		case r.URL.Path == "/users":
+			if r.URL.Query().Get("name") == "John" {
+				_, _ = io.WriteString(w, `{"items":[`+users["2"]+`]}`)
+				return
+			}
+			_, _ = io.WriteString(w, `{"items":[`+users["1"]+`,`+users["2"]+`]}`)
+		case r.URL.Path == "/reviews":
+			var items []string
+			for _, userID := range strings.Split(r.URL.Query().Get("user_ids"), ",") {
+				items = append([]string{`{"id":"` + userID + `","items":[{"text":"review of ` + userID + `","rating":4}]}`}, items...)
+			}
+			_, _ = io.WriteString(w, `[`+strings.Join(items, ",")+`]`)
+		case strings.HasPrefix(r.URL.Path, "/reviews/"):
+			userID := strings.TrimPrefix(r.URL.Path, "/reviews/")
+			_, _ = io.WriteString(w, `{"items":[{"text":"review of `+userID+`","rating":5}]}`)
+		case strings.HasPrefix(r.URL.Path, "/users/"):
+			user, ok := users[strings.TrimPrefix(r.URL.Path, "/users/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = io.WriteString(w, user)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newRESTExecutionEngine(t *testing.T, baseURL string, reviews rest_datasource.EntityConfiguration) *ExecutionEngine {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	factory, err := rest_datasource.NewFactory(ctx, httpclient.DefaultNetHttpClient)
+	require.NoError(t, err)
+
+	usersDataSource, err := plan.NewDataSourceConfiguration[rest_datasource.Configuration](
+		"users",
+		factory,
+		&plan.DataSourceMetadata{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"user", "users"}},
+				{TypeName: "Mutation", FieldNames: []string{"createUser"}},
+				{TypeName: "User", FieldNames: []string{"id", "name"}},
+			},
+			FederationMetaData: plan.FederationMetaData{
+				Keys: plan.FederationFieldConfigurations{{TypeName: "User", SelectionSet: "id"}},
+			},
+		},
+		rest_datasource.Configuration{
+			BaseURL: baseURL,
+			RootFields: []rest_datasource.RootFieldConfiguration{
+				{TypeName: "Query", FieldName: "user", Endpoint: rest_datasource.Endpoint{Path: "/users/{{ args.id }}"}},
+				{
+					TypeName:  "Query",
+					FieldName: "users",
+					Endpoint: rest_datasource.Endpoint{
+						Path:         "/users",
+						Query:        []rest_datasource.QueryParameter{{Name: "name", Value: "{{ args.name }}"}},
+						ResponsePath: []string{"items"},
+					},
+				},
+				{TypeName: "Mutation", FieldName: "createUser", Endpoint: rest_datasource.Endpoint{Method: http.MethodPost, Path: "/users", Body: "{{ args.input }}"}},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	reviewsDataSource, err := plan.NewDataSourceConfiguration[rest_datasource.Configuration](
+		"reviews",
+		factory,
+		&plan.DataSourceMetadata{
+			RootNodes: []plan.TypeField{
+				{TypeName: "User", FieldNames: []string{"id", "reviews"}},
+			},
+			ChildNodes: []plan.TypeField{
+				{TypeName: "Review", FieldNames: []string{"body", "stars"}},
+			},
+			FederationMetaData: plan.FederationMetaData{
+				Keys: plan.FederationFieldConfigurations{{TypeName: "User", SelectionSet: "id"}},
+			},
+		},
+		rest_datasource.Configuration{
+			BaseURL:  baseURL,
+			Entities: []rest_datasource.EntityConfiguration{reviews},
+		},
+	)
+	require.NoError(t, err)
+
+	schema, err := graphql.NewSchemaFromString(restSchema)
+	require.NoError(t, err)
+
+	engineConfig := NewConfiguration(schema)
+	engineConfig.SetDataSources([]plan.DataSource{usersDataSource, reviewsDataSource})
+	engineConfig.SetFieldConfigurations(plan.FieldConfigurations{
+		{TypeName: "Query", FieldName: "user", Arguments: plan.ArgumentsConfigurations{{Name: "id", SourceType: plan.FieldArgumentSource}}},
+		{TypeName: "Query", FieldName: "users", Arguments: plan.ArgumentsConfigurations{{Name: "name", SourceType: plan.FieldArgumentSource}}},
+		{TypeName: "Mutation", FieldName: "createUser", Arguments: plan.ArgumentsConfigurations{{Name: "input", SourceType: plan.FieldArgumentSource}}},
+		{TypeName: "User", FieldName: "name", Path: []string{"profile", "full_name"}},
+		{TypeName: "User", FieldName: "reviews", Path: []string{"items"}},
+		{TypeName: "Review", FieldName: "body", Path: []string{"text"}},
+		{TypeName: "Review", FieldName: "stars", Path: []string{"rating"}},
+	})
+
+	engine, err := NewExecutionEngine(ctx, abstractlogger.Noop{}, engineConfig, resolve.ResolverOptions{
+		MaxConcurrency: 1024,
+	})
+	require.NoError(t, err)
+	return engine
+}
+
+func TestRESTDataSourceExecution(t *testing.T) {
+	backend := newRESTBackend(t)
+	engine := newRESTExecutionEngine(t, backend.URL, rest_datasource.EntityConfiguration{
+		TypeName: "User",
+		Endpoint: rest_datasource.Endpoint{Path: "/reviews/{{ args.id }}"},
+	})
+
+	execute := func(t *testing.T, query, variables string) string {
+		t.Helper()
+		request := &graphql.Request{Query: query, Variables: []byte(variables)}
+		writer := graphql.NewEngineResultWriter()
+		require.NoError(t, engine.Execute(t.Context(), request, &writer))
+		return writer.String()
+	}
+
+	t.Run("root fields with aliases are called with their arguments", func(t *testing.T) {
+		response := execute(t, `query { jane: user(id: "1") { id name } unknown: user(id: "9") { id } johns: users(name: "John") { name } }`, "")
+		assert.Equal(t, `{"data":{"jane":{"id":"1","name":"Jane"},"unknown":null,"johns":[{"name":"John"}]}}`, response)
+	})
+
+	t.Run("entity fields are fetched from the entity endpoint of another data source", func(t *testing.T) {
+		response := execute(t, `query { users { id reviews { body stars } } }`, "")
+		assert.Equal(t, `{"data":{"users":[{"id":"1","reviews":[{"body":"review of 1","stars":5}]},{"id":"2","reviews":[{"body":"review of 2","stars":5}]}]}}`, response)
+
+		response = execute(t, `query User($id: ID!) { user(id: $id) { name reviews { body } } }`, `{"id":"2"}`)
+		assert.Equal(t, `{"data":{"user":{"name":"John","reviews":[{"body":"review of 2"}]}}}`, response)
+	})
+
+	t.Run("an input object is sent as body", func(t *testing.T) {
+		response := execute(t, `mutation { createUser(input: {name: "Jim"}) { id name } }`, "")
+		assert.Equal(t, `{"data":{"createUser":{"id":"3","name":"Jim"}}}`, response)
+	})
+
+	t.Run("entities are fetched from a batch endpoint", func(t *testing.T) {
+		engine := newRESTExecutionEngine(t, backend.URL, rest_datasource.EntityConfiguration{
+			TypeName: "User",
+			BatchEndpoint: &rest_datasource.BatchEndpoint{
+				Endpoint: rest_datasource.Endpoint{
+					Path:  "/reviews",
+					Query: []rest_datasource.QueryParameter{{Name: "user_ids", Value: "{{ args.id }}"}},
+				},
+				Separator: ",",
+			},
+		})
+		request := &graphql.Request{Query: `query { users { id reviews { body stars } } }`}
+		writer := graphql.NewEngineResultWriter()
+		require.NoError(t, engine.Execute(t.Context(), request, &writer))
+		assert.Equal(t, `{"data":{"users":[{"id":"1","reviews":[{"body":"review of 1","stars":4}]},{"id":"2","reviews":[{"body":"review of 2","stars":4}]}]}}`, writer.String())
+	})
+}
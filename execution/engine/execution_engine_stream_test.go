@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+)
+
+func TestExecutionEngine_Execute_Stream(t *testing.T) {
+	const query = `query Top($n: Int!, $on: Boolean!) { topProducts @stream(initialCount: $n, if: $on) { upc } }`
+
+	// withStream declares @stream in the schema, which the checked in
+	// federation configuration does not.
+	withStream := func(c *Configuration) {
+		schema, err := graphql.NewSchemaFromString(string(c.schema.RawSchema()) + `
+			directive @stream(label: String, if: Boolean! = true, initialCount: Int = 0) on FIELD`)
+		require.NoError(t, err)
+		c.schema = schema
+	}
+
+	// execute returns the payloads the operation was flushed in.
+	execute := func(t *testing.T, h *harness, query, variables string) []string {
+		t.Helper()
+		var payloads []string
+		writer := graphql.NewEngineResultWriter()
+		writer.SetFlushCallback(func(data []byte) {
+			payloads = append(payloads, string(data))
+		})
+		request := &graphql.Request{OperationName: "Top", Query: query, Variables: json.RawMessage(variables)}
+		require.NoError(t, h.engine.Execute(t.Context(), request, &writer))
+		if len(payloads) == 0 {
+			payloads = append(payloads, writer.String())
+		}
+		return payloads
+	}
+
+	t.Run("the items after the initial count are sent one payload each", func(t *testing.T) {
+		h := newHarness(t, withStream)
+		h.products.answers(productsAnswer("1", "2", "3"))
+
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1"}]},"pending":[{"id":"1","path":["topProducts"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"2"}],"id":"1"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"3"}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, execute(t, h, query, `{"n":1,"on":true}`))
+	})
+
+	t.Run("every value of the variables of the arguments is planned for", func(t *testing.T) {
+		h := newHarness(t, withStream)
+		h.products.answers(productsAnswer("1", "2", "3"))
+
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1"}]},"pending":[{"id":"1","path":["topProducts"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"2"}],"id":"1"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"3"}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, execute(t, h, query, `{"n":1,"on":true}`))
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1"},{"upc":"2"}]},"pending":[{"id":"1","path":["topProducts"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"3"}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, execute(t, h, query, `{"n":2,"on":true}`))
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1"},{"upc":"2"},{"upc":"3"}]}}`,
+		}, execute(t, h, query, `{"n":1,"on":false}`))
+		assert.Equal(t, int64(3), h.engine.ExecutionPlanCacheStats().Entries)
+	})
+	t.Run("the entities of a stream are fetched before the initial payload", func(t *testing.T) {
+		h := newHarness(t, withStream)
+		h.products.answers(productsAnswer("1", "2"))
+		h.reviews.answers(reviewsAnswer("r1", "r2"))
+
+		// The reviews of the streamed product are part of the one entity fetch of
+		// the initial fetch tree, they do not follow with the product.
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1","reviews":[{"body":"r1"}]}]},"pending":[{"id":"1","path":["topProducts"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"upc":"2","reviews":[{"body":"r2"}]}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, execute(t, h, `query Top { topProducts @stream(initialCount: 1) { upc reviews { body } } }`, `{}`))
+		assert.Equal(t, int64(1), h.reviews.calls())
+	})
+
+	t.Run("a list nested in a list is delivered whole", func(t *testing.T) {
+		h := newHarness(t, withStream)
+		h.products.answers(productsAnswer("1"))
+		h.reviews.answers(reviewsAnswer("r1"))
+
+		assert.Equal(t, []string{
+			`{"data":{"topProducts":[{"upc":"1","reviews":[{"body":"r1"}]}]}}`,
+		}, execute(t, h, `query Top { topProducts { upc reviews @stream(initialCount: 0) { body } } }`, `{}`))
+	})
+
+	t.Run("a list in a deferred fragment is delivered whole", func(t *testing.T) {
+		h := newHarness(t, withStream)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("r1"))
+
+		assert.Equal(t, []string{
+			`{"data":{"me":{"id":"1234"}},"pending":[{"id":"1","path":["me"]}],"hasNext":true}`,
+			`{"incremental":[{"data":{"reviews":[{"body":"r1"}]},"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, execute(t, h, `query Top { me { id ... @defer { reviews @stream(initialCount: 0) { body } } } }`, `{}`))
+	})
+}
@@ -0,0 +1,164 @@
+package engine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/telemetry"
+)
+
+func TestExecutionEngine_Telemetry(t *testing.T) {
+	newTelemetryHarness := func(t *testing.T) (*harness, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+		t.Helper()
+
+		exporter := tracetest.NewInMemoryExporter()
+		reader := sdkmetric.NewManualReader()
+		tel, err := telemetry.New(telemetry.Options{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+			MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		})
+		require.NoError(t, err)
+
+		h := newHarness(t, func(c *Configuration) { c.SetTelemetry(tel) })
+		return h, exporter, reader
+	}
+
+	spanNames := func(spans tracetest.SpanStubs) []string {
+		names := make([]string, 0, len(spans))
+		for _, span := range spans {
+			names = append(names, span.Name)
+		}
+		return names
+	}
+
+	spanAttribute := func(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+		for _, kv := range span.Attributes {
+			if kv.Key == key {
+				return kv.Value
+			}
+		}
+		return attribute.Value{}
+	}
+
+	// fetchSpans returns the fetch spans by subgraph, which the federation
+	// configuration names by their id: 0 is the users and 2 the reviews subgraph.
+	fetchSpans := func(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+		fetches := make(map[string]tracetest.SpanStub)
+		for _, span := range spans {
+			if span.Name == telemetry.FetchSpanName {
+				fetches[spanAttribute(span, telemetry.SubgraphNameKey).AsString()] = span
+			}
+		}
+		return fetches
+	}
+
+	histogram := func(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Histogram[float64] {
+		t.Helper()
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		for _, scope := range rm.ScopeMetrics {
+			for _, m := range scope.Metrics {
+				if m.Name == name {
+					return m.Data.(metricdata.Histogram[float64])
+				}
+			}
+		}
+		require.Failf(t, "histogram not recorded", "name: %s", name)
+		return metricdata.Histogram[float64]{}
+	}
+
+	t.Run("spans for the phases and fetches of an operation", func(t *testing.T) {
+		h, exporter, reader := newTelemetryHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+
+		out := h.execute(t, singleEntityQuery)
+		assert.Equal(t, `{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A review"}]}}}`, out)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, []string{
+			"graphql.parse",
+			"graphql.normalize",
+			"graphql.validate",
+			"graphql.normalize",
+			"graphql.plan",
+			telemetry.FetchSpanName,
+			telemetry.FetchSpanName,
+			telemetry.OperationSpanName,
+		}, spanNames(spans))
+
+		operation := spans[len(spans)-1]
+		assert.Equal(t, "query", spanAttribute(operation, telemetry.OperationTypeKey).AsString())
+		for _, span := range spans[:len(spans)-1] {
+			assert.Equal(t, operation.SpanContext.TraceID(), span.SpanContext.TraceID())
+			assert.Equal(t, operation.SpanContext.SpanID(), span.Parent.SpanID(), span.Name)
+		}
+
+		fetches := fetchSpans(spans)
+		require.Len(t, fetches, 2)
+		for name, stub := range map[string]*stub{"0": h.users, "2": h.reviews} {
+			fetch, ok := fetches[name]
+			require.True(t, ok, name)
+			assert.Equal(t, int64(200), spanAttribute(fetch, telemetry.StatusCodeKey).AsInt64())
+			assert.False(t, spanAttribute(fetch, telemetry.CacheHitKey).AsBool())
+
+			spanContext := fetch.SpanContext
+			assert.Equal(t, "00-"+spanContext.TraceID().String()+"-"+spanContext.SpanID().String()+"-01", stub.lastHeader().Get("traceparent"), name)
+		}
+		assert.Equal(t, "single", spanAttribute(fetches["0"], telemetry.FetchKindKey).AsString())
+		assert.Equal(t, "entity", spanAttribute(fetches["2"], telemetry.FetchKindKey).AsString())
+
+		operations := histogram(t, reader, telemetry.OperationDurationName)
+		require.Len(t, operations.DataPoints, 1)
+		assert.Equal(t, uint64(1), operations.DataPoints[0].Count)
+
+		fetchDurations := histogram(t, reader, telemetry.FetchDurationName)
+		assert.Len(t, fetchDurations.DataPoints, 2)
+	})
+
+	t.Run("a failed validation ends the operation with an error", func(t *testing.T) {
+		h, exporter, reader := newTelemetryHarness(t)
+
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), &graphql.Request{Query: `{ me { unknown } }`}, &writer)
+		require.Error(t, err)
+
+		spans := exporter.GetSpans()
+		assert.Equal(t, []string{"graphql.parse", "graphql.normalize", "graphql.validate", telemetry.OperationSpanName}, spanNames(spans))
+		assert.Equal(t, codes.Error, spans[2].Status.Code)
+		assert.Equal(t, codes.Error, spans[3].Status.Code)
+		assert.Equal(t, int64(0), h.users.calls())
+
+		operations := histogram(t, reader, telemetry.OperationDurationName)
+		require.Len(t, operations.DataPoints, 1)
+		failed, _ := operations.DataPoints[0].Attributes.Value(telemetry.ErrorKey)
+		assert.True(t, failed.AsBool())
+	})
+
+	t.Run("a fetch served from the response cache is a cache hit", func(t *testing.T) {
+		h, exporter, _ := newTelemetryHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+		cache := newMapCache()
+
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		exporter.Reset()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.EqualValues(t, 1, h.reviews.calls())
+
+		fetches := fetchSpans(exporter.GetSpans())
+		require.Len(t, fetches, 2)
+		assert.False(t, spanAttribute(fetches["0"], telemetry.CacheHitKey).AsBool())
+		assert.True(t, spanAttribute(fetches["2"], telemetry.CacheHitKey).AsBool())
+	})
+}
@@ -0,0 +1,350 @@
+package engine
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+)
+
+const (
+	// DefaultExecutionPlanCacheMaxBytes is the estimated memory the execution
+	// plan cache holds unless SetExecutionPlanCacheMaxBytes sets another bound.
+	DefaultExecutionPlanCacheMaxBytes = 64 << 20
+
+	// planCacheEntryOverhead approximates what a cached plan costs regardless
+	// of its operation: the entry, the plan and its response.
+	planCacheEntryOverhead = 1 << 10
+	// planBytesPerFetch approximates a fetch of the plan without its input,
+	// such as its configuration, dependencies and info.
+	planBytesPerFetch = 1 << 10
+	// planBytesPerNode approximates a node of the response plan, such as a
+	// field with its value and info.
+	planBytesPerNode = 256
+	// planBytesPerSegment approximates a segment of an input template without
+	// its data, such as the path and renderer of a variable.
+	planBytesPerSegment = 64
+)
+
+// ExecutionPlanCacheStats is a snapshot of the counters of the execution plan
+// cache.
+type ExecutionPlanCacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Entries   int64
+	// Bytes is the estimated memory held by the cached plans.
+	Bytes int64
+}
+
+// CachedOperation is an operation the execution plan cache holds a plan for,
+// as exported by ExportExecutionPlanCache. Query is the normalized operation,
+// with its argument values extracted into variables. CachedOperation marshals
+// to JSON, so the hot set of an engine can be stored and imported into the
+// engine of the next deploy with ImportExecutionPlanCache.
+type CachedOperation struct {
+	OperationName string `json:"operationName,omitempty"`
+	Query         string `json:"query"`
+}
+
+// executionPlanCache is an LRU cache of plans keyed by the hash of the printed
+// operation. It is bounded by the estimated memory of the plans rather than by
+// their number, and is safe for concurrent use.
+type executionPlanCache struct {
+	mu       sync.Mutex
+	entries  map[uint64]*list.Element
+	order    *list.List // front is most recently used
+	bytes    int64
+	maxBytes int64
+
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+}
+
+type executionPlanCacheEntry struct {
+	key       uint64
+	operation CachedOperation
+	plan      plan.Plan
+	size      int64
+}
+
+func newExecutionPlanCache(maxBytes int64) *executionPlanCache {
+	if maxBytes <= 0 {
+		maxBytes = DefaultExecutionPlanCacheMaxBytes
+	}
+	return &executionPlanCache{
+		entries:  make(map[uint64]*list.Element),
+		order:    list.New(),
+		maxBytes: maxBytes,
+	}
+}
+
+// Get returns the plan cached under the key and marks it as recently used.
+func (c *executionPlanCache) Get(key uint64) (plan.Plan, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	c.order.MoveToFront(element)
+	return element.Value.(*executionPlanCacheEntry).plan, true
+}
+
+// Add caches the plan of the operation under the key, evicting the least
+// recently used plans until the cache is within its bound. A plan estimated
+// to be larger than the whole cache is not cached.
+func (c *executionPlanCache) Add(key uint64, operation CachedOperation, p plan.Plan) {
+	size := estimatedPlanSize(operation, p)
+	if size > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	for c.bytes+size > c.maxBytes {
+		c.remove(c.order.Back())
+		c.evictions.Add(1)
+	}
+	c.entries[key] = c.order.PushFront(&executionPlanCacheEntry{key: key, operation: operation, plan: p, size: size})
+	c.bytes += size
+}
+
+func (c *executionPlanCache) remove(element *list.Element) {
+	entry := element.Value.(*executionPlanCacheEntry)
+	c.order.Remove(element)
+	delete(c.entries, entry.key)
+	c.bytes -= entry.size
+}
+
+// GetOldest returns the least recently used plan.
+func (c *executionPlanCache) GetOldest() (key uint64, p plan.Plan, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element := c.order.Back()
+	if element == nil {
+		return 0, nil, false
+	}
+	entry := element.Value.(*executionPlanCacheEntry)
+	return entry.key, entry.plan, true
+}
+
+func (c *executionPlanCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge removes every plan. The counters are kept.
+func (c *executionPlanCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[uint64]*list.Element)
+	c.order.Init()
+	c.bytes = 0
+}
+
+func (c *executionPlanCache) Stats() ExecutionPlanCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ExecutionPlanCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   int64(c.order.Len()),
+		Bytes:     c.bytes,
+	}
+}
+
+// operations returns the operations of the cached plans, the most recently
+// used first.
+func (c *executionPlanCache) operations() []CachedOperation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operations := make([]CachedOperation, 0, c.order.Len())
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		operations = append(operations, element.Value.(*executionPlanCacheEntry).operation)
+	}
+	return operations
+}
+
+// estimatedPlanSize estimates the memory a cached plan holds from what it is
+// made of: its fetches with their input templates, and the nodes of its
+// response plan, along with the operation it is cached for.
+func estimatedPlanSize(operation CachedOperation, p plan.Plan) int64 {
+	size := int64(planCacheEntryOverhead + len(operation.OperationName) + len(operation.Query))
+	switch p := p.(type) {
+	case *plan.SynchronousResponsePlan:
+		size += estimatedResponseSize(p.Response)
+	case *plan.DeferResponsePlan:
+		size += estimatedResponseSize(p.Response.Response)
+		for _, deferred := range p.Response.Defers {
+			size += estimatedFetchTreeSize(deferred.Fetches)
+		}
+	case *plan.SubscriptionResponsePlan:
+		size += planBytesPerFetch + estimatedInputTemplateSize(&p.Response.Trigger.InputTemplate)
+		size += estimatedResponseSize(p.Response.Response)
+	}
+	return size
+}
+
+func estimatedResponseSize(response *resolve.GraphQLResponse) int64 {
+	if response == nil {
+		return 0
+	}
+	size := estimatedFetchTreeSize(response.Fetches)
+	if response.Data != nil {
+		size += estimatedNodeSize(response.Data)
+	}
+	return size
+}
+
+func estimatedFetchTreeSize(node *resolve.FetchTreeNode) int64 {
+	if node == nil {
+		return 0
+	}
+	size := estimatedFetchTreeSize(node.Trigger)
+	if node.Item != nil {
+		size += estimatedFetchSize(node.Item.Fetch)
+	}
+	for _, child := range node.ChildNodes {
+		size += estimatedFetchTreeSize(child)
+	}
+	return size
+}
+
+func estimatedFetchSize(fetch resolve.Fetch) int64 {
+	size := int64(planBytesPerFetch)
+	switch f := fetch.(type) {
+	case *resolve.SingleFetch:
+		size += estimatedInputTemplateSize(&f.InputTemplate)
+	case *resolve.EntityFetch:
+		size += estimatedInputTemplateSize(&f.Input.Header)
+		size += estimatedInputTemplateSize(&f.Input.Item)
+		size += estimatedInputTemplateSize(&f.Input.Footer)
+	case *resolve.BatchEntityFetch:
+		size += estimatedInputTemplateSize(&f.Input.Header)
+		for i := range f.Input.Items {
+			size += estimatedInputTemplateSize(&f.Input.Items[i])
+		}
+		size += estimatedInputTemplateSize(&f.Input.Separator)
+		size += estimatedInputTemplateSize(&f.Input.Footer)
+	case *resolve.MultiEntityFetch:
+		size += estimatedInputTemplateSize(&f.Input.Header)
+		size += estimatedInputTemplateSize(&f.Input.Footer)
+		size += int64(len(f.Input.Entries)) * planBytesPerFetch
+	}
+	return size
+}
+
+func estimatedInputTemplateSize(template *resolve.InputTemplate) int64 {
+	return estimatedSegmentsSize(template.Segments)
+}
+
+func estimatedSegmentsSize(segments []resolve.TemplateSegment) int64 {
+	var size int64
+	for i := range segments {
+		size += planBytesPerSegment + int64(len(segments[i].Data))
+		size += estimatedSegmentsSize(segments[i].Segments)
+	}
+	return size
+}
+
+func estimatedNodeSize(node resolve.Node) int64 {
+	size := int64(planBytesPerNode)
+	switch n := node.(type) {
+	case *resolve.Object:
+		for _, field := range n.Fields {
+			size += estimatedNodeSize(field.Value)
+		}
+	case *resolve.Array:
+		if n.Item != nil {
+			size += estimatedNodeSize(n.Item)
+		}
+	}
+	return size
+}
+
+// ExecutionPlanCacheStats returns the counters of the execution plan cache.
+func (e *ExecutionEngine) ExecutionPlanCacheStats() ExecutionPlanCacheStats {
+	return e.executionPlanCache.Stats()
+}
+
+// WarmUpExecutionPlanCache plans the operations the way Execute would and
+// caches their plans, without executing them, such as the persisted
+// operations of a PersistedOperationStore at startup. Operations that fail to
+// plan are skipped and their errors returned joined once all operations were
+// planned.
+func (e *ExecutionEngine) WarmUpExecutionPlanCache(ctx context.Context, operations ...*graphql.Request) error {
+	var errs []error
+	for _, operation := range operations {
+		if err := e.warmUpExecutionPlan(ctx, operation); err != nil {
+			errs = append(errs, fmt.Errorf("operation %q: %w", operation.OperationName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *ExecutionEngine) warmUpExecutionPlan(ctx context.Context, operation *graphql.Request) error {
+	if _, err := e.prepareOperation(ctx, operation); err != nil {
+		return err
+	}
+	return e.planOperation(operation)
+}
+
+// ExportExecutionPlanCache returns the operations the execution plan cache
+// holds plans for, the most recently used first.
+func (e *ExecutionEngine) ExportExecutionPlanCache() []CachedOperation {
+	return e.executionPlanCache.operations()
+}
+
+// ImportExecutionPlanCache plans and caches the operations exported by
+// ExportExecutionPlanCache, which may have been an engine of a previous deploy.
+// The operations are normalized already, they are only validated against the
+// schema of this engine. Operations that are no longer valid are skipped and
+// their errors returned joined once all operations were imported.
+func (e *ExecutionEngine) ImportExecutionPlanCache(operations []CachedOperation) error {
+	var errs []error
+	// The least recently used operation is planned first, so that the most
+	// recently used ones are kept when not all of them fit.
+	for i := len(operations) - 1; i >= 0; i-- {
+		if err := e.importExecutionPlan(operations[i]); err != nil {
+			errs = append(errs, fmt.Errorf("operation %q: %w", operations[i].OperationName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (e *ExecutionEngine) importExecutionPlan(cached CachedOperation) error {
+	operation := &graphql.Request{OperationName: cached.OperationName, Query: cached.Query}
+	result, err := operation.ValidateForSchema(e.config.schema, e.validationOptions...)
+	if err != nil {
+		return err
+	} else if !result.Valid {
+		return result.Errors
+	}
+	return e.planOperation(operation)
+}
+
+// planOperation plans the prepared operation into the execution plan cache.
+func (e *ExecutionEngine) planOperation(operation *graphql.Request) error {
+	var report operationreport.Report
+	e.getCachedPlan(newInternalExecutionContext(e.postProcessorOptions...), operation.Document(), e.config.schema.Document(), operation.OperationName, &report)
+	if report.HasErrors() {
+		return report
+	}
+	return nil
+}
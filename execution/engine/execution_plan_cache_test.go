@@ -0,0 +1,173 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+)
+
+func TestExecutionEngine_ExecutionPlanCache(t *testing.T) {
+	const (
+		meQuery      = `query Me { me { id username } }`
+		meResponse   = `{"data":{"me":{"id":"1234","username":"Me"}}}`
+		meIDQuery    = `query MeID { me { id } }`
+		meIDResponse = `{"data":{"me":{"id":"1234"}}}`
+	)
+
+	execute := func(t *testing.T, h *harness, operationName, query string) string {
+		t.Helper()
+		writer := graphql.NewEngineResultWriter()
+		require.NoError(t, h.engine.Execute(t.Context(), &graphql.Request{OperationName: operationName, Query: query}, &writer))
+		return writer.String()
+	}
+
+	t.Run("hits and misses are counted", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		for range 3 {
+			assert.Equal(t, meResponse, execute(t, h, "Me", meQuery))
+		}
+
+		stats := h.engine.ExecutionPlanCacheStats()
+		assert.Equal(t, int64(2), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+		assert.Equal(t, int64(0), stats.Evictions)
+		assert.Equal(t, int64(1), stats.Entries)
+		assert.Positive(t, stats.Bytes)
+	})
+
+	planSize := func(t *testing.T, operationName, query string) int64 {
+		t.Helper()
+		h := newHarness(t)
+		require.NoError(t, h.engine.WarmUpExecutionPlanCache(t.Context(), &graphql.Request{OperationName: operationName, Query: query}))
+		return h.engine.ExecutionPlanCacheStats().Bytes
+	}
+
+	t.Run("the least recently used plan is evicted once the cache is full", func(t *testing.T) {
+		meSize := planSize(t, "Me", meQuery)
+		h := newHarness(t, func(c *Configuration) {
+			c.SetExecutionPlanCacheMaxBytes(meSize + 1)
+		})
+		h.users.answers(meAnswer)
+
+		assert.Equal(t, meResponse, execute(t, h, "Me", meQuery))
+		assert.Equal(t, meIDResponse, execute(t, h, "MeID", meIDQuery))
+
+		stats := h.engine.ExecutionPlanCacheStats()
+		assert.Equal(t, int64(1), stats.Evictions)
+		assert.Equal(t, int64(1), stats.Entries)
+		assert.Equal(t, []CachedOperation{{OperationName: "MeID", Query: "query MeID {me {id}}"}}, h.engine.ExportExecutionPlanCache())
+	})
+
+	t.Run("a plan is charged by what it is made of rather than by its operation", func(t *testing.T) {
+		fields := newHarness(t)
+		require.NoError(t, fields.engine.WarmUpExecutionPlanCache(t.Context(), &graphql.Request{OperationName: "A", Query: `query A { me { i: id username } }`}))
+		reviews := newHarness(t)
+		require.NoError(t, reviews.engine.WarmUpExecutionPlanCache(t.Context(), &graphql.Request{OperationName: "B", Query: `query B { me { reviews { body } } }`}))
+
+		fieldsOperation, reviewsOperation := fields.engine.ExportExecutionPlanCache()[0], reviews.engine.ExportExecutionPlanCache()[0]
+		require.Len(t, fieldsOperation.Query, len(reviewsOperation.Query))
+		assert.Less(t, fields.engine.ExecutionPlanCacheStats().Bytes, reviews.engine.ExecutionPlanCacheStats().Bytes, "the reviews are fetched by a second fetch")
+	})
+
+	t.Run("a warmed up operation is served from the cache", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		require.NoError(t, h.engine.WarmUpExecutionPlanCache(t.Context(), &graphql.Request{OperationName: "Me", Query: meQuery}))
+		assert.Equal(t, int64(0), h.users.calls(), "warming up does not execute the operation")
+
+		assert.Equal(t, meResponse, execute(t, h, "Me", meQuery))
+		stats := h.engine.ExecutionPlanCacheStats()
+		assert.Equal(t, int64(1), stats.Hits)
+		assert.Equal(t, int64(1), stats.Misses)
+	})
+
+	t.Run("warming up persisted operations", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery))
+		})
+		h.users.answers(meAnswer)
+
+		require.NoError(t, h.engine.WarmUpExecutionPlanCache(t.Context(), persistedOperationRequest(t, meQuery, "")))
+
+		writer := graphql.NewEngineResultWriter()
+		require.NoError(t, h.engine.Execute(t.Context(), persistedOperationRequest(t, meQuery, ""), &writer))
+		assert.Equal(t, meResponse, writer.String())
+		assert.Equal(t, int64(1), h.engine.ExecutionPlanCacheStats().Hits)
+	})
+
+	t.Run("warming up reports the operations that fail and plans the others", func(t *testing.T) {
+		h := newHarness(t)
+
+		err := h.engine.WarmUpExecutionPlanCache(t.Context(),
+			&graphql.Request{OperationName: "Unknown", Query: `query Unknown { me { unknown } }`},
+			&graphql.Request{OperationName: "Me", Query: meQuery},
+		)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `operation "Unknown"`)
+		assert.Equal(t, int64(1), h.engine.ExecutionPlanCacheStats().Entries)
+	})
+
+	t.Run("the exported hot set is imported into another engine", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		assert.Equal(t, meIDResponse, execute(t, h, "MeID", meIDQuery))
+		assert.Equal(t, meResponse, execute(t, h, "Me", meQuery))
+
+		exported, err := json.Marshal(h.engine.ExportExecutionPlanCache())
+		require.NoError(t, err)
+		assert.JSONEq(t, `[{"operationName":"Me","query":"query Me {me {id username}}"},{"operationName":"MeID","query":"query MeID {me {id}}"}]`, string(exported))
+
+		next := newHarness(t)
+		next.users.answers(meAnswer)
+		var operations []CachedOperation
+		require.NoError(t, json.Unmarshal(exported, &operations))
+		require.NoError(t, next.engine.ImportExecutionPlanCache(operations))
+		assert.Equal(t, operations, next.engine.ExportExecutionPlanCache(), "the import keeps the order of use")
+
+		assert.Equal(t, meResponse, execute(t, next, "Me", meQuery))
+		assert.Equal(t, meIDResponse, execute(t, next, "MeID", meIDQuery))
+		stats := next.engine.ExecutionPlanCacheStats()
+		assert.Equal(t, int64(2), stats.Hits)
+		assert.Equal(t, int64(2), stats.Misses, "the misses of the import")
+	})
+
+	t.Run("an operation with extracted arguments is imported", func(t *testing.T) {
+		h := newHarness(t)
+		h.products.answers(`{"data":{"topProducts":[]}}`)
+
+		const query = `query Top { topProducts(first: 2) { upc } }`
+		writer := graphql.NewEngineResultWriter()
+		require.NoError(t, h.engine.Execute(t.Context(), &graphql.Request{OperationName: "Top", Query: query}, &writer))
+		exported := h.engine.ExportExecutionPlanCache()
+		assert.Equal(t, []CachedOperation{{OperationName: "Top", Query: "query Top($a: Int){topProducts(first: $a){upc}}"}}, exported)
+
+		next := newHarness(t)
+		next.products.answers(`{"data":{"topProducts":[]}}`)
+		require.NoError(t, next.engine.ImportExecutionPlanCache(exported))
+
+		writer = graphql.NewEngineResultWriter()
+		require.NoError(t, next.engine.Execute(t.Context(), &graphql.Request{OperationName: "Top", Query: `query Top { topProducts(first: 3) { upc } }`}, &writer))
+		assert.Equal(t, `{"data":{"topProducts":[]}}`, writer.String())
+		assert.Equal(t, int64(1), next.engine.ExecutionPlanCacheStats().Hits)
+	})
+
+	t.Run("operations no longer valid for the schema are not imported", func(t *testing.T) {
+		h := newHarness(t)
+
+		err := h.engine.ImportExecutionPlanCache([]CachedOperation{
+			{OperationName: "Me", Query: "query Me {me {id username}}"},
+			{OperationName: "Removed", Query: "query Removed {me {removed}}"},
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `operation "Removed"`)
+		assert.Equal(t, []CachedOperation{{OperationName: "Me", Query: "query Me {me {id username}}"}}, h.engine.ExportExecutionPlanCache())
+	})
+}
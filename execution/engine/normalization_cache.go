@@ -0,0 +1,375 @@
+package engine
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/buger/jsonparser"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/tidwall/sjson"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astnormalization"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/lexer/literal"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
+)
+
+// The normalization cache holds operations the way the engine left them after
+// normalizing and validating them, extracting their argument values into
+// variables and naming these canonically, so that a repeated operation is
+// neither parsed, normalized nor validated again. Only the variables of a
+// request are prepared for the cached operation.
+//
+// Normalization depends on the values of the variables @skip, @include,
+// @defer and @stream take, so the cache is looked up in two steps: the query
+// of an operation keys its operationShape, which names these variables, and
+// the query along with their values keys the normalizedOperation.
+//
+// Persisted operations are cached the same way, in a cache of their own that
+// keys their query by its hash.
+
+// DefaultNormalizationCacheSize is the number of entries the normalization
+// cache holds unless SetNormalizationCacheSize sets another bound. A query
+// takes an entry for its shape and one per normalized operation.
+const DefaultNormalizationCacheSize = 1024
+
+// operationShape is what the normalization cache needs to know about the
+// query of an operation before it is normalized.
+type operationShape struct {
+	// directiveVariables are the variables of the directive arguments
+	// normalization inlines.
+	directiveVariables []string
+	// variables are the variables the operation declares.
+	variables []string
+}
+
+// normalizedOperation is a normalized and validated operation, or the errors
+// it failed normalization or validation with.
+type normalizedOperation struct {
+	// document is copied for every request, the planner modifies it.
+	document *ast.Document
+	// remapVariables maps the canonical names of the variables of the document
+	// to their names in the variables of a request.
+	remapVariables map[string]string
+	// canonicalVariables are the keys of remapVariables in order.
+	canonicalVariables []string
+	// unusedVariables are the variables normalization removed from the
+	// operation as unused, they are removed from the variables of a request too.
+	unusedVariables []string
+	// defaultValues are the default values of the variables, which a request
+	// that has no value for one of them takes.
+	defaultValues []variableValue
+	// extractedVariables are the argument values normalization extracted into
+	// variables.
+	extractedVariables []variableValue
+	errors             graphqlerrors.Errors
+}
+
+type variableValue struct {
+	name  string
+	value []byte
+}
+
+// normalizationCacheLookup is a lookup that missed the normalization cache,
+// it caches the operation once it is normalized and validated.
+type normalizationCacheLookup struct {
+	cache      *lru.Cache
+	queryKey   uint64
+	shape      *operationShape
+	key        uint64
+	normalized *normalizedOperation
+}
+
+// loadNormalizedOperation looks the operation up in the normalization cache.
+// On a hit it returns the normalized operation to load the request with, or
+// the errors the operation failed with. On a miss it returns the lookup to
+// cache the operation with.
+func (e *ExecutionEngine) loadNormalizedOperation(operation *graphql.Request) (lookup *normalizationCacheLookup, normalized *normalizedOperation, err error) {
+	queryKey := normalizationQueryKey(e.config.schema.Hash(), operation.OperationName, operation.Query)
+	return lookupNormalizedOperation(e.normalizationCache, queryKey, operation)
+}
+
+// lookupNormalizedOperation looks the operation up in the cache by the key of
+// its query, see loadNormalizedOperation.
+func lookupNormalizedOperation(cache *lru.Cache, queryKey uint64, operation *graphql.Request) (lookup *normalizationCacheLookup, normalized *normalizedOperation, err error) {
+	lookup = &normalizationCacheLookup{cache: cache, queryKey: queryKey}
+	entry, ok := cache.Get(lookup.queryKey)
+	if !ok {
+		return lookup, nil, nil
+	}
+	lookup.shape = entry.(*operationShape)
+	lookup.key = normalizationKey(lookup.queryKey, lookup.shape, operation.Variables)
+	entry, ok = cache.Get(lookup.key)
+	if !ok {
+		return lookup, nil, nil
+	}
+
+	normalized = entry.(*normalizedOperation)
+	if normalized.errors != nil {
+		return nil, nil, normalized.errors
+	}
+	return nil, normalized, nil
+}
+
+var variablesCoercionPool = sync.Pool{
+	New: func() any {
+		return astnormalization.NewVariablesCoercion()
+	},
+}
+
+// load sets a copy of the normalized document on the request and prepares
+// the variables of the request for it the way normalization does.
+func (n *normalizedOperation) load(operation *graphql.Request, schema *graphql.Schema) (remapVariables map[string]string, err error) {
+	variables := []byte(operation.Variables)
+	for _, name := range n.unusedVariables {
+		variables, err = sjson.DeleteBytes(variables, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, variable := range n.defaultValues {
+		if _, _, _, err := jsonparser.Get(variables, variable.name); err == nil {
+			continue
+		}
+		variables, err = sjson.SetRawBytes(variables, variable.name, variable.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, variable := range n.extractedVariables {
+		variables, err = sjson.SetRawBytes(variables, variable.name, variable.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// List values are coerced and input field defaults injected by the
+	// variable definitions of the document, which are named canonically.
+	document := n.document.CloneOperation()
+	document.Input.Variables = []byte("{}")
+	for _, name := range n.canonicalVariables {
+		value, ok := variableJSON(variables, n.remapVariables[name])
+		if !ok {
+			continue
+		}
+		document.Input.Variables, err = sjson.SetRawBytes(document.Input.Variables, name, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	coercion := variablesCoercionPool.Get().(*astnormalization.VariablesCoercion)
+	var report operationreport.Report
+	coercion.CoerceVariables(document, schema.Document(), &report)
+	variablesCoercionPool.Put(coercion)
+	if report.HasErrors() {
+		return nil, report
+	}
+	for _, name := range n.canonicalVariables {
+		value, ok := variableJSON(document.Input.Variables, name)
+		if !ok {
+			continue
+		}
+		variables, err = sjson.SetRawBytes(variables, n.remapVariables[name], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	document.Input.Variables = variables
+	operation.SetNormalizedDocument(document, schema)
+	operation.Variables = variables
+	return n.remapVariables, nil
+}
+
+// inspect records the shape of the parsed operation when its query missed the
+// cache. It has to run before the operation is normalized.
+func (l *normalizationCacheLookup) inspect(operation *graphql.Request) {
+	if l.shape != nil {
+		return
+	}
+	document := operation.Document()
+	l.shape = &operationShape{
+		directiveVariables: directiveVariables(document),
+		variables:          operationVariables(document, operation.OperationName),
+	}
+	l.key = normalizationKey(l.queryKey, l.shape, operation.Variables)
+	l.cache.Add(l.queryKey, l.shape)
+}
+
+// inspectValidated records the variables of the normalized and validated
+// operation, before their argument values are extracted into variables.
+func (l *normalizationCacheLookup) inspectValidated(operation *graphql.Request) error {
+	l.normalized = &normalizedOperation{}
+	document := operation.Document()
+	remaining := operationVariables(document, operation.OperationName)
+	for _, name := range l.shape.variables {
+		if !contains(remaining, name) {
+			l.normalized.unusedVariables = append(l.normalized.unusedVariables, name)
+		}
+	}
+	for _, node := range document.RootNodes {
+		if node.Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		for _, ref := range document.OperationDefinitions[node.Ref].VariableDefinitions.Refs {
+			if !document.VariableDefinitionHasDefaultValue(ref) {
+				continue
+			}
+			value, err := astnormalization.VariableDefaultValue(document, ref)
+			if err != nil {
+				return err
+			}
+			l.normalized.defaultValues = append(l.normalized.defaultValues, variableValue{
+				name:  document.VariableDefinitionNameString(ref),
+				value: value,
+			})
+		}
+	}
+	return nil
+}
+
+// add caches a copy of the operation once its variables are extracted and
+// named canonically.
+func (l *normalizationCacheLookup) add(operation *graphql.Request, remapVariables map[string]string) {
+	normalized := l.normalized
+	normalized.document = operation.Document().CloneOperation()
+	normalized.document.Input.Variables = nil
+	normalized.remapVariables = remapVariables
+	for name, variable := range remapVariables {
+		normalized.canonicalVariables = append(normalized.canonicalVariables, name)
+		if contains(l.shape.variables, variable) {
+			continue
+		}
+		value, ok := variableJSON(operation.Variables, variable)
+		if !ok {
+			continue
+		}
+		normalized.extractedVariables = append(normalized.extractedVariables, variableValue{name: variable, value: value})
+	}
+	slices.Sort(normalized.canonicalVariables)
+	slices.SortFunc(normalized.extractedVariables, func(a, b variableValue) int {
+		return strings.Compare(a.name, b.name)
+	})
+	l.cache.Add(l.key, normalized)
+}
+
+// addErrors caches the errors an operation failed normalization or
+// validation with, so that an invalid operation sent over and over again is
+// rejected from the cache. Internal errors are not cached.
+func (l *normalizationCacheLookup) addErrors(err error) {
+	var requestErrors graphqlerrors.Errors
+	if l == nil || l.shape == nil || !errors.As(err, &requestErrors) {
+		return
+	}
+	l.cache.Add(l.key, &normalizedOperation{errors: requestErrors})
+}
+
+// variableJSON returns the value of the variable with the given name as JSON.
+func variableJSON(variables []byte, name string) ([]byte, bool) {
+	value, dataType, _, err := jsonparser.Get(variables, name)
+	if err != nil {
+		return nil, false
+	}
+	if dataType == jsonparser.String {
+		// jsonparser strips the quotes, but keeps the string escaped
+		return append(append([]byte{'"'}, value...), '"'), true
+	}
+	return value, true
+}
+
+// normalizationQueryKey keys the shape of an operation by its name and query,
+// or the hash of a persisted query.
+func normalizationQueryKey(schemaHash uint64, operationName, query string) uint64 {
+	digest := pool.Hash64.Get()
+	digest.Reset()
+	defer pool.Hash64.Put(digest)
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(schemaHash >> (8 * i))
+	}
+	_, _ = digest.Write(buf[:])
+	_, _ = digest.WriteString(operationName)
+	_, _ = digest.Write([]byte{0})
+	_, _ = digest.WriteString(query)
+	return digest.Sum64()
+}
+
+// normalizationKey keys a normalized operation by its query and the values of
+// the variables of the directive arguments normalization inlines.
+func normalizationKey(queryKey uint64, shape *operationShape, variables []byte) uint64 {
+	digest := pool.Hash64.Get()
+	digest.Reset()
+	defer pool.Hash64.Put(digest)
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = byte(queryKey >> (8 * i))
+	}
+	_, _ = digest.Write(buf[:])
+	for _, name := range shape.directiveVariables {
+		value, dataType, _, _ := jsonparser.Get(variables, name)
+		_, _ = digest.Write([]byte{0, byte(dataType)})
+		_, _ = digest.Write(value)
+	}
+	return digest.Sum64()
+}
+
+// directiveVariables returns the variables normalization inlines: those the if
+// argument of @skip, @include, @defer and @stream directives takes, and the
+// initialCount argument of @stream.
+func directiveVariables(document *ast.Document) []string {
+	var names []string
+	for ref := range document.Directives {
+		var arguments [][]byte
+		switch document.DirectiveNameString(ref) {
+		case "skip", "include", "defer":
+			arguments = [][]byte{literal.IF}
+		case "stream":
+			arguments = [][]byte{literal.IF, literal.INITIAL_COUNT}
+		default:
+			continue
+		}
+		for _, argument := range arguments {
+			value, ok := document.DirectiveArgumentValueByName(ref, argument)
+			if !ok || value.Kind != ast.ValueKindVariable {
+				continue
+			}
+			name := document.VariableValueNameString(value.Ref)
+			if !contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// operationVariables returns the variables the operation with the given name
+// declares, or all operations of the document when the name is empty.
+func operationVariables(document *ast.Document, operationName string) []string {
+	var names []string
+	for _, node := range document.RootNodes {
+		if node.Kind != ast.NodeKindOperationDefinition {
+			continue
+		}
+		if operationName != "" && document.OperationDefinitionNameString(node.Ref) != operationName {
+			continue
+		}
+		for _, ref := range document.OperationDefinitions[node.Ref].VariableDefinitions.Refs {
+			names = append(names, document.VariableDefinitionNameString(ref))
+		}
+	}
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
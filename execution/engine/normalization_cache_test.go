@@ -0,0 +1,180 @@
+package engine
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/telemetry"
+)
+
+func TestExecutionEngine_NormalizationCache(t *testing.T) {
+	const (
+		meResponse      = `{"data":{"me":{"id":"1234","username":"Me"}}}`
+		meOptionalQuery = `query Me($withName: Boolean!) { me { id username @include(if: $withName) } }`
+	)
+
+	execute := func(t *testing.T, h *harness, query, variables string) (string, error) {
+		t.Helper()
+		request := &graphql.Request{Query: query}
+		if variables != "" {
+			request.Variables = json.RawMessage(variables)
+		}
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), request, &writer)
+		return writer.String(), err
+	}
+
+	t.Run("a repeated operation is served from the cache", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		for range 2 {
+			out, err := execute(t, h, `query Me { ...Me } fragment Me on Query { me { id username } }`, "")
+			require.NoError(t, err)
+			assert.Equal(t, meResponse, out)
+		}
+		assert.Equal(t, 2, h.engine.normalizationCache.Len(), "the shape of the query and the normalized operation")
+		assert.Equal(t, int64(2), h.users.calls())
+	})
+
+	t.Run("the cache keeps operations normalized for different @include values apart", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		for range 2 {
+			out, err := execute(t, h, meOptionalQuery, `{"withName":true}`)
+			require.NoError(t, err)
+			assert.Equal(t, meResponse, out)
+
+			out, err = execute(t, h, meOptionalQuery, `{"withName":false}`)
+			require.NoError(t, err)
+			assert.Equal(t, `{"data":{"me":{"id":"1234"}}}`, out)
+		}
+		assert.Equal(t, 3, h.engine.normalizationCache.Len())
+	})
+
+	t.Run("variables the cached operation does not use are removed", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		const query = `query Me($skip: Boolean!, $withName: Boolean!) { me { id ... @skip(if: $skip) { username @include(if: $withName) } } }`
+		for range 2 {
+			out, err := execute(t, h, query, `{"skip":true,"withName":true}`)
+			require.NoError(t, err)
+			assert.Equal(t, `{"data":{"me":{"id":"1234"}}}`, out)
+		}
+	})
+
+	t.Run("variables are validated on a cache hit", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		_, err := execute(t, h, meOptionalQuery, `{"withName":true}`)
+		require.NoError(t, err)
+
+		_, err = execute(t, h, meOptionalQuery, `{"withName":"yes"}`)
+		require.Error(t, err)
+		assert.Equal(t, int64(1), h.users.calls())
+	})
+
+	t.Run("an invalid operation is rejected from the cache", func(t *testing.T) {
+		h := newHarness(t)
+
+		_, first := execute(t, h, `{ me { unknown } }`, "")
+		require.Error(t, first)
+		_, second := execute(t, h, `{ me { unknown } }`, "")
+		require.Error(t, second)
+		assert.Equal(t, first.Error(), second.Error())
+		assert.Equal(t, 2, h.engine.normalizationCache.Len())
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("a cache hit is neither parsed nor normalized", func(t *testing.T) {
+		exporter := tracetest.NewInMemoryExporter()
+		tel, err := telemetry.New(telemetry.Options{
+			TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		})
+		require.NoError(t, err)
+		h := newHarness(t, func(c *Configuration) { c.SetTelemetry(tel) })
+		h.users.answers(meAnswer)
+
+		phases := func() []string {
+			var names []string
+			for _, span := range exporter.GetSpans() {
+				if span.Name != telemetry.FetchSpanName && span.Name != telemetry.OperationSpanName {
+					names = append(names, span.Name)
+				}
+			}
+			exporter.Reset()
+			return names
+		}
+
+		_, err = execute(t, h, meOptionalQuery, `{"withName":true}`)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"graphql.parse", "graphql.normalize", "graphql.validate", "graphql.normalize", "graphql.validate", "graphql.plan"}, phases())
+
+		out, err := execute(t, h, meOptionalQuery, `{"withName":true}`)
+		require.NoError(t, err)
+		assert.Equal(t, meResponse, out)
+		// only the variables of the request are validated
+		assert.Equal(t, []string{"graphql.validate", "graphql.plan"}, phases())
+	})
+
+	t.Run("the variables of a request are prepared for the cached operation", func(t *testing.T) {
+		h := newHarness(t)
+		h.products.answers(productsAnswer("1"))
+
+		upstreamVariables := func() string {
+			var request struct {
+				Variables json.RawMessage `json:"variables"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(h.products.last.Load().(string)), &request))
+			return string(request.Variables)
+		}
+
+		const query = `query Top($first: Int = 3) { topProducts(first: $first) { upc } alias: topProducts(first: 4) { name } }`
+		for range 2 {
+			_, err := execute(t, h, query, `{}`)
+			require.NoError(t, err)
+			assert.Equal(t, `{"b":4,"a":3}`, upstreamVariables())
+
+			_, err = execute(t, h, query, `{"first":2}`)
+			require.NoError(t, err)
+			assert.Equal(t, `{"b":4,"a":2}`, upstreamVariables())
+		}
+		assert.Equal(t, 2, h.engine.normalizationCache.Len())
+	})
+
+	t.Run("the size of the cache is configurable", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetNormalizationCacheSize(2) })
+		h.users.answers(meAnswer)
+
+		for _, query := range []string{`{ me { id } }`, `{ me { username } }`} {
+			_, err := execute(t, h, query, "")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, 2, h.engine.normalizationCache.Len())
+	})
+
+	t.Run("operations are cached per operation name", func(t *testing.T) {
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+
+		const document = `query A { me { id } } query B { me { id username } }`
+		for range 2 {
+			writer := graphql.NewEngineResultWriter()
+			require.NoError(t, h.engine.Execute(t.Context(), &graphql.Request{Query: document, OperationName: "B"}, &writer))
+			assert.Equal(t, meResponse, writer.String())
+
+			writer = graphql.NewEngineResultWriter()
+			require.NoError(t, h.engine.Execute(t.Context(), &graphql.Request{Query: document, OperationName: "A"}, &writer))
+			assert.Equal(t, `{"data":{"me":{"id":"1234"}}}`, writer.String())
+		}
+	})
+}
@@ -0,0 +1,185 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/errorcodes"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+var (
+	// ErrPersistedOperationNotFound is returned for a request that names a
+	// persisted operation the PersistedOperationStore does not have. It reads as
+	// the PersistedQueryNotFound error of the APQ protocol, which tells a client
+	// to send the query along with the hash.
+	ErrPersistedOperationNotFound error = persistedQueryError{message: "PersistedQueryNotFound", code: errorcodes.PersistedQueryNotFound}
+	// ErrPersistedOperationsNotSupported is returned for a request that names a
+	// persisted operation when no PersistedOperationStore is configured.
+	ErrPersistedOperationsNotSupported error = persistedQueryError{message: "PersistedQueryNotSupported", code: errorcodes.PersistedQueryNotSupported}
+	// ErrOperationNotPersisted is returned for an operation that is not in the
+	// PersistedOperationStore when only persisted operations are executed.
+	ErrOperationNotPersisted = errors.New("operation is not a persisted operation")
+	// ErrPersistedQueryHashMismatch is returned for a request whose query does
+	// not hash to the sha256 hash it was sent with.
+	ErrPersistedQueryHashMismatch = errors.New("provided sha does not match query")
+	// ErrPersistedQueryVersionNotSupported is returned for a request whose
+	// persistedQuery extension has a version other than 1.
+	ErrPersistedQueryVersionNotSupported = errors.New("unsupported persisted query version")
+)
+
+// persistedQueryError is an error of the APQ protocol. Clients recognize it by
+// its message and extension code, so it converts to graphqlerrors.RequestErrors
+// carrying both.
+type persistedQueryError struct {
+	message string
+	code    string
+}
+
+func (e persistedQueryError) Error() string {
+	return e.message
+}
+
+func (e persistedQueryError) As(target any) bool {
+	requestErrors, ok := target.(*graphqlerrors.RequestErrors)
+	if !ok {
+		return false
+	}
+	*requestErrors = graphqlerrors.RequestErrors{
+		{Message: e.message, Extensions: &graphqlerrors.Extensions{Code: e.code}},
+	}
+	return true
+}
+
+// PersistedOperationStore holds the documents of persisted operations (trusted
+// documents), keyed by the hex encoded sha256 hash of the document.
+type PersistedOperationStore interface {
+	// PersistedOperation returns the document with the given hash, and false
+	// when the store has none.
+	PersistedOperation(ctx context.Context, sha256Hash string) (document string, ok bool, err error)
+}
+
+// PersistedOperationRegistry is implemented by a PersistedOperationStore that
+// clients may add operations to, such as AutomaticPersistedQueryStore. A
+// request that carries a query along with its hash registers the query when the
+// store is one, unless only persisted operations are executed.
+type PersistedOperationRegistry interface {
+	// RegisterPersistedOperation stores the document under its sha256 hash.
+	RegisterPersistedOperation(ctx context.Context, sha256Hash string, document string) error
+}
+
+// StaticPersistedOperationStore is a PersistedOperationStore over a fixed set
+// of documents, such as a manifest of trusted documents shipped with a client.
+type StaticPersistedOperationStore map[string]string
+
+// NewStaticPersistedOperationStore returns a store holding the documents under
+// their sha256 hash.
+func NewStaticPersistedOperationStore(documents ...string) StaticPersistedOperationStore {
+	store := make(StaticPersistedOperationStore, len(documents))
+	for _, document := range documents {
+		store[persistedOperationHash(document)] = document
+	}
+	return store
+}
+
+func (s StaticPersistedOperationStore) PersistedOperation(_ context.Context, sha256Hash string) (string, bool, error) {
+	document, ok := s[sha256Hash]
+	return document, ok, nil
+}
+
+func persistedOperationHash(document string) string {
+	sum := sha256.Sum256([]byte(document))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadPersistedOperation sets the document of a request that names a persisted
+// operation and has no query, registers the query of a request that sends both
+// with a PersistedOperationRegistry, and rejects operations that are not
+// persisted when only those are executed. The store is asked for the operation
+// on every request. A persisted operation is then looked up in the persisted
+// operation cache the way loadNormalizedOperation looks up other operations: it
+// returns the lookup to cache the operation with on a miss, nil for an
+// operation that is not persisted, and the normalized operation to load the
+// request with on a hit.
+func (e *ExecutionEngine) loadPersistedOperation(ctx context.Context, operation *graphql.Request) (lookup *normalizationCacheLookup, normalized *normalizedOperation, err error) {
+	persistedQuery, err := operation.PersistedQuery()
+	if err != nil {
+		return nil, nil, err
+	}
+	if persistedQuery != nil && persistedQuery.Version != 1 {
+		return nil, nil, ErrPersistedQueryVersionNotSupported
+	}
+	store := e.config.persistedOperationStore
+	if store == nil {
+		if persistedQuery != nil && operation.Query == "" {
+			return nil, nil, ErrPersistedOperationsNotSupported
+		}
+		return nil, nil, nil
+	}
+	if operation.IsNormalized() {
+		// An operation normalized by the caller is not cached, but is still
+		// rejected when it is not persisted and only those are executed.
+		if !e.config.persistedOperationsOnly {
+			return nil, nil, nil
+		}
+		_, ok, err := store.PersistedOperation(ctx, persistedOperationHash(operation.Query))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			return nil, nil, ErrOperationNotPersisted
+		}
+		return nil, nil, nil
+	}
+
+	var (
+		hash       string
+		registered bool
+	)
+	switch {
+	case operation.Query == "" && persistedQuery != nil:
+		hash = persistedQuery.Sha256Hash
+	case e.config.persistedOperationsOnly:
+		// the hash a client sends along is not trusted, the document is
+		hash = persistedOperationHash(operation.Query)
+	case persistedQuery != nil:
+		registry, ok := store.(PersistedOperationRegistry)
+		if !ok {
+			return nil, nil, nil
+		}
+		if persistedOperationHash(operation.Query) != persistedQuery.Sha256Hash {
+			return nil, nil, ErrPersistedQueryHashMismatch
+		}
+		hash = persistedQuery.Sha256Hash
+		if err := registry.RegisterPersistedOperation(ctx, hash, operation.Query); err != nil {
+			return nil, nil, err
+		}
+		registered = true
+	default:
+		return nil, nil, nil
+	}
+
+	if !registered {
+		// The store is asked on every request, even when the operation is cached,
+		// so that a document removed from it, or expired, is no longer executed.
+		document, ok, err := store.PersistedOperation(ctx, hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			if operation.Query == "" {
+				return nil, nil, ErrPersistedOperationNotFound
+			}
+			return nil, nil, ErrOperationNotPersisted
+		}
+		if operation.Query == "" {
+			operation.SetQuery(document)
+		}
+	}
+
+	queryKey := normalizationQueryKey(e.config.schema.Hash(), operation.OperationName, hash)
+	return lookupNormalizedOperation(e.persistedOperationCache, queryKey, operation)
+}
@@ -0,0 +1,198 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+)
+
+// countingPersistedOperationStore counts the lookups that reach the store.
+type countingPersistedOperationStore struct {
+	StaticPersistedOperationStore
+	lookups atomic.Int64
+}
+
+func (s *countingPersistedOperationStore) PersistedOperation(ctx context.Context, sha256Hash string) (string, bool, error) {
+	s.lookups.Add(1)
+	return s.StaticPersistedOperationStore.PersistedOperation(ctx, sha256Hash)
+}
+
+func persistedOperationRequest(t *testing.T, document string, variables string) *graphql.Request {
+	t.Helper()
+
+	extensions, err := json.Marshal(map[string]any{
+		"persistedQuery": map[string]any{"version": 1, "sha256Hash": persistedOperationHash(document)},
+	})
+	require.NoError(t, err)
+
+	request := &graphql.Request{Extensions: extensions}
+	if variables != "" {
+		request.Variables = json.RawMessage(variables)
+	}
+	return request
+}
+
+func TestExecutionEngine_PersistedOperations(t *testing.T) {
+	const (
+		meQuery           = `query Me { me { id username } }`
+		meResponse        = `{"data":{"me":{"id":"1234","username":"Me"}}}`
+		meOptionalQuery   = `query Me($withName: Boolean!) { me { id username @include(if: $withName) } }`
+		notPersistedQuery = `{ me { id } }`
+	)
+
+	execute := func(t *testing.T, h *harness, request *graphql.Request) (string, error) {
+		t.Helper()
+		writer := graphql.NewEngineResultWriter()
+		err := h.engine.Execute(t.Context(), request, &writer)
+		return writer.String(), err
+	}
+
+	t.Run("a request with only a hash executes the persisted operation", func(t *testing.T) {
+		store := &countingPersistedOperationStore{StaticPersistedOperationStore: NewStaticPersistedOperationStore(meQuery)}
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(store) })
+		h.users.answers(meAnswer)
+
+		for range 2 {
+			out, err := execute(t, h, persistedOperationRequest(t, meQuery, ""))
+			require.NoError(t, err)
+			assert.Equal(t, meResponse, out)
+		}
+		assert.Equal(t, int64(2), store.lookups.Load(), "every request is checked against the store")
+		assert.Equal(t, int64(2), h.users.calls())
+	})
+
+	t.Run("an operation removed from the store is no longer executed", func(t *testing.T) {
+		store := NewStaticPersistedOperationStore(meQuery)
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(store) })
+		h.users.answers(meAnswer)
+
+		_, err := execute(t, h, persistedOperationRequest(t, meQuery, ""))
+		require.NoError(t, err)
+		delete(store, persistedOperationHash(meQuery))
+
+		_, err = execute(t, h, persistedOperationRequest(t, meQuery, ""))
+		assert.ErrorIs(t, err, ErrPersistedOperationNotFound)
+		assert.Equal(t, int64(1), h.users.calls())
+	})
+
+	t.Run("an operation removed from the store is rejected when only persisted operations are executed", func(t *testing.T) {
+		store := NewStaticPersistedOperationStore(meQuery)
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(store)
+			c.EnablePersistedOperationsOnly()
+		})
+		h.users.answers(meAnswer)
+
+		_, err := execute(t, h, &graphql.Request{Query: meQuery})
+		require.NoError(t, err)
+		delete(store, persistedOperationHash(meQuery))
+
+		_, err = execute(t, h, &graphql.Request{Query: meQuery})
+		assert.ErrorIs(t, err, ErrOperationNotPersisted)
+		assert.Equal(t, int64(1), h.users.calls())
+	})
+
+	t.Run("the cache keeps operations normalized for different variables apart", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meOptionalQuery))
+		})
+		h.users.answers(meAnswer)
+
+		for range 2 {
+			out, err := execute(t, h, persistedOperationRequest(t, meOptionalQuery, `{"withName":true}`))
+			require.NoError(t, err)
+			assert.Equal(t, meResponse, out)
+
+			out, err = execute(t, h, persistedOperationRequest(t, meOptionalQuery, `{"withName":false}`))
+			require.NoError(t, err)
+			assert.Equal(t, `{"data":{"me":{"id":"1234"}}}`, out)
+		}
+	})
+
+	t.Run("the cache keys operations by the variables normalization depends on only", func(t *testing.T) {
+		const topQuery = `query Top($first: Int) { topProducts(first: $first) { upc } }`
+		store := &countingPersistedOperationStore{StaticPersistedOperationStore: NewStaticPersistedOperationStore(topQuery)}
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(store) })
+		h.products.answers(`{"data":{"topProducts":[]}}`)
+
+		for _, variables := range []string{`{"first":1}`, `{"first":2}`} {
+			out, err := execute(t, h, persistedOperationRequest(t, topQuery, variables))
+			require.NoError(t, err)
+			assert.Equal(t, `{"data":{"topProducts":[]}}`, out)
+		}
+		assert.Equal(t, int64(2), store.lookups.Load())
+		assert.Equal(t, 2, h.engine.persistedOperationCache.Len(), "the shape of the query and the normalized operation")
+	})
+
+	t.Run("a persisted query version other than 1 is rejected", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery)) })
+
+		request := persistedOperationRequest(t, meQuery, "")
+		request.Extensions = json.RawMessage(`{"persistedQuery":{"version":2,"sha256Hash":"` + persistedOperationHash(meQuery) + `"}}`)
+		_, err := execute(t, h, request)
+		assert.ErrorIs(t, err, ErrPersistedQueryVersionNotSupported)
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("an unknown hash is not found", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery)) })
+
+		_, err := execute(t, h, persistedOperationRequest(t, notPersistedQuery, ""))
+		assert.ErrorIs(t, err, ErrPersistedOperationNotFound)
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("a hash without a store is not supported", func(t *testing.T) {
+		h := newHarness(t)
+
+		_, err := execute(t, h, persistedOperationRequest(t, meQuery, ""))
+		assert.ErrorIs(t, err, ErrPersistedOperationsNotSupported)
+	})
+
+	t.Run("only persisted operations are executed when enabled", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery))
+			c.EnablePersistedOperationsOnly()
+		})
+		h.users.answers(meAnswer)
+
+		_, err := execute(t, h, &graphql.Request{Query: notPersistedQuery})
+		assert.ErrorIs(t, err, ErrOperationNotPersisted)
+		assert.Equal(t, int64(0), h.users.calls())
+
+		out, err := execute(t, h, &graphql.Request{Query: meQuery})
+		require.NoError(t, err)
+		assert.Equal(t, meResponse, out)
+	})
+
+	t.Run("a normalized operation is rejected when only persisted operations are executed", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) {
+			c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery))
+			c.EnablePersistedOperationsOnly()
+		})
+
+		request := &graphql.Request{Query: notPersistedQuery}
+		result, err := request.Normalize(h.engine.config.schema)
+		require.NoError(t, err)
+		require.True(t, result.Successful)
+
+		_, err = execute(t, h, request)
+		assert.ErrorIs(t, err, ErrOperationNotPersisted)
+		assert.Equal(t, int64(0), h.users.calls())
+	})
+
+	t.Run("operations outside the store are executed when not only persisted ones are allowed", func(t *testing.T) {
+		h := newHarness(t, func(c *Configuration) { c.SetPersistedOperationStore(NewStaticPersistedOperationStore(meQuery)) })
+		h.users.answers(meAnswer)
+
+		out, err := execute(t, h, &graphql.Request{Query: notPersistedQuery})
+		require.NoError(t, err)
+		assert.Equal(t, `{"data":{"me":{"id":"1234"}}}`, out)
+	})
+}
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"errors"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/variablesvalidation"
+)
+
+// RequestErrorsFrom returns the errors to answer a request error with, one
+// that is the client's to fix such as a validation error, and false when err
+// is not a request error but a failure of the engine. A failure of the engine
+// is not meant to be shown to the client.
+func RequestErrorsFrom(err error) (graphqlerrors.RequestErrors, bool) {
+	var invalidVariable *variablesvalidation.InvalidVariableError
+	if errors.As(err, &invalidVariable) {
+		requestError := graphqlerrors.RequestError{Message: invalidVariable.Message}
+		if invalidVariable.ExtensionCode != "" {
+			requestError.Extensions = &graphqlerrors.Extensions{Code: invalidVariable.ExtensionCode}
+		}
+		return graphqlerrors.RequestErrors{requestError}, true
+	}
+	var requestErrors graphqlerrors.RequestErrors
+	if errors.As(err, &requestErrors) {
+		return requestErrors, true
+	}
+	switch {
+	case errors.Is(err, ErrOperationNotPersisted),
+		errors.Is(err, ErrPersistedQueryHashMismatch),
+		errors.Is(err, ErrPersistedQueryVersionNotSupported),
+		errors.Is(err, graphql.ErrInvalidExtensions),
+		errors.Is(err, ErrMutationNotAllowed),
+		errors.Is(err, ErrBatchCostExceeded),
+		errors.Is(err, ErrSubscriptionInBatch),
+		errors.Is(err, ErrIncrementalDeliveryInBatch):
+		return graphqlerrors.RequestErrors{{Message: err.Error()}}, true
+	}
+	var report operationreport.Report
+	if errors.As(err, &report) && len(report.ExternalErrors) > 0 {
+		return graphqlerrors.RequestErrorsFromOperationReport(report), true
+	}
+	return nil, false
+}
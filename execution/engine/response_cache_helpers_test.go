@@ -23,6 +23,7 @@ import (
 
 	"github.com/wundergraph/graphql-go-tools/execution/graphql"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
 )
 
@@ -39,6 +40,14 @@ type harness struct {
 func newHarness(t *testing.T, configure ...func(*Configuration)) *harness {
 	t.Helper()
 
+	return newHarnessWithResolverOptions(t, nil, configure...)
+}
+
+// newHarnessWithResolverOptions is newHarness for the cases that need the
+// resolver set up differently, such as how subgraph errors are propagated.
+func newHarnessWithResolverOptions(t *testing.T, resolverOptions func(*resolve.ResolverOptions), configure ...func(*Configuration)) *harness {
+	t.Helper()
+
 	h := &harness{users: newStub(t), products: newStub(t), reviews: newStub(t)}
 
 	// The federation configuration is the one checked in for these tests, with
@@ -60,9 +69,14 @@ func newHarness(t *testing.T, configure ...func(*Configuration)) *harness {
 		configure(&engineConfig)
 	}
 
-	h.engine, err = NewExecutionEngine(ctx, abstractlogger.NoopLogger, engineConfig, resolve.ResolverOptions{
+	options := resolve.ResolverOptions{
 		MaxConcurrency: 1024,
-	})
+	}
+	if resolverOptions != nil {
+		resolverOptions(&options)
+	}
+
+	h.engine, err = NewExecutionEngine(ctx, abstractlogger.NoopLogger, engineConfig, options)
 	require.NoError(t, err)
 
 	return h
@@ -82,16 +96,47 @@ func (h *harness) execute(t *testing.T, query string, options ...ExecutionOption
 // it on the resolve context. Reaching into the execution context is what keeps
 // this out of the engine's exported surface: the cache is the caller's to supply,
 // and no option needs to exist for a test to supply one.
-func withResponseCache(t *testing.T, cache caching.Cache) ExecutionOptions {
+func withResponseCache(t *testing.T, cache caching.Cache, options ...resolve.ResponseCacheOption) ExecutionOptions {
 	t.Helper()
 
 	return func(execCtx *internalExecutionContext) {
 		execCtx.resolveContext.SetResponseCache(cache, time.Minute, func(err error) {
 			t.Errorf("response cache reported an error: %v", err)
-		})
+		}, options...)
+	}
+}
+
+// withCacheControl gives a field the @cacheControl hint its subgraph schema
+// does not carry, keeping whatever else is configured for the field.
+func withCacheControl(typeName, fieldName string, policy *resolve.CacheControlPolicy) func(*Configuration) {
+	return func(c *Configuration) {
+		fields := c.FieldConfigurations()
+		if field := fields.ForTypeField(typeName, fieldName); field != nil {
+			field.CacheControl = policy
+			return
+		}
+		c.AddFieldConfiguration(plan.FieldConfiguration{TypeName: typeName, FieldName: fieldName, CacheControl: policy})
 	}
 }
 
+// withSubgraphHeaders makes every subgraph request of the execution carry a
+// header set whose hash is hash, the way a router forwards client headers.
+func withSubgraphHeaders(hash uint64) ExecutionOptions {
+	return func(execCtx *internalExecutionContext) {
+		execCtx.resolveContext.SubgraphHeadersBuilder = fixedHeaders(hash)
+	}
+}
+
+type fixedHeaders uint64
+
+func (h fixedHeaders) HeadersForSubgraph(string) (http.Header, uint64) {
+	return http.Header{}, uint64(h)
+}
+
+func (h fixedHeaders) HashAll() uint64 {
+	return uint64(h)
+}
+
 func withRateLimiter(limiter resolve.RateLimiter) ExecutionOptions {
 	return func(execCtx *internalExecutionContext) {
 		execCtx.resolveContext.RateLimitOptions = resolve.RateLimitOptions{Enable: true}
@@ -112,6 +157,7 @@ type stub struct {
 	server *httptest.Server
 	count  atomic.Int64
 	last   atomic.Value // string
+	header atomic.Value // http.Header
 	state  atomic.Value // stubState
 }
 
@@ -119,6 +165,7 @@ type stubState struct {
 	body         string
 	status       int
 	cacheControl string
+	delay        time.Duration
 }
 
 func newStub(t *testing.T) *stub {
@@ -126,14 +173,17 @@ func newStub(t *testing.T) *stub {
 
 	s := &stub{}
 	s.last.Store("")
+	s.header.Store(http.Header{})
 	s.state.Store(stubState{status: http.StatusOK, cacheControl: cacheableForAMinute})
 
 	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.count.Add(1)
 		request, _ := io.ReadAll(r.Body)
 		s.last.Store(string(request))
+		s.header.Store(r.Header.Clone())
 
 		state := s.state.Load().(stubState)
+		time.Sleep(state.delay)
 		w.Header().Set("Content-Type", "application/json")
 		if state.cacheControl != "" {
 			w.Header().Set("Cache-Control", state.cacheControl)
@@ -157,7 +207,9 @@ func (s *stub) update(mutate func(*stubState)) {
 func (s *stub) answers(body string)       { s.update(func(st *stubState) { st.body = body }) }
 func (s *stub) status(code int)           { s.update(func(st *stubState) { st.status = code }) }
 func (s *stub) cacheControl(value string) { s.update(func(st *stubState) { st.cacheControl = value }) }
+func (s *stub) delays(d time.Duration)    { s.update(func(st *stubState) { st.delay = d }) }
 func (s *stub) calls() int64              { return s.count.Load() }
+func (s *stub) lastHeader() http.Header   { return s.header.Load().(http.Header) }
 
 // representationCount is how many entities the last entity fetch asked for, which
 // is the only way to tell a batch that was trimmed from one that was not.
@@ -226,9 +278,22 @@ func (h *countingLoaderHooks) OnFinished(context.Context, resolve.DataSourceInfo
 	h.onFinished.Add(1)
 }
 
+// cacheHitLoaderHooks also counts the fetches served from the response cache.
+type cacheHitLoaderHooks struct {
+	countingLoaderHooks
+	onCacheHit atomic.Int64
+}
+
+func (h *cacheHitLoaderHooks) OnCacheHit(_ context.Context, _ resolve.DataSourceInfo, info *resolve.ResponseInfo) {
+	if info.CacheHit {
+		h.onCacheHit.Add(1)
+	}
+}
+
 // mapCache is an response cache held in a map, which is all the engine asks of one.
-// Expiry is left out because nothing here waits for it: a TTL is only checked for
-// being positive, the way a real adapter refuses an item it cannot expire.
+// Nothing here waits for an entry to expire: a TTL is only checked for being
+// positive, the way a real adapter refuses an item it cannot expire, and a test
+// that needs time to pass calls age instead.
 type mapCache struct {
 	mu    sync.Mutex
 	items map[string]caching.Item
@@ -266,6 +331,33 @@ func (c *mapCache) SetMany(_ context.Context, items []caching.Item) error {
 	return nil
 }
 
+func (c *mapCache) Delete(_ context.Context, keys []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+
+	return nil
+}
+
+// age takes d off the TTL of every entry, and drops the ones that have none
+// left, as the same stretch of time would in a real cache.
+func (c *mapCache) age(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, item := range c.items {
+		item.TTL -= d
+		if item.TTL <= 0 {
+			delete(c.items, key)
+			continue
+		}
+		c.items[key] = item
+	}
+}
+
 func (c *mapCache) keys() []string {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -1,11 +1,20 @@
 package engine
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
 )
 
 // The response cache stores the answers to entity fetches, so every case here is
@@ -92,29 +101,65 @@ func TestResponseCacheExecution(t *testing.T) {
 			"a batch containing an entity that was never cached must go back to the subgraph")
 	})
 
-	t.Run("a batch containing one uncached entity is refetched whole", func(t *testing.T) {
+	t.Run("a batch with some entities cached fetches only the rest", func(t *testing.T) {
 		t.Parallel()
 
 		h := newHarness(t)
 		cache := newMapCache()
 
-		// Caches product 1 alone.
-		h.products.answers(productsAnswer("1"))
-		h.reviews.answers(reviewsAnswer("r1"))
+		// Caches product 2 alone.
+		h.products.answers(productsAnswer("2"))
+		h.reviews.answers(reviewsAnswer("r2"))
 		h.execute(t, batchQuery(1), withResponseCache(t, cache))
 		require.EqualValues(t, 1, h.reviews.calls())
 
-		// Product 1 is cached and 2 and 3 are not. A batch is served from the
-		// cache only when every entity in it is present, so all three go to the
-		// subgraph, product 1 included.
+		// Product 2 is cached and 1 and 3 are not. Only those two reach the
+		// subgraph, which answers them in the order it was asked, and the cached
+		// entity has to be put back between them.
 		h.products.answers(productsAnswer("1", "2", "3"))
-		h.reviews.answers(reviewsAnswer("r1", "r2", "r3"))
+		h.reviews.answers(reviewsAnswer("r1", "r3"))
+		partial := h.execute(t, batchQuery(3), withResponseCache(t, cache))
+
+		require.EqualValues(t, 2, h.reviews.calls())
+		require.Equal(t, 2, h.reviews.representationCount(t),
+			"the cached entity must be trimmed out of the batch")
+		require.Equal(t, `{"data":{"topProducts":[`+
+			`{"upc":"1","reviews":[{"body":"r1"}]},`+
+			`{"upc":"2","reviews":[{"body":"r2"}]},`+
+			`{"upc":"3","reviews":[{"body":"r3"}]}]}}`, partial)
+		require.Len(t, cache.keys(), 3, "the fetched entities are cached alongside the one that was")
+
+		// Now all three are cached.
 		h.execute(t, batchQuery(3), withResponseCache(t, cache))
+		require.EqualValues(t, 2, h.reviews.calls())
+	})
 
-		require.EqualValues(t, 2, h.reviews.calls(),
-			"one miss in a batch must send the whole batch to the subgraph")
-		require.Equal(t, 3, h.reviews.representationCount(t),
-			"the already cached entity is refetched along with the rest, not trimmed out")
+	t.Run("a partial hit points subgraph errors at the entity they belong to", func(t *testing.T) {
+		t.Parallel()
+
+		// Passed through with the path left as the subgraph sent it, so the
+		// _entities index the client sees is the one the engine settled on.
+		h := newHarnessWithResolverOptions(t, func(o *resolve.ResolverOptions) {
+			o.PropagateSubgraphErrors = true
+			o.SubgraphErrorPropagationMode = resolve.SubgraphErrorPropagationModePassThrough
+		})
+		cache := newMapCache()
+
+		h.products.answers(productsAnswer("1"))
+		h.reviews.answers(reviewsAnswer("r1"))
+		h.execute(t, batchQuery(1), withResponseCache(t, cache))
+
+		// The trimmed batch is products 2 and 3, so the subgraph's _entities.1 is
+		// product 3, which is index 2 of the batch the client asked for.
+		h.products.answers(productsAnswer("1", "2", "3"))
+		h.reviews.answers(`{"data":{"_entities":[{"reviews":[{"body":"r2"}]},null]},` +
+			`"errors":[{"message":"not found","path":["_entities",1,"reviews"]}]}`)
+		partial := h.execute(t, batchQuery(3), withResponseCache(t, cache))
+
+		require.Equal(t, 2, h.reviews.representationCount(t))
+		require.Contains(t, partial, `"body":"r1"`)
+		require.Contains(t, partial, `"body":"r2"`)
+		require.Contains(t, partial, `"path":["_entities",2,"reviews"]`)
 	})
 
 	t.Run("the same entity twice in one batch is one representation and one entry", func(t *testing.T) {
@@ -134,6 +179,133 @@ func TestResponseCacheExecution(t *testing.T) {
 		require.Len(t, cache.keys(), 2)
 	})
 
+	// --- serving past the TTL ---
+
+	t.Run("an entity within its stale-while-revalidate window is served and refetched", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("old"))
+		h.reviews.cacheControl("public, max-age=60, stale-while-revalidate=30")
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		cache.age(70 * time.Second)
+
+		h.reviews.answers(reviewsAnswer("new"))
+		stale := h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.Contains(t, stale, `"body":"old"`, "the stale entity answers the request that found it")
+
+		require.Eventually(t, func() bool { return h.reviews.calls() == 2 }, time.Second, time.Millisecond,
+			"the stale entity is refetched in the background")
+		require.Eventually(t, func() bool {
+			return strings.Contains(h.execute(t, singleEntityQuery, withResponseCache(t, cache)), `"body":"new"`)
+		}, time.Second, 10*time.Millisecond, "the refetched entity replaces the stale one")
+		require.EqualValues(t, 2, h.reviews.calls(), "the refreshed entry is fresh again")
+	})
+
+	t.Run("a revalidation is loaded the way any fetch is", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("old"))
+		h.reviews.cacheControl("public, max-age=60, stale-while-revalidate=30")
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		cache.age(70 * time.Second)
+
+		hooks := &countingLoaderHooks{}
+		h.reviews.answers(reviewsAnswer("new"))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache), withLoaderHooks(hooks), func(execCtx *internalExecutionContext) {
+			execCtx.resolveContext.Extensions = []byte(`{"client":"web"}`)
+		})
+
+		require.Eventually(t, func() bool { return h.reviews.calls() == 2 }, time.Second, time.Millisecond)
+		// The expired me is fetched for the request, and the stale reviews in the
+		// background, and the hooks see both.
+		require.Eventually(t, func() bool { return hooks.onFinished.Load() == 2 }, time.Second, time.Millisecond,
+			"the revalidation is reported to the loader hooks")
+		require.EqualValues(t, 2, hooks.onLoad.Load())
+		require.Contains(t, h.reviews.last.Load(), `"extensions":{"client":"web"}`,
+			"the revalidation carries the request's extensions")
+	})
+
+	t.Run("a revalidation is abandoned after the revalidation timeout", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("old"))
+		h.reviews.cacheControl("public, max-age=60, stale-while-revalidate=30")
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		cache.age(70 * time.Second)
+
+		h.reviews.delays(time.Second)
+		reported := make(chan error, 1)
+		stale := h.execute(t, singleEntityQuery, func(execCtx *internalExecutionContext) {
+			execCtx.resolveContext.SetResponseCache(cache, time.Minute, func(err error) { reported <- err },
+				resolve.WithRevalidationTimeout(10*time.Millisecond))
+		})
+		require.Contains(t, stale, `"body":"old"`)
+
+		select {
+		case err := <-reported:
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(500 * time.Millisecond):
+			t.Fatal("the revalidation outlived its timeout")
+		}
+	})
+
+	t.Run("an entity past its stale-while-revalidate window is fetched", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("old"))
+
+		cache := newMapCache()
+		options := []resolve.ResponseCacheOption{resolve.WithStaleWhileRevalidate(30 * time.Second), resolve.WithStaleIfError(time.Hour)}
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache, options...))
+		cache.age(100 * time.Second)
+
+		h.reviews.answers(reviewsAnswer("new"))
+		fetched := h.execute(t, singleEntityQuery, withResponseCache(t, cache, options...))
+
+		require.Contains(t, fetched, `"body":"new"`,
+			"an entry kept only for stale-if-error must not answer a fetch that succeeds")
+		require.EqualValues(t, 2, h.reviews.calls())
+	})
+
+	t.Run("an entity within its stale-if-error window stands in for a failed fetch", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.products.answers(productsAnswer("1", "2"))
+		h.reviews.answers(reviewsAnswer("r1", "r2"))
+
+		cache := newMapCache()
+		withCache := withResponseCache(t, cache, resolve.WithStaleIfError(10*time.Minute))
+		first := h.execute(t, batchQuery(2), withCache)
+		cache.age(5 * time.Minute)
+
+		h.reviews.status(http.StatusServiceUnavailable)
+		h.reviews.answers(`{"errors":[{"message":"unavailable"}]}`)
+		stale := h.execute(t, batchQuery(2), withCache)
+
+		require.EqualValues(t, 2, h.reviews.calls(), "the subgraph is asked first")
+		require.Equal(t, first, stale, "and the stale entities answer when it fails")
+
+		// Past the window there is nothing left to stand in.
+		cache.age(10 * time.Minute)
+		failed := h.execute(t, batchQuery(2), withCache)
+		require.Contains(t, failed, `"errors"`)
+	})
+
 	// --- every reason the engine declines to cache an answer it did get ---
 
 	t.Run("a response with no cache-control is not cached", func(t *testing.T) {
@@ -170,6 +342,137 @@ func TestResponseCacheExecution(t *testing.T) {
 			"private names a cache that belongs to one client, which this is not")
 	})
 
+	t.Run("a @cacheControl hint caches a response that does not opt in itself", func(t *testing.T) {
+		t.Parallel()
+
+		maxAge := 30 * time.Second
+		h := newHarness(t, withCacheControl("User", "reviews", &resolve.CacheControlPolicy{MaxAge: &maxAge}))
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+		h.reviews.cacheControl("")
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+
+		require.Len(t, cache.keys(), 1)
+		require.EqualValues(t, 1, h.reviews.calls(),
+			"the hint stands in for public")
+
+		cache.age(maxAge)
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.EqualValues(t, 2, h.reviews.calls(),
+			"the entry lives as long as the hint says, not the default TTL")
+	})
+
+	t.Run("a private @cacheControl hint caches per auth key", func(t *testing.T) {
+		t.Parallel()
+
+		maxAge := time.Minute
+		h := newHarness(t, withCacheControl("User", "reviews", &resolve.CacheControlPolicy{MaxAge: &maxAge, Private: true}))
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.Empty(t, cache.keys(), "without an auth key there is no viewer to cache for")
+
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache, resolve.WithAuthKey("alice")))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache, resolve.WithAuthKey("alice")))
+		require.EqualValues(t, 2, h.reviews.calls(), "alice's second execution is served from her entry")
+
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache, resolve.WithAuthKey("bob")))
+		require.EqualValues(t, 3, h.reviews.calls(), "bob does not see alice's entry")
+		require.Len(t, cache.keys(), 2)
+	})
+
+	t.Run("a root query fetch is cached when asked for", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.products.answers(productsAnswer("1", "2"))
+		h.reviews.answers(reviewsAnswer("r1", "r2"))
+
+		cache := newMapCache()
+		first := h.execute(t, batchQuery(2), withResponseCache(t, cache, resolve.WithRootFetches()))
+		second := h.execute(t, batchQuery(2), withResponseCache(t, cache, resolve.WithRootFetches()))
+
+		require.Equal(t, first, second)
+		require.EqualValues(t, 1, h.products.calls(), "topProducts must be served from the cache")
+		require.EqualValues(t, 1, h.reviews.calls())
+
+		h.products.answers(productsAnswer("1"))
+		h.reviews.answers(reviewsAnswer("r1"))
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()))
+		require.EqualValues(t, 2, h.products.calls(), "other arguments render another input")
+	})
+
+	t.Run("a root query fetch is not cached unless asked for", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.products.answers(productsAnswer("1"))
+		h.reviews.answers(reviewsAnswer("r1"))
+
+		cache := newMapCache()
+		h.execute(t, batchQuery(1), withResponseCache(t, cache))
+		h.execute(t, batchQuery(1), withResponseCache(t, cache))
+
+		require.EqualValues(t, 2, h.products.calls())
+		require.EqualValues(t, 1, h.reviews.calls())
+	})
+
+	t.Run("a root query fetch is cached per subgraph headers", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.products.answers(productsAnswer("1"))
+		h.reviews.answers(reviewsAnswer("r1"))
+
+		cache := newMapCache()
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()), withSubgraphHeaders(1))
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()), withSubgraphHeaders(1))
+		require.EqualValues(t, 1, h.products.calls())
+
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()), withSubgraphHeaders(2))
+		require.EqualValues(t, 2, h.products.calls(),
+			"a request that forwards other headers may be answered differently")
+	})
+
+	t.Run("a root response carrying errors is not cached", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.products.answers(`{"data":{"topProducts":null},"errors":[{"message":"boom"}]}`)
+
+		cache := newMapCache()
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()))
+		h.execute(t, batchQuery(1), withResponseCache(t, cache, resolve.WithRootFetches()))
+
+		require.Empty(t, cache.keys())
+		require.EqualValues(t, 2, h.products.calls())
+	})
+
+	t.Run("the hint of a parent field is not part of the policy of a fetch", func(t *testing.T) {
+		t.Parallel()
+
+		maxAge := time.Minute
+		h := newHarness(t,
+			withCacheControl("Query", "me", &resolve.CacheControlPolicy{MaxAge: &maxAge, Private: true}),
+			withCacheControl("User", "reviews", &resolve.CacheControlPolicy{MaxAge: &maxAge}),
+		)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+		h.reviews.cacheControl("")
+
+		cache := newMapCache()
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+
+		require.Len(t, cache.keys(), 1, "the reviews fetch does not select me, so me being private does not make it private")
+		require.EqualValues(t, 1, h.reviews.calls())
+	})
+
 	t.Run("a response carrying subgraph errors is not cached", func(t *testing.T) {
 		t.Parallel()
 
@@ -268,6 +571,47 @@ func TestResponseCacheExecution(t *testing.T) {
 		require.Len(t, cache.keys(), 2)
 	})
 
+	t.Run("an invalidated entity is refetched under every selection it was cached with", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		cache := newMapCache()
+
+		const widerQuery = `{ me { id username reviews { body product { upc } } } }`
+		h.reviews.answers(reviewsAnswer("A review"))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		h.reviews.answers(`{"data":{"_entities":[{"reviews":[{"body":"A review","product":{"upc":"1"}}]}]}}`)
+		h.execute(t, widerQuery, withResponseCache(t, cache))
+		require.Len(t, cache.keys(), 2)
+
+		// Another entity of the same type, which must survive the invalidation.
+		h.users.answers(`{"data":{"me":{"id":"5678","username":"Other","__typename":"User"}}}`)
+		h.reviews.answers(reviewsAnswer("Another review"))
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.Len(t, cache.keys(), 3)
+
+		// The @key fields alone name the entity the representation was rendered
+		// for, __typename and all.
+		require.NoError(t, h.engine.resolver.InvalidateEntity(t.Context(), cache, "User", []byte(`{"id":"1234"}`)))
+		require.Len(t, cache.keys(), 1, "both selections of the invalidated user are gone")
+
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("An edited review"))
+		calls := h.reviews.calls()
+		out := h.execute(t, singleEntityQuery, withResponseCache(t, cache))
+		require.Contains(t, out, `"body":"An edited review"`)
+		require.Equal(t, calls+1, h.reviews.calls())
+	})
+
+	t.Run("invalidation needs a cache that can delete", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		err := h.engine.resolver.InvalidateEntity(t.Context(), struct{ caching.Cache }{newMapCache()}, "User", []byte(`{"id":"1234"}`))
+		require.ErrorIs(t, err, resolve.ErrResponseCacheNotDeletable)
+	})
+
 	t.Run("a cache hit is still rate limited", func(t *testing.T) {
 		t.Parallel()
 
@@ -296,11 +640,9 @@ func TestResponseCacheExecution(t *testing.T) {
 	t.Run("a cache hit skips the loader hooks", func(t *testing.T) {
 		t.Parallel()
 
-		// Pins today's behaviour rather than endorsing it. The hooks are where a
-		// router hangs subgraph spans and metrics, and a hit reaches neither
-		// OnLoad nor OnFinished, so a cached entity fetch is invisible to
-		// subgraph telemetry. Should that ever be wanted, this test is the one
-		// that has to be changed on purpose.
+		// A hit reaches neither OnLoad nor OnFinished, nothing is loaded. Hooks
+		// that want to see it implement resolve.LoaderCacheHooks, see the next
+		// case.
 		h := newHarness(t)
 		h.users.answers(meAnswer)
 		h.reviews.answers(reviewsAnswer("A review"))
@@ -320,6 +662,25 @@ func TestResponseCacheExecution(t *testing.T) {
 		require.EqualValues(t, 3, hooks.onFinished.Load())
 	})
 
+	t.Run("a cache hit is reported to loader cache hooks", func(t *testing.T) {
+		t.Parallel()
+
+		h := newHarness(t)
+		h.users.answers(meAnswer)
+		h.reviews.answers(reviewsAnswer("A review"))
+
+		hooks := &cacheHitLoaderHooks{}
+		cache := newMapCache()
+
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache), withLoaderHooks(hooks))
+		require.EqualValues(t, 0, hooks.onCacheHit.Load())
+
+		h.execute(t, singleEntityQuery, withResponseCache(t, cache), withLoaderHooks(hooks))
+		require.EqualValues(t, 1, h.reviews.calls())
+		require.EqualValues(t, 1, hooks.onCacheHit.Load())
+		require.EqualValues(t, 3, hooks.onLoad.Load())
+	})
+
 	t.Run("a merged multi entity fetch is never cached", func(t *testing.T) {
 		t.Parallel()
 
@@ -362,3 +723,42 @@ func TestResponseCacheExecution(t *testing.T) {
 		require.Contains(t, h.reviews.last.Load().(string), `f2: _entities`)
 	})
 }
+
+// The planner can leave out the info of fetches, which the response cache does
+// not rely on for the policy of a fetch: a private fetch stays private.
+func TestResponseCachePolicyWithoutFetchInfo(t *testing.T) {
+	maxAge := time.Minute
+	private := &resolve.CacheControlPolicy{MaxAge: &maxAge, Private: true}
+	h := newHarness(t,
+		withCacheControl("User", "reviews", private),
+		func(c *Configuration) { c.plannerConfig.DisableIncludeInfo = true },
+	)
+
+	operation := &graphql.Request{Query: singleEntityQuery}
+	_, err := h.engine.prepareOperation(t.Context(), operation)
+	require.NoError(t, err)
+	var report operationreport.Report
+	cachedPlan, _ := h.engine.getCachedPlan(newInternalExecutionContext(h.engine.postProcessorOptions...), operation.Document(), h.engine.config.schema.Document(), "", &report)
+	require.False(t, report.HasErrors(), report.Error())
+
+	var entityFetch *resolve.EntityFetch
+	var walk func(node *resolve.FetchTreeNode)
+	walk = func(node *resolve.FetchTreeNode) {
+		if node == nil {
+			return
+		}
+		if node.Item != nil {
+			if fetch, ok := node.Item.Fetch.(*resolve.EntityFetch); ok {
+				entityFetch = fetch
+			}
+		}
+		for _, child := range node.ChildNodes {
+			walk(child)
+		}
+	}
+	walk(cachedPlan.(*plan.SynchronousResponsePlan).Response.Fetches)
+
+	require.NotNil(t, entityFetch)
+	require.Nil(t, entityFetch.Info)
+	require.Equal(t, private, entityFetch.CacheControl)
+}
@@ -13,4 +13,7 @@ const (
 	// SubscriptionTypeGraphQLTransportWS is for subscriptions using a WebSocket connection with
 	// 'graphql-transport-ws' as protocol.
 	SubscriptionTypeGraphQLTransportWS
+	// SubscriptionTypeMultipart is for subscriptions using the multipart HTTP protocol
+	// (multipart/mixed;subscriptionSpec="1.0").
+	SubscriptionTypeMultipart
 )
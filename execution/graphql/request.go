@@ -1,8 +1,10 @@
 package graphql
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 
@@ -29,13 +31,19 @@ const (
 
 var (
 	ErrEmptyRequest = errors.New("the provided request is empty")
+	ErrEmptyBatch   = errors.New("the provided batch holds no requests")
 	ErrNilSchema    = errors.New("the provided schema is nil")
+	// ErrInvalidExtensions is returned for a request whose extensions do not
+	// have the shape of the extensions the engine reads, such as a
+	// persistedQuery that is not an object.
+	ErrInvalidExtensions = errors.New("the provided extensions are invalid")
 )
 
 type Request struct {
 	OperationName string          `json:"operationName"`
 	Variables     json.RawMessage `json:"variables,omitempty"`
 	Query         string          `json:"query"`
+	Extensions    json.RawMessage `json:"extensions,omitempty"`
 
 	document     ast.Document
 	isParsed     bool
@@ -48,6 +56,15 @@ type Request struct {
 	actualCost    int
 }
 
+// PersistedQuery is the persistedQuery extension of a request, which names a
+// persisted operation by the sha256 hash of its document:
+//
+//	{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"..."}}}
+type PersistedQuery struct {
+	Version    int    `json:"version"`
+	Sha256Hash string `json:"sha256Hash"`
+}
+
 func UnmarshalRequest(reader io.Reader, request *Request) error {
 	requestBytes, err := io.ReadAll(reader)
 	if err != nil {
@@ -61,6 +78,41 @@ func UnmarshalRequest(reader io.Reader, request *Request) error {
 	return json.Unmarshal(requestBytes, &request)
 }
 
+// UnmarshalBatchRequest reads either a single request or a JSON array of
+// requests, a batch, and reports whether it was a batch.
+func UnmarshalBatchRequest(reader io.Reader) (requests []*Request, batched bool, err error) {
+	requestBytes, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, err
+	}
+
+	requestBytes = bytes.TrimLeft(requestBytes, " \t\r\n")
+	if len(requestBytes) == 0 {
+		return nil, false, ErrEmptyRequest
+	}
+
+	if requestBytes[0] != '[' {
+		var request Request
+		if err := json.Unmarshal(requestBytes, &request); err != nil {
+			return nil, false, err
+		}
+		return []*Request{&request}, false, nil
+	}
+
+	if err := json.Unmarshal(requestBytes, &requests); err != nil {
+		return nil, true, err
+	}
+	if len(requests) == 0 {
+		return nil, true, ErrEmptyBatch
+	}
+	for _, request := range requests {
+		if request == nil {
+			return nil, true, ErrEmptyRequest
+		}
+	}
+	return requests, true, nil
+}
+
 func UnmarshalHttpRequest(r *http.Request, request *Request) error {
 	request.request.Header = r.Header
 	return UnmarshalRequest(r.Body, request)
@@ -70,6 +122,44 @@ func (r *Request) SetHeader(header http.Header) {
 	r.request.Header = header
 }
 
+// PersistedQuery returns the persistedQuery extension of the request, and nil
+// when the request has none.
+func (r *Request) PersistedQuery() (*PersistedQuery, error) {
+	if len(r.Extensions) == 0 {
+		return nil, nil
+	}
+	var extensions struct {
+		PersistedQuery *PersistedQuery `json:"persistedQuery"`
+	}
+	if err := json.Unmarshal(r.Extensions, &extensions); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidExtensions, err)
+	}
+	if extensions.PersistedQuery == nil || extensions.PersistedQuery.Sha256Hash == "" {
+		return nil, nil
+	}
+	return extensions.PersistedQuery, nil
+}
+
+// SetQuery replaces the query of the request, and drops what was parsed,
+// normalized or validated of the previous one.
+func (r *Request) SetQuery(query string) {
+	r.Query = query
+	r.document.Reset()
+	r.isParsed = false
+	r.isNormalized = false
+	r.validForSchema = nil
+}
+
+// SetNormalizedDocument replaces the document of the request with one that was
+// normalized and validated for the schema already, e.g. a cached copy. The
+// request owns the document afterwards.
+func (r *Request) SetNormalizedDocument(document *ast.Document, schema *Schema) {
+	r.document = *document
+	r.isParsed = true
+	r.isNormalized = true
+	r.validForSchema = map[uint64]ValidationResult{schema.Hash(): {Valid: true}}
+}
+
 func (r *Request) Document() *ast.Document {
 	return &r.document
 }
@@ -91,6 +181,20 @@ func (r *Request) IsNormalized() bool {
 	return r.isNormalized
 }
 
+// Parse parses the query, which is otherwise parsed on first use, e.g. by
+// Normalize. A query failing to parse returns the errors Normalize returns for it.
+func (r *Request) Parse() error {
+	report := r.parseQueryOnce()
+	if !report.HasErrors() {
+		return nil
+	}
+	result, err := NormalizationResultFromReport(report)
+	if err != nil {
+		return err
+	}
+	return result.Errors
+}
+
 func (r *Request) parseQueryOnce() (report operationreport.Report) {
 	if r.isParsed {
 		return report
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/middleware/operation_complexity"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/starwars"
@@ -39,6 +40,79 @@ func TestUnmarshalRequest(t *testing.T) {
 	})
 }
 
+func TestUnmarshalBatchRequest(t *testing.T) {
+	t.Parallel()
+	t.Run("should read a single request", func(t *testing.T) {
+		t.Parallel()
+		requests, batched, err := UnmarshalBatchRequest(bytes.NewBufferString(`{"query": "{ hello }"}`))
+
+		require.NoError(t, err)
+		assert.False(t, batched)
+		require.Len(t, requests, 1)
+		assert.Equal(t, "{ hello }", requests[0].Query)
+	})
+
+	t.Run("should read a batch of requests in order", func(t *testing.T) {
+		t.Parallel()
+		requests, batched, err := UnmarshalBatchRequest(bytes.NewBufferString(` [{"query": "{ hello }"}, {"query": "{ goodbye }", "operationName": "Bye"}]`))
+
+		require.NoError(t, err)
+		assert.True(t, batched)
+		require.Len(t, requests, 2)
+		assert.Equal(t, "{ hello }", requests[0].Query)
+		assert.Equal(t, "{ goodbye }", requests[1].Query)
+		assert.Equal(t, "Bye", requests[1].OperationName)
+	})
+
+	t.Run("should return error when the batch is empty", func(t *testing.T) {
+		t.Parallel()
+		_, batched, err := UnmarshalBatchRequest(bytes.NewBufferString(`[]`))
+
+		assert.True(t, batched)
+		assert.Equal(t, ErrEmptyBatch, err)
+	})
+
+	t.Run("should return error when a request of the batch is null", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := UnmarshalBatchRequest(bytes.NewBufferString(`[{"query": "{ hello }"}, null]`))
+
+		assert.Equal(t, ErrEmptyRequest, err)
+	})
+}
+
+func TestRequest_PersistedQuery(t *testing.T) {
+	t.Parallel()
+	t.Run("should return the persisted query extension", func(t *testing.T) {
+		t.Parallel()
+		requestBytes := []byte(`{"operationName": "Hello", "extensions": {"persistedQuery": {"version": 1, "sha256Hash": "ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"}}}`)
+
+		var request Request
+		assert.NoError(t, UnmarshalRequest(bytes.NewBuffer(requestBytes), &request))
+		persistedQuery, err := request.PersistedQuery()
+
+		assert.NoError(t, err)
+		assert.Equal(t, &PersistedQuery{Version: 1, Sha256Hash: "ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"}, persistedQuery)
+		assert.Empty(t, request.Query)
+	})
+
+	t.Run("should return nil without the extension", func(t *testing.T) {
+		t.Parallel()
+		request := Request{Query: "query Hello { hello }", Extensions: []byte(`{"tracing": true}`)}
+		persistedQuery, err := request.PersistedQuery()
+
+		assert.NoError(t, err)
+		assert.Nil(t, persistedQuery)
+	})
+
+	t.Run("should return error on malformed extensions", func(t *testing.T) {
+		t.Parallel()
+		request := Request{Extensions: []byte(`{"persistedQuery": "hash"}`)}
+		_, err := request.PersistedQuery()
+
+		assert.Error(t, err)
+	})
+}
+
 func TestRequest_Print(t *testing.T) {
 	t.Parallel()
 	query := "query Hello { hello }"
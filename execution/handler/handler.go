@@ -0,0 +1,273 @@
+// Package handler serves an ExecutionEngine over HTTP following the
+// GraphQL-over-HTTP specification.
+//
+// Queries and mutations are accepted as GET and POST requests and answered
+// with application/graphql-response+json or application/json, whichever the
+// Accept header prefers. An operation with @defer or @stream is answered
+// incrementally with multipart/mixed, a subscription with Server-Sent Events
+// (text/event-stream). A POST request may carry a JSON array of operations,
+// which is executed as a batch and answered with the array of their
+// responses. A WebSocket upgrade request is handed to the graphql-ws and
+// graphql-transport-ws protocol handlers of the subscription package.
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"mime"
+	"net/http"
+	"slices"
+
+	"github.com/gobwas/ws"
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/graphql-go-tools/execution/engine"
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/execution/subscription"
+	"github.com/wundergraph/graphql-go-tools/execution/subscription/websocket"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/graphqlerrors"
+)
+
+const (
+	httpHeaderAccept       = "Accept"
+	httpHeaderAllow        = "Allow"
+	httpHeaderCacheControl = "Cache-Control"
+	httpHeaderContentType  = "Content-Type"
+	httpHeaderUpgrade      = "Upgrade"
+
+	contentTypeJSON                = "application/json"
+	contentTypeGraphQLResponseJSON = "application/graphql-response+json"
+	contentTypeMultipartMixed      = "multipart/mixed"
+	contentTypeEventStream         = "text/event-stream"
+
+	// DefaultMaxRequestBodyBytes bounds the body of a POST request when
+	// Options.MaxRequestBodyBytes is not set.
+	DefaultMaxRequestBodyBytes = 1 << 20
+)
+
+// Options configures a Handler. The zero value is ready to use.
+type Options struct {
+	Logger abstractlogger.Logger
+	// MaxRequestBodyBytes bounds the body of a POST request. Zero means
+	// DefaultMaxRequestBodyBytes.
+	MaxRequestBodyBytes int64
+	// ExecutionOptions returns additional options to execute the operation of
+	// an HTTP request with, such as an authorizer for the caller.
+	ExecutionOptions func(r *http.Request) []engine.ExecutionOptions
+	// WebSocketUpgrader upgrades WebSocket requests. Nil means an upgrader
+	// that accepts the graphql-ws and graphql-transport-ws subprotocols.
+	WebSocketUpgrader *ws.HTTPUpgrader
+	// WebSocketOptions are passed on to websocket.Handle for every
+	// WebSocket connection.
+	WebSocketOptions []websocket.HandleOptionFunc
+}
+
+// Handler is an http.Handler executing GraphQL operations with an
+// ExecutionEngine.
+type Handler struct {
+	engine              *engine.ExecutionEngine
+	logger              abstractlogger.Logger
+	maxRequestBodyBytes int64
+	executionOptions    func(r *http.Request) []engine.ExecutionOptions
+	webSocketUpgrader   *ws.HTTPUpgrader
+	webSocketOptions    []websocket.HandleOptionFunc
+}
+
+// New returns a Handler executing operations with executionEngine.
+func New(executionEngine *engine.ExecutionEngine, options Options) *Handler {
+	h := &Handler{
+		engine:              executionEngine,
+		logger:              options.Logger,
+		maxRequestBodyBytes: options.MaxRequestBodyBytes,
+		executionOptions:    options.ExecutionOptions,
+		webSocketUpgrader:   options.WebSocketUpgrader,
+		webSocketOptions:    options.WebSocketOptions,
+	}
+	if h.logger == nil {
+		h.logger = abstractlogger.NoopLogger
+	}
+	if h.maxRequestBodyBytes <= 0 {
+		h.maxRequestBodyBytes = DefaultMaxRequestBodyBytes
+	}
+	if h.webSocketUpgrader == nil {
+		h.webSocketUpgrader = &ws.HTTPUpgrader{
+			Protocol: func(protocol string) bool {
+				return protocol == string(websocket.ProtocolGraphQLWS) || protocol == string(websocket.ProtocolGraphQLTransportWS)
+			},
+		}
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if slices.Contains(r.Header.Values(httpHeaderUpgrade), "websocket") {
+		h.serveWebSocket(w, r)
+		return
+	}
+
+	accepted := parseAccept(r.Header.Values(httpHeaderAccept))
+	contentType, ok := accepted.responseContentType()
+	if !ok {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	var (
+		request graphql.Request
+		options []engine.ExecutionOptions
+	)
+	switch r.Method {
+	case http.MethodGet:
+		if err := decodeGetRequest(r, &request); err != nil {
+			writeErrors(w, contentType, http.StatusBadRequest, graphqlerrors.RequestErrorsFromError(err))
+			return
+		}
+		options = append(options, engine.WithoutMutations())
+	case http.MethodPost:
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get(httpHeaderContentType)); err != nil || mediaType != contentTypeJSON {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		requests, batched, err := graphql.UnmarshalBatchRequest(http.MaxBytesReader(w, r.Body, h.maxRequestBodyBytes))
+		if err != nil {
+			writeErrors(w, contentType, decodeErrorStatus(err), graphqlerrors.RequestErrors{{Message: err.Error()}})
+			return
+		}
+		if batched {
+			h.serveBatch(w, r, contentType, requests)
+			return
+		}
+		request = *requests[0]
+	default:
+		w.Header().Set(httpHeaderAllow, "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	request.SetHeader(r.Header)
+	if h.executionOptions != nil {
+		options = append(options, h.executionOptions(r)...)
+	}
+
+	writer := newResponseWriter(w, &request, accepted)
+	err := h.engine.Execute(r.Context(), &request, writer, options...)
+	if writer.streaming() {
+		// The status is sent already, and the stream was terminated by the
+		// resolver; all that is left is to record why it ended early.
+		if err != nil {
+			h.logger.Error("handler.Handler.ServeHTTP: streaming response", abstractlogger.Error(err))
+		}
+		return
+	}
+	if err == nil {
+		err = writer.err
+	}
+	if err != nil {
+		h.writeExecutionError(w, contentType, err)
+		return
+	}
+
+	w.Header().Set(httpHeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(writer.buf.Bytes()); err != nil {
+		h.logger.Error("handler.Handler.ServeHTTP: writing response", abstractlogger.Error(err))
+	}
+}
+
+// serveBatch executes a batch of operations, a POST request with a JSON array
+// of requests, and answers with the array of their responses.
+func (h *Handler) serveBatch(w http.ResponseWriter, r *http.Request, contentType string, requests []*graphql.Request) {
+	var options []engine.ExecutionOptions
+	if h.executionOptions != nil {
+		options = h.executionOptions(r)
+	}
+	for _, request := range requests {
+		request.SetHeader(r.Header)
+	}
+
+	var buf bytes.Buffer
+	if err := h.engine.ExecuteBatch(r.Context(), requests, &buf, options...); err != nil {
+		if errors.Is(err, engine.ErrBatchTooLarge) {
+			writeErrors(w, contentType, http.StatusBadRequest, graphqlerrors.RequestErrors{{Message: err.Error()}})
+			return
+		}
+		h.writeExecutionError(w, contentType, err)
+		return
+	}
+
+	w.Header().Set(httpHeaderContentType, contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		h.logger.Error("handler.Handler.serveBatch: writing response", abstractlogger.Error(err))
+	}
+}
+
+// writeExecutionError answers a request the engine did not execute.
+//
+// A request error, one that is the client's to fix such as a validation
+// error, is answered with 400 Bad Request as application/graphql-response+json,
+// but with 200 OK as application/json, which legacy clients expect for every
+// well-formed request.
+func (h *Handler) writeExecutionError(w http.ResponseWriter, contentType string, err error) {
+	switch {
+	case errors.Is(err, errStreamNotAcceptable):
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	case errors.Is(err, engine.ErrMutationNotAllowed):
+		w.Header().Set(httpHeaderAllow, "POST")
+		writeErrors(w, contentType, http.StatusMethodNotAllowed, graphqlerrors.RequestErrors{{Message: err.Error()}})
+		return
+	}
+
+	requestErrors, ok := engine.RequestErrorsFrom(err)
+	if !ok {
+		h.logger.Error("handler.Handler.ServeHTTP: executing operation", abstractlogger.Error(err))
+		writeErrors(w, contentType, http.StatusInternalServerError, graphqlerrors.RequestErrors{{Message: http.StatusText(http.StatusInternalServerError)}})
+		return
+	}
+	status := http.StatusBadRequest
+	if contentType == contentTypeJSON {
+		status = http.StatusOK
+	}
+	writeErrors(w, contentType, status, requestErrors)
+}
+
+func decodeErrorStatus(err error) int {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+func writeErrors(w http.ResponseWriter, contentType string, status int, requestErrors graphqlerrors.RequestErrors) {
+	w.Header().Set(httpHeaderContentType, contentType)
+	w.WriteHeader(status)
+	_, _ = requestErrors.WriteResponse(w)
+}
+
+// serveWebSocket upgrades the connection and serves it until the client goes
+// away. The headers of the upgrade request are forwarded with every operation
+// sent over the connection.
+func (h *Handler) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, _, _, err := h.webSocketUpgrader.Upgrade(r, w)
+	if err != nil {
+		// the upgrader has answered the request already
+		h.logger.Error("handler.Handler.serveWebSocket: upgrading connection", abstractlogger.Error(err))
+		return
+	}
+
+	done := make(chan bool)
+	errChan := make(chan error)
+	executorPool := subscription.NewExecutorV2Pool(h.engine, subscription.NewInitialHttpRequestContext(r))
+	options := append([]websocket.HandleOptionFunc{
+		websocket.WithLogger(h.logger),
+		websocket.WithProtocolFromRequestHeaders(r),
+	}, h.webSocketOptions...)
+
+	go websocket.Handle(done, errChan, conn, executorPool, options...)
+	select {
+	case err := <-errChan:
+		h.logger.Error("handler.Handler.serveWebSocket: handling connection", abstractlogger.Error(err))
+	case <-done:
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/execution/engine"
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+const testSchema = `
+	directive @defer(label: String, if: Boolean! = true) on FRAGMENT_SPREAD | INLINE_FRAGMENT
+
+	type Query {
+		hello(name: String): String
+		slow: String
+	}
+
+	type Mutation {
+		setHello(value: String!): String
+	}
+
+	type Subscription {
+		counter: Int
+	}
+`
+
+// newTestUpstream answers every query and mutation with all root fields, and a
+// subscription with two events over Server-Sent Events.
+func newTestUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for i := 1; i <= 2; i++ {
+				_, _ = io.WriteString(w, "event: next\ndata: {\"data\":{\"counter\":"+string(rune('0'+i))+"}}\n\n")
+				w.(http.Flusher).Flush()
+			}
+			_, _ = io.WriteString(w, "event: complete\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"data":{"hello":"world","slow":"later","setHello":"ok"}}`)
+	}))
+	t.Cleanup(upstream.Close)
+	return upstream
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	schema, err := graphql.NewSchemaFromString(testSchema)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	upstream := newTestUpstream(t)
+	engineConfig, err := engine.NewProxyEngineConfigFactory(ctx, schema, engine.ProxyUpstreamConfig{
+		URL:              upstream.URL,
+		Method:           http.MethodPost,
+		SubscriptionType: engine.SubscriptionTypeSSE,
+	}).EngineConfiguration()
+	require.NoError(t, err)
+
+	executionEngine, err := engine.NewExecutionEngine(ctx, abstractlogger.NoopLogger, engineConfig, resolve.ResolverOptions{
+		MaxConcurrency: 1024,
+	})
+	require.NoError(t, err)
+
+	server := httptest.NewServer(New(executionEngine, Options{}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type testResponse struct {
+	status      int
+	contentType string
+	body        string
+	header      http.Header
+}
+
+func do(t *testing.T, method, target, contentType, accept, body string) testResponse {
+	t.Helper()
+
+	request, err := http.NewRequestWithContext(t.Context(), method, target, strings.NewReader(body))
+	require.NoError(t, err)
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		request.Header.Set("Accept", accept)
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	return testResponse{
+		status:      response.StatusCode,
+		contentType: response.Header.Get("Content-Type"),
+		body:        string(data),
+		header:      response.Header,
+	}
+}
+
+func TestHandler(t *testing.T) {
+	server := newTestServer(t)
+
+	post := func(t *testing.T, accept, body string) testResponse {
+		t.Helper()
+		return do(t, http.MethodPost, server.URL, "application/json", accept, body)
+	}
+
+	t.Run("a POST request is answered with application/graphql-response+json", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json, application/json;q=0.9", `{"query":"{ hello }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, "application/graphql-response+json", response.contentType)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, response.body)
+	})
+
+	t.Run("application/json is used when preferred or nothing is asked for", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json;q=0.5, application/json", `{"query":"{ hello }"}`)
+		assert.Equal(t, "application/json", response.contentType)
+
+		response = post(t, "", `{"query":"{ hello }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, "application/json", response.contentType)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, response.body)
+	})
+
+	t.Run("a GET request reads the operation from the query parameters", func(t *testing.T) {
+		query := url.Values{"query": {"query Hello($v: String) { hello(name: $v) }"}, "variables": {`{"v":"x"}`}}
+		response := do(t, http.MethodGet, server.URL+"?"+query.Encode(), "", "application/graphql-response+json", "")
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, `{"data":{"hello":"world"}}`, response.body)
+	})
+
+	t.Run("a mutation in a GET request is not allowed", func(t *testing.T) {
+		query := url.Values{"query": {`mutation { setHello(value: "x") }`}}
+		response := do(t, http.MethodGet, server.URL+"?"+query.Encode(), "", "application/graphql-response+json", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, response.status)
+		assert.Equal(t, "POST", response.header.Get("Allow"))
+
+		response = post(t, "application/graphql-response+json", `{"query":"mutation { setHello(value: \"x\") }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, `{"data":{"setHello":"ok"}}`, response.body)
+	})
+
+	t.Run("a request error is a bad request for application/graphql-response+json only", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json", `{"query":"{ goodbye }"}`)
+		assert.Equal(t, http.StatusBadRequest, response.status)
+		assert.Contains(t, response.body, `"errors"`)
+
+		response = post(t, "application/json", `{"query":"{ goodbye }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Contains(t, response.body, `"errors"`)
+	})
+
+	t.Run("an unsupported persisted query version is a request error", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json", `{"query":"{ hello }","extensions":{"persistedQuery":{"version":2,"sha256Hash":"abc"}}}`)
+		assert.Equal(t, http.StatusBadRequest, response.status)
+		assert.Equal(t, `{"errors":[{"message":"unsupported persisted query version"}]}`, response.body)
+	})
+
+	t.Run("malformed extensions are a request error", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json", `{"query":"{ hello }","extensions":{"persistedQuery":"abc"}}`)
+		assert.Equal(t, http.StatusBadRequest, response.status)
+		assert.Contains(t, response.body, `{"errors":[{"message":"the provided extensions are invalid: `)
+	})
+
+	t.Run("a batch is answered with the responses in order", func(t *testing.T) {
+		response := post(t, "application/graphql-response+json", `[{"query":"{ hello }"},{"query":"{ goodbye }"},{"query":"{ slow }"}]`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, `[`+
+			`{"data":{"hello":"world"}},`+
+			`{"errors":[{"message":"Cannot query field \"goodbye\" on type \"Query\".","path":["query"]}]},`+
+			`{"data":{"slow":"later"}}`+
+			`]`, response.body)
+	})
+
+	t.Run("a body that is not a request is a bad request", func(t *testing.T) {
+		response := post(t, "application/json", `{"query":`)
+		assert.Equal(t, http.StatusBadRequest, response.status)
+	})
+
+	t.Run("unsupported methods, media types and accept headers are rejected", func(t *testing.T) {
+		response := do(t, http.MethodPut, server.URL, "application/json", "", `{"query":"{ hello }"}`)
+		assert.Equal(t, http.StatusMethodNotAllowed, response.status)
+		assert.Equal(t, "GET, POST", response.header.Get("Allow"))
+
+		response = do(t, http.MethodPost, server.URL, "text/plain", "", `{"query":"{ hello }"}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, response.status)
+
+		response = post(t, "text/html", `{"query":"{ hello }"}`)
+		assert.Equal(t, http.StatusNotAcceptable, response.status)
+	})
+
+	t.Run("a deferred fragment is sent as a part of a multipart response", func(t *testing.T) {
+		response := post(t, "multipart/mixed;deferSpec=20220824, application/json", `{"query":"{ hello ... @defer { slow } }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, `multipart/mixed; boundary="-"`, response.contentType)
+		assert.Equal(t, "\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"data":{"hello":"world"},"pending":[{"id":"1","path":[]}],"hasNext":true}`+
+			"\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"+
+			`{"incremental":[{"data":{"slow":"later"},"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`+
+			"\r\n-----\r\n", response.body)
+	})
+
+	t.Run("a deferred fragment needs a client that accepts a stream", func(t *testing.T) {
+		response := post(t, "application/json", `{"query":"{ hello ... @defer { slow } }"}`)
+		assert.Equal(t, http.StatusNotAcceptable, response.status)
+	})
+
+	t.Run("a subscription is sent as server-sent events", func(t *testing.T) {
+		response := post(t, "text/event-stream", `{"query":"subscription { counter }"}`)
+		assert.Equal(t, http.StatusOK, response.status)
+		assert.Equal(t, "text/event-stream", response.contentType)
+		assert.Equal(t, "event: next\ndata: {\"data\":{\"counter\":1}}\n\n"+
+			"event: next\ndata: {\"data\":{\"counter\":2}}\n\n"+
+			"event: complete\ndata: \n\n", response.body)
+	})
+
+	t.Run("a subscription over a websocket", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		dialer := ws.Dialer{Protocols: []string{"graphql-transport-ws"}}
+		conn, _, _, err := dialer.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"))
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"type":"connection_init"}`)))
+		message, err := wsutil.ReadServerText(conn)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type":"connection_ack"}`, string(message))
+
+		require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"1","type":"subscribe","payload":{"query":"subscription { counter }"}}`)))
+		message, err = wsutil.ReadServerText(conn)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"id":"1","type":"next","payload":{"data":{"counter":1}}}`, string(message))
+	})
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+)
+
+var errInvalidQueryParameter = errors.New("the variables and extensions query parameters must be JSON objects")
+
+// decodeGetRequest reads the operation of a GET request from its query
+// parameters. The variables and extensions parameters hold JSON.
+func decodeGetRequest(r *http.Request, request *graphql.Request) error {
+	query := r.URL.Query()
+	request.Query = query.Get("query")
+	request.OperationName = query.Get("operationName")
+	if variables := query.Get("variables"); variables != "" {
+		if !json.Valid([]byte(variables)) {
+			return errInvalidQueryParameter
+		}
+		request.Variables = json.RawMessage(variables)
+	}
+	if extensions := query.Get("extensions"); extensions != "" {
+		if !json.Valid([]byte(extensions)) {
+			return errInvalidQueryParameter
+		}
+		request.Extensions = json.RawMessage(extensions)
+	}
+	if request.Query == "" && request.Extensions == nil {
+		return graphql.ErrEmptyRequest
+	}
+	return nil
+}
+
+// acceptedMediaTypes is what the Accept header of a request allows for the
+// response. The JSON media types carry their quality, zero when not accepted.
+type acceptedMediaTypes struct {
+	graphQLResponseJSON float64
+	json                float64
+	multipartMixed      bool
+	eventStream         bool
+}
+
+// parseAccept reads the Accept header values. A request without any is taken
+// to accept application/json, like the legacy clients that send none.
+func parseAccept(values []string) acceptedMediaTypes {
+	var accepted acceptedMediaTypes
+	if len(values) == 0 {
+		accepted.json = 1
+		return accepted
+	}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			quality := 1.0
+			if q, ok := params["q"]; ok {
+				if quality, err = strconv.ParseFloat(q, 64); err != nil {
+					continue
+				}
+			}
+			if quality <= 0 {
+				continue
+			}
+			switch mediaType {
+			case contentTypeGraphQLResponseJSON, "*/*", "application/*":
+				accepted.graphQLResponseJSON = max(accepted.graphQLResponseJSON, quality)
+			case contentTypeJSON:
+				accepted.json = max(accepted.json, quality)
+			case contentTypeMultipartMixed:
+				accepted.multipartMixed = true
+			case contentTypeEventStream:
+				accepted.eventStream = true
+			}
+		}
+	}
+	return accepted
+}
+
+// responseContentType returns the media type of a single response, which
+// errors are sent as even to a client that only accepts a stream. It prefers
+// application/graphql-response+json unless application/json has the higher
+// quality, and returns false when the client accepts neither nor a stream.
+func (a acceptedMediaTypes) responseContentType() (string, bool) {
+	switch {
+	case a.graphQLResponseJSON > 0 && a.graphQLResponseJSON >= a.json:
+		return contentTypeGraphQLResponseJSON, true
+	case a.json > 0, a.multipartMixed, a.eventStream:
+		return contentTypeJSON, true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/wundergraph/graphql-go-tools/execution/graphql"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var errStreamNotAcceptable = errors.New("the response has to be streamed, but the request accepts neither multipart/mixed nor text/event-stream")
+
+var (
+	multipartContentType = `multipart/mixed; boundary="-"`
+	multipartPartHeader  = []byte("\r\n---\r\nContent-Type: application/json; charset=utf-8\r\n\r\n")
+	multipartEnd         = []byte("\r\n-----\r\n")
+
+	eventStreamNext      = []byte("event: next\ndata: ")
+	eventStreamEnd       = []byte("\n\n")
+	eventStreamComplete  = []byte("event: complete\ndata: \n\n")
+	eventStreamHeartbeat = []byte(":\n\n")
+)
+
+type transport int
+
+const (
+	// transportSingle sends the response as one JSON document once the
+	// operation is executed.
+	transportSingle transport = iota
+	// transportMultipart sends every payload of an incremental response as a
+	// part of a multipart/mixed response.
+	transportMultipart
+	// transportEventStream sends every payload as a next event of a
+	// text/event-stream response.
+	transportEventStream
+)
+
+// responseWriter is the resolve.SubscriptionResponseWriter the engine writes a
+// response to. Everything is buffered until the resolver flushes a payload:
+// a query or mutation is never flushed and sent as one JSON document by the
+// handler, while the first flush of a subscription or an incremental response
+// picks the transport to stream it with.
+type responseWriter struct {
+	w        http.ResponseWriter
+	rc       *http.ResponseController
+	request  *graphql.Request
+	accepted acceptedMediaTypes
+
+	buf       bytes.Buffer
+	transport transport
+	// held is set when an incremental response flushed its first payload to a
+	// client that accepts no stream. The payload is sent as a single response
+	// if it turns out to be the only one.
+	held bool
+	// err is the reason the response could not be written.
+	err error
+}
+
+var _ resolve.SubscriptionResponseWriter = (*responseWriter)(nil)
+
+func newResponseWriter(w http.ResponseWriter, request *graphql.Request, accepted acceptedMediaTypes) *responseWriter {
+	return &responseWriter{
+		w:        w,
+		rc:       http.NewResponseController(w),
+		request:  request,
+		accepted: accepted,
+	}
+}
+
+func (rw *responseWriter) streaming() bool {
+	return rw.transport != transportSingle
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	return rw.buf.Write(p)
+}
+
+func (rw *responseWriter) Flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if rw.transport == transportSingle {
+		if rw.held {
+			rw.err = errStreamNotAcceptable
+			return rw.err
+		}
+		rw.transport = rw.streamTransport()
+		switch rw.transport {
+		case transportSingle:
+			if rw.err != nil {
+				return rw.err
+			}
+			// not a stream as far as the client knows, unless another payload follows
+			rw.held = true
+			return nil
+		case transportMultipart:
+			rw.w.Header().Set(httpHeaderContentType, multipartContentType)
+		case transportEventStream:
+			rw.w.Header().Set(httpHeaderContentType, contentTypeEventStream)
+			rw.w.Header().Set(httpHeaderCacheControl, "no-cache")
+		}
+		rw.w.WriteHeader(http.StatusOK)
+	}
+
+	switch rw.transport {
+	case transportMultipart:
+		rw.write(multipartPartHeader)
+		rw.write(rw.buf.Bytes())
+	case transportEventStream:
+		rw.write(eventStreamNext)
+		rw.write(rw.buf.Bytes())
+		rw.write(eventStreamEnd)
+	}
+	rw.buf.Reset()
+	return rw.flush()
+}
+
+// streamTransport picks the transport for a response that is flushed, or
+// keeps transportSingle for an incremental response to a client that accepts
+// no stream. A subscription is streamed as events only.
+func (rw *responseWriter) streamTransport() transport {
+	operationType, _ := rw.request.OperationType()
+	switch {
+	case operationType == graphql.OperationTypeSubscription && rw.accepted.eventStream:
+		return transportEventStream
+	case operationType == graphql.OperationTypeSubscription:
+		rw.err = errStreamNotAcceptable
+		return transportSingle
+	case rw.accepted.multipartMixed:
+		return transportMultipart
+	case rw.accepted.eventStream:
+		return transportEventStream
+	default:
+		return transportSingle
+	}
+}
+
+func (rw *responseWriter) Complete() {
+	switch rw.transport {
+	case transportMultipart:
+		rw.write(multipartEnd)
+	case transportEventStream:
+		rw.write(eventStreamComplete)
+	default:
+		return
+	}
+	_ = rw.flush()
+}
+
+func (rw *responseWriter) Heartbeat() error {
+	if rw.transport != transportEventStream {
+		return nil
+	}
+	rw.write(eventStreamHeartbeat)
+	return rw.flush()
+}
+
+// Error writes a payload with the errors a subscription ended with.
+func (rw *responseWriter) Error(data []byte) {
+	rw.buf.Write(data)
+	_ = rw.Flush()
+}
+
+func (rw *responseWriter) write(p []byte) {
+	if rw.err != nil {
+		return
+	}
+	_, rw.err = rw.w.Write(p)
+}
+
+func (rw *responseWriter) flush() error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if err := rw.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		rw.err = err
+	}
+	return rw.err
+}
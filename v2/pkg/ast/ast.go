@@ -177,6 +177,95 @@ func (d *Document) Reset() {
 	d.OnMergeFields = nil
 }
 
+// CloneOperation returns a deep copy of the executable definitions of d,
+// which can be modified, e.g. by the planner, without changing d.
+// Type system definitions are not copied.
+func (d *Document) CloneOperation() *Document {
+	c := &Document{
+		Input: Input{
+			RawBytes:      slices.Clone(d.Input.RawBytes),
+			Length:        d.Input.Length,
+			InputPosition: d.Input.InputPosition,
+			TextPosition:  d.Input.TextPosition,
+			Variables:     slices.Clone(d.Input.Variables),
+		},
+		RootNodes:            slices.Clone(d.RootNodes),
+		Directives:           slices.Clone(d.Directives),
+		Arguments:            slices.Clone(d.Arguments),
+		Types:                slices.Clone(d.Types),
+		Values:               slices.Clone(d.Values),
+		ListValues:           slices.Clone(d.ListValues),
+		VariableValues:       slices.Clone(d.VariableValues),
+		StringValues:         slices.Clone(d.StringValues),
+		IntValues:            slices.Clone(d.IntValues),
+		FloatValues:          slices.Clone(d.FloatValues),
+		EnumValues:           slices.Clone(d.EnumValues),
+		ObjectFields:         slices.Clone(d.ObjectFields),
+		ObjectValues:         slices.Clone(d.ObjectValues),
+		Selections:           slices.Clone(d.Selections),
+		SelectionSets:        slices.Clone(d.SelectionSets),
+		Fields:               slices.Clone(d.Fields),
+		InlineFragments:      slices.Clone(d.InlineFragments),
+		FragmentSpreads:      slices.Clone(d.FragmentSpreads),
+		OperationDefinitions: slices.Clone(d.OperationDefinitions),
+		VariableDefinitions:  slices.Clone(d.VariableDefinitions),
+		FragmentDefinitions:  slices.Clone(d.FragmentDefinitions),
+		BooleanValues:        d.BooleanValues,
+		Refs:                 slices.Clone(d.Refs),
+		RefIndex:             d.RefIndex,
+		Index: Index{
+			QueryTypeName:           slices.Clone(d.Index.QueryTypeName),
+			MutationTypeName:        slices.Clone(d.Index.MutationTypeName),
+			SubscriptionTypeName:    slices.Clone(d.Index.SubscriptionTypeName),
+			nodes:                   make(map[uint64][]Node, len(d.Index.nodes)),
+			ReplacedFragmentSpreads: slices.Clone(d.Index.ReplacedFragmentSpreads),
+			MergedTypeExtensions:    slices.Clone(d.Index.MergedTypeExtensions),
+		},
+	}
+	for hash, nodes := range d.Index.nodes {
+		c.Index.nodes[hash] = slices.Clone(nodes)
+	}
+
+	// the ref lists of the nodes share their backing arrays with d
+	for i := range c.Directives {
+		c.Directives[i].Arguments.Refs = slices.Clone(c.Directives[i].Arguments.Refs)
+	}
+	for i := range c.Arguments {
+		c.Arguments[i].PrintBeforeValue = slices.Clone(c.Arguments[i].PrintBeforeValue)
+		c.Arguments[i].PrintAfterValue = slices.Clone(c.Arguments[i].PrintAfterValue)
+	}
+	for i := range c.ListValues {
+		c.ListValues[i].Refs = slices.Clone(c.ListValues[i].Refs)
+	}
+	for i := range c.ObjectValues {
+		c.ObjectValues[i].Refs = slices.Clone(c.ObjectValues[i].Refs)
+	}
+	for i := range c.SelectionSets {
+		c.SelectionSets[i].SelectionRefs = slices.Clone(c.SelectionSets[i].SelectionRefs)
+	}
+	for i := range c.Fields {
+		c.Fields[i].Arguments.Refs = slices.Clone(c.Fields[i].Arguments.Refs)
+		c.Fields[i].Directives.Refs = slices.Clone(c.Fields[i].Directives.Refs)
+	}
+	for i := range c.InlineFragments {
+		c.InlineFragments[i].Directives.Refs = slices.Clone(c.InlineFragments[i].Directives.Refs)
+	}
+	for i := range c.FragmentSpreads {
+		c.FragmentSpreads[i].Directives.Refs = slices.Clone(c.FragmentSpreads[i].Directives.Refs)
+	}
+	for i := range c.OperationDefinitions {
+		c.OperationDefinitions[i].VariableDefinitions.Refs = slices.Clone(c.OperationDefinitions[i].VariableDefinitions.Refs)
+		c.OperationDefinitions[i].Directives.Refs = slices.Clone(c.OperationDefinitions[i].Directives.Refs)
+	}
+	for i := range c.VariableDefinitions {
+		c.VariableDefinitions[i].Directives.Refs = slices.Clone(c.VariableDefinitions[i].Directives.Refs)
+	}
+	for i := range c.FragmentDefinitions {
+		c.FragmentDefinitions[i].Directives.Refs = slices.Clone(c.FragmentDefinitions[i].Directives.Refs)
+	}
+	return c
+}
+
 func (d *Document) NextRefIndex() int {
 	d.RefIndex++
 	if d.RefIndex == len(d.Refs) {
@@ -247,6 +247,42 @@ fragment namedFragment on SomeType {
 	assert.Equal(t, expected, out)
 }
 
+func TestDocument_CloneOperation(t *testing.T) {
+	doc, report := astparser.ParseGraphqlDocumentString(`
+		query testQuery($someVariable: String!) @operationDirective {
+			user(id: $someVariable) {
+				listArgField(arg: [1, 2])
+				objectArgField(arg: {key: "value"})
+				... on SomeType @include(if: true) {
+					inlineFragmentField
+				}
+			}
+		}`)
+	assert.False(t, report.HasErrors())
+	original, err := astprinter.PrintString(&doc)
+	assert.NoError(t, err)
+
+	clone := doc.CloneOperation()
+	for ref := range clone.Fields {
+		clone.Fields[ref].Arguments.Refs = clone.Fields[ref].Arguments.Refs[:0]
+		clone.Fields[ref].HasArguments = false
+		if clone.Fields[ref].HasSelections {
+			set := clone.Fields[ref].SelectionSet
+			clone.SelectionSets[set].SelectionRefs = clone.SelectionSets[set].SelectionRefs[:1]
+		}
+	}
+	clone.AddSelectionToDocument(ast.Selection{Kind: ast.SelectionKindField, Ref: clone.AddField(ast.Field{Name: clone.Input.AppendInputString("added")}).Ref})
+	clone.AddSelection(clone.OperationDefinitions[0].SelectionSet, clone.Selections[len(clone.Selections)-1])
+
+	out, err := astprinter.PrintString(&doc)
+	assert.NoError(t, err)
+	assert.Equal(t, original, out)
+
+	out, err = astprinter.PrintString(clone)
+	assert.NoError(t, err)
+	assert.Equal(t, `query testQuery($someVariable: String!)@operationDirective {user {listArgField} added}`, out)
+}
+
 func TestKinds(t *testing.T) {
 	expectedArray := func(start, count int) (out []int) {
 		for i := start; i < start+count; i++ {
@@ -79,3 +79,19 @@ func (d *Document) GetVariableBooleanValue(name string) (value, valid bool) {
 	}
 	return false, false
 }
+
+func (d *Document) GetVariableIntValue(name string) (value int, valid bool) {
+	val, err := jsonparser.GetInt(d.Input.Variables, name)
+	if err == nil {
+		return int(val), true
+	}
+	for i := range d.VariableDefinitions {
+		definitionName := d.VariableDefinitionNameString(i)
+		if definitionName == name {
+			if d.VariableDefinitions[i].DefaultValue.IsDefined && d.VariableDefinitions[i].DefaultValue.Value.Kind == ValueKindInteger {
+				return int(d.IntValueAsInt(d.VariableDefinitions[i].DefaultValue.Value.Ref)), true
+			}
+		}
+	}
+	return 0, false
+}
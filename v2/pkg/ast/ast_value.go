@@ -371,3 +371,14 @@ func (d *Document) GetBooleanValue(value Value) (out, valid bool) {
 		return false, false
 	}
 }
+
+func (d *Document) GetIntValue(value Value) (out int, valid bool) {
+	switch value.Kind {
+	case ValueKindInteger:
+		return int(d.IntValueAsInt(value.Ref)), true
+	case ValueKindVariable:
+		return d.GetVariableIntValue(d.VariableValueNameString(value.Ref))
+	default:
+		return 0, false
+	}
+}
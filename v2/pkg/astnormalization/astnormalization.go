@@ -288,8 +288,9 @@ func (o *OperationNormalizer) setupOperationWalkers() {
 
 	inlineDefer := astvisitor.NewWalkerWithID(8, "Inline defer")
 	o.inlineDeferVisitor = deferExpandIntoInternalWithDisabled(&inlineDefer, !o.options.enableDefer)
+	streamInlineArguments(&inlineDefer)
 	o.operationWalkers = append(o.operationWalkers, walkerStage{
-		name:   "inlineDefer",
+		name:   "inlineDefer, streamInlineArguments",
 		walker: &inlineDefer,
 	})
 
@@ -504,6 +505,28 @@ func (v *VariablesNormalizer) NormalizeOperation(operation, definition *ast.Docu
 	return v.variablesExtractionVisitor.uploadsPath
 }
 
+// VariablesCoercion processes the variables of an operation normalized with
+// extracted variables the way normalization does: list values are coerced and
+// the default values of input fields are injected. It allows an operation
+// normalized for one request to be reused with the variables of another.
+type VariablesCoercion struct {
+	walker *astvisitor.Walker
+}
+
+func NewVariablesCoercion() *VariablesCoercion {
+	walker := astvisitor.NewWalkerWithID(8, "VariablesCoercion")
+	inputCoercionForList(&walker)
+	injectInputFieldDefaults(&walker)
+	return &VariablesCoercion{
+		walker: &walker,
+	}
+}
+
+// CoerceVariables processes operation.Input.Variables.
+func (v *VariablesCoercion) CoerceVariables(operation, definition *ast.Document, report *operationreport.Report) {
+	v.walker.Walk(operation, definition, report)
+}
+
 type fragmentCycleVisitor struct {
 	*astvisitor.Walker
 
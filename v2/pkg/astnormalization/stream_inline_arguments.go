@@ -0,0 +1,80 @@
+package astnormalization
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/lexer/literal"
+)
+
+// streamInlineArguments registers a visitor that
+// inlines the variable values of the if and initialCount arguments of @stream,
+// so that the normalized operation, and with it the plan, reflects them
+func streamInlineArguments(walker *astvisitor.Walker) {
+	visitor := streamInlineArgumentsVisitor{
+		Walker: walker,
+	}
+	walker.RegisterEnterDocumentVisitor(&visitor)
+	walker.RegisterEnterFieldVisitor(&visitor)
+}
+
+type streamInlineArgumentsVisitor struct {
+	*astvisitor.Walker
+
+	operation *ast.Document
+}
+
+func (s *streamInlineArgumentsVisitor) EnterDocument(operation, _ *ast.Document) {
+	s.operation = operation
+}
+
+func (s *streamInlineArgumentsVisitor) EnterField(ref int) {
+	// inline arguments only on operation fields
+	// this rule runs after fragments were inlined, but before they removed
+	if len(s.Walker.Ancestors) > 0 && s.Walker.Ancestors[0].Kind == ast.NodeKindFragmentDefinition {
+		return
+	}
+
+	directiveRef, exists := s.operation.Fields[ref].Directives.HasDirectiveByNameBytes(s.operation, literal.STREAM)
+	if !exists {
+		return
+	}
+
+	argumentRefs := s.operation.Directives[directiveRef].Arguments.Refs
+	inlined := make([]int, 0, len(argumentRefs))
+	for _, argumentRef := range argumentRefs {
+		value := s.operation.ArgumentValue(argumentRef)
+		if value.Kind != ast.ValueKindVariable {
+			inlined = append(inlined, argumentRef)
+			continue
+		}
+
+		switch name := s.operation.ArgumentNameBytes(argumentRef); {
+		case bytes.Equal(name, literal.IF):
+			// the same as for defer, a stream without a valid if value is disabled
+			enabled, valid := s.operation.GetBooleanValue(value)
+			if !valid || !enabled {
+				s.operation.RemoveDirectiveFromNode(ast.Node{Kind: ast.NodeKindField, Ref: ref}, directiveRef)
+				return
+			}
+			// an enabled stream needs no if argument
+		case bytes.Equal(name, literal.INITIAL_COUNT):
+			count, valid := s.operation.GetIntValue(value)
+			if !valid {
+				// keep the variable, so that validation reports its value
+				inlined = append(inlined, argumentRef)
+				continue
+			}
+			// a negative count is kept, so that validation reports it
+			intValueRef := s.operation.ImportIntValue([]byte(strconv.Itoa(max(count, -count))), count < 0)
+			inlined = append(inlined, s.operation.ImportArgument(string(literal.INITIAL_COUNT), ast.Value{Kind: ast.ValueKindInteger, Ref: intValueRef}))
+		default:
+			inlined = append(inlined, argumentRef)
+		}
+	}
+
+	s.operation.Directives[directiveRef].Arguments.Refs = inlined
+	s.operation.Directives[directiveRef].HasArguments = len(inlined) > 0
+}
@@ -0,0 +1,116 @@
+package astnormalization
+
+import (
+	"testing"
+)
+
+func TestStreamInlineArguments(t *testing.T) {
+	t.Run("literal arguments are kept", func(t *testing.T) {
+		run(t, streamInlineArguments, testDefinition, `
+					query dog {
+						dog {
+							extras @stream(initialCount: 1, if: true) {
+								string
+							}
+						}
+					}`,
+			`
+					query dog {
+						dog {
+							extras @stream(initialCount: 1, if: true) {
+								string
+							}
+						}
+					}`)
+	})
+	t.Run("variable arguments are inlined", func(t *testing.T) {
+		runWithVariables(t, streamInlineArguments, testDefinition, `
+					query dog($count: Int!, $stream: Boolean!) {
+						dog {
+							extras @stream(initialCount: $count, if: $stream, label: "extras") {
+								string
+							}
+						}
+					}`,
+			`
+					query dog($count: Int!, $stream: Boolean!) {
+						dog {
+							extras @stream(initialCount: 2, label: "extras") {
+								string
+							}
+						}
+					}`, `{"count": 2, "stream": true}`)
+	})
+	t.Run("negative count is inlined", func(t *testing.T) {
+		runWithVariables(t, streamInlineArguments, testDefinition, `
+					query dog($count: Int!) {
+						dog {
+							extras @stream(initialCount: $count) {
+								string
+							}
+						}
+					}`,
+			`
+					query dog($count: Int!) {
+						dog {
+							extras @stream(initialCount: -1) {
+								string
+							}
+						}
+					}`, `{"count": -1}`)
+	})
+	t.Run("count without a variable value is kept", func(t *testing.T) {
+		runWithVariables(t, streamInlineArguments, testDefinition, `
+					query dog($count: Int!) {
+						dog {
+							extras @stream(initialCount: $count) {
+								string
+							}
+						}
+					}`,
+			`
+					query dog($count: Int!) {
+						dog {
+							extras @stream(initialCount: $count) {
+								string
+							}
+						}
+					}`, `{"count": "two"}`)
+	})
+	t.Run("stream disabled via variable is removed", func(t *testing.T) {
+		runWithVariables(t, streamInlineArguments, testDefinition, `
+					query dog($count: Int!, $stream: Boolean!) {
+						dog {
+							extras @stream(initialCount: $count, if: $stream) {
+								string
+							}
+						}
+					}`,
+			`
+					query dog($count: Int!, $stream: Boolean!) {
+						dog {
+							extras {
+								string
+							}
+						}
+					}`, `{"count": 2, "stream": false}`)
+	})
+	t.Run("stream disabled without variable value is removed", func(t *testing.T) {
+		run(t, streamInlineArguments, testDefinition, `
+					query dog($stream: Boolean!) {
+						dog {
+							extras @stream(if: $stream) {
+								string
+							}
+						}
+					}`,
+			`
+					query dog($stream: Boolean!) {
+						dog {
+							extras {
+								string
+							}
+						}
+					}`)
+	})
+}
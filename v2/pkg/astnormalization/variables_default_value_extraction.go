@@ -102,19 +102,11 @@ func (v *variablesDefaultValueExtractionVisitor) EnterVariableDefinition(ref int
 		return
 	}
 
-	valueBytes, err := v.operation.ValueToJSON(v.operation.VariableDefinitionDefaultValue(ref))
+	valueBytes, err := VariableDefaultValue(v.operation, ref)
 	if err != nil {
 		return
 	}
 
-	isListVariable := v.operation.TypeIsList(v.operation.VariableDefinitions[ref].Type)
-	if isListVariable && len(valueBytes) > 0 && valueBytes[0] != '[' {
-		listWraps := v.operation.TypeNumberOfListWraps(v.operation.VariableDefinitions[ref].Type)
-		for range listWraps {
-			valueBytes = append([]byte{'['}, append(valueBytes, ']')...)
-		}
-	}
-
 	v.operation.Input.Variables, err = sjson.SetRawBytes(v.operation.Input.Variables, variableName, valueBytes)
 	if err != nil {
 		v.StopWithInternalErr(err)
@@ -122,6 +114,25 @@ func (v *variablesDefaultValueExtractionVisitor) EnterVariableDefinition(ref int
 	}
 }
 
+// VariableDefaultValue returns the default value of the variable definition as
+// JSON, wrapped in lists for a list variable, the way normalization extracts it
+// into the variables when the variable has no value.
+func VariableDefaultValue(operation *ast.Document, ref int) ([]byte, error) {
+	valueBytes, err := operation.ValueToJSON(operation.VariableDefinitionDefaultValue(ref))
+	if err != nil {
+		return nil, err
+	}
+
+	isListVariable := operation.TypeIsList(operation.VariableDefinitions[ref].Type)
+	if isListVariable && len(valueBytes) > 0 && valueBytes[0] != '[' {
+		listWraps := operation.TypeNumberOfListWraps(operation.VariableDefinitions[ref].Type)
+		for range listWraps {
+			valueBytes = append([]byte{'['}, append(valueBytes, ']')...)
+		}
+	}
+	return valueBytes, nil
+}
+
 func (v *variablesDefaultValueExtractionVisitor) EnterOperationDefinition(ref int) {
 	v.operationRef = ref
 }
@@ -41,6 +41,15 @@ type Cache interface {
 	SetMany(ctx context.Context, items []Item) error
 }
 
+// Deleter is implemented by a Cache that can remove entries before their TTL
+// runs out. It is optional: entity invalidation requires it, and everything
+// else works with a Cache that only expires entries.
+type Deleter interface {
+	// Delete removes every key that is present. A key that is not is skipped,
+	// not an error.
+	Delete(ctx context.Context, keys []string) error
+}
+
 var (
 	ErrMissingTTL = errors.New("cache item requires a positive TTL")
 	ErrNoKeys     = errors.New("response cache lookup requires at least one key")
@@ -44,3 +44,33 @@ func TTL(headers http.Header, defaultTTL time.Duration) (time.Duration, bool) {
 	}
 	return defaultTTL, true
 }
+
+// HintedTTL is TTL for a response whose fields carry a @cacheControl hint of
+// maxAge. The hint stands in for public, so the response is cached without
+// it, but the subgraph's headers can still shorten the lifetime or refuse
+// caching altogether.
+func HintedTTL(headers http.Header, maxAge time.Duration) (time.Duration, bool) {
+	if maxAge <= 0 {
+		return 0, false
+	}
+
+	cc, err := cache.ParseCacheControlResponse(headers)
+	if err != nil {
+		return 0, false
+	}
+	if cc.NoStore || cc.NoCache != nil || cc.Private != nil {
+		return 0, false
+	}
+
+	lifetime := cc.MaxAge
+	if cc.SMaxAge != nil {
+		lifetime = cc.SMaxAge
+	}
+	if lifetime != nil {
+		if *lifetime <= 0 {
+			return 0, false
+		}
+		maxAge = min(maxAge, lifetime.AsDuration())
+	}
+	return maxAge, true
+}
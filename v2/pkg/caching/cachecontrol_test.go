@@ -62,3 +62,46 @@ func TestTTL(t *testing.T) {
 		})
 	}
 }
+
+func TestHintedTTL(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		cacheControl string
+		maxAge       time.Duration
+		wantTTL      time.Duration
+		wantOK       bool
+	}{
+		// The hint is the opt-in, so public is not needed.
+		{name: "hint alone", cacheControl: "", maxAge: time.Minute, wantTTL: time.Minute, wantOK: true},
+		{name: "hint with public", cacheControl: "public", maxAge: time.Minute, wantTTL: time.Minute, wantOK: true},
+
+		// The shorter of the hint and the header lifetime wins.
+		{name: "max-age shortens the hint", cacheControl: "max-age=30", maxAge: time.Minute, wantTTL: 30 * time.Second, wantOK: true},
+		{name: "max-age does not lengthen the hint", cacheControl: "max-age=3600", maxAge: time.Minute, wantTTL: time.Minute, wantOK: true},
+		{name: "s-maxage outranks max-age", cacheControl: "s-maxage=10, max-age=30", maxAge: time.Minute, wantTTL: 10 * time.Second, wantOK: true},
+
+		// Refusals from the subgraph still win.
+		{name: "no-store", cacheControl: "no-store", maxAge: time.Minute, wantOK: false},
+		{name: "no-cache", cacheControl: "no-cache", maxAge: time.Minute, wantOK: false},
+		{name: "private", cacheControl: "private", maxAge: time.Minute, wantOK: false},
+		{name: "zero max-age", cacheControl: "max-age=0", maxAge: time.Minute, wantOK: false},
+
+		// A hint of zero is a refusal of its own.
+		{name: "zero hint", cacheControl: "public, max-age=60", maxAge: 0, wantOK: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tc.cacheControl != "" {
+				headers.Set("Cache-Control", tc.cacheControl)
+			}
+
+			ttl, ok := HintedTTL(headers, tc.maxAge)
+			if ok != tc.wantOK {
+				t.Fatalf("HintedTTL(%q, %s) ok = %v, want %v", tc.cacheControl, tc.maxAge, ok, tc.wantOK)
+			}
+			if ok && ttl != tc.wantTTL {
+				t.Fatalf("HintedTTL(%q, %s) = %s, want %s", tc.cacheControl, tc.maxAge, ttl, tc.wantTTL)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package caching
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/wundergraph/astjson"
+)
+
+var ErrInvalidRepresentation = errors.New("entity representation must be a JSON object with a __typename")
+
+// EntityHash returns the typename of an entity representation, as sent to a
+// subgraph in the representations of an _entities fetch, along with the hash
+// that identifies the entity in its cache keys. The hash is taken over the
+// representation with the fields of every object sorted, so it does not
+// depend on the order the fields were rendered in, and EntityKeyHash arrives
+// at the same one from a typename and the fields of a @key.
+func EntityHash(representation []byte) (typeName string, hash uint64, err error) {
+	value, err := astjson.ParseBytes(representation)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrInvalidRepresentation, err)
+	}
+	return entityHash(value)
+}
+
+// EntityKeyHash is EntityHash for the entity of the given typename whose @key
+// fields are the JSON object key, e.g. {"upc":"1"}. It only matches the hash
+// of a representation carrying nothing but the key, so entities fetched with
+// @requires fields in their representation are not found by it.
+func EntityKeyHash(typeName string, key []byte) (uint64, error) {
+	value, err := astjson.ParseBytes(key)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidRepresentation, err)
+	}
+	if value.Type() != astjson.TypeObject {
+		return 0, ErrInvalidRepresentation
+	}
+	value.Set(nil, "__typename", astjson.StringValue(nil, typeName))
+	_, hash, err := entityHash(value)
+	return hash, err
+}
+
+func entityHash(value *astjson.Value) (string, uint64, error) {
+	if value.Type() != astjson.TypeObject {
+		return "", 0, ErrInvalidRepresentation
+	}
+	typeName := value.Get("__typename")
+	if typeName == nil || typeName.Type() != astjson.TypeString {
+		return "", 0, ErrInvalidRepresentation
+	}
+	return string(typeName.GetStringBytes()), xxhash.Sum64(appendCanonical(nil, value)), nil
+}
+
+// appendCanonical marshals value with the fields of every object in it sorted
+// by name.
+func appendCanonical(dst []byte, value *astjson.Value) []byte {
+	switch value.Type() {
+	case astjson.TypeObject:
+		type field struct {
+			key   []byte
+			value *astjson.Value
+		}
+		var fields []field
+		value.GetObject().Visit(func(key []byte, v *astjson.Value) {
+			fields = append(fields, field{key: key, value: v})
+		})
+		slices.SortFunc(fields, func(a, b field) int {
+			return slices.Compare(a.key, b.key)
+		})
+		dst = append(dst, '{')
+		for i, f := range fields {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = astjson.StringValueBytes(nil, f.key).MarshalTo(dst)
+			dst = append(dst, ':')
+			dst = appendCanonical(dst, f.value)
+		}
+		return append(dst, '}')
+	case astjson.TypeArray:
+		dst = append(dst, '[')
+		for i, item := range value.GetArray() {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendCanonical(dst, item)
+		}
+		return append(dst, ']')
+	default:
+		return value.MarshalTo(dst)
+	}
+}
@@ -0,0 +1,45 @@
+package caching
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntityHash(t *testing.T) {
+	t.Run("the order of fields does not matter", func(t *testing.T) {
+		typeName, a, err := EntityHash([]byte(`{"__typename":"Product","upc":"1","sku":{"b":2,"a":1}}`))
+		require.NoError(t, err)
+		require.Equal(t, "Product", typeName)
+
+		_, b, err := EntityHash([]byte(`{"sku":{"a":1,"b":2},"upc":"1","__typename":"Product"}`))
+		require.NoError(t, err)
+		require.Equal(t, a, b)
+	})
+
+	t.Run("a key finds the representation it belongs to", func(t *testing.T) {
+		_, fromRepresentation, err := EntityHash([]byte(`{"__typename":"Product","upc":"1"}`))
+		require.NoError(t, err)
+
+		fromKey, err := EntityKeyHash("Product", []byte(`{"upc":"1"}`))
+		require.NoError(t, err)
+		require.Equal(t, fromRepresentation, fromKey)
+
+		other, err := EntityKeyHash("Product", []byte(`{"upc":"2"}`))
+		require.NoError(t, err)
+		require.NotEqual(t, fromRepresentation, other)
+
+		otherType, err := EntityKeyHash("User", []byte(`{"upc":"1"}`))
+		require.NoError(t, err)
+		require.NotEqual(t, fromRepresentation, otherType)
+	})
+
+	t.Run("a representation must be an object with a typename", func(t *testing.T) {
+		for _, representation := range []string{`[]`, `{"upc":"1"}`, `{"__typename":1}`, `{`} {
+			_, _, err := EntityHash([]byte(representation))
+			require.ErrorIs(t, err, ErrInvalidRepresentation, representation)
+		}
+		_, err := EntityKeyHash("Product", []byte(`"1"`))
+		require.ErrorIs(t, err, ErrInvalidRepresentation)
+	})
+}
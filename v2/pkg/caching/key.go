@@ -10,7 +10,14 @@ import (
 // under the old layout instead of letting them be read back as something they
 // are not. Orphaned entries are not deleted, they simply stop being asked for
 // and fall out on their own TTL.
-const keyFormatVersion = "v1"
+//
+// v2 is the first layout whose values may carry stale windows, see StaleItem.
+// The keys are built exactly as in v1, but a v1 reader would take those values
+// for entities.
+//
+// v3 takes the entity hash over the representation with its fields sorted,
+// see EntityHash, so that an entity can be found again from its @key alone.
+const keyFormatVersion = "v3"
 
 // Key builds the cache key for one entity within one fetch.
 func Key(entityHash, selectionHash uint64) string {
@@ -31,3 +38,19 @@ func appendHex64(dst []byte, fromInt uint64) []byte {
 	binary.BigEndian.PutUint64(fromByte, fromInt)
 	return hex.AppendEncode(dst, fromByte)
 }
+
+// RootKey builds the cache key for the whole response of a root fetch. It
+// shares no prefix with the keys Key builds, so a root response and an entity
+// are never taken for one another.
+func RootKey(inputHash, headersHash uint64) string {
+	const hexDigits = 16
+	buf := make([]byte, 0, len(keyFormatVersion)+len(":root:")+hexDigits+1+hexDigits)
+
+	buf = append(buf, keyFormatVersion...)
+	buf = append(buf, ":root:"...)
+	buf = appendHex64(buf, inputHash)
+	buf = append(buf, ':')
+	buf = appendHex64(buf, headersHash)
+
+	return string(buf)
+}
@@ -0,0 +1,359 @@
+package caching
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// EvictionPolicy decides which entry a full MemoryCache gives up to make room.
+type EvictionPolicy int
+
+const (
+	// EvictionLRU evicts the least recently used entry, and always admits the
+	// entry being written.
+	EvictionLRU EvictionPolicy = iota
+	// EvictionTinyLFU evicts like EvictionLRU, but only once the entry being
+	// written has been asked for more often than the one it would push out.
+	// A burst of keys that are written once and never read again then cannot
+	// flush out the entries that are read all the time.
+	EvictionTinyLFU
+)
+
+const (
+	defaultMemoryCacheShards = 16
+	// memoryEntryOverhead approximates what an entry costs beyond its key and
+	// value: the list element, the map slot and the entry itself.
+	memoryEntryOverhead = 96
+)
+
+var ErrInvalidMaxBytes = errors.New("memory cache requires a positive MaxBytes")
+
+type MemoryCacheOptions struct {
+	// MaxBytes bounds the estimated memory held by keys and values together. It
+	// is divided evenly between the shards, so a single entry larger than
+	// MaxBytes/Shards is never stored.
+	MaxBytes int64
+	// Shards is the number of independently locked partitions, rounded up to a
+	// power of two. Zero means 16.
+	Shards int
+	// Eviction is the policy applied once a shard is full.
+	Eviction EvictionPolicy
+}
+
+// MemoryCacheStats is a snapshot of a MemoryCache's counters. Hits and Misses
+// count keys, not GetMany calls.
+type MemoryCacheStats struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+	// Rejections counts writes that TinyLFU declined to admit, and entries too
+	// large to fit in a shard at all.
+	Rejections int64
+	Entries    int64
+	Bytes      int64
+}
+
+// MemoryCache is a Cache held in process memory. It is bounded by size rather
+// than by entry count, and expires every entry after the TTL it was written
+// with. It is safe for concurrent use.
+type MemoryCache struct {
+	shards []*memoryShard
+	mask   uint64
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	evictions   atomic.Int64
+	expirations atomic.Int64
+	rejections  atomic.Int64
+
+	now func() time.Time
+}
+
+var (
+	_ Cache   = (*MemoryCache)(nil)
+	_ Deleter = (*MemoryCache)(nil)
+)
+
+func NewMemoryCache(options MemoryCacheOptions) (*MemoryCache, error) {
+	if options.MaxBytes <= 0 {
+		return nil, ErrInvalidMaxBytes
+	}
+	shards := options.Shards
+	if shards <= 0 {
+		shards = defaultMemoryCacheShards
+	}
+	shards = nextPowerOfTwo(shards)
+
+	c := &MemoryCache{
+		shards: make([]*memoryShard, shards),
+		mask:   uint64(shards - 1),
+		now:    time.Now,
+	}
+	maxBytes := max(options.MaxBytes/int64(shards), 1)
+	for i := range c.shards {
+		c.shards[i] = newMemoryShard(c, maxBytes, options.Eviction == EvictionTinyLFU)
+	}
+	return c, nil
+}
+
+// GetMany returns every key that is present and has not expired, with the TTL
+// it has left. Expired entries found on the way are removed. The values are
+// shared with the cache and must not be modified.
+func (c *MemoryCache) GetMany(_ context.Context, keys []string) (map[string]Item, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoKeys
+	}
+	now := c.now()
+	found := make(map[string]Item, len(keys))
+	for _, key := range keys {
+		item, ok := c.shard(key).get(key, now)
+		if !ok {
+			c.misses.Add(1)
+			continue
+		}
+		c.hits.Add(1)
+		found[key] = item
+	}
+	return found, nil
+}
+
+// SetMany stores a copy of every item. It checks every TTL before it stores
+// anything, so a batch with one invalid item leaves the cache untouched.
+func (c *MemoryCache) SetMany(_ context.Context, items []Item) error {
+	if len(items) == 0 {
+		return ErrNoItems
+	}
+	for _, item := range items {
+		if item.TTL <= 0 {
+			return fmt.Errorf("%w: key %q", ErrMissingTTL, item.Key)
+		}
+	}
+	now := c.now()
+	for _, item := range items {
+		c.shard(item.Key).set(item, now)
+	}
+	return nil
+}
+
+// Delete removes every key that is present.
+func (c *MemoryCache) Delete(_ context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return ErrNoKeys
+	}
+	for _, key := range keys {
+		c.shard(key).delete(key)
+	}
+	return nil
+}
+
+func (c *MemoryCache) Stats() MemoryCacheStats {
+	stats := MemoryCacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions.Load(),
+		Expirations: c.expirations.Load(),
+		Rejections:  c.rejections.Load(),
+	}
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Entries += int64(len(s.entries))
+		stats.Bytes += s.bytes
+		s.mu.Unlock()
+	}
+	return stats
+}
+
+func (c *MemoryCache) shard(key string) *memoryShard {
+	return c.shards[xxhash.Sum64String(key)&c.mask]
+}
+
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+	size      int64
+}
+
+type memoryShard struct {
+	mu       sync.Mutex
+	cache    *MemoryCache
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	bytes    int64
+	maxBytes int64
+	sketch   *frequencySketch
+}
+
+func newMemoryShard(cache *MemoryCache, maxBytes int64, tinyLFU bool) *memoryShard {
+	s := &memoryShard{
+		cache:    cache,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		maxBytes: maxBytes,
+	}
+	if tinyLFU {
+		// Sized for entries of about 256 bytes, which only affects how often
+		// two keys share a counter, never whether an entry is stored.
+		s.sketch = newFrequencySketch(int(min(maxBytes/256, 1<<20)))
+	}
+	return s
+}
+
+func (s *memoryShard) get(key string, now time.Time) (Item, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sketch != nil {
+		s.sketch.increment(key)
+	}
+	element, ok := s.entries[key]
+	if !ok {
+		return Item{}, false
+	}
+	entry := element.Value.(*memoryEntry)
+	ttl := entry.expiresAt.Sub(now)
+	if ttl <= 0 {
+		s.remove(element)
+		s.cache.expirations.Add(1)
+		return Item{}, false
+	}
+	s.order.MoveToFront(element)
+	return Item{Key: key, Value: entry.value, TTL: ttl}, true
+}
+
+func (s *memoryShard) set(item Item, now time.Time) {
+	size := int64(len(item.Key)+len(item.Value)) + memoryEntryOverhead
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.sketch != nil {
+		s.sketch.increment(item.Key)
+	}
+
+	// An update of a present key is always admitted, the key has been
+	// admitted before. It is replaced in place, so a write never counts
+	// against its own key.
+	element, update := s.entries[item.Key]
+	if update {
+		s.remove(element)
+	}
+	if size > s.maxBytes {
+		s.cache.rejections.Add(1)
+		return
+	}
+
+	for s.bytes+size > s.maxBytes {
+		victim := s.order.Back()
+		entry := victim.Value.(*memoryEntry)
+		if !entry.expiresAt.After(now) {
+			s.remove(victim)
+			s.cache.expirations.Add(1)
+			continue
+		}
+		if s.sketch != nil && !update && s.sketch.estimate(item.Key) <= s.sketch.estimate(entry.key) {
+			s.cache.rejections.Add(1)
+			return
+		}
+		s.remove(victim)
+		s.cache.evictions.Add(1)
+	}
+
+	entry := &memoryEntry{
+		key:       item.Key,
+		value:     bytes.Clone(item.Value),
+		expiresAt: now.Add(item.TTL),
+		size:      size,
+	}
+	s.entries[item.Key] = s.order.PushFront(entry)
+	s.bytes += size
+}
+
+func (s *memoryShard) delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+}
+
+func (s *memoryShard) remove(element *list.Element) {
+	entry := s.order.Remove(element).(*memoryEntry)
+	delete(s.entries, entry.key)
+	s.bytes -= entry.size
+}
+
+// frequencySketch is a count-min sketch of 4 rows of 8 bit counters. Every
+// counter is halved once as many increments have been seen as ten times its
+// width, so a key that used to be popular does not stay admitted forever.
+type frequencySketch struct {
+	rows      [4][]uint8
+	mask      uint64
+	additions int
+	resetAt   int
+}
+
+func newFrequencySketch(width int) *frequencySketch {
+	width = nextPowerOfTwo(max(width, 64))
+	f := &frequencySketch{
+		mask:    uint64(width - 1),
+		resetAt: width * 10,
+	}
+	for i := range f.rows {
+		f.rows[i] = make([]uint8, width)
+	}
+	return f
+}
+
+func (f *frequencySketch) increment(key string) {
+	hash := xxhash.Sum64String(key)
+	for i := range f.rows {
+		index := f.index(hash, i)
+		if f.rows[i][index] < 255 {
+			f.rows[i][index]++
+		}
+	}
+	f.additions++
+	if f.additions >= f.resetAt {
+		for i := range f.rows {
+			for j := range f.rows[i] {
+				f.rows[i][j] >>= 1
+			}
+		}
+		f.additions /= 2
+	}
+}
+
+func (f *frequencySketch) estimate(key string) uint8 {
+	hash := xxhash.Sum64String(key)
+	estimate := uint8(255)
+	for i := range f.rows {
+		estimate = min(estimate, f.rows[i][f.index(hash, i)])
+	}
+	return estimate
+}
+
+// index derives row i's counter from a different 16 bit slice of the hash,
+// mixed with the row so that the rows do not collide in lockstep.
+func (f *frequencySketch) index(hash uint64, row int) uint64 {
+	h := (hash >> (16 * row)) * (0x9E3779B97F4A7C15 + uint64(row)*2)
+	return (h ^ h>>29) & f.mask
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,263 @@
+package caching
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestMemoryCache(t *testing.T, options MemoryCacheOptions) (*MemoryCache, *fakeClock) {
+	t.Helper()
+
+	cache, err := NewMemoryCache(options)
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	cache.now = clock.Now
+
+	return cache, clock
+}
+
+// entrySize is what an entry with a one byte key and a value of n bytes is
+// charged against MaxBytes.
+func entrySize(n int) int64 {
+	return int64(1+n) + memoryEntryOverhead
+}
+
+func TestMemoryCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("a stored item comes back with the TTL it has left", func(t *testing.T) {
+		cache, clock := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "a", Value: []byte(`{"id":1}`), TTL: time.Minute}}))
+
+		clock.advance(20 * time.Second)
+		found, err := cache.GetMany(ctx, []string{"a", "b"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]Item{
+			"a": {Key: "a", Value: []byte(`{"id":1}`), TTL: 40 * time.Second},
+		}, found, "the miss is absent, and the hit has 40 of its 60 seconds left")
+
+		stats := cache.Stats()
+		require.EqualValues(t, 1, stats.Hits)
+		require.EqualValues(t, 1, stats.Misses)
+	})
+
+	t.Run("an expired entry is a miss and is removed", func(t *testing.T) {
+		cache, clock := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "a", Value: []byte("1"), TTL: time.Second}}))
+		clock.advance(time.Second)
+
+		found, err := cache.GetMany(ctx, []string{"a"})
+		require.NoError(t, err)
+		require.Empty(t, found, "an entry with nothing left to live is a miss")
+
+		stats := cache.Stats()
+		require.EqualValues(t, 1, stats.Expirations)
+		require.EqualValues(t, 0, stats.Entries)
+		require.EqualValues(t, 0, stats.Bytes)
+	})
+
+	t.Run("a batch with one invalid TTL stores nothing", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		err := cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Minute},
+			{Key: "b", Value: []byte("2")},
+		})
+		require.True(t, errors.Is(err, ErrMissingTTL))
+		require.EqualValues(t, 0, cache.Stats().Entries)
+	})
+
+	t.Run("empty batches are refused", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		_, err := cache.GetMany(ctx, nil)
+		require.ErrorIs(t, err, ErrNoKeys)
+		require.ErrorIs(t, cache.SetMany(ctx, nil), ErrNoItems)
+	})
+
+	t.Run("the last write of a key wins", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Minute},
+			{Key: "a", Value: []byte("22"), TTL: time.Hour},
+		}))
+
+		found, err := cache.GetMany(ctx, []string{"a"})
+		require.NoError(t, err)
+		require.Equal(t, Item{Key: "a", Value: []byte("22"), TTL: time.Hour}, found["a"])
+		require.Equal(t, MemoryCacheStats{Hits: 1, Entries: 1, Bytes: entrySize(2)}, cache.Stats())
+	})
+
+	t.Run("LRU evicts the least recently used entry", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 3 * entrySize(1), Shards: 1})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Minute},
+			{Key: "b", Value: []byte("2"), TTL: time.Minute},
+			{Key: "c", Value: []byte("3"), TTL: time.Minute},
+		}))
+		// Reading a makes b the least recently used.
+		_, err := cache.GetMany(ctx, []string{"a"})
+		require.NoError(t, err)
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "d", Value: []byte("4"), TTL: time.Minute}}))
+
+		found, err := cache.GetMany(ctx, []string{"a", "b", "c", "d"})
+		require.NoError(t, err)
+		require.Len(t, found, 3)
+		require.NotContains(t, found, "b")
+		require.EqualValues(t, 1, cache.Stats().Evictions)
+	})
+
+	t.Run("expired entries make room before live ones are evicted", func(t *testing.T) {
+		cache, clock := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 2 * entrySize(1), Shards: 1})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Second},
+			{Key: "b", Value: []byte("2"), TTL: time.Minute},
+		}))
+		clock.advance(time.Second)
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "c", Value: []byte("3"), TTL: time.Minute}}))
+
+		stats := cache.Stats()
+		require.EqualValues(t, 1, stats.Expirations)
+		require.EqualValues(t, 0, stats.Evictions)
+		require.EqualValues(t, 2, stats.Entries)
+	})
+
+	t.Run("an entry larger than a shard is not stored", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: entrySize(1), Shards: 1})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "a", Value: []byte("too large"), TTL: time.Minute}}))
+		require.Equal(t, MemoryCacheStats{Rejections: 1}, cache.Stats())
+	})
+
+	t.Run("TinyLFU keeps a popular entry over a one-off write", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: entrySize(1), Shards: 1, Eviction: EvictionTinyLFU})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "a", Value: []byte("1"), TTL: time.Minute}}))
+		for range 5 {
+			_, err := cache.GetMany(ctx, []string{"a"})
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "b", Value: []byte("2"), TTL: time.Minute}}))
+		found, err := cache.GetMany(ctx, []string{"a", "b"})
+		require.NoError(t, err)
+		require.Contains(t, found, "a")
+		require.NotContains(t, found, "b")
+		require.EqualValues(t, 1, cache.Stats().Rejections)
+
+		// Once b has been asked for more often than a, it is admitted.
+		for range 10 {
+			_, err = cache.GetMany(ctx, []string{"b"})
+			require.NoError(t, err)
+		}
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "b", Value: []byte("2"), TTL: time.Minute}}))
+		found, err = cache.GetMany(ctx, []string{"a", "b"})
+		require.NoError(t, err)
+		require.NotContains(t, found, "a")
+		require.Contains(t, found, "b")
+	})
+
+	t.Run("TinyLFU admits an update of a present entry", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: entrySize(1) + entrySize(2), Shards: 1, Eviction: EvictionTinyLFU})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Minute},
+			{Key: "b", Value: []byte("2"), TTL: time.Minute},
+		}))
+		for range 5 {
+			_, err := cache.GetMany(ctx, []string{"a"})
+			require.NoError(t, err)
+		}
+
+		// The update needs the room of a, which is asked for more often.
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "b", Value: []byte("333"), TTL: time.Minute}}))
+		found, err := cache.GetMany(ctx, []string{"b"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("333"), found["b"].Value)
+		require.EqualValues(t, 0, cache.Stats().Rejections)
+	})
+
+	t.Run("a stored value is a copy", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		value := []byte("1")
+		require.NoError(t, cache.SetMany(ctx, []Item{{Key: "a", Value: value, TTL: time.Minute}}))
+		value[0] = '2'
+
+		found, err := cache.GetMany(ctx, []string{"a"})
+		require.NoError(t, err)
+		require.Equal(t, []byte("1"), found["a"].Value)
+	})
+
+	t.Run("a deleted entry is a miss", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 20})
+
+		require.NoError(t, cache.SetMany(ctx, []Item{
+			{Key: "a", Value: []byte("1"), TTL: time.Minute},
+			{Key: "b", Value: []byte("2"), TTL: time.Minute},
+		}))
+		require.NoError(t, cache.Delete(ctx, []string{"a", "missing"}))
+
+		found, err := cache.GetMany(ctx, []string{"a", "b"})
+		require.NoError(t, err)
+		require.NotContains(t, found, "a")
+		require.Contains(t, found, "b")
+		require.Equal(t, int64(1), cache.Stats().Entries)
+		require.ErrorIs(t, cache.Delete(ctx, nil), ErrNoKeys)
+	})
+
+	t.Run("MaxBytes must be positive", func(t *testing.T) {
+		_, err := NewMemoryCache(MemoryCacheOptions{})
+		require.ErrorIs(t, err, ErrInvalidMaxBytes)
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		cache, _ := newTestMemoryCache(t, MemoryCacheOptions{MaxBytes: 1 << 16, Shards: 4, Eviction: EvictionTinyLFU})
+
+		var wg sync.WaitGroup
+		for g := range 8 {
+			wg.Go(func() {
+				for i := range 200 {
+					key := fmt.Sprintf("%d-%d", g, i%20)
+					require.NoError(t, cache.SetMany(ctx, []Item{{Key: key, Value: []byte(key), TTL: time.Minute}}))
+					_, err := cache.GetMany(ctx, []string{key})
+					require.NoError(t, err)
+				}
+			})
+		}
+		wg.Wait()
+
+		stats := cache.Stats()
+		require.LessOrEqual(t, stats.Bytes, int64(1<<16))
+		require.EqualValues(t, 8*200, stats.Hits+stats.Misses)
+	})
+}
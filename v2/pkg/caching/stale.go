@@ -0,0 +1,112 @@
+package caching
+
+import (
+	"encoding/binary"
+	"net/http"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/cache"
+)
+
+// StaleWindows returns how long past its freshness lifetime a response may be
+// served while it is revalidated, and how long in place of an error. A window
+// the response names itself wins over the default passed for it. A response
+// whose Cache-Control cannot be parsed gets no windows at all, the same way
+// TTL refuses to cache it.
+func StaleWindows(headers http.Header, defaultWhileRevalidate, defaultIfError time.Duration) (whileRevalidate, ifError time.Duration) {
+	cc, err := cache.ParseCacheControlResponse(headers)
+	if err != nil {
+		return 0, 0
+	}
+
+	whileRevalidate, ifError = max(defaultWhileRevalidate, 0), max(defaultIfError, 0)
+	if cc.StaleWhileRevalidate != nil {
+		whileRevalidate = cc.StaleWhileRevalidate.AsDuration()
+	}
+	if cc.StaleIfError != nil {
+		ifError = cc.StaleIfError.AsDuration()
+	}
+	return whileRevalidate, ifError
+}
+
+// staleMarker leads a value stored with stale windows. Every value stored
+// without them is a JSON object, so its first byte is never this one.
+const staleMarker = 0x01
+
+const staleHeaderSize = 1 + 4 + 4
+
+// StaleItem builds the Item for a value that may be served stale. The windows
+// travel inside the stored value, so whoever reads it back can tell a fresh
+// entry from a stale one without knowing how it was written, and the TTL is
+// stretched by the longer window so the entry outlives its freshness. Without
+// any window the Item is exactly the one a plain write would have stored. The
+// windows are stored in whole seconds, so they are rounded up to the next one.
+func StaleItem(key string, value []byte, ttl, whileRevalidate, ifError time.Duration) Item {
+	if whileRevalidate <= 0 && ifError <= 0 {
+		return Item{Key: key, Value: value, TTL: ttl}
+	}
+	whileRevalidate, ifError = ceilSeconds(whileRevalidate), ceilSeconds(ifError)
+
+	stored := make([]byte, staleHeaderSize, staleHeaderSize+len(value))
+	stored[0] = staleMarker
+	binary.BigEndian.PutUint32(stored[1:5], durationSeconds(whileRevalidate))
+	binary.BigEndian.PutUint32(stored[5:9], durationSeconds(ifError))
+	stored = append(stored, value...)
+
+	return Item{Key: key, Value: stored, TTL: ttl + max(whileRevalidate, ifError)}
+}
+
+// Freshness says how an entry read back from the cache may be used.
+type Freshness int
+
+const (
+	// Fresh entries are served as they are.
+	Fresh Freshness = iota
+	// StaleWhileRevalidate entries are served, and refreshed in the background.
+	StaleWhileRevalidate
+	// StaleIfError entries are only served in place of a failed fetch.
+	StaleIfError
+)
+
+// ReadStale takes apart an Item that may have been written by StaleItem. It
+// returns the value as it was passed in, and how the entry may be used given
+// the TTL it has left. An item stored without windows is always Fresh, since
+// the cache only ever returns entries that have life left in them.
+func ReadStale(item Item) ([]byte, Freshness) {
+	stored := item.Value
+	if len(stored) < staleHeaderSize || stored[0] != staleMarker {
+		return stored, Fresh
+	}
+
+	whileRevalidate := time.Duration(binary.BigEndian.Uint32(stored[1:5])) * time.Second
+	ifError := time.Duration(binary.BigEndian.Uint32(stored[5:9])) * time.Second
+	value := stored[staleHeaderSize:]
+
+	// The entry was written to live for its freshness plus the longer window,
+	// so how far into that window it is follows from what it has left.
+	staleFor := max(whileRevalidate, ifError) - item.TTL
+	switch {
+	case staleFor < 0:
+		return value, Fresh
+	case staleFor < whileRevalidate:
+		return value, StaleWhileRevalidate
+	default:
+		return value, StaleIfError
+	}
+}
+
+// ceilSeconds rounds a positive duration up to whole seconds.
+func ceilSeconds(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return (d + time.Second - 1).Truncate(time.Second)
+}
+
+func durationSeconds(d time.Duration) uint32 {
+	seconds := d / time.Second
+	if seconds > (1<<32)-1 {
+		return (1 << 32) - 1
+	}
+	return uint32(seconds)
+}
@@ -0,0 +1,90 @@
+package caching
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaleWindows(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		cacheControl        string
+		wantWhileRevalidate time.Duration
+		wantIfError         time.Duration
+	}{
+		{name: "defaults apply when the response names no window", cacheControl: "public, max-age=60",
+			wantWhileRevalidate: 10 * time.Second, wantIfError: time.Minute},
+		{name: "a window the response names wins", cacheControl: "public, max-age=60, stale-while-revalidate=5, stale-if-error=0",
+			wantWhileRevalidate: 5 * time.Second, wantIfError: 0},
+		{name: "an unparsable header has no windows", cacheControl: "public, max-age=abc",
+			wantWhileRevalidate: 0, wantIfError: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			headers.Set("Cache-Control", tc.cacheControl)
+
+			whileRevalidate, ifError := StaleWindows(headers, 10*time.Second, time.Minute)
+			if whileRevalidate != tc.wantWhileRevalidate || ifError != tc.wantIfError {
+				t.Fatalf("StaleWindows(%q) = %s, %s, want %s, %s", tc.cacheControl,
+					whileRevalidate, ifError, tc.wantWhileRevalidate, tc.wantIfError)
+			}
+		})
+	}
+}
+
+func TestReadStale(t *testing.T) {
+	value := []byte(`{"id":1}`)
+
+	t.Run("an item without windows is stored as it is and always fresh", func(t *testing.T) {
+		item := StaleItem("k", value, time.Minute, 0, 0)
+		if string(item.Value) != string(value) || item.TTL != time.Minute {
+			t.Fatalf("StaleItem without windows = %q, %s", item.Value, item.TTL)
+		}
+		if got, freshness := ReadStale(Item{Value: item.Value, TTL: time.Second}); string(got) != string(value) || freshness != Fresh {
+			t.Fatalf("ReadStale = %q, %d", got, freshness)
+		}
+	})
+
+	// Fresh for a minute, then 30 seconds to revalidate in, then another 30
+	// seconds only to stand in for an error.
+	item := StaleItem("k", value, time.Minute, 30*time.Second, time.Minute)
+	if item.TTL != 2*time.Minute {
+		t.Fatalf("StaleItem TTL = %s, want the freshness plus the longer window", item.TTL)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		remaining time.Duration
+		want      Freshness
+	}{
+		{name: "fresh", remaining: 90 * time.Second, want: Fresh},
+		{name: "just expired", remaining: time.Minute, want: StaleWhileRevalidate},
+		{name: "within stale-while-revalidate", remaining: 45 * time.Second, want: StaleWhileRevalidate},
+		{name: "past stale-while-revalidate", remaining: 30 * time.Second, want: StaleIfError},
+		{name: "about to be dropped", remaining: time.Second, want: StaleIfError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, freshness := ReadStale(Item{Key: item.Key, Value: item.Value, TTL: tc.remaining})
+			if string(got) != string(value) {
+				t.Fatalf("ReadStale value = %q, want %q", got, value)
+			}
+			if freshness != tc.want {
+				t.Fatalf("ReadStale freshness = %d, want %d", freshness, tc.want)
+			}
+		})
+	}
+
+	t.Run("a window under a second is rounded up", func(t *testing.T) {
+		item := StaleItem("k", value, time.Second, 500*time.Millisecond, 0)
+		if item.TTL != 2*time.Second {
+			t.Fatalf("StaleItem TTL = %s, want the freshness plus a second", item.TTL)
+		}
+		if _, freshness := ReadStale(Item{Value: item.Value, TTL: 1500 * time.Millisecond}); freshness != Fresh {
+			t.Fatalf("ReadStale freshness = %d, want %d", freshness, Fresh)
+		}
+		if _, freshness := ReadStale(Item{Value: item.Value, TTL: 500 * time.Millisecond}); freshness != StaleWhileRevalidate {
+			t.Fatalf("ReadStale freshness = %d, want %d", freshness, StaleWhileRevalidate)
+		}
+	})
+}
@@ -195,6 +195,18 @@ type CacheControlResponse struct {
 	// store the specified field-names(s), whereas it MAY store the
 	// remainder of the response message.
 	Private *FieldNames
+
+	// StaleWhileRevalidate is how long past its freshness lifetime a cache MAY
+	// keep serving the response while it revalidates it in the background.
+	//
+	// Defined in https://datatracker.ietf.org/doc/html/rfc5861#section-3
+	StaleWhileRevalidate *DeltaSeconds
+
+	// StaleIfError is how long past its freshness lifetime a cache MAY serve
+	// the response in place of an error from the origin.
+	//
+	// Defined in https://datatracker.ietf.org/doc/html/rfc5861#section-4
+	StaleIfError *DeltaSeconds
 }
 
 func ParseCacheControlResponse(headers http.Header) (*CacheControlResponse, error) {
@@ -240,7 +252,7 @@ func parse(s string) (*CacheControlResponse, error) {
 }
 
 // deltaSecondsArgument interprets an argument as delta-seconds. An unusable
-// argument is an error, so a non-nil MaxAge/SMaxAge always means we parsed one
+// argument is an error, so a non-nil delta-seconds field always means we parsed one
 // successfully.
 func deltaSecondsArgument(name string, arg directiveArgument) (DeltaSeconds, error) {
 	if !arg.present || arg.text == "" {
@@ -330,6 +342,31 @@ func parseIdent(name token, l *lexer, cc *CacheControlResponse) error {
 		}
 		cc.SMaxAge = &value
 
+	case "stale-while-revalidate":
+		if cc.StaleWhileRevalidate != nil {
+			return nil
+		}
+
+		// Unlike max-age, these are extensions a cache that does not know them
+		// ignores, and RFC 9111 §5.2 has an unusable one ignored the same way
+		// rather than discarding the freshness information next to it.
+		value, err := deltaSecondsArgument("stale-while-revalidate", arg)
+		if err != nil {
+			return nil
+		}
+		cc.StaleWhileRevalidate = &value
+
+	case "stale-if-error":
+		if cc.StaleIfError != nil {
+			return nil
+		}
+
+		value, err := deltaSecondsArgument("stale-if-error", arg)
+		if err != nil {
+			return nil
+		}
+		cc.StaleIfError = &value
+
 	case "no-store":
 		// A boolean directive cannot have an argument, so we ignore it.
 		cc.NoStore = true
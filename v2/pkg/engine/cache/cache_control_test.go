@@ -48,7 +48,7 @@ import (
 //	D2  Directive names are case-insensitive (RFC 9110 tokens).
 //	D3  Directives that the CacheControlResponse struct does not model
 //	    (must-revalidate, no-transform, proxy-revalidate, immutable,
-//	    stale-while-revalidate, arbitrary cache extensions) are ignored, not
+//	    arbitrary cache extensions) are ignored, not
 //	    rejected. RFC 9111 §5.2: unknown directives MUST be ignored. A header
 //	    consisting only of such directives still parses to a non-nil, zero
 //	    CacheControlResponse.
@@ -203,6 +203,14 @@ import (
 //	    whole directive to its unqualified form. That was defensible for a
 //	    general-purpose HTTP cache and wrong for this one.
 //
+//	D20 The RFC 5861 extensions stale-while-revalidate and stale-if-error are
+//	    modelled, because the response cache serves stale entries on them.
+//	    They are delta-seconds exactly like max-age, so D5-D7 and D14 apply to
+//	    them unchanged and the first usable occurrence wins. D0 does not: they
+//	    are extensions a cache that does not know them ignores, so per RFC 9111
+//	    §5.2 an unusable value ignores the directive, as if it were absent,
+//	    rather than rejecting the field.
+//
 
 func TestParse(t *testing.T) {
 	t.Parallel()
@@ -252,9 +260,40 @@ func TestParse(t *testing.T) {
 		requireParses(t, "must-revalidate", &CacheControlResponse{})
 	})
 
-	t.Run("stale-while-revalidate is ignored", func(t *testing.T) {
+	// --- D20: the RFC 5861 stale extensions --------------------------------
+
+	t.Run("stale-while-revalidate", func(t *testing.T) {
+		t.Parallel()
+		requireParses(t, "max-age=60, stale-while-revalidate=30",
+			&CacheControlResponse{MaxAge: seconds(60), StaleWhileRevalidate: seconds(30)})
+	})
+
+	t.Run("stale-if-error", func(t *testing.T) {
+		t.Parallel()
+		requireParses(t, "max-age=60, stale-if-error=600",
+			&CacheControlResponse{MaxAge: seconds(60), StaleIfError: seconds(600)})
+	})
+
+	t.Run("stale extensions keep their first occurrence", func(t *testing.T) {
+		t.Parallel()
+		requireParses(t, "stale-while-revalidate=30, stale-while-revalidate=abc, stale-if-error=5, stale-if-error=10",
+			&CacheControlResponse{StaleWhileRevalidate: seconds(30), StaleIfError: seconds(5)})
+	})
+
+	t.Run("stale-while-revalidate non-numeric is ignored", func(t *testing.T) {
+		t.Parallel()
+		requireParses(t, "max-age=60, stale-while-revalidate=soon", &CacheControlResponse{MaxAge: seconds(60)})
+	})
+
+	t.Run("stale-if-error with no argument is ignored", func(t *testing.T) {
+		t.Parallel()
+		requireParses(t, "max-age=60, stale-if-error", &CacheControlResponse{MaxAge: seconds(60)})
+	})
+
+	t.Run("an ignored stale extension leaves room for a later usable one", func(t *testing.T) {
 		t.Parallel()
-		requireParses(t, "max-age=60, stale-while-revalidate=30", &CacheControlResponse{MaxAge: seconds(60)})
+		requireParses(t, "stale-while-revalidate=-1, stale-while-revalidate=30, stale-if-error=, stale-if-error=5",
+			&CacheControlResponse{StaleWhileRevalidate: seconds(30), StaleIfError: seconds(5)})
 	})
 
 	t.Run("unknown extension with quoted argument is ignored", func(t *testing.T) {
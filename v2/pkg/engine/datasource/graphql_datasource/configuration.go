@@ -59,6 +59,10 @@ func NewConfiguration(input ConfigurationInput) (Configuration, error) {
 	}
 
 	if input.Subscription != nil {
+		if input.Subscription.UseSSE && input.Subscription.UseMultipart {
+			return Configuration{}, errors.New("subscription configuration can't use both SSE and multipart HTTP")
+		}
+
 		cfg.subscription = input.Subscription
 
 		if cfg.fetch != nil {
@@ -114,6 +118,10 @@ type SubscriptionConfiguration struct {
 	Header        http.Header
 	UseSSE        bool
 	SSEMethodPost bool
+	// UseMultipart subscribes with the multipart HTTP subscription protocol
+	// (multipart/mixed;subscriptionSpec="1.0") instead of a WebSocket.
+	// It can't be combined with UseSSE.
+	UseMultipart bool
 	// ForwardedClientHeaderNames indicates headers names that might be forwarded from the
 	// client to the upstream server. This is used to determine which connections
 	// can be multiplexed together, but the subscription engine does not forward
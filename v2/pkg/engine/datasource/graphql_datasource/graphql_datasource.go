@@ -159,6 +159,10 @@ func (p *Planner[T]) addDirectiveToNode(directiveRef int, node ast.Node) {
 	if bytes.Equal(p.visitor.Operation.DirectiveNameBytes(directiveRef), literal.DEFER_INTERNAL) {
 		return
 	}
+	// @stream is executed by the gateway, the upstream sends the whole list
+	if bytes.Equal(p.visitor.Operation.DirectiveNameBytes(directiveRef), literal.STREAM) {
+		return
+	}
 
 	directiveName := p.visitor.Operation.DirectiveNameString(directiveRef)
 	operationType := ast.OperationTypeQuery
@@ -487,6 +491,10 @@ func (p *Planner[T]) requiresEntityBatchFetch() bool {
 }
 
 func (p *Planner[T]) ConfigureSubscription() plan.SubscriptionConfiguration {
+	if p.config.grpc != nil {
+		return p.configureGRPCSubscription()
+	}
+
 	if p.config.subscription == nil {
 		p.stopWithError(errors.WithStack(errors.New("ConfigureSubscription: subscription configuration is empty")))
 		return plan.SubscriptionConfiguration{}
@@ -501,6 +509,9 @@ func (p *Planner[T]) ConfigureSubscription() plan.SubscriptionConfiguration {
 			input = httpclient.SetInputFlag(input, httpclient.SSE_METHOD_POST)
 		}
 	}
+	if p.config.subscription.UseMultipart {
+		input = httpclient.SetInputFlag(input, httpclient.USE_MULTIPART)
+	}
 	input = httpclient.SetInputWSSubprotocol(input, []byte(p.config.subscription.WsSubProtocol))
 
 	header, err := json.Marshal(p.config.subscription.Header)
@@ -542,6 +553,39 @@ func (p *Planner[T]) ConfigureSubscription() plan.SubscriptionConfiguration {
 	}
 }
 
+// configureGRPCSubscription configures a subscription resolved with a
+// server-streaming RPC of the gRPC service.
+func (p *Planner[T]) configureGRPCSubscription() plan.SubscriptionConfiguration {
+	input, operation := p.createInputForQuery()
+
+	opDocument, opReport := astparser.ParseGraphqlDocumentBytes(operation)
+	if opReport.HasErrors() {
+		p.stopWithError(errors.WithStack(fmt.Errorf("failed to parse operation: %w", opReport)))
+		return plan.SubscriptionConfiguration{}
+	}
+
+	dataSource, err := grpcdatasource.NewSubscriptionSource(p.rpcTransport, grpcdatasource.DataSourceConfig{
+		Operation:    &opDocument,
+		Definition:   p.config.schemaConfiguration.upstreamSchemaAst,
+		Mapping:      p.config.grpc.Mapping,
+		Compiler:     p.config.grpc.Compiler,
+		Disabled:     p.config.grpc.Disabled,
+		SubgraphName: p.dataSourceConfig.Name(),
+	})
+	if err != nil {
+		p.stopWithError(errors.WithStack(fmt.Errorf("ConfigureSubscription: failed to create datasource: %w", err)))
+		return plan.SubscriptionConfiguration{}
+	}
+
+	return plan.SubscriptionConfiguration{
+		Input:          string(input),
+		DataSource:     dataSource,
+		Variables:      p.variables,
+		PostProcessing: DefaultPostProcessingConfiguration,
+		QueryPlan:      p.queryPlan,
+	}
+}
+
 func sanitize(element string) string {
 	// replace all invalid characters with underscore
 	return strings.Map(func(r rune) rune {
@@ -2122,6 +2166,7 @@ type GraphQLSubscriptionOptions struct {
 	Header                                  http.Header         `json:"header"`
 	UseSSE                                  bool                `json:"use_sse"`
 	SSEMethodPost                           bool                `json:"sse_method_post"`
+	UseMultipart                            bool                `json:"use_multipart"`
 	ForwardedClientHeaderNames              []string            `json:"forwarded_client_header_names"`
 	ForwardedClientHeaderRegularExpressions []RegularExpression `json:"forwarded_client_header_regular_expressions"`
 	WsSubProtocol                           string              `json:"ws_sub_protocol"`
@@ -0,0 +1,167 @@
+package graphql_datasource
+
+import (
+	"testing"
+
+	. "github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasourcetesting"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func TestGraphQLDataSourceStream(t *testing.T) {
+	definition := `
+		directive @stream(label: String, if: Boolean! = true, initialCount: Int = 0) on FIELD
+
+		type User {
+			id: ID!
+			name: String!
+			friends: [User!]!
+		}
+
+		type Query {
+			users: [User!]!
+		}
+	`
+
+	subgraphSDL := `
+		type User {
+			id: ID!
+			name: String!
+			friends: [User!]!
+		}
+
+		type Query {
+			users: [User!]!
+		}
+	`
+
+	planConfiguration := plan.Configuration{
+		DataSources: []plan.DataSource{
+			mustDataSourceConfiguration(
+				t,
+				"first-service",
+				&plan.DataSourceMetadata{
+					RootNodes: []plan.TypeField{
+						{
+							TypeName:   "Query",
+							FieldNames: []string{"users"},
+						},
+					},
+					ChildNodes: []plan.TypeField{
+						{
+							TypeName:   "User",
+							FieldNames: []string{"id", "name", "friends"},
+						},
+					},
+				},
+				mustCustomConfiguration(t,
+					ConfigurationInput{
+						Fetch: &FetchConfiguration{
+							URL: "http://first.service",
+						},
+						SchemaConfiguration: mustSchema(t,
+							&FederationConfiguration{
+								Enabled:    true,
+								ServiceSDL: subgraphSDL,
+							},
+							subgraphSDL,
+						),
+					},
+				),
+			),
+		},
+		DisableResolveFieldPositions: true,
+	}
+
+	t.Run("stream a root list", func(t *testing.T) {
+		RunWithPermutations(
+			t,
+			definition,
+			`
+				query Users {
+					users @stream(initialCount: 2, label: "users") {
+						name
+						friends @stream {
+							name
+						}
+					}
+				}`,
+			"Users",
+			&plan.DeferResponsePlan{
+				Response: &resolve.GraphQLDeferResponse{
+					DeferDescriptors: map[int]resolve.DeferDescriptor{},
+					StreamDescriptors: map[int]resolve.DeferDescriptor{
+						1: {
+							ID:    1,
+							Label: "users",
+							Path:  []string{"users"},
+						},
+					},
+					Response: &resolve.GraphQLResponse{
+						Fetches: resolve.Sequence(
+							resolve.Single(&resolve.SingleFetch{
+								FetchConfiguration: resolve.FetchConfiguration{
+									Input:          `{"method":"POST","url":"http://first.service","body":{"query":"{users {name friends {name}}}"}}`,
+									PostProcessing: DefaultPostProcessingConfiguration,
+									DataSource:     &Source{},
+								},
+								DataSourceIdentifier: []byte("graphql_datasource.Source"),
+							}),
+						),
+						Data: &resolve.Object{
+							Fields: []*resolve.Field{
+								{
+									Name: []byte("users"),
+									Stream: &resolve.StreamField{
+										ID:               1,
+										InitialBatchSize: 2,
+									},
+									Value: &resolve.Array{
+										Path: []string{"users"},
+										Item: &resolve.Object{
+											PossibleTypes: map[string]struct{}{
+												"User": {},
+											},
+											TypeName: "User",
+											Fields: []*resolve.Field{
+												{
+													Name: []byte("name"),
+													Value: &resolve.String{
+														Path: []string{"name"},
+													},
+												},
+												{
+													Name: []byte("friends"),
+													Value: &resolve.Array{
+														Path: []string{"friends"},
+														Item: &resolve.Object{
+															PossibleTypes: map[string]struct{}{
+																"User": {},
+															},
+															TypeName: "User",
+															Fields: []*resolve.Field{
+																{
+																	Name: []byte("name"),
+																	Value: &resolve.String{
+																		Path: []string{"name"},
+																	},
+																},
+															},
+														},
+													},
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			planConfiguration,
+			WithDefaultPostProcessor(),
+			WithDefer(),
+		)
+	})
+}
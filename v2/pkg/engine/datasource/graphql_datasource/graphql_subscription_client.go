@@ -28,6 +28,11 @@ type subscriptionClientConfig struct {
 	WriteTimeout time.Duration
 	ReadLimit    int64
 
+	// Multiplexing of subscriptions onto WebSocket connections
+	MaxSubscriptionsPerConnection int
+	MaxConnectionsPerUpstream     int
+	ResubscribeOnConnectionLoss   bool
+
 	// DefaultErrorExtensionCode is the extension code attached to GraphQL
 	// errors produced by upstream connection failures. Should match the
 	// resolve package's setting for consistent error formatting.
@@ -57,7 +62,7 @@ func WithUpgradeClient(c *http.Client) SubscriptionClientOption {
 	}
 }
 
-// WithStreamingClient sets the HTTP client used for SSE requests.
+// WithStreamingClient sets the HTTP client used for SSE and multipart HTTP requests.
 // This client should have appropriate timeouts for long-lived connections.
 func WithStreamingClient(c *http.Client) SubscriptionClientOption {
 	return func(cfg *subscriptionClientConfig) {
@@ -124,6 +129,33 @@ func WithReadLimit(n int64) SubscriptionClientOption {
 	}
 }
 
+// WithMaxSubscriptionsPerConnection limits the number of subscriptions multiplexed
+// onto a single WebSocket connection. New subscriptions go to the connection with
+// the fewest subscriptions, a new connection is dialed once all are full.
+// Default: 0 (no limit).
+func WithMaxSubscriptionsPerConnection(n int) SubscriptionClientOption {
+	return func(cfg *subscriptionClientConfig) {
+		cfg.MaxSubscriptionsPerConnection = n
+	}
+}
+
+// WithMaxConnectionsPerUpstream limits the number of WebSocket connections to the
+// same upstream. Subscribing fails once all of them are full.
+// Only applies together with WithMaxSubscriptionsPerConnection. Default: 0 (no limit).
+func WithMaxConnectionsPerUpstream(n int) SubscriptionClientOption {
+	return func(cfg *subscriptionClientConfig) {
+		cfg.MaxConnectionsPerUpstream = n
+	}
+}
+
+// WithResubscribeOnConnectionLoss moves the subscriptions of a lost WebSocket
+// connection to another connection instead of ending them with an error.
+func WithResubscribeOnConnectionLoss(enabled bool) SubscriptionClientOption {
+	return func(cfg *subscriptionClientConfig) {
+		cfg.ResubscribeOnConnectionLoss = enabled
+	}
+}
+
 // subscriptionClientV2 implements GraphQLSubscriptionClient using the new
 // channel-based subscription client.
 type subscriptionClientV2 struct {
@@ -149,6 +181,10 @@ func NewGraphQLSubscriptionClient(ctx context.Context, opts ...SubscriptionClien
 			AckTimeout:      cfg.AckTimeout,
 			WriteTimeout:    cfg.WriteTimeout,
 			ReadLimit:       cfg.ReadLimit,
+
+			WSMaxSubscriptionsPerConn: cfg.MaxSubscriptionsPerConnection,
+			WSMaxConnsPerUpstream:     cfg.MaxConnectionsPerUpstream,
+			WSResubscribeOnConnLoss:   cfg.ResubscribeOnConnectionLoss,
 		}),
 	}
 }
@@ -221,6 +257,7 @@ func isUpstreamError(err error) bool {
 		errors.Is(err, client.ErrConnectionError) ||
 		errors.Is(err, client.ErrInitFailed) ||
 		errors.Is(err, client.ErrDialFailed) ||
+		errors.Is(err, client.ErrConnectionLimitReached) ||
 		errors.Is(err, client.ErrAckTimeout) ||
 		errors.Is(err, client.ErrAckNotReceived) ||
 		errors.Is(err, context.Canceled) ||
@@ -235,14 +272,17 @@ func convertToClientOptions(options GraphQLSubscriptionOptions) (client.Options,
 	}
 
 	// Transport selection
-	if options.UseSSE {
+	switch {
+	case options.UseMultipart:
+		opts.Transport = client.TransportMultipart
+	case options.UseSSE:
 		opts.Transport = client.TransportSSE
 		if options.SSEMethodPost {
 			opts.SSEMethod = client.SSEMethodPOST
 		} else {
 			opts.SSEMethod = client.SSEMethodGET
 		}
-	} else {
+	default:
 		opts.Transport = client.TransportWS
 		opts.WSSubprotocol = mapWSSubprotocol(options.WsSubProtocol)
 	}
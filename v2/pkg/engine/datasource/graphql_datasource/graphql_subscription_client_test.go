@@ -105,3 +105,25 @@ func TestBuildMessageHandlerRoutesEachMessageTypeCorrectly(t *testing.T) {
 		require.Empty(t, updater.errors)
 	})
 }
+
+func TestConvertToClientOptionsSelectsTransport(t *testing.T) {
+	t.Run("multipart", func(t *testing.T) {
+		opts, _, err := convertToClientOptions(GraphQLSubscriptionOptions{URL: "http://localhost/graphql", UseMultipart: true})
+		require.NoError(t, err)
+		assert.Equal(t, client.TransportMultipart, opts.Transport)
+	})
+
+	t.Run("sse with post", func(t *testing.T) {
+		opts, _, err := convertToClientOptions(GraphQLSubscriptionOptions{URL: "http://localhost/graphql", UseSSE: true, SSEMethodPost: true})
+		require.NoError(t, err)
+		assert.Equal(t, client.TransportSSE, opts.Transport)
+		assert.Equal(t, client.SSEMethodPOST, opts.SSEMethod)
+	})
+
+	t.Run("websocket by default", func(t *testing.T) {
+		opts, _, err := convertToClientOptions(GraphQLSubscriptionOptions{URL: "ws://localhost/graphql", WsSubProtocol: "graphql-ws"})
+		require.NoError(t, err)
+		assert.Equal(t, client.TransportWS, opts.Transport)
+		assert.Equal(t, client.SubprotocolGraphQLWS, opts.WSSubprotocol)
+	})
+}
@@ -26,14 +26,20 @@ type Client struct {
 	ctx context.Context
 	log abstractlogger.Logger
 
-	ws  *transport.WSTransport
-	sse *transport.SSETransport
+	ws        *transport.WSTransport
+	sse       *transport.SSETransport
+	multipart *transport.MultipartTransport
 }
 
 // Stats contains client statistics.
 type Stats struct {
-	WSConns  int // active WebSocket connections
-	SSEConns int // active SSE connections
+	WSConns        int // active WebSocket connections
+	SSEConns       int // active SSE connections
+	MultipartConns int // active multipart HTTP connections
+
+	// WSSubscriptionMoves counts the subscriptions moved to another WebSocket
+	// connection after their connection was lost.
+	WSSubscriptionMoves int
 }
 
 // Config holds the client configuration.
@@ -47,6 +53,13 @@ type Config struct {
 	WriteTimeout    time.Duration
 	ReadLimit       int64
 	WSIdleTimeout   time.Duration
+
+	// WSMaxSubscriptionsPerConn, WSMaxConnsPerUpstream and
+	// WSResubscribeOnConnLoss configure the multiplexing of subscriptions
+	// onto WebSocket connections, see transport.WSTransportOptions.
+	WSMaxSubscriptionsPerConn int
+	WSMaxConnsPerUpstream     int
+	WSResubscribeOnConnLoss   bool
 }
 
 // New creates a new subscription client with the provided config.
@@ -83,8 +96,13 @@ func New(ctx context.Context, cfg Config) *Client {
 			WriteTimeout:  cfg.WriteTimeout,
 			ReadLimit:     cfg.ReadLimit,
 			IdleTimeout:   cfg.WSIdleTimeout,
+
+			MaxSubscriptionsPerConn: cfg.WSMaxSubscriptionsPerConn,
+			MaxConnsPerUpstream:     cfg.WSMaxConnsPerUpstream,
+			ResubscribeOnConnLoss:   cfg.WSResubscribeOnConnLoss,
 		}),
-		sse: transport.NewSSETransport(ctx, cfg.StreamingClient, cfg.Logger),
+		sse:       transport.NewSSETransport(ctx, cfg.StreamingClient, cfg.Logger),
+		multipart: transport.NewMultipartTransport(ctx, cfg.StreamingClient, cfg.Logger),
 	}
 
 	c.log.Debug("subscriptionClient.New", abstractlogger.String("status", "initialized"))
@@ -103,6 +121,8 @@ func (c *Client) Subscribe(ctx context.Context, req *common.Request, opts common
 		return c.sse.Subscribe(ctx, req, opts, handler)
 	case common.TransportWS:
 		return c.ws.Subscribe(ctx, req, opts, handler)
+	case common.TransportMultipart:
+		return c.multipart.Subscribe(ctx, req, opts, handler)
 	default:
 		return nil, fmt.Errorf("unsupported transport: %q", opts.Transport)
 	}
@@ -111,8 +131,11 @@ func (c *Client) Subscribe(ctx context.Context, req *common.Request, opts common
 // Stats returns client statistics.
 func (c *Client) Stats() Stats {
 	stats := Stats{
-		WSConns:  c.ws.ConnCount(),
-		SSEConns: c.sse.ConnCount(),
+		WSConns:        c.ws.ConnCount(),
+		SSEConns:       c.sse.ConnCount(),
+		MultipartConns: c.multipart.ConnCount(),
+
+		WSSubscriptionMoves: c.ws.MovedSubscriptions(),
 	}
 	return stats
 }
@@ -22,6 +22,7 @@ func TestClient(t *testing.T) {
 
 		assert.NotNil(t, c.ws)
 		assert.NotNil(t, c.sse)
+		assert.NotNil(t, c.multipart)
 	})
 
 	t.Run("context cancellation is idempotent", func(t *testing.T) {
@@ -130,6 +131,47 @@ func TestClient_SubscriberDrain(t *testing.T) {
 	})
 }
 
+func TestClient_Multipart(t *testing.T) {
+	t.Run("multipart subscription is counted until cancelled", func(t *testing.T) {
+		defer goleak.VerifyNone(t, goleak.IgnoreAnyFunction("net/http/httptest.(*Server).goServe.func1"))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", `multipart/mixed;boundary="graphql";subscriptionSpec="1.0"`)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("\r\n--graphql\r\ncontent-type: application/json\r\n\r\n{\"payload\":{\"data\":{\"test\":1}}}\r\n--graphql\r\n"))
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}))
+		defer server.Close()
+
+		c := New(t.Context(), Config{})
+
+		ch := make(chan *common.Message, 1)
+		cancel, err := c.Subscribe(t.Context(), &Request{Query: "subscription { test }"}, Options{
+			Endpoint:  server.URL,
+			Transport: TransportMultipart,
+		}, func(msg *common.Message) {
+			ch <- msg
+		})
+		require.NoError(t, err)
+
+		select {
+		case msg := <-ch:
+			assert.Equal(t, MessageTypeData, msg.Type)
+			assert.JSONEq(t, `{"test":1}`, string(msg.Payload.Data))
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+		assert.Equal(t, Stats{MultipartConns: 1}, c.Stats())
+
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return c.Stats().MultipartConns == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
+
 func TestClient_CancelSendsComplete(t *testing.T) {
 	t.Run("cancel sends complete to server", func(t *testing.T) {
 		defer goleak.VerifyNone(t,
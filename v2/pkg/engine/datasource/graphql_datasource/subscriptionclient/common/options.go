@@ -8,8 +8,9 @@ import (
 type TransportType string
 
 const (
-	TransportWS  TransportType = "ws"  // WebSocket connection
-	TransportSSE TransportType = "sse" // Server-Sent Events over HTTP
+	TransportWS        TransportType = "ws"        // WebSocket connection
+	TransportSSE       TransportType = "sse"       // Server-Sent Events over HTTP
+	TransportMultipart TransportType = "multipart" // multipart/mixed HTTP responses (subscriptionSpec 1.0)
 )
 
 // WSSubprotocol selects the GraphQL-over-WebSocket subprotocol.
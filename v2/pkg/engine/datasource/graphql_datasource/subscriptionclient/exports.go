@@ -30,8 +30,9 @@ const (
 	MessageTypeComplete        = common.MessageTypeComplete
 	MessageTypeConnectionError = common.MessageTypeConnectionError
 
-	TransportWS  = common.TransportWS
-	TransportSSE = common.TransportSSE
+	TransportWS        = common.TransportWS
+	TransportSSE       = common.TransportSSE
+	TransportMultipart = common.TransportMultipart
 
 	SubprotocolAuto               = common.SubprotocolAuto
 	SubprotocolGraphQLTransportWS = common.SubprotocolGraphQLTransportWS
@@ -51,11 +52,12 @@ type (
 // Re-export sentinel errors.
 
 var (
-	ErrConnectionClosed   = common.ErrConnectionClosed
-	ErrConnectionError    = protocol.ErrConnectionError
-	ErrAckTimeout         = protocol.ErrAckTimeout
-	ErrAckNotReceived     = protocol.ErrAckNotReceived
-	ErrSubscriptionExists = transport.ErrSubscriptionExists
-	ErrDialFailed         = transport.ErrDialFailed
-	ErrInitFailed         = transport.ErrInitFailed
+	ErrConnectionClosed       = common.ErrConnectionClosed
+	ErrConnectionError        = protocol.ErrConnectionError
+	ErrAckTimeout             = protocol.ErrAckTimeout
+	ErrAckNotReceived         = protocol.ErrAckNotReceived
+	ErrSubscriptionExists     = transport.ErrSubscriptionExists
+	ErrDialFailed             = transport.ErrDialFailed
+	ErrInitFailed             = transport.ErrInitFailed
+	ErrConnectionLimitReached = transport.ErrConnectionLimitReached
 )
@@ -0,0 +1,221 @@
+package transport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource/subscriptionclient/common"
+)
+
+// multipartConnection handles a single multipart subscription stream.
+type multipartConnection struct {
+	resp *http.Response
+	// reader is nil for a single JSON response.
+	reader  *multipart.Reader
+	tail    *tailReader
+	handler common.Handler
+	closed  atomic.Bool
+	onClose func() // Callback function to notify parent transport that the connection was closed.
+}
+
+func newMultipartConnection(resp *http.Response, boundary string, handler common.Handler, onClose func()) *multipartConnection {
+	c := &multipartConnection{
+		resp:    resp,
+		handler: handler,
+		onClose: onClose,
+	}
+	if boundary != "" {
+		c.tail = newTailReader(resp.Body, "--"+boundary+"--")
+		c.reader = multipart.NewReader(c.tail, boundary)
+	}
+	return c
+}
+
+// readLoop reads the parts of the response body and delivers them to the handler.
+// Every exit path delivers a terminal message to the handler unless the connection
+// was closed by the consumer.
+func (c *multipartConnection) readLoop() {
+	defer c.cleanup()
+
+	if c.reader == nil {
+		c.readSingle()
+		return
+	}
+
+	for {
+		if c.closed.Load() {
+			return
+		}
+
+		part, err := c.reader.NextPart()
+		if err == io.EOF && c.tail.closed() {
+			// The closing boundary ends the stream normally
+			if c.closed.Load() {
+				return
+			}
+			c.handler(&common.Message{Type: common.MessageTypeComplete})
+			return
+		}
+		if err != nil {
+			if c.closed.Load() {
+				return
+			}
+			c.sendError(err)
+			return
+		}
+
+		data, err := io.ReadAll(part)
+		if err != nil {
+			if c.closed.Load() {
+				return
+			}
+			c.sendError(err)
+			return
+		}
+
+		msg := c.parsePart(data)
+
+		// Skip heartbeats
+		if msg == nil {
+			continue
+		}
+
+		if c.closed.Load() {
+			return
+		}
+		c.handler(msg)
+
+		if msg.Type.IsTerminal() {
+			return
+		}
+	}
+}
+
+// readSingle delivers a single JSON response, which ends the subscription.
+func (c *multipartConnection) readSingle() {
+	data, err := io.ReadAll(c.resp.Body)
+	if err != nil {
+		c.sendError(err)
+		return
+	}
+
+	var resp common.ExecutionResult
+	if err := json.Unmarshal(data, &resp); err != nil {
+		c.sendError(err)
+		return
+	}
+
+	if c.closed.Load() {
+		return
+	}
+	if len(resp.Data) == 0 || bytes.Equal(resp.Data, []byte("null")) {
+		c.handler(&common.Message{Type: common.MessageTypeError, Payload: &resp})
+		return
+	}
+	c.handler(&common.Message{Type: common.MessageTypeData, Payload: &resp})
+	if c.closed.Load() {
+		return
+	}
+	c.handler(&common.Message{Type: common.MessageTypeComplete})
+}
+
+// parsePart converts a part of the stream into a common.Message. It returns nil
+// for heartbeats, which are sent as empty JSON objects.
+//
+// Parts of the multipart subscription protocol wrap the execution result in a
+// payload field, a transport-level error has a null payload and top-level
+// errors. Parts without payload field are treated as plain execution results,
+// as sent by servers implementing incremental delivery over multipart.
+func (c *multipartConnection) parsePart(data []byte) *common.Message {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return &common.Message{Type: common.MessageTypeConnectionError, Err: err}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	payload, ok := fields["payload"]
+	if !ok {
+		var resp common.ExecutionResult
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return &common.Message{Type: common.MessageTypeConnectionError, Err: err}
+		}
+		return &common.Message{Type: common.MessageTypeData, Payload: &resp}
+	}
+
+	if len(payload) == 0 || bytes.Equal(payload, []byte("null")) {
+		return &common.Message{
+			Type:    common.MessageTypeError,
+			Payload: &common.ExecutionResult{Errors: fields["errors"]},
+		}
+	}
+
+	var resp common.ExecutionResult
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return &common.Message{Type: common.MessageTypeConnectionError, Err: err}
+	}
+	return &common.Message{Type: common.MessageTypeData, Payload: &resp}
+}
+
+// tailReader keeps the end of the stream read so far. The multipart reader
+// returns io.EOF for the closing boundary as well as for a stream closed after
+// a boundary, the tail tells them apart.
+type tailReader struct {
+	r       io.Reader
+	closing []byte
+	tail    []byte
+}
+
+func newTailReader(r io.Reader, closingBoundary string) *tailReader {
+	return &tailReader{r: r, closing: []byte(closingBoundary)}
+}
+
+func (t *tailReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	t.tail = append(t.tail, p[:n]...)
+	// Keep some room for the line break and whitespace after the boundary
+	if over := len(t.tail) - len(t.closing) - 16; over > 0 {
+		t.tail = append(t.tail[:0], t.tail[over:]...)
+	}
+	return n, err
+}
+
+// closed reports whether the stream read so far ends with the closing boundary.
+func (t *tailReader) closed() bool {
+	return bytes.HasSuffix(bytes.TrimRight(t.tail, " \t\r\n"), t.closing)
+}
+
+func (c *multipartConnection) sendError(err error) {
+	if c.closed.Load() {
+		return
+	}
+	c.handler(&common.Message{Type: common.MessageTypeConnectionError, Err: err})
+}
+
+func (c *multipartConnection) cleanup() {
+	c.closed.Store(true)
+	c.resp.Body.Close()
+
+	if c.onClose != nil {
+		c.onClose()
+	}
+}
+
+// closeConn terminates the multipart connection.
+func (c *multipartConnection) closeConn() {
+	if !c.closed.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.resp.Body.Close()
+}
@@ -0,0 +1,217 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource/subscriptionclient/common"
+)
+
+// multipartAcceptHeader requests the multipart subscription protocol, falling
+// back to a single JSON response for servers rejecting the operation.
+const multipartAcceptHeader = `multipart/mixed;subscriptionSpec="1.0", application/json`
+
+// defaultMultipartBoundary is used when the server omits the boundary parameter,
+// as allowed by the multipart subscription protocol.
+const defaultMultipartBoundary = "-"
+
+// MultipartTransport implements the Transport interface using the multipart
+// HTTP subscription protocol (multipart/mixed;subscriptionSpec="1.0") spoken by
+// Apollo Router and many Node.js GraphQL servers.
+//
+// Like SSE, each subscription creates a separate HTTP request. TCP connection
+// reuse is handled by http.Client's connection pool.
+type MultipartTransport struct {
+	ctx    context.Context
+	client *http.Client
+	log    abstractlogger.Logger
+
+	mu    sync.Mutex
+	conns map[*multipartConnection]struct{}
+}
+
+// NewMultipartTransport creates a new MultipartTransport with the provided http.Client.
+// The transport will automatically close all connections when ctx is cancelled.
+func NewMultipartTransport(ctx context.Context, client *http.Client, log abstractlogger.Logger) *MultipartTransport {
+	if log == nil {
+		log = abstractlogger.NoopLogger
+	}
+
+	t := &MultipartTransport{
+		ctx:    ctx,
+		client: client,
+		log:    log,
+		conns:  make(map[*multipartConnection]struct{}),
+	}
+
+	context.AfterFunc(ctx, t.closeAll)
+
+	return t
+}
+
+// Subscribe initiates a GraphQL subscription over a multipart HTTP response.
+// Each call creates a new POST request (no multiplexing).
+func (t *MultipartTransport) Subscribe(ctx context.Context, req *common.Request, opts common.Options, handler common.Handler) (func(), error) {
+	t.log.Debug("multipartTransport.Subscribe",
+		abstractlogger.String("endpoint", opts.Endpoint),
+	)
+
+	httpReq, err := buildMultipartRequest(req, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// See SSETransport.Subscribe: the request is cancelled by the transport
+	// shutdown and by the cancellation of the individual subscription.
+	requestCtx, requestCancel := context.WithCancel(context.WithoutCancel(ctx))
+	context.AfterFunc(t.ctx, requestCancel)
+	context.AfterFunc(ctx, requestCancel)
+
+	httpReq = httpReq.WithContext(requestCtx)
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		requestCancel()
+		t.log.Error("multipartTransport.Subscribe",
+			abstractlogger.String("endpoint", opts.Endpoint),
+			abstractlogger.Error(err),
+		)
+		return nil, fmt.Errorf("execute request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		requestCancel()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+		t.log.Error("multipartTransport.Subscribe",
+			abstractlogger.String("endpoint", opts.Endpoint),
+			abstractlogger.Int("status", resp.StatusCode),
+		)
+		if len(body) > 0 {
+			return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(body))
+		}
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	boundary, err := responseBoundary(resp)
+	if err != nil {
+		requestCancel()
+		resp.Body.Close()
+		return nil, err
+	}
+
+	t.log.Debug("multipartTransport.Subscribe",
+		abstractlogger.String("endpoint", opts.Endpoint),
+		abstractlogger.String("status", "connected"),
+	)
+
+	var conn *multipartConnection
+	// As with SSE, a connection whose read loop terminates removes itself from
+	// the transport so that completed streams don't leak until shutdown.
+	conn = newMultipartConnection(resp, boundary, handler, func() { t.removeConn(conn) })
+
+	t.mu.Lock()
+	t.conns[conn] = struct{}{}
+	t.mu.Unlock()
+
+	go conn.readLoop()
+
+	cancelFn := func() {
+		requestCancel()
+		conn.closeConn()
+		t.removeConn(conn)
+	}
+
+	return cancelFn, nil
+}
+
+// buildMultipartRequest creates a POST request with JSON body accepting a
+// multipart subscription response.
+func buildMultipartRequest(req *common.Request, opts common.Options) (*http.Request, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	// Add custom headers first, then set protocol-required headers so they cannot be overwritten
+	maps.Copy(httpReq.Header, opts.Headers)
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", multipartAcceptHeader)
+	httpReq.Header.Set("Cache-Control", "no-cache")
+
+	return httpReq, nil
+}
+
+// responseBoundary checks the content type of the response and returns the
+// boundary of a multipart/mixed response. An empty boundary is returned for a
+// single JSON response, which servers send instead of a multipart response
+// when they reject the operation, e.g. on validation errors.
+func responseBoundary(resp *http.Response) (string, error) {
+	contentType := resp.Header.Get("Content-Type")
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid content-type %q: %w", contentType, err)
+	}
+
+	switch {
+	case strings.EqualFold(mediaType, "application/json"), strings.EqualFold(mediaType, "application/graphql-response+json"):
+		return "", nil
+	case !strings.EqualFold(mediaType, "multipart/mixed"):
+		return "", fmt.Errorf("unexpected content-type: %s", contentType)
+	}
+
+	if boundary := params["boundary"]; boundary != "" {
+		return boundary, nil
+	}
+
+	return defaultMultipartBoundary, nil
+}
+
+func (t *MultipartTransport) removeConn(conn *multipartConnection) {
+	t.mu.Lock()
+	delete(t.conns, conn)
+	t.mu.Unlock()
+}
+
+// closeAll terminates all active multipart connections. Called automatically when context is cancelled.
+func (t *MultipartTransport) closeAll() {
+	t.mu.Lock()
+	conns := make([]*multipartConnection, 0, len(t.conns))
+	for conn := range t.conns {
+		conns = append(conns, conn)
+	}
+	t.conns = make(map[*multipartConnection]struct{})
+	t.mu.Unlock()
+
+	t.log.Debug("multipartTransport.closeAll",
+		abstractlogger.Int("connections", len(conns)),
+	)
+
+	for _, conn := range conns {
+		conn.closeConn()
+	}
+}
+
+// ConnCount returns the number of active multipart connections.
+func (t *MultipartTransport) ConnCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.conns)
+}
@@ -0,0 +1,396 @@
+package transport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/graphql_datasource/subscriptionclient/common"
+)
+
+const multipartContentType = `multipart/mixed;boundary="graphql";subscriptionSpec="1.0"`
+
+// writePart writes a part of a multipart subscription response followed by the
+// boundary, which the client needs to see before it can deliver the part.
+func writePart(w http.ResponseWriter, body string) {
+	fmt.Fprintf(w, "content-type: application/json\r\n\r\n%s\r\n--graphql\r\n", body)
+	w.(http.Flusher).Flush()
+}
+
+// writeMultipartHeader writes the response header and the first boundary.
+func writeMultipartHeader(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", multipartContentType)
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "\r\n--graphql\r\n")
+}
+
+func newMultipartServer(t *testing.T, handler func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestMultipartTransport_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sends POST request and receives messages", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedBody map[string]any
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, `multipart/mixed;subscriptionSpec="1.0", application/json`, r.Header.Get("Accept"))
+
+			body, _ := io.ReadAll(r.Body)
+			assert.NoError(t, json.Unmarshal(body, &receivedBody))
+
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":{"value":42}}}`)
+			fmt.Fprint(w, "content-type: application/json\r\n\r\n{\"payload\":{\"data\":{\"value\":43}}}\r\n--graphql--\r\n")
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query:         "subscription { test }",
+			Variables:     []byte(`{"id": 123}`),
+			OperationName: "TestSub",
+		}, common.Options{
+			Endpoint:  server.URL,
+			Transport: common.TransportMultipart,
+		}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		assert.Equal(t, "subscription { test }", receivedBody["query"])
+		assert.Equal(t, float64(123), receivedBody["variables"].(map[string]any)["id"])
+		assert.Equal(t, "TestSub", receivedBody["operationName"])
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+		assert.JSONEq(t, `{"value":42}`, string(msg.Payload.Data))
+
+		msg = receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+		assert.JSONEq(t, `{"value":43}`, string(msg.Payload.Data))
+
+		// The closing boundary completes the subscription
+		msg = receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeComplete, msg.Type)
+
+		assert.Eventually(t, func() bool {
+			return tr.ConnCount() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("passes custom headers", func(t *testing.T) {
+		t.Parallel()
+
+		var receivedAuth, receivedAccept string
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			receivedAuth = r.Header.Get("Authorization")
+			receivedAccept = r.Header.Get("Accept")
+
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":{}}}`)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{
+			Endpoint: server.URL,
+			Headers: http.Header{
+				"Authorization": []string{"Bearer token123"},
+				"Accept":        []string{"text/html"},
+			},
+		}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		receive(t, time.Second)
+
+		assert.Equal(t, "Bearer token123", receivedAuth)
+		assert.Equal(t, multipartAcceptHeader, receivedAccept)
+	})
+
+	t.Run("skips heartbeats", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{}`)
+			writePart(w, `{}`)
+			writePart(w, `{"payload":{"data":{"value":1}}}`)
+			writePart(w, `{}`)
+			fmt.Fprint(w, "content-type: application/json\r\n\r\n{}\r\n--graphql--\r\n")
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, collect := waitForMessages(func(*common.Message) {})
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msgs := collect(time.Second)
+		require.Len(t, msgs, 2)
+		assert.Equal(t, common.MessageTypeData, msgs[0].Type)
+		assert.JSONEq(t, `{"value":1}`, string(msgs[0].Payload.Data))
+		assert.Equal(t, common.MessageTypeComplete, msgs[1].Type)
+	})
+
+	t.Run("payload with errors is delivered as data", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":null,"errors":[{"message":"resolver failed"}]}}`)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+		assert.JSONEq(t, `[{"message":"resolver failed"}]`, string(msg.Payload.Errors))
+	})
+
+	t.Run("transport error ends the subscription", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":null,"errors":[{"message":"subgraph unavailable"}]}`)
+			<-r.Context().Done()
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeError, msg.Type)
+		assert.JSONEq(t, `[{"message":"subgraph unavailable"}]`, string(msg.Payload.Errors))
+
+		assert.Eventually(t, func() bool {
+			return tr.ConnCount() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("parts without payload are execution results", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"data":{"value":7}}`)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+		assert.JSONEq(t, `{"value":7}`, string(msg.Payload.Data))
+	})
+
+	t.Run("handles server closing stream without closing boundary", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":{}}}`)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+
+		msg = receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeConnectionError, msg.Type)
+		assert.ErrorIs(t, msg.Err, io.EOF)
+	})
+
+	t.Run("single JSON response ends the subscription", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			fmt.Fprint(w, `{"errors":[{"message":"Cannot query field \"unknown\""}]}`)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { unknown }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeError, msg.Type)
+		assert.JSONEq(t, `[{"message":"Cannot query field \"unknown\""}]`, string(msg.Payload.Errors))
+	})
+
+	t.Run("cancel closes connection", func(t *testing.T) {
+		t.Parallel()
+
+		serverClosed := make(chan struct{})
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":{}}}`)
+
+			<-r.Context().Done()
+			close(serverClosed)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, handler)
+		require.NoError(t, err)
+
+		receive(t, time.Second)
+
+		assert.Equal(t, 1, tr.ConnCount())
+
+		cancel()
+
+		select {
+		case <-serverClosed:
+		case <-time.After(time.Second):
+			t.Fatal("server did not detect disconnect")
+		}
+
+		assert.Eventually(t, func() bool {
+			return tr.ConnCount() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("handles non-200 response", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		_, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, func(_ *common.Message) {})
+
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401")
+	})
+
+	t.Run("rejects unexpected content type", func(t *testing.T) {
+		t.Parallel()
+
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+		})
+
+		tr := NewMultipartTransport(t.Context(), http.DefaultClient, nil)
+
+		_, err := tr.Subscribe(context.Background(), &common.Request{
+			Query: "subscription { test }",
+		}, common.Options{Endpoint: server.URL}, func(_ *common.Message) {})
+
+		require.EqualError(t, err, "unexpected content-type: text/event-stream")
+		assert.Equal(t, 0, tr.ConnCount())
+	})
+}
+
+func TestMultipartTransport_ContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	t.Run("context cancellation closes all connections", func(t *testing.T) {
+		t.Parallel()
+
+		var closedCount atomic.Int32
+		server := newMultipartServer(t, func(w http.ResponseWriter, r *http.Request) {
+			writeMultipartHeader(w)
+			writePart(w, `{"payload":{"data":{}}}`)
+
+			<-r.Context().Done()
+			closedCount.Add(1)
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		tr := NewMultipartTransport(ctx, http.DefaultClient, nil)
+
+		opts := common.Options{Endpoint: server.URL, Transport: common.TransportMultipart}
+
+		handler1, receive1 := collectingHandler()
+		_, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { a }"}, opts, handler1)
+		require.NoError(t, err)
+
+		handler2, receive2 := collectingHandler()
+		_, err = tr.Subscribe(context.Background(), &common.Request{Query: "subscription { b }"}, opts, handler2)
+		require.NoError(t, err)
+
+		receive1(t, time.Second)
+		receive2(t, time.Second)
+
+		assert.Equal(t, 2, tr.ConnCount())
+
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return closedCount.Load() == 2
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			return tr.ConnCount() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+}
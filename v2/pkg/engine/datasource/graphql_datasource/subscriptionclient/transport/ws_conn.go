@@ -17,11 +17,16 @@ import (
 
 var ErrSubscriptionExists = errors.New("subscription ID already exists")
 
+// errConnectionFull is returned by subscribe when the connection holds its
+// maximum number of subscriptions.
+var errConnectionFull = errors.New("connection is full")
+
 type wsConnectionOptions struct {
-	logger       abstractlogger.Logger
-	writeTimeout time.Duration
-	idleTimeout  time.Duration
-	onEmpty      func()
+	logger           abstractlogger.Logger
+	writeTimeout     time.Duration
+	idleTimeout      time.Duration
+	maxSubscriptions int
+	onEmpty          func()
 }
 
 type wsConnection struct {
@@ -42,6 +47,10 @@ type wsConnection struct {
 	onEmpty     func()
 	idleTimeout time.Duration
 
+	// maxSubscriptions limits the subscriptions of the connection, zero
+	// means no limit.
+	maxSubscriptions int
+
 	writeTimeout time.Duration
 
 	// Ping/pong tracking for client-initiated heartbeats.
@@ -66,8 +75,9 @@ func newWSConnection(conn *websocket.Conn, proto protocol.Protocol, opts wsConne
 		subs:     make(map[string]common.Handler),
 		onEmpty:  opts.onEmpty,
 
-		writeTimeout: opts.writeTimeout,
-		idleTimeout:  opts.idleTimeout,
+		writeTimeout:     opts.writeTimeout,
+		idleTimeout:      opts.idleTimeout,
+		maxSubscriptions: opts.maxSubscriptions,
 	}
 
 	c.lastPongAt.Store(time.Now().UnixNano())
@@ -91,6 +101,11 @@ func (c *wsConnection) subscribe(ctx context.Context, id string, req *common.Req
 		return nil, ErrSubscriptionExists
 	}
 
+	if c.maxSubscriptions > 0 && len(c.subs) >= c.maxSubscriptions {
+		c.subsMu.Unlock()
+		return nil, errConnectionFull
+	}
+
 	c.subs[id] = handler
 	c.subsMu.Unlock()
 
@@ -6,7 +6,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
@@ -27,6 +29,11 @@ var ErrDialFailed = errors.New("websocket dial failed")
 // underlying cause (e.g. protocol.ErrAckTimeout) is available via errors.Unwrap.
 var ErrInitFailed = errors.New("protocol init failed")
 
+// ErrConnectionLimitReached indicates that every connection to an upstream
+// holds MaxSubscriptionsPerConn subscriptions and no further connection may be
+// dialed because of MaxConnsPerUpstream.
+var ErrConnectionLimitReached = errors.New("websocket connection limit reached")
+
 type ErrFailedUpgrade struct {
 	URL        string
 	StatusCode int
@@ -74,6 +81,22 @@ type WSTransportOptions struct {
 	// subscription is removed, allowing new subscriptions to reuse it without
 	// re-dialing. Zero means close immediately.
 	IdleTimeout time.Duration
+
+	// MaxSubscriptionsPerConn is the maximum number of subscriptions
+	// multiplexed onto a single connection. A new connection is dialed once
+	// every connection to the upstream is full. Zero means no limit.
+	MaxSubscriptionsPerConn int
+
+	// MaxConnsPerUpstream is the maximum number of connections to the same
+	// upstream (endpoint, subprotocol, headers, and init payload). Only
+	// meaningful when MaxSubscriptionsPerConn is set. Zero means no limit.
+	MaxConnsPerUpstream int
+
+	// ResubscribeOnConnLoss moves the subscriptions of a lost connection, e.g.
+	// closed by the server or timed out on a ping, to another connection by
+	// subscribing again instead of delivering a connection error. The error is
+	// delivered when the subscription can't be moved.
+	ResubscribeOnConnLoss bool
 }
 
 type WSTransport struct {
@@ -90,7 +113,11 @@ type WSTransport struct {
 	// ready state.
 	mu      sync.Mutex
 	dialing map[uint64]*dialResult
-	conns   map[uint64]*wsConnection
+	conns   map[uint64][]*wsConnection
+
+	// moves counts the subscriptions moved to another connection after
+	// their connection was lost.
+	moves atomic.Int64
 }
 
 type dialResult struct {
@@ -118,7 +145,7 @@ func NewWSTransport(ctx context.Context, opts WSTransportOptions) *WSTransport {
 	t := &WSTransport{
 		ctx:     ctx,
 		opts:    opts,
-		conns:   make(map[uint64]*wsConnection),
+		conns:   make(map[uint64][]*wsConnection),
 		dialing: make(map[uint64]*dialResult),
 	}
 
@@ -131,15 +158,92 @@ func NewWSTransport(ctx context.Context, opts WSTransportOptions) *WSTransport {
 
 // Subscribe initiates a GraphQL subscription over WebSocket. It reuses an
 // existing connection when one is available for the same endpoint, subprotocol,
-// headers, and init payload, dialing a new one otherwise.
+// headers, and init payload, dialing a new one otherwise. With
+// MaxSubscriptionsPerConn set, the subscription goes to the connection with the
+// fewest subscriptions that isn't full.
 func (t *WSTransport) Subscribe(ctx context.Context, req *common.Request, opts common.Options, handler common.Handler) (func(), error) {
-	conn, err := t.getOrDial(ctx, opts)
+	sub := &wsSubscription{
+		ctx:     ctx,
+		id:      xid.New().String(),
+		req:     req,
+		opts:    opts,
+		handler: handler,
+	}
+
+	unsubscribe, err := t.subscribe(sub)
 	if err != nil {
 		return nil, err
 	}
+	sub.subscribed(unsubscribe)
+
+	return sub.cancel, nil
+}
 
-	id := xid.New().String()
-	return conn.subscribe(ctx, id, req, handler)
+// subscribe adds the subscription to a connection with capacity left. The
+// connection may fill up between picking and subscribing, the subscription is
+// then retried on another one.
+func (t *WSTransport) subscribe(sub *wsSubscription) (func(), error) {
+	for {
+		conn, err := t.getOrDial(sub.ctx, sub.opts)
+		if err != nil {
+			return nil, err
+		}
+
+		unsubscribe, err := conn.subscribe(sub.ctx, sub.id, sub.req, t.subscriptionHandler(sub, conn))
+		if errors.Is(err, errConnectionFull) {
+			continue
+		}
+		return unsubscribe, err
+	}
+}
+
+// subscriptionHandler delivers the messages of conn to the handler of the
+// subscription. With ResubscribeOnConnLoss, the connection error of a lost
+// connection moves the subscription instead.
+func (t *WSTransport) subscriptionHandler(sub *wsSubscription, conn *wsConnection) common.Handler {
+	if !t.opts.ResubscribeOnConnLoss {
+		return sub.handler
+	}
+	return func(msg *common.Message) {
+		// A connection error of an open connection is an error of this
+		// subscription only, e.g. an error message without payload.
+		if msg.Type == common.MessageTypeConnectionError && conn.isClosed() {
+			go t.resubscribe(sub, msg)
+			return
+		}
+		sub.handler(msg)
+	}
+}
+
+// resubscribe moves a subscription of a lost connection to another connection.
+// The connection error msg is delivered when this fails.
+func (t *WSTransport) resubscribe(sub *wsSubscription, msg *common.Message) {
+	if sub.isCancelled() || sub.ctx.Err() != nil {
+		return
+	}
+
+	t.opts.Logger.Debug("wsTransport.resubscribe",
+		abstractlogger.String("endpoint", sub.opts.Endpoint),
+		abstractlogger.String("id", sub.id),
+	)
+
+	unsubscribe, err := t.subscribe(sub)
+	if err != nil {
+		t.opts.Logger.Error("wsTransport.resubscribe",
+			abstractlogger.String("endpoint", sub.opts.Endpoint),
+			abstractlogger.String("id", sub.id),
+			abstractlogger.Error(err),
+		)
+		sub.handler(msg)
+		return
+	}
+
+	if !sub.moved(unsubscribe) {
+		unsubscribe()
+		return
+	}
+
+	t.moves.Add(1)
 }
 
 // pingLoop sends periodic pings to all active connections and shuts down
@@ -153,8 +257,8 @@ func (t *WSTransport) pingLoop() {
 		case <-tick:
 			t.mu.Lock()
 			conns := make([]*wsConnection, 0, len(t.conns))
-			for _, conn := range t.conns {
-				conns = append(conns, conn)
+			for _, upstreamConns := range t.conns {
+				conns = append(conns, upstreamConns...)
 			}
 			t.mu.Unlock()
 
@@ -186,7 +290,17 @@ func (t *WSTransport) ConnCount() int {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	return len(t.conns)
+	count := 0
+	for _, conns := range t.conns {
+		count += len(conns)
+	}
+	return count
+}
+
+// MovedSubscriptions returns the number of subscriptions moved to another
+// connection after their connection was lost.
+func (t *WSTransport) MovedSubscriptions() int {
+	return int(t.moves.Load())
 }
 
 func (t *WSTransport) getOrDial(ctx context.Context, opts common.Options) (*wsConnection, error) {
@@ -194,12 +308,22 @@ func (t *WSTransport) getOrDial(ctx context.Context, opts common.Options) (*wsCo
 
 	t.mu.Lock()
 
-	if conn, ok := t.conns[key]; ok && !conn.isClosed() {
-		t.mu.Unlock()
-		return conn, nil
-	}
+	for {
+		conn, open := t.pickConn(key)
+		if conn != nil {
+			t.mu.Unlock()
+			return conn, nil
+		}
+
+		result, ok := t.dialing[key]
+		if !ok {
+			if t.opts.MaxConnsPerUpstream > 0 && open >= t.opts.MaxConnsPerUpstream {
+				t.mu.Unlock()
+				return nil, ErrConnectionLimitReached
+			}
+			break
+		}
 
-	if result, ok := t.dialing[key]; ok {
 		t.mu.Unlock()
 		select {
 		case <-ctx.Done():
@@ -211,7 +335,9 @@ func (t *WSTransport) getOrDial(ctx context.Context, opts common.Options) (*wsCo
 			return nil, result.err
 		}
 
-		return result.conn, nil
+		// Another subscription may have taken the last free slot of the
+		// new connection in the meantime, so pick again.
+		t.mu.Lock()
 	}
 
 	result := &dialResult{done: make(chan struct{})}
@@ -228,13 +354,38 @@ func (t *WSTransport) getOrDial(ctx context.Context, opts common.Options) (*wsCo
 	delete(t.dialing, key)
 
 	if err == nil {
-		t.conns[key] = conn
+		t.conns[key] = append(t.conns[key], conn)
 	}
 	t.mu.Unlock()
 
 	return conn, err
 }
 
+// pickConn returns the open connection for key with the fewest subscriptions
+// that isn't full, and the number of open connections. Must be called with
+// t.mu held.
+func (t *WSTransport) pickConn(key uint64) (*wsConnection, int) {
+	var (
+		picked *wsConnection
+		fewest int
+		open   int
+	)
+	for _, conn := range t.conns[key] {
+		if conn.isClosed() {
+			continue
+		}
+		open++
+		count := conn.subCount()
+		if t.opts.MaxSubscriptionsPerConn > 0 && count >= t.opts.MaxSubscriptionsPerConn {
+			continue
+		}
+		if picked == nil || count < fewest {
+			picked, fewest = conn, count
+		}
+	}
+	return picked, open
+}
+
 func (t *WSTransport) dial(ctx context.Context, key uint64, opts common.Options) (*wsConnection, error) {
 	t.opts.Logger.Debug("wsTransport.dial",
 		abstractlogger.String("endpoint", opts.Endpoint),
@@ -296,11 +447,13 @@ func (t *WSTransport) dial(ctx context.Context, key uint64, opts common.Options)
 		abstractlogger.String("negotiated_subprotocol", wsConn.Subprotocol()),
 	)
 
-	conn := newWSConnection(wsConn, proto, wsConnectionOptions{
-		logger:       t.opts.Logger,
-		writeTimeout: t.opts.WriteTimeout,
-		idleTimeout:  t.opts.IdleTimeout,
-		onEmpty:      func() { t.removeConn(key) },
+	var conn *wsConnection
+	conn = newWSConnection(wsConn, proto, wsConnectionOptions{
+		logger:           t.opts.Logger,
+		writeTimeout:     t.opts.WriteTimeout,
+		idleTimeout:      t.opts.IdleTimeout,
+		maxSubscriptions: t.opts.MaxSubscriptionsPerConn,
+		onEmpty:          func() { t.removeConn(key, conn) },
 	})
 
 	go conn.readLoop()
@@ -331,10 +484,72 @@ func (t *WSTransport) negotiateSubprotocol(requested common.WSSubprotocol, accep
 	}
 }
 
-func (t *WSTransport) removeConn(key uint64) {
+func (t *WSTransport) removeConn(key uint64, conn *wsConnection) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
-	delete(t.conns, key)
+
+	conns := slices.DeleteFunc(t.conns[key], func(c *wsConnection) bool { return c == conn })
+	if len(conns) == 0 {
+		delete(t.conns, key)
+		return
+	}
+	t.conns[key] = conns
+}
+
+// wsSubscription is a subscription of the transport. Its connection may
+// change when the subscription is moved after a connection loss.
+type wsSubscription struct {
+	ctx     context.Context
+	id      string
+	req     *common.Request
+	opts    common.Options
+	handler common.Handler
+
+	mu          sync.Mutex
+	unsubscribe func()
+	cancelled   bool
+}
+
+// cancel unsubscribes from the current connection of the subscription.
+func (s *wsSubscription) cancel() {
+	s.mu.Lock()
+	s.cancelled = true
+	unsubscribe := s.unsubscribe
+	s.mu.Unlock()
+
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// subscribed sets the unsubscribe function of the first connection, unless the
+// subscription was already moved away from it.
+func (s *wsSubscription) subscribed(unsubscribe func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.unsubscribe == nil {
+		s.unsubscribe = unsubscribe
+	}
+}
+
+// moved replaces the unsubscribe function after a move. It returns false if
+// the subscription was cancelled while moving.
+func (s *wsSubscription) moved(unsubscribe func()) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelled {
+		return false
+	}
+	s.unsubscribe = unsubscribe
+	return true
+}
+
+func (s *wsSubscription) isCancelled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cancelled
 }
 
 // connKey computes a hash key for connection pooling.
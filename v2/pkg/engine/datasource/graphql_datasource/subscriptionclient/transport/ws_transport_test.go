@@ -432,6 +432,228 @@ func TestWSTransport_ConcurrentSubscribe(t *testing.T) {
 	})
 }
 
+func TestWSTransport_MultiplexingLimits(t *testing.T) {
+	t.Parallel()
+
+	// echoServer answers every subscribe with a next message carrying the
+	// number of the connection, starting at 1.
+	echoServer := func(t *testing.T, dialCount *atomic.Int32) *httptest.Server {
+		return newGraphQLWSServer(t, func(ctx context.Context, conn *websocket.Conn) {
+			connNumber := dialCount.Add(1)
+
+			for {
+				var msg map[string]any
+				if err := wsjson.Read(ctx, conn, &msg); err != nil {
+					return
+				}
+
+				if msg["type"] == "subscribe" {
+					_ = wsjson.Write(ctx, conn, map[string]any{
+						"id":      msg["id"],
+						"type":    "next",
+						"payload": map[string]any{"data": map[string]any{"conn": connNumber}},
+					})
+				}
+			}
+		})
+	}
+
+	t.Run("dials a new connection once all connections are full", func(t *testing.T) {
+		t.Parallel()
+
+		var dialCount atomic.Int32
+		server := echoServer(t, &dialCount)
+
+		tr := newTestWSTransport(t, WSTransportOptions{
+			MaxSubscriptionsPerConn: 2,
+		})
+
+		opts := common.Options{Endpoint: server.URL, Transport: common.TransportWS}
+
+		var conns []string
+		for range 5 {
+			handler, receive := collectingHandler()
+			cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, opts, handler)
+			require.NoError(t, err)
+			defer cancel()
+
+			conns = append(conns, string(receive(t, time.Second).Payload.Data))
+		}
+
+		assert.Equal(t, []string{`{"conn":1}`, `{"conn":1}`, `{"conn":2}`, `{"conn":2}`, `{"conn":3}`}, conns)
+		assert.Equal(t, 3, tr.ConnCount())
+	})
+
+	t.Run("new subscriptions go to the connection with the fewest subscriptions", func(t *testing.T) {
+		t.Parallel()
+
+		var dialCount atomic.Int32
+		server := echoServer(t, &dialCount)
+
+		tr := newTestWSTransport(t, WSTransportOptions{
+			MaxSubscriptionsPerConn: 2,
+			IdleTimeout:             30 * time.Second,
+		})
+
+		opts := common.Options{Endpoint: server.URL, Transport: common.TransportWS}
+
+		subscribe := func() (func(), string) {
+			handler, receive := collectingHandler()
+			cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, opts, handler)
+			require.NoError(t, err)
+			return cancel, string(receive(t, time.Second).Payload.Data)
+		}
+
+		cancel1, _ := subscribe()
+		cancel2, _ := subscribe()
+		cancel3, conn := subscribe()
+		defer cancel3()
+		assert.Equal(t, `{"conn":2}`, conn)
+
+		// The first connection is idle now, the second one holds a subscription
+		cancel1()
+		cancel2()
+
+		cancel4, conn := subscribe()
+		defer cancel4()
+		assert.Equal(t, `{"conn":1}`, conn)
+		assert.Equal(t, int32(2), dialCount.Load())
+	})
+
+	t.Run("fails when the connection limit is reached", func(t *testing.T) {
+		t.Parallel()
+
+		var dialCount atomic.Int32
+		server := echoServer(t, &dialCount)
+
+		tr := newTestWSTransport(t, WSTransportOptions{
+			MaxSubscriptionsPerConn: 1,
+			MaxConnsPerUpstream:     2,
+		})
+
+		opts := common.Options{Endpoint: server.URL, Transport: common.TransportWS}
+
+		cancels := make([]func(), 0, 2)
+		for range 2 {
+			handler, receive := collectingHandler()
+			cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, opts, handler)
+			require.NoError(t, err)
+			cancels = append(cancels, cancel)
+			receive(t, time.Second)
+		}
+
+		_, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, opts, func(*common.Message) {})
+		require.ErrorIs(t, err, ErrConnectionLimitReached)
+
+		cancels[0]()
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, opts, handler)
+		require.NoError(t, err)
+		defer cancel()
+		defer cancels[1]()
+		receive(t, time.Second)
+	})
+
+	t.Run("moves subscriptions of a lost connection", func(t *testing.T) {
+		t.Parallel()
+
+		var dialCount atomic.Int32
+		server := newGraphQLWSServer(t, func(ctx context.Context, conn *websocket.Conn) {
+			connNumber := dialCount.Add(1)
+
+			for {
+				var msg map[string]any
+				if err := wsjson.Read(ctx, conn, &msg); err != nil {
+					return
+				}
+
+				if msg["type"] == "subscribe" {
+					_ = wsjson.Write(ctx, conn, map[string]any{
+						"id":      msg["id"],
+						"type":    "next",
+						"payload": map[string]any{"data": map[string]any{"conn": connNumber}},
+					})
+					if connNumber == 1 {
+						_ = conn.Close(websocket.StatusGoingAway, "restarting")
+						return
+					}
+				}
+			}
+		})
+
+		tr := newTestWSTransport(t, WSTransportOptions{
+			ResubscribeOnConnLoss: true,
+		})
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, common.Options{
+			Endpoint:  server.URL,
+			Transport: common.TransportWS,
+		}, handler)
+		require.NoError(t, err)
+
+		msg := receive(t, time.Second)
+		assert.JSONEq(t, `{"conn":1}`, string(msg.Payload.Data))
+
+		// The subscription continues on a new connection without an error
+		msg = receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeData, msg.Type)
+		assert.JSONEq(t, `{"conn":2}`, string(msg.Payload.Data))
+		assert.Equal(t, 1, tr.MovedSubscriptions())
+		assert.Equal(t, 1, tr.ConnCount())
+
+		// Cancelling unsubscribes from the new connection
+		cancel()
+
+		assert.Eventually(t, func() bool {
+			return tr.ConnCount() == 0
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("delivers the connection error when the subscription can't be moved", func(t *testing.T) {
+		t.Parallel()
+
+		var dialCount atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if dialCount.Add(1) > 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+				Subprotocols: []string{"graphql-transport-ws"},
+			})
+			if err != nil {
+				return
+			}
+
+			var msg map[string]any
+			_ = wsjson.Read(r.Context(), conn, &msg)
+			_ = wsjson.Write(r.Context(), conn, map[string]string{"type": "connection_ack"})
+			_ = wsjson.Read(r.Context(), conn, &msg)
+			_ = conn.Close(websocket.StatusGoingAway, "restarting")
+		}))
+		t.Cleanup(server.Close)
+
+		tr := newTestWSTransport(t, WSTransportOptions{
+			ResubscribeOnConnLoss: true,
+		})
+
+		handler, receive := collectingHandler()
+		cancel, err := tr.Subscribe(context.Background(), &common.Request{Query: "subscription { test }"}, common.Options{
+			Endpoint:  server.URL,
+			Transport: common.TransportWS,
+		}, handler)
+		require.NoError(t, err)
+		defer cancel()
+
+		msg := receive(t, time.Second)
+		assert.Equal(t, common.MessageTypeConnectionError, msg.Type)
+		assert.ErrorIs(t, msg.Err, common.ErrConnectionClosed)
+		assert.Equal(t, 0, tr.MovedSubscriptions())
+	})
+}
+
 func TestWSTransport_InitPayloadForwarding(t *testing.T) {
 	t.Parallel()
 
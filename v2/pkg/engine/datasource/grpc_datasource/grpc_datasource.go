@@ -121,18 +121,41 @@ func (d *DataSource) Load(ctx context.Context, headers http.Header, input []byte
 		return nil, fmt.Errorf("gRPC / connect configuration requires an rpc transport")
 	}
 
-	// convert headers to grpc metadata and attach to ctx
-	if len(headers) > 0 {
-		// assume that each header has exactly one value for default pairs size
-		pairs := make([]string, 0, len(headers)*2)
-		for headerName, headerValues := range headers {
-			headerName = strings.ToLower(headerName)
-			for _, v := range headerValues {
-				pairs = append(pairs, headerName, v)
-			}
+	return d.resolve(withHeaderMetadata(ctx, headers), input, variables, builder, d.invoke), nil
+}
+
+// invokeFunc performs a call of the execution plan and populates the output
+// of the service call.
+type invokeFunc func(ctx context.Context, serviceCall *ServiceCall) error
+
+func (d *DataSource) invoke(ctx context.Context, serviceCall *ServiceCall) error {
+	return d.transport.Invoke(ctx, serviceCall.MethodFullName(), serviceCall.Input, serviceCall.Output)
+}
+
+// withHeaderMetadata converts headers to gRPC metadata and attaches it to ctx.
+func withHeaderMetadata(ctx context.Context, headers http.Header) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	// assume that each header has exactly one value for default pairs size
+	pairs := make([]string, 0, len(headers)*2)
+	for headerName, headerValues := range headers {
+		headerName = strings.ToLower(headerName)
+		for _, v := range headerValues {
+			pairs = append(pairs, headerName, v)
 		}
-		ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
 	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// resolve performs the calls of the execution plan in the order of their
+// dependencies and returns the response, or the errors of a failed call
+// written with builder.
+func (d *DataSource) resolve(ctx context.Context, input []byte, variables gjson.Result, builder *jsonBuilder, invoke invokeFunc) []byte {
+	var poolItems []*arena.PoolItem
+	defer func() {
+		d.pool.ReleaseMany(poolItems)
+	}()
 
 	graph := NewDependencyGraph(d.plan)
 
@@ -156,7 +179,7 @@ func (d *DataSource) Load(ctx context.Context, headers http.Header, input []byte
 			builder := newJSONBuilder(item.Arena, d.mapping, variables)
 			errGrp.Go(func() error {
 				// Invoke the gRPC method - this will populate serviceCall.Output
-				err := d.transport.Invoke(errGrpCtx, serviceCall.MethodFullName(), serviceCall.Input, serviceCall.Output)
+				err := invoke(errGrpCtx, &serviceCall)
 				if err != nil {
 					return err
 				}
@@ -206,11 +229,11 @@ func (d *DataSource) Load(ctx context.Context, headers http.Header, input []byte
 
 		return nil
 	}); err != nil {
-		return builder.writeErrorBytes(err), nil
+		return builder.writeErrorBytes(err)
 	}
 
 	value := builder.toDataObject(root)
-	return value.MarshalTo(nil), err
+	return value.MarshalTo(nil)
 }
 
 func (d *DataSource) acquirePoolItem(input []byte, index int) *arena.PoolItem {
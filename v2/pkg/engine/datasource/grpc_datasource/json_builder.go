@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"connectrpc.com/connect"
 	"github.com/tidwall/gjson"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -593,9 +594,13 @@ func (j *jsonBuilder) writeErrorBytes(err error) []byte {
 
 	// Add gRPC status code information to extensions
 	extensions := astjson.ObjectValue(j.jsonArena)
+	var connectErr *connect.Error
 	if st, ok := status.FromError(err); ok {
 		// gRPC error - include the specific status code
 		extensions.Set(j.jsonArena, "code", astjson.StringValue(j.jsonArena, st.Code().String()))
+	} else if errors.As(err, &connectErr) {
+		// Connect error - Connect codes share their values with gRPC status codes
+		extensions.Set(j.jsonArena, "code", astjson.StringValue(j.jsonArena, codes.Code(connectErr.Code()).String()))
 	} else {
 		// Generic error - default to INTERNAL status
 		extensions.Set(j.jsonArena, "code", astjson.StringValue(j.jsonArena, codes.Internal.String()))
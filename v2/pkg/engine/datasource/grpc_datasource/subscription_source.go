@@ -0,0 +1,155 @@
+package grpcdatasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/tidwall/gjson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/internal/unsafebytes"
+)
+
+// Verify SubscriptionSource implements the resolve.SubscriptionDataSource interface
+var _ resolve.SubscriptionDataSource = (*SubscriptionSource)(nil)
+
+// SubscriptionSource implements the resolve.SubscriptionDataSource interface
+// for gRPC services. The root field of a subscription is resolved with a
+// server-streaming RPC of a StreamingRPCTransport.
+//
+// Every message the service streams is turned into a response the same way
+// DataSource turns the response of a unary call into one, including the calls
+// of field resolvers, and sent to the subscribers as an update. The stream
+// ending successfully completes the subscription, a status error ends it with
+// that error.
+type SubscriptionSource struct {
+	ds *DataSource
+	// rootCallID is the call of the execution plan resolving the root field
+	// of the subscription.
+	rootCallID int
+}
+
+// NewSubscriptionSource creates a new subscription datasource with the given
+// RPCTransport, which has to implement StreamingRPCTransport to start
+// subscriptions.
+func NewSubscriptionSource(transport RPCTransport, config DataSourceConfig) (*SubscriptionSource, error) {
+	ds, err := NewDataSource(transport, config)
+	if err != nil {
+		return nil, err
+	}
+
+	rootCallID := -1
+	for _, call := range ds.plan.Calls {
+		if call.Kind != CallKindStandard || len(call.DependentCalls) > 0 {
+			continue
+		}
+		if rootCallID != -1 {
+			return nil, fmt.Errorf("a subscription must be resolved by exactly one server-streaming rpc")
+		}
+		rootCallID = call.ID
+	}
+	if rootCallID == -1 {
+		return nil, fmt.Errorf("no rpc mapping found for the subscription root field")
+	}
+
+	return &SubscriptionSource{
+		ds:         ds,
+		rootCallID: rootCallID,
+	}, nil
+}
+
+// HashTriggerInput implements resolve.SubscriptionDataSource interface.
+func (s *SubscriptionSource) HashTriggerInput(input []byte, xxh *xxhash.Digest) error {
+	_, err := xxh.Write(input)
+	return err
+}
+
+// Start implements resolve.SubscriptionDataSource interface.
+// It starts the server-streaming call and returns once the call is started,
+// receiving its messages in a separate goroutine until the stream ends or
+// the subscription is cancelled.
+//
+// Headers are converted to gRPC metadata and are part of every gRPC call.
+func (s *SubscriptionSource) Start(ctx *resolve.Context, headers http.Header, input []byte, updater resolve.SubscriptionUpdater) error {
+	if s.ds.disabled {
+		return fmt.Errorf("gRPC / connect datasource needs to be enabled to be used")
+	}
+	if s.ds.transport == nil {
+		return fmt.Errorf("gRPC / connect configuration requires an rpc transport")
+	}
+	transport, ok := s.ds.transport.(StreamingRPCTransport)
+	if !ok {
+		return fmt.Errorf("gRPC / connect subscriptions require an rpc transport supporting server-streaming calls")
+	}
+
+	// get variables from input
+	variables := gjson.Parse(unsafebytes.BytesToString(input)).Get("body.variables")
+
+	graph := NewDependencyGraph(s.ds.plan)
+	rootFetch, err := graph.Fetch(s.rootCallID)
+	if err != nil {
+		return err
+	}
+	rootCall, err := s.ds.rc.CompileNode(graph, rootFetch, variables)
+	if err != nil {
+		return err
+	}
+
+	streamCtx := withHeaderMetadata(ctx.Context(), headers)
+	stream, err := transport.NewServerStream(streamCtx, rootCall.MethodFullName(), rootCall.Input, rootCall.Output.Descriptor())
+	if err != nil {
+		return err
+	}
+
+	go s.receive(streamCtx, stream, &rootCall, input, variables, updater)
+	return nil
+}
+
+// receive sends an update for every message of the stream, and completes the
+// subscription or ends it with an error once the stream ends.
+func (s *SubscriptionSource) receive(ctx context.Context, stream ServerStream, rootCall *ServiceCall, input []byte, variables gjson.Result, updater resolve.SubscriptionUpdater) {
+	defer func() {
+		_ = stream.Close()
+		updater.Done()
+	}()
+
+	message := dynamicpb.NewMessage(rootCall.Output.Descriptor())
+	for {
+		err := stream.Recv(message)
+		if errors.Is(err, io.EOF) {
+			updater.Complete()
+			return
+		}
+
+		item := s.ds.acquirePoolItem(input, 0)
+		builder := newJSONBuilder(item.Arena, s.ds.mapping, variables)
+
+		if err != nil {
+			// The updater drops the error of a subscription that was cancelled.
+			updater.Error(builder.writeErrorBytes(err))
+			s.ds.pool.Release(item)
+			return
+		}
+
+		// The root call of the plan is answered with the streamed message,
+		// the calls depending on it are invoked as usual.
+		data := s.ds.resolve(ctx, input, variables, builder, func(ctx context.Context, serviceCall *ServiceCall) error {
+			if serviceCall.RPC.ID != s.rootCallID {
+				return s.ds.invoke(ctx, serviceCall)
+			}
+			output := serviceCall.Output.Interface()
+			proto.Reset(output)
+			proto.Merge(output, message)
+			return nil
+		})
+		s.ds.pool.Release(item)
+
+		updater.Update(data)
+	}
+}
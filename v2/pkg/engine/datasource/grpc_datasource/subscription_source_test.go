@@ -0,0 +1,305 @@
+package grpcdatasource
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	protoref "google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/internal/unsafeparser"
+)
+
+const counterProtoSchema = `
+syntax = "proto3";
+package counterv1;
+
+service CounterService {
+  rpc SubscriptionCounter(SubscriptionCounterRequest) returns (stream SubscriptionCounterResponse) {}
+}
+
+message SubscriptionCounterRequest {
+  int32 up_to = 1;
+}
+
+message SubscriptionCounterResponse {
+  Counter counter = 1;
+}
+
+message Counter {
+  int32 value = 1;
+}
+`
+
+const counterGraphQLSchema = `
+type Query {
+  hello: String
+}
+
+type Subscription {
+  counter(upTo: Int!): Counter!
+}
+
+type Counter {
+  value: Int!
+}
+`
+
+const (
+	counterProcedure    = "/counterv1.CounterService/SubscriptionCounter"
+	counterSubscription = `subscription Counter($upTo: Int!) { counter(upTo: $upTo) { value } }`
+)
+
+var counterMapping = &GRPCMapping{
+	Service: "CounterService",
+	SubscriptionRPCs: RPCConfigMap[RPCConfig]{
+		"counter": {
+			RPC:      "SubscriptionCounter",
+			Request:  "SubscriptionCounterRequest",
+			Response: "SubscriptionCounterResponse",
+		},
+	},
+	Fields: map[string]FieldMap{
+		"Subscription": {
+			"counter": {
+				TargetName:       "counter",
+				ArgumentMappings: FieldArgumentMap{"upTo": "up_to"},
+			},
+		},
+		"Counter": {
+			"value": {TargetName: "value"},
+		},
+	},
+}
+
+// countTo streams a counter from 1 to the up_to field of the request, or
+// fails with PermissionDenied for a negative one.
+func countTo(request *dynamicpb.Message, send func(*dynamicpb.Message) error) error {
+	upTo := request.Get(request.Descriptor().Fields().ByName("up_to")).Int()
+	if upTo < 0 {
+		return status.Error(codes.PermissionDenied, "counting down is not allowed")
+	}
+
+	responseDesc := request.Descriptor().ParentFile().Messages().ByName("SubscriptionCounterResponse")
+	counterDesc := request.Descriptor().ParentFile().Messages().ByName("Counter")
+	for i := int64(1); i <= upTo; i++ {
+		counter := dynamicpb.NewMessage(counterDesc)
+		counter.Set(counterDesc.Fields().ByName("value"), protoref.ValueOfInt32(int32(i)))
+		response := dynamicpb.NewMessage(responseDesc)
+		response.Set(responseDesc.Fields().ByName("counter"), protoref.ValueOfMessage(counter))
+		if err := send(response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// subscriptionEvent is a call of a resolve.SubscriptionUpdater.
+type subscriptionEvent struct {
+	kind string
+	data string
+}
+
+// recordingSubscriptionUpdater sends every call to a channel, so tests can
+// assert on the order of updates, completion and errors.
+type recordingSubscriptionUpdater struct {
+	events chan subscriptionEvent
+}
+
+func newRecordingSubscriptionUpdater() *recordingSubscriptionUpdater {
+	return &recordingSubscriptionUpdater{events: make(chan subscriptionEvent, 16)}
+}
+
+func (u *recordingSubscriptionUpdater) Update(data []byte) {
+	u.events <- subscriptionEvent{kind: "update", data: string(data)}
+}
+
+func (u *recordingSubscriptionUpdater) UpdateSubscription(id resolve.SubscriptionIdentifier, data []byte) {
+}
+
+func (u *recordingSubscriptionUpdater) Complete() {
+	u.events <- subscriptionEvent{kind: "complete"}
+}
+
+func (u *recordingSubscriptionUpdater) Error(data []byte) {
+	u.events <- subscriptionEvent{kind: "error", data: string(data)}
+}
+
+func (u *recordingSubscriptionUpdater) Done() {
+	u.events <- subscriptionEvent{kind: "done"}
+}
+
+func (u *recordingSubscriptionUpdater) CloseSubscription(id resolve.SubscriptionIdentifier) {
+}
+
+func (u *recordingSubscriptionUpdater) Subscriptions() map[context.Context]resolve.SubscriptionIdentifier {
+	return nil
+}
+
+func (u *recordingSubscriptionUpdater) await(t *testing.T, count int) []subscriptionEvent {
+	t.Helper()
+	events := make([]subscriptionEvent, 0, count)
+	for range count {
+		select {
+		case event := <-u.events:
+			events = append(events, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for subscription events, got %v", events)
+		}
+	}
+	return events
+}
+
+func newCounterSubscriptionSource(t *testing.T, transport RPCTransport) *SubscriptionSource {
+	t.Helper()
+
+	compiler, err := NewProtoCompiler(counterProtoSchema, counterMapping)
+	require.NoError(t, err)
+
+	definition := unsafeparser.ParseGraphqlDocumentStringWithBaseSchema(counterGraphQLSchema)
+	operation, report := astparser.ParseGraphqlDocumentString(counterSubscription)
+	require.False(t, report.HasErrors(), report.Error())
+
+	source, err := NewSubscriptionSource(transport, DataSourceConfig{
+		Operation:    &operation,
+		Definition:   &definition,
+		SubgraphName: "Counter",
+		Compiler:     compiler,
+		Mapping:      counterMapping,
+	})
+	require.NoError(t, err)
+	return source
+}
+
+func setupCounterGRPCServer(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	compiler, err := NewProtoCompiler(counterProtoSchema, counterMapping)
+	require.NoError(t, err)
+	requestDesc := findMessageDesc(t, compiler, "counterv1.SubscriptionCounterRequest")
+
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnknownServiceHandler(func(_ any, stream grpc.ServerStream) error {
+		request := dynamicpb.NewMessage(requestDesc)
+		if err := stream.RecvMsg(request); err != nil {
+			return err
+		}
+		return countTo(request, func(response *dynamicpb.Message) error {
+			return stream.SendMsg(response)
+		})
+	}))
+	go func() {
+		_ = server.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return lis.Dial()
+		}),
+	)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_ = conn.Close()
+		server.Stop()
+		_ = lis.Close()
+	})
+	return conn
+}
+
+func setupCounterConnectServer(t *testing.T) string {
+	t.Helper()
+
+	compiler, err := NewProtoCompiler(counterProtoSchema, counterMapping)
+	require.NoError(t, err)
+	requestDesc := findMessageDesc(t, compiler, "counterv1.SubscriptionCounterRequest")
+
+	mux := http.NewServeMux()
+	mux.Handle(counterProcedure, connect.NewServerStreamHandler(counterProcedure,
+		func(_ context.Context, request *connect.Request[dynamicpb.Message], stream *connect.ServerStream[dynamicpb.Message]) error {
+			err := countTo(request.Msg, stream.Send)
+			if st, ok := status.FromError(err); ok && err != nil {
+				return connect.NewError(connect.Code(st.Code()), errors.New(st.Message()))
+			}
+			return err
+		},
+		connect.WithCodec(&dynamicProtoCodec{responseDesc: requestDesc}),
+	))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func TestSubscriptionSource_Start(t *testing.T) {
+	transports := map[string]func(t *testing.T) RPCTransport{
+		"grpc": func(t *testing.T) RPCTransport {
+			return NewGRPCTransport(setupCounterGRPCServer(t))
+		},
+		"connect": func(t *testing.T) RPCTransport {
+			return NewConnectTransport(ConnectTransportConfig{BaseURL: setupCounterConnectServer(t)})
+		},
+	}
+
+	for name, newTransport := range transports {
+		t.Run(name, func(t *testing.T) {
+			t.Run("every streamed message is an update until the stream completes", func(t *testing.T) {
+				source := newCounterSubscriptionSource(t, newTransport(t))
+				updater := newRecordingSubscriptionUpdater()
+
+				err := source.Start(resolve.NewContext(t.Context()), nil, []byte(`{"body":{"variables":{"upTo":2}}}`), updater)
+				require.NoError(t, err)
+
+				require.Equal(t, []subscriptionEvent{
+					{kind: "update", data: `{"data":{"counter":{"value":1}}}`},
+					{kind: "update", data: `{"data":{"counter":{"value":2}}}`},
+					{kind: "complete"},
+					{kind: "done"},
+				}, updater.await(t, 4))
+			})
+
+			t.Run("a status error ends the subscription with the error", func(t *testing.T) {
+				source := newCounterSubscriptionSource(t, newTransport(t))
+				updater := newRecordingSubscriptionUpdater()
+
+				err := source.Start(resolve.NewContext(t.Context()), nil, []byte(`{"body":{"variables":{"upTo":-1}}}`), updater)
+				require.NoError(t, err)
+
+				events := updater.await(t, 2)
+				require.Equal(t, "error", events[0].kind)
+				require.Contains(t, events[0].data, "counting down is not allowed")
+				require.Contains(t, events[0].data, `"extensions":{"code":"PermissionDenied"}`)
+				require.Equal(t, subscriptionEvent{kind: "done"}, events[1])
+			})
+		})
+	}
+
+	t.Run("a transport without server-streaming calls cannot start a subscription", func(t *testing.T) {
+		source := newCounterSubscriptionSource(t, unaryOnlyTransport{})
+
+		err := source.Start(resolve.NewContext(t.Context()), nil, []byte(`{"body":{"variables":{"upTo":2}}}`), newRecordingSubscriptionUpdater())
+		require.EqualError(t, err, "gRPC / connect subscriptions require an rpc transport supporting server-streaming calls")
+	})
+}
+
+type unaryOnlyTransport struct{}
+
+func (unaryOnlyTransport) Invoke(context.Context, string, protoref.Message, protoref.Message) error {
+	return nil
+}
@@ -3,6 +3,7 @@ package grpcdatasource
 import (
 	"context"
 	"errors"
+	"io"
 
 	"google.golang.org/grpc"
 	protoref "google.golang.org/protobuf/reflect/protoreflect"
@@ -22,11 +23,37 @@ type RPCTransport interface {
 	Invoke(ctx context.Context, methodFullName string, input, output protoref.Message) error
 }
 
+// StreamingRPCTransport is an RPCTransport that also dispatches
+// server-streaming calls, which resolve the root fields of subscriptions.
+// Both the gRPC and the Connect transport implement it.
+type StreamingRPCTransport interface {
+	RPCTransport
+	// NewServerStream sends input as the only request message of a
+	// server-streaming call and returns the stream of response messages.
+	// methodFullName and input follow the rules of Invoke; responseDesc is
+	// the descriptor of the streamed response messages. The call is
+	// cancelled with ctx.
+	NewServerStream(ctx context.Context, methodFullName string, input protoref.Message, responseDesc protoref.MessageDescriptor) (ServerStream, error)
+}
+
+// ServerStream receives the response messages of a server-streaming call.
+type ServerStream interface {
+	// Recv populates output, a *dynamicpb.Message bound to the response
+	// descriptor, with the next message of the stream. It returns io.EOF
+	// once the service ended the stream successfully, and the status error
+	// the service ended it with otherwise.
+	Recv(output protoref.Message) error
+	// Close cancels the call if it is still running.
+	Close() error
+}
+
 // grpcTransport wraps grpc.ClientConnInterface to implement RPCTransport.
 type grpcTransport struct {
 	cc grpc.ClientConnInterface
 }
 
+var _ StreamingRPCTransport = (*grpcTransport)(nil)
+
 // NewGRPCTransport creates an RPCTransport that delegates to a gRPC ClientConnInterface.
 func NewGRPCTransport(cc grpc.ClientConnInterface) RPCTransport {
 	return &grpcTransport{cc: cc}
@@ -42,3 +69,45 @@ func (t *grpcTransport) Invoke(ctx context.Context, method string, input, output
 	// is protocol-agnostic. The existing grpc_datasource code does not use any CallOption at the Invoke site.
 	return t.cc.Invoke(ctx, method, input, output)
 }
+
+// serverStreamDesc describes a call with a single request message and a
+// stream of response messages.
+var serverStreamDesc = &grpc.StreamDesc{ServerStreams: true}
+
+func (t *grpcTransport) NewServerStream(ctx context.Context, method string, input protoref.Message, _ protoref.MessageDescriptor) (ServerStream, error) {
+	if t.cc == nil {
+		return nil, errors.New("grpc transport: nil client connection")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stream, err := t.cc.NewStream(ctx, serverStreamDesc, method)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := stream.SendMsg(input); err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		return nil, err
+	}
+	// SendMsg returns io.EOF when the service ended the call already; the
+	// status it ended it with is returned by the first RecvMsg.
+	if err := stream.CloseSend(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &grpcServerStream{stream: stream, cancel: cancel}, nil
+}
+
+// grpcServerStream is the ServerStream of a grpc.ClientStream.
+type grpcServerStream struct {
+	stream grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *grpcServerStream) Recv(output protoref.Message) error {
+	return s.stream.RecvMsg(output)
+}
+
+func (s *grpcServerStream) Close() error {
+	s.cancel()
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
@@ -73,6 +74,8 @@ type connectTransport struct {
 	clients map[string]*connect.Client[dynamicpb.Message, dynamicpb.Message]
 }
 
+var _ StreamingRPCTransport = (*connectTransport)(nil)
+
 // NewConnectTransport creates an RPCTransport that uses the Connect protocol.
 func NewConnectTransport(config ConnectTransportConfig) RPCTransport {
 	httpClient := config.HTTPClient
@@ -198,6 +201,29 @@ func (t *connectTransport) Invoke(ctx context.Context, methodFullName string, in
 	cli := t.clientFor(methodFullName, output.Descriptor())
 
 	req := connect.NewRequest(inDyn)
+	setConnectHeaders(ctx, req.Header())
+
+	resp, err := cli.CallUnary(ctx, req)
+	if err != nil {
+		// Wrap with %w so callers can errors.As the original *connect.Error
+		// to inspect Code, Message, Details, and Metadata. The default
+		// formatting of *connect.Error is "<code>: <message>", so the error
+		// string remains human-readable.
+		return fmt.Errorf("connect: %w", err)
+	}
+
+	// resp.Msg was populated by the codec's Unmarshal with a fresh
+	// dynamicpb.Message bound to the response descriptor. Reset the caller's
+	// output before merging so a reused output message does not accumulate
+	// repeated-field values across invocations.
+	proto.Reset(outDyn)
+	proto.Merge(outDyn, resp.Msg)
+	return nil
+}
+
+// setConnectHeaders copies the outgoing gRPC metadata of ctx to the headers
+// of a Connect request.
+func setConnectHeaders(ctx context.Context, header http.Header) {
 	if md, ok := metadata.FromOutgoingContext(ctx); ok {
 		for k, vs := range md {
 			// Headers ending in "-bin" carry binary values. HTTP headers
@@ -211,28 +237,57 @@ func (t *connectTransport) Invoke(ctx context.Context, methodFullName string, in
 			isBin := strings.HasSuffix(k, "-bin")
 			for _, v := range vs {
 				if isBin {
-					req.Header().Add(k, base64.StdEncoding.EncodeToString([]byte(v)))
+					header.Add(k, base64.StdEncoding.EncodeToString([]byte(v)))
 				} else {
-					req.Header().Add(k, v)
+					header.Add(k, v)
 				}
 			}
 		}
 	}
+}
 
-	resp, err := cli.CallUnary(ctx, req)
+// NewServerStream starts a Connect server-streaming call to the configured
+// base URL. The response messages are decoded with the same codecs as the
+// response of Invoke.
+func (t *connectTransport) NewServerStream(ctx context.Context, methodFullName string, input protoreflect.Message, responseDesc protoreflect.MessageDescriptor) (ServerStream, error) {
+	inDyn, ok := input.Interface().(*dynamicpb.Message)
+	if !ok {
+		return nil, fmt.Errorf("connect: input is %T, want *dynamicpb.Message", input.Interface())
+	}
+
+	cli := t.clientFor(methodFullName, responseDesc)
+
+	req := connect.NewRequest(inDyn)
+	setConnectHeaders(ctx, req.Header())
+
+	stream, err := cli.CallServerStream(ctx, req)
 	if err != nil {
-		// Wrap with %w so callers can errors.As the original *connect.Error
-		// to inspect Code, Message, Details, and Metadata. The default
-		// formatting of *connect.Error is "<code>: <message>", so the error
-		// string remains human-readable.
-		return fmt.Errorf("connect: %w", err)
+		return nil, fmt.Errorf("connect: %w", err)
 	}
+	return &connectServerStream{stream: stream}, nil
+}
 
-	// resp.Msg was populated by the codec's Unmarshal with a fresh
-	// dynamicpb.Message bound to the response descriptor. Reset the caller's
-	// output before merging so a reused output message does not accumulate
-	// repeated-field values across invocations.
+// connectServerStream is the ServerStream of a Connect server-streaming call.
+type connectServerStream struct {
+	stream *connect.ServerStreamForClient[dynamicpb.Message]
+}
+
+func (s *connectServerStream) Recv(output protoreflect.Message) error {
+	outDyn, ok := output.Interface().(*dynamicpb.Message)
+	if !ok {
+		return fmt.Errorf("connect: output is %T, want *dynamicpb.Message", output.Interface())
+	}
+	if !s.stream.Receive() {
+		if err := s.stream.Err(); err != nil {
+			return fmt.Errorf("connect: %w", err)
+		}
+		return io.EOF
+	}
 	proto.Reset(outDyn)
-	proto.Merge(outDyn, resp.Msg)
+	proto.Merge(outDyn, s.stream.Msg())
 	return nil
 }
+
+func (s *connectServerStream) Close() error {
+	return s.stream.Close()
+}
@@ -26,6 +26,7 @@ const (
 	QUERYPARAMS                                 = "query_params"
 	USE_SSE                                     = "use_sse"
 	SSE_METHOD_POST                             = "sse_method_post"
+	USE_MULTIPART                               = "use_multipart"
 	SCHEME                                      = "scheme"
 	HOST                                        = "host"
 	UNDEFINED_VARIABLES                         = "undefined"
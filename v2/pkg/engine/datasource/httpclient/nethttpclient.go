@@ -141,7 +141,8 @@ func respBodyReader(res *http.Response) (io.Reader, error) {
 type httpClientContext string
 
 const (
-	sizeHintKey httpClientContext = "size-hint"
+	sizeHintKey      httpClientContext = "size-hint"
+	requestHeaderKey httpClientContext = "request-header"
 )
 
 // WithHTTPClientSizeHint allows the engine to keep track of response sizes per subgraph fetch
@@ -151,6 +152,13 @@ func WithHTTPClientSizeHint(ctx context.Context, size int) context.Context {
 	return context.WithValue(ctx, sizeHintKey, size)
 }
 
+// WithRequestHeader adds header to the requests made with ctx, on top of the
+// headers of the request input, e.g. to propagate the trace context of a fetch.
+// A header set by the request input is overwritten.
+func WithRequestHeader(ctx context.Context, header http.Header) context.Context {
+	return context.WithValue(ctx, requestHeaderKey, header)
+}
+
 func buffer(ctx context.Context) *bytes.Buffer {
 	if sizeHint, ok := ctx.Value(sizeHintKey).(int); ok && sizeHint > 0 {
 		return bytes.NewBuffer(make([]byte, 0, sizeHint))
@@ -220,6 +228,12 @@ func makeHTTPRequest(client *http.Client, ctx context.Context, baseHeaders http.
 		request.URL.RawQuery = query.Encode()
 	}
 
+	if header, ok := ctx.Value(requestHeaderKey).(http.Header); ok {
+		for key, values := range header {
+			request.Header[key] = slices.Clone(values)
+		}
+	}
+
 	request.Header.Add(AcceptHeader, ContentTypeJSON)
 	request.Header.Add(ContentTypeHeader, contentType)
 	request.Header.Set(AcceptEncodingHeader, EncodingGzip)
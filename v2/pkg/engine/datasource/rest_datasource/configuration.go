@@ -0,0 +1,121 @@
+// Package rest_datasource provides a datasource resolving GraphQL fields with
+// calls to plain REST endpoints.
+//
+// Root fields and entities are mapped to endpoints. The path, query parameters
+// and body of an endpoint are templates referencing the arguments of the field,
+// or the key fields of the entity, with argument templates such as
+// {{ args.id }}. The JSON response of an endpoint is mapped to the selection
+// set of the field, where a FieldConfiguration.Path selects the value of a
+// field from the response.
+//
+// A BatchEndpoint fetches the entities of an entity fetch with a single call
+// to a bulk endpoint, e.g. GET /products?ids=1,2,3, instead of a call per
+// entity.
+package rest_datasource
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type Configuration struct {
+	// BaseURL is prepended to the path of every endpoint.
+	BaseURL string
+	// Header is sent with every request.
+	Header http.Header
+	// RootFields maps root fields, e.g. Query.user, to endpoints.
+	RootFields []RootFieldConfiguration
+	// Entities maps entities to endpoints fetching a single entity by its key,
+	// or to batch endpoints fetching many entities by their keys.
+	Entities []EntityConfiguration
+}
+
+// RootFieldConfiguration maps a root field to an endpoint. The templates of
+// the endpoint reference the arguments of the field.
+type RootFieldConfiguration struct {
+	TypeName  string
+	FieldName string
+	Endpoint  Endpoint
+}
+
+// EntityConfiguration maps an entity to an endpoint. The templates of the
+// endpoint reference the key fields of the entity, e.g. {{ args.id }} for
+// the key "id".
+type EntityConfiguration struct {
+	TypeName string
+	Endpoint Endpoint
+	// BatchEndpoint fetches all entities of a fetch with a single call
+	// instead of a call of Endpoint per entity. Endpoint is not used when
+	// BatchEndpoint is set.
+	BatchEndpoint *BatchEndpoint
+}
+
+// BatchEndpoint is an endpoint fetching a list of entities by their keys,
+// e.g. GET /products?ids=1,2,3. The templates reference the key fields of the
+// entity, which are rendered as the list of the keys of all entities, e.g.
+// {{ args.id }} as ["1","2","3"].
+//
+// The response, at the ResponsePath, is a list of entities. They are mapped
+// to the entities of the fetch by their key fields, the order of the list
+// does not matter. An entity missing in the list is null.
+type BatchEndpoint struct {
+	Endpoint
+	// Separator joins the keys to a single string, e.g. "," to render
+	// {{ args.id }} as 1,2,3 instead of a list.
+	Separator string
+	// MaxBatchSize splits the entities of a fetch into calls of at most
+	// MaxBatchSize entities, zero means no limit.
+	MaxBatchSize int
+}
+
+// Endpoint describes an HTTP call.
+type Endpoint struct {
+	// Method defaults to GET.
+	Method string
+	// Path is appended to the base URL. Arguments are path escaped.
+	Path string
+	// Query parameters with a null or missing argument as value are omitted,
+	// a list argument adds the parameter once per item.
+	Query []QueryParameter
+	// Body is a JSON template, arguments are rendered as JSON values.
+	Body string
+	// ResponsePath selects the value of the field from the response,
+	// e.g. ["data"] for a response wrapping the object in an envelope.
+	ResponsePath []string
+}
+
+type QueryParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (c *Configuration) rootField(typeName, fieldName string) (Endpoint, bool) {
+	for i := range c.RootFields {
+		if c.RootFields[i].TypeName == typeName && c.RootFields[i].FieldName == fieldName {
+			return c.RootFields[i].Endpoint, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+func (c *Configuration) entity(typeName string) (EntityConfiguration, bool) {
+	for i := range c.Entities {
+		if c.Entities[i].TypeName == typeName {
+			return c.Entities[i], true
+		}
+	}
+	return EntityConfiguration{}, false
+}
+
+func (e Endpoint) method() (string, error) {
+	if e.Method == "" {
+		return http.MethodGet, nil
+	}
+	method := strings.ToUpper(e.Method)
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return method, nil
+	}
+	return "", fmt.Errorf("unsupported method %q", e.Method)
+}
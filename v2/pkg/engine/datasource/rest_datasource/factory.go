@@ -0,0 +1,54 @@
+package rest_datasource
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jensneuse/abstractlogger"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+)
+
+type Factory[T Configuration] struct {
+	executionContext context.Context
+	httpClient       *http.Client
+}
+
+// NewFactory creates a new factory for the REST datasource planner.
+func NewFactory(executionContext context.Context, httpClient *http.Client) (*Factory[Configuration], error) {
+	if executionContext == nil {
+		return nil, fmt.Errorf("execution context is required")
+	}
+	if httpClient == nil {
+		return nil, fmt.Errorf("http client is required")
+	}
+
+	return &Factory[Configuration]{
+		executionContext: executionContext,
+		httpClient:       httpClient,
+	}, nil
+}
+
+func (f *Factory[T]) Planner(_ abstractlogger.Logger) plan.DataSourcePlanner[T] {
+	return &Planner[T]{httpClient: f.httpClient}
+}
+
+func (f *Factory[T]) Context() context.Context {
+	return f.executionContext
+}
+
+func (f *Factory[T]) UpstreamSchema(_ plan.DataSourceConfiguration[T]) (*ast.Document, bool) {
+	return nil, false
+}
+
+func (f *Factory[T]) PlanningBehavior() plan.DataSourcePlanningBehavior {
+	// Root fields are merged into a single fetch, which calls the endpoints of
+	// all its root fields, so that the fields of an entity are fetched with
+	// one call of the entity endpoint.
+	return plan.DataSourcePlanningBehavior{
+		MergeAliasedRootNodes: true,
+		AllowPlanningTypeName: true,
+	}
+}
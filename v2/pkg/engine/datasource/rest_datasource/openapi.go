@@ -0,0 +1,265 @@
+package rest_datasource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// OpenAPIMapping maps root fields and entities to the operations of an
+// OpenAPI 3 document.
+type OpenAPIMapping struct {
+	// BaseURL overrides the URL of the first server of the document.
+	BaseURL    string
+	Header     http.Header
+	RootFields []OpenAPIRootFieldMapping
+	Entities   []OpenAPIEntityMapping
+}
+
+type OpenAPIRootFieldMapping struct {
+	TypeName    string
+	FieldName   string
+	OperationID string
+	// Arguments maps the names of parameters to the names of the arguments
+	// of the field, parameters missing in the map are arguments of the same
+	// name.
+	Arguments map[string]string
+	// BodyArgument is the argument sent as request body of an operation with
+	// a request body, "input" by default.
+	BodyArgument string
+	ResponsePath []string
+}
+
+type OpenAPIEntityMapping struct {
+	TypeName    string
+	OperationID string
+	// Arguments maps the names of parameters to the key fields of the entity,
+	// parameters missing in the map are key fields of the same name.
+	Arguments map[string]string
+	// BatchOperationID is an operation fetching a list of entities by their
+	// keys, it is used instead of OperationID. The parameters are mapped by
+	// Arguments.
+	BatchOperationID string
+	// Separator and MaxBatchSize configure the BatchEndpoint of the entity.
+	Separator    string
+	MaxBatchSize int
+}
+
+type openAPIDocument struct {
+	OpenAPI string `json:"openapi" yaml:"openapi"`
+	Servers []struct {
+		URL string `json:"url" yaml:"url"`
+	} `json:"servers" yaml:"servers"`
+	Paths      map[string]openAPIPathItem `json:"paths" yaml:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `json:"parameters" yaml:"parameters"`
+	} `json:"components" yaml:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []openAPIParameter `json:"parameters" yaml:"parameters"`
+	Get        *openAPIOperation  `json:"get" yaml:"get"`
+	Put        *openAPIOperation  `json:"put" yaml:"put"`
+	Post       *openAPIOperation  `json:"post" yaml:"post"`
+	Delete     *openAPIOperation  `json:"delete" yaml:"delete"`
+	Patch      *openAPIOperation  `json:"patch" yaml:"patch"`
+}
+
+type openAPIOperation struct {
+	OperationID string             `json:"operationId" yaml:"operationId"`
+	Parameters  []openAPIParameter `json:"parameters" yaml:"parameters"`
+	RequestBody *struct{}          `json:"requestBody" yaml:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref  string `json:"$ref" yaml:"$ref"`
+	Name string `json:"name" yaml:"name"`
+	In   string `json:"in" yaml:"in"`
+}
+
+// openAPIEndpoint is an operation of the document with its path and method.
+type openAPIEndpoint struct {
+	method     string
+	path       string
+	operation  *openAPIOperation
+	parameters []openAPIParameter
+}
+
+var openAPIPathParameterRegex = regexp.MustCompile(`{([^{}]+)}`)
+
+// ConfigurationFromOpenAPI generates the configuration of the datasource from
+// an OpenAPI 3 document in JSON or YAML format.
+//
+// Path and query parameters of an operation become argument templates, a
+// request body becomes a template of the body argument. Header and cookie
+// parameters are not supported and left out.
+func ConfigurationFromOpenAPI(document []byte, mapping OpenAPIMapping) (Configuration, error) {
+	var doc openAPIDocument
+	var err error
+	if trimmed := bytes.TrimSpace(document); len(trimmed) != 0 && trimmed[0] == '{' {
+		err = json.Unmarshal(document, &doc)
+	} else {
+		err = yaml.Unmarshal(document, &doc)
+	}
+	if err != nil {
+		return Configuration{}, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return Configuration{}, fmt.Errorf("unsupported OpenAPI version %q, expected 3.x", doc.OpenAPI)
+	}
+
+	config := Configuration{
+		BaseURL: mapping.BaseURL,
+		Header:  mapping.Header,
+	}
+	if config.BaseURL == "" && len(doc.Servers) != 0 {
+		config.BaseURL = doc.Servers[0].URL
+	}
+
+	endpoints := doc.endpoints()
+
+	for _, field := range mapping.RootFields {
+		endpoint, err := doc.endpoint(endpoints, field.OperationID, field.Arguments, field.BodyArgument)
+		if err != nil {
+			return Configuration{}, fmt.Errorf("root field %s.%s: %w", field.TypeName, field.FieldName, err)
+		}
+		endpoint.ResponsePath = field.ResponsePath
+		config.RootFields = append(config.RootFields, RootFieldConfiguration{
+			TypeName:  field.TypeName,
+			FieldName: field.FieldName,
+			Endpoint:  endpoint,
+		})
+	}
+
+	for _, entity := range mapping.Entities {
+		if entity.BatchOperationID != "" {
+			endpoint, err := doc.endpoint(endpoints, entity.BatchOperationID, entity.Arguments, "")
+			if err != nil {
+				return Configuration{}, fmt.Errorf("entity %s: %w", entity.TypeName, err)
+			}
+			if endpoint.Body != "" {
+				return Configuration{}, fmt.Errorf("entity %s: operation %q has a request body", entity.TypeName, entity.BatchOperationID)
+			}
+			config.Entities = append(config.Entities, EntityConfiguration{
+				TypeName: entity.TypeName,
+				BatchEndpoint: &BatchEndpoint{
+					Endpoint:     endpoint,
+					Separator:    entity.Separator,
+					MaxBatchSize: entity.MaxBatchSize,
+				},
+			})
+			continue
+		}
+		endpoint, err := doc.endpoint(endpoints, entity.OperationID, entity.Arguments, "")
+		if err != nil {
+			return Configuration{}, fmt.Errorf("entity %s: %w", entity.TypeName, err)
+		}
+		if endpoint.Body != "" {
+			return Configuration{}, fmt.Errorf("entity %s: operation %q has a request body", entity.TypeName, entity.OperationID)
+		}
+		config.Entities = append(config.Entities, EntityConfiguration{
+			TypeName: entity.TypeName,
+			Endpoint: endpoint,
+		})
+	}
+
+	return config, nil
+}
+
+// endpoints returns the operations of the document by their operation id.
+func (d *openAPIDocument) endpoints() map[string]openAPIEndpoint {
+	endpoints := make(map[string]openAPIEndpoint)
+	for path, item := range d.Paths {
+		operations := []struct {
+			method    string
+			operation *openAPIOperation
+		}{
+			{http.MethodGet, item.Get},
+			{http.MethodPut, item.Put},
+			{http.MethodPost, item.Post},
+			{http.MethodDelete, item.Delete},
+			{http.MethodPatch, item.Patch},
+		}
+		for _, o := range operations {
+			if o.operation == nil || o.operation.OperationID == "" {
+				continue
+			}
+			endpoints[o.operation.OperationID] = openAPIEndpoint{
+				method:    o.method,
+				path:      path,
+				operation: o.operation,
+				// parameters of the operation override the parameters of the path
+				parameters: append(append([]openAPIParameter(nil), item.Parameters...), o.operation.Parameters...),
+			}
+		}
+	}
+	return endpoints
+}
+
+func (d *openAPIDocument) endpoint(endpoints map[string]openAPIEndpoint, operationID string, arguments map[string]string, bodyArgument string) (Endpoint, error) {
+	e, ok := endpoints[operationID]
+	if !ok {
+		return Endpoint{}, fmt.Errorf("operation %q not found", operationID)
+	}
+
+	argument := func(parameter string) string {
+		if name, ok := arguments[parameter]; ok {
+			return name
+		}
+		return parameter
+	}
+
+	endpoint := Endpoint{
+		Method: e.method,
+		Path: openAPIPathParameterRegex.ReplaceAllStringFunc(e.path, func(match string) string {
+			return "{{ args." + argument(match[1:len(match)-1]) + " }}"
+		}),
+	}
+
+	seen := make(map[string]int)
+	for _, parameter := range e.parameters {
+		parameter, err := d.resolveParameter(parameter)
+		if err != nil {
+			return Endpoint{}, err
+		}
+		if parameter.In != "query" {
+			continue
+		}
+		queryParameter := QueryParameter{Name: parameter.Name, Value: "{{ args." + argument(parameter.Name) + " }}"}
+		if i, ok := seen[parameter.Name]; ok {
+			endpoint.Query[i] = queryParameter
+			continue
+		}
+		seen[parameter.Name] = len(endpoint.Query)
+		endpoint.Query = append(endpoint.Query, queryParameter)
+	}
+
+	if e.operation.RequestBody != nil {
+		if bodyArgument == "" {
+			bodyArgument = "input"
+		}
+		endpoint.Body = "{{ args." + bodyArgument + " }}"
+	}
+
+	return endpoint, nil
+}
+
+func (d *openAPIDocument) resolveParameter(parameter openAPIParameter) (openAPIParameter, error) {
+	if parameter.Ref == "" {
+		return parameter, nil
+	}
+	name, ok := strings.CutPrefix(parameter.Ref, "#/components/parameters/")
+	if !ok {
+		return openAPIParameter{}, fmt.Errorf("unsupported parameter reference %q", parameter.Ref)
+	}
+	resolved, ok := d.Components.Parameters[name]
+	if !ok {
+		return openAPIParameter{}, fmt.Errorf("parameter reference %q not found", parameter.Ref)
+	}
+	return resolved, nil
+}
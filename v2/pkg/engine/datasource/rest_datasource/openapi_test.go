@@ -0,0 +1,153 @@
+package rest_datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const usersOpenAPIDocument = `
+openapi: 3.0.3
+info:
+  title: Users
+  version: 1.0.0
+servers:
+  - url: https://users.service/v1
+components:
+  parameters:
+    PageSize:
+      name: page-size
+      in: query
+      schema:
+        type: integer
+paths:
+  /users:
+    get:
+      operationId: listUsers
+      parameters:
+        - name: name
+          in: query
+          schema:
+            type: string
+        - $ref: '#/components/parameters/PageSize'
+        - name: X-Request-Id
+          in: header
+          schema:
+            type: string
+    post:
+      operationId: createUser
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+  /users/{userId}:
+    parameters:
+      - name: userId
+        in: path
+        required: true
+        schema:
+          type: string
+    get:
+      operationId: getUser
+`
+
+func TestConfigurationFromOpenAPI(t *testing.T) {
+	t.Run("root fields and entities are mapped to operations", func(t *testing.T) {
+		config, err := ConfigurationFromOpenAPI([]byte(usersOpenAPIDocument), OpenAPIMapping{
+			RootFields: []OpenAPIRootFieldMapping{
+				{TypeName: "Query", FieldName: "users", OperationID: "listUsers", Arguments: map[string]string{"page-size": "first"}, ResponsePath: []string{"items"}},
+				{TypeName: "Mutation", FieldName: "createUser", OperationID: "createUser"},
+			},
+			Entities: []OpenAPIEntityMapping{
+				{TypeName: "User", OperationID: "getUser", Arguments: map[string]string{"userId": "id"}},
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, Configuration{
+			BaseURL: "https://users.service/v1",
+			RootFields: []RootFieldConfiguration{
+				{
+					TypeName:  "Query",
+					FieldName: "users",
+					Endpoint: Endpoint{
+						Method: "GET",
+						Path:   "/users",
+						Query: []QueryParameter{
+							{Name: "name", Value: "{{ args.name }}"},
+							{Name: "page-size", Value: "{{ args.first }}"},
+						},
+						ResponsePath: []string{"items"},
+					},
+				},
+				{
+					TypeName:  "Mutation",
+					FieldName: "createUser",
+					Endpoint:  Endpoint{Method: "POST", Path: "/users", Body: "{{ args.input }}"},
+				},
+			},
+			Entities: []EntityConfiguration{
+				{
+					TypeName: "User",
+					Endpoint: Endpoint{Method: "GET", Path: "/users/{{ args.id }}"},
+				},
+			},
+		}, config)
+	})
+
+	t.Run("a JSON document is supported", func(t *testing.T) {
+		config, err := ConfigurationFromOpenAPI([]byte(`{
+			"openapi": "3.1.0",
+			"paths": {"/users/{id}": {"get": {"operationId": "getUser"}}}
+		}`), OpenAPIMapping{
+			BaseURL:    "http://localhost:8080",
+			RootFields: []OpenAPIRootFieldMapping{{TypeName: "Query", FieldName: "user", OperationID: "getUser"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080", config.BaseURL)
+		assert.Equal(t, Endpoint{Method: "GET", Path: "/users/{{ args.id }}"}, config.RootFields[0].Endpoint)
+	})
+
+	t.Run("an entity is mapped to a batch operation", func(t *testing.T) {
+		config, err := ConfigurationFromOpenAPI([]byte(usersOpenAPIDocument), OpenAPIMapping{
+			Entities: []OpenAPIEntityMapping{
+				{TypeName: "User", BatchOperationID: "listUsers", Arguments: map[string]string{"name": "id"}, Separator: ",", MaxBatchSize: 50},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []EntityConfiguration{
+			{
+				TypeName: "User",
+				BatchEndpoint: &BatchEndpoint{
+					Endpoint: Endpoint{
+						Method: "GET",
+						Path:   "/users",
+						Query: []QueryParameter{
+							{Name: "name", Value: "{{ args.id }}"},
+							{Name: "page-size", Value: "{{ args.page-size }}"},
+						},
+					},
+					Separator:    ",",
+					MaxBatchSize: 50,
+				},
+			},
+		}, config.Entities)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ConfigurationFromOpenAPI([]byte(`{"swagger": "2.0"}`), OpenAPIMapping{})
+		assert.EqualError(t, err, `unsupported OpenAPI version "", expected 3.x`)
+
+		_, err = ConfigurationFromOpenAPI([]byte(usersOpenAPIDocument), OpenAPIMapping{
+			RootFields: []OpenAPIRootFieldMapping{{TypeName: "Query", FieldName: "user", OperationID: "deleteUser"}},
+		})
+		assert.EqualError(t, err, `root field Query.user: operation "deleteUser" not found`)
+
+		_, err = ConfigurationFromOpenAPI([]byte(usersOpenAPIDocument), OpenAPIMapping{
+			Entities: []OpenAPIEntityMapping{{TypeName: "User", OperationID: "createUser"}},
+		})
+		assert.EqualError(t, err, `entity User: operation "createUser" has a request body`)
+	})
+}
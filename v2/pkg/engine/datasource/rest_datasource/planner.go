@@ -0,0 +1,479 @@
+package rest_datasource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/argument_templates"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var (
+	// PostProcessingConfiguration selects the root fields from the response
+	// of a fetch calling root field endpoints.
+	PostProcessingConfiguration = resolve.PostProcessingConfiguration{
+		SelectResponseDataPath:   []string{"data"},
+		SelectResponseErrorsPath: []string{"errors"},
+	}
+	// EntitiesPostProcessingConfiguration selects the entities from the
+	// response of a fetch calling an entity endpoint for a list of entities.
+	EntitiesPostProcessingConfiguration = resolve.PostProcessingConfiguration{
+		SelectResponseDataPath:   []string{"data", "_entities"},
+		SelectResponseErrorsPath: []string{"errors"},
+	}
+	// SingleEntityPostProcessingConfiguration selects the entity from the
+	// response of a fetch calling an entity endpoint for a single entity.
+	SingleEntityPostProcessingConfiguration = resolve.PostProcessingConfiguration{
+		SelectResponseDataPath:   []string{"data", "_entities", "0"},
+		SelectResponseErrorsPath: []string{"errors"},
+	}
+)
+
+type Planner[T Configuration] struct {
+	id            int
+	httpClient    *http.Client
+	v             *plan.Visitor
+	config        Configuration
+	plannerConfig plan.DataSourcePlannerConfiguration
+
+	variables resolve.Variables
+	// requests are the calls of the root field endpoints, an entity fetch
+	// has none.
+	requests []*plannedRequest
+	// entitySelect maps the response of an entity endpoint to the selection
+	// set of the entity.
+	entitySelect []*selection
+	// selections is the stack of selection sets the next field is added to.
+	selections []selectionSet
+}
+
+// plannedRequest is a request of the input, with the values of its argument
+// templates being variables.
+type plannedRequest struct {
+	request
+	// argPaths keeps the order of the args in the input.
+	argPaths []string
+	// args are the variable placeholders or literal values, keyed by the
+	// argument path.
+	args map[string]string
+}
+
+type selectionSet struct {
+	// fieldRef is the field the selection set belongs to, the selection set
+	// of an entity fetch has no field.
+	fieldRef   int
+	selections *[]*selection
+}
+
+func (p *Planner[T]) SetID(id int) {
+	p.id = id
+}
+
+func (p *Planner[T]) ID() (id int) {
+	return p.id
+}
+
+func (p *Planner[T]) DownstreamResponseFieldAlias(_ int) (alias string, exists bool) {
+	// the REST DataSourcePlanner maps the response to the aliases of the fields: skip
+	return
+}
+
+func (p *Planner[T]) Register(visitor *plan.Visitor, configuration plan.DataSourceConfiguration[T], dataSourcePlannerConfiguration plan.DataSourcePlannerConfiguration) error {
+	p.v = visitor
+	p.config = Configuration(configuration.CustomConfiguration())
+	p.plannerConfig = dataSourcePlannerConfiguration
+
+	visitor.Walker.RegisterEnterDocumentVisitor(p)
+	visitor.Walker.RegisterFieldVisitor(p)
+	return nil
+}
+
+func (p *Planner[T]) EnterDocument(_, _ *ast.Document) {
+	p.variables = p.variables[:0]
+	p.requests = p.requests[:0]
+	p.entitySelect = nil
+	p.selections = p.selections[:0]
+
+	if p.isEntityFetch() {
+		p.selections = append(p.selections, selectionSet{fieldRef: ast.InvalidRef, selections: &p.entitySelect})
+	}
+}
+
+func (p *Planner[T]) EnterField(ref int) {
+	if !p.allowField(ref) {
+		return
+	}
+
+	fieldName := p.v.Operation.FieldNameString(ref)
+	typeName := p.v.Walker.EnclosingTypeDefinition.NameString(p.v.Definition)
+
+	if len(p.selections) == 0 {
+		if fieldName == "__typename" {
+			return
+		}
+		p.addRootField(ref, typeName, fieldName)
+		return
+	}
+
+	parent := p.selections[len(p.selections)-1].selections
+	key := p.v.Operation.FieldAliasOrNameString(ref)
+
+	if fieldName == "__typename" {
+		s := &selection{Key: key, TypeName: typeName}
+		if p.v.Walker.EnclosingTypeDefinition.Kind != ast.NodeKindObjectTypeDefinition {
+			// the concrete type of an interface or union has to be part of the response
+			s = &selection{Key: key, Path: []string{"__typename"}}
+		}
+		if !slices.ContainsFunc(*parent, func(existing *selection) bool { return existing.Key == key }) {
+			*parent = append(*parent, s)
+		}
+		return
+	}
+
+	path := []string{fieldName}
+	if fieldConfiguration := p.v.Config.Fields.ForTypeField(typeName, fieldName); fieldConfiguration != nil && len(fieldConfiguration.Path) != 0 {
+		path = fieldConfiguration.Path
+	}
+
+	// the same field can be selected by fragments on different types
+	idx := slices.IndexFunc(*parent, func(existing *selection) bool { return existing.Key == key })
+	if idx == -1 {
+		*parent = append(*parent, &selection{Key: key, Path: path})
+		idx = len(*parent) - 1
+	}
+
+	if p.v.Operation.FieldHasSelections(ref) {
+		p.selections = append(p.selections, selectionSet{fieldRef: ref, selections: &(*parent)[idx].Select})
+	}
+}
+
+func (p *Planner[T]) LeaveField(ref int) {
+	if len(p.selections) == 0 {
+		return
+	}
+	if p.selections[len(p.selections)-1].fieldRef == ref {
+		p.selections = p.selections[:len(p.selections)-1]
+	}
+}
+
+// allowField skips the field of the parent path of a nested fetch, which
+// is planned on the planner, but fetched by the parent fetch.
+func (p *Planner[T]) allowField(ref int) bool {
+	currentPath := fmt.Sprintf("%s.%s", p.v.Walker.Path.DotDelimitedString(), p.v.Operation.FieldAliasOrNameString(ref))
+	return p.plannerConfig.ParentPath == "query" || p.plannerConfig.ParentPath != currentPath
+}
+
+func (p *Planner[T]) isEntityFetch() bool {
+	return p.plannerConfig.HasRequiredFields()
+}
+
+func (p *Planner[T]) addRootField(ref int, typeName, fieldName string) {
+	endpoint, ok := p.config.rootField(typeName, fieldName)
+	if !ok {
+		p.stopWithError(fmt.Errorf("no endpoint configured for root field %s.%s", typeName, fieldName))
+		return
+	}
+
+	r, err := p.newRequest(endpoint)
+	if err != nil {
+		p.stopWithError(fmt.Errorf("endpoint of root field %s.%s: %w", typeName, fieldName, err))
+		return
+	}
+	r.Key = p.v.Operation.FieldAliasOrNameString(ref)
+
+	fieldDefinitionRef, ok := p.v.Walker.FieldDefinition(ref)
+	if !ok {
+		p.stopWithError(fmt.Errorf("expected field definition to exist for field %s.%s", typeName, fieldName))
+		return
+	}
+	for _, template := range endpoint.templates() {
+		if err := p.addArguments(r, ref, fieldDefinitionRef, template.value, template.allowObjects); err != nil {
+			p.stopWithError(fmt.Errorf("argument template defined on field %s.%s is invalid: %w", typeName, fieldName, err))
+			return
+		}
+	}
+
+	p.requests = append(p.requests, r)
+	if p.v.Operation.FieldHasSelections(ref) {
+		p.selections = append(p.selections, selectionSet{fieldRef: ref, selections: &r.Select})
+	}
+}
+
+func (p *Planner[T]) newRequest(endpoint Endpoint) (*plannedRequest, error) {
+	method, err := endpoint.method()
+	if err != nil {
+		return nil, err
+	}
+	return &plannedRequest{
+		request: request{
+			Method:       method,
+			URL:          strings.TrimSuffix(p.config.BaseURL, "/") + endpoint.Path,
+			Query:        endpoint.Query,
+			Body:         endpoint.Body,
+			Header:       p.config.Header,
+			ResponsePath: endpoint.ResponsePath,
+		},
+		args: map[string]string{},
+	}, nil
+}
+
+// addArguments adds a variable for every argument template of value. An
+// argument missing in the operation is null.
+func (p *Planner[T]) addArguments(r *plannedRequest, fieldRef, fieldDefinitionRef int, value string, allowObjects bool) error {
+	for _, match := range argument_templates.ArgumentTemplateRegex.FindAllStringSubmatch(value, -1) {
+		argumentPath := match[1]
+		if _, exists := r.args[argumentPath]; exists {
+			continue
+		}
+
+		path, err := p.validateArgumentPath(argumentPath, fieldDefinitionRef, allowObjects)
+		if err != nil {
+			return err
+		}
+
+		r.argPaths = append(r.argPaths, argumentPath)
+		argumentRef, ok := p.v.Operation.FieldArgument(fieldRef, []byte(path[0]))
+		if !ok {
+			r.args[argumentPath] = "null"
+			continue
+		}
+		variablePath, err := p.v.Operation.VariablePathByArgumentRefAndArgumentPath(argumentRef, path, p.v.Walker.Ancestors[0].Ref)
+		if err != nil {
+			return err
+		}
+		r.args[argumentPath], _ = p.variables.AddVariable(&resolve.ContextVariable{
+			Path:     variablePath,
+			Renderer: resolve.NewJSONVariableRenderer(),
+		})
+	}
+	return nil
+}
+
+// validateArgumentPath validates the argument path of a template. A body
+// template can reference an argument of an input object type as a whole.
+func (p *Planner[T]) validateArgumentPath(argumentPath string, fieldDefinitionRef int, allowObjects bool) ([]string, error) {
+	path := strings.Split(argumentPath, ".")
+	if allowObjects && len(path) == 1 {
+		if _, ok := p.v.Definition.InputValueDefinitionRefByFieldDefinitionRefAndArgumentNameBytes(fieldDefinitionRef, []byte(path[0])); !ok {
+			return nil, fmt.Errorf(`path "%s" references undefined argument "%s"`, argumentPath, path[0])
+		}
+		return path, nil
+	}
+	result, err := argument_templates.ValidateArgumentPath(p.v.Definition, argumentPath, fieldDefinitionRef)
+	if err != nil {
+		return nil, err
+	}
+	return result.ArgumentPath, nil
+}
+
+func (p *Planner[T]) ConfigureFetch() resolve.FetchConfiguration {
+	if p.isEntityFetch() {
+		return p.configureEntityFetch()
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"requests":[`)
+	for i, r := range p.requests {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := r.write(buf); err != nil {
+			p.stopWithError(err)
+			return resolve.FetchConfiguration{}
+		}
+	}
+	buf.WriteString(`]}`)
+
+	return resolve.FetchConfiguration{
+		Input:          buf.String(),
+		Variables:      p.variables,
+		DataSource:     &Source{httpClient: p.httpClient},
+		PostProcessing: PostProcessingConfiguration,
+	}
+}
+
+func (p *Planner[T]) configureEntityFetch() resolve.FetchConfiguration {
+	requiredFields, keyFields, representation, err := p.representationVariable()
+	if err != nil {
+		p.stopWithError(err)
+		return resolve.FetchConfiguration{}
+	}
+
+	entities := map[string]request{}
+	for _, cfg := range p.plannerConfig.RequiredFields {
+		if _, ok := entities[cfg.TypeName]; ok {
+			continue
+		}
+		entity, ok := p.config.entity(cfg.TypeName)
+		if !ok {
+			p.stopWithError(fmt.Errorf("no endpoint configured for entity %s", cfg.TypeName))
+			return resolve.FetchConfiguration{}
+		}
+		endpoint := entity.Endpoint
+		if entity.BatchEndpoint != nil {
+			endpoint = entity.BatchEndpoint.Endpoint
+		}
+		r, err := p.newRequest(endpoint)
+		if err != nil {
+			p.stopWithError(fmt.Errorf("endpoint of entity %s: %w", cfg.TypeName, err))
+			return resolve.FetchConfiguration{}
+		}
+		for _, template := range endpoint.templates() {
+			for _, match := range argument_templates.ArgumentTemplateRegex.FindAllStringSubmatch(template.value, -1) {
+				if !slices.Contains(requiredFields[cfg.TypeName], match[1]) {
+					p.stopWithError(fmt.Errorf(`argument template of entity %s references "%s", which is not a key field`, cfg.TypeName, match[1]))
+					return resolve.FetchConfiguration{}
+				}
+			}
+		}
+		if entity.BatchEndpoint != nil {
+			r.Batch = &batch{
+				Separator:    entity.BatchEndpoint.Separator,
+				MaxBatchSize: entity.BatchEndpoint.MaxBatchSize,
+				Keys:         p.batchKeys(cfg.TypeName, keyFields[cfg.TypeName]),
+			}
+		}
+		r.Select = p.entitySelect
+		entities[cfg.TypeName] = r.request
+	}
+
+	data, err := json.Marshal(entities)
+	if err != nil {
+		p.stopWithError(err)
+		return resolve.FetchConfiguration{}
+	}
+	variable, _ := p.variables.AddVariable(representation)
+
+	postProcessing := EntitiesPostProcessingConfiguration
+	requiresEntityFetch := p.plannerConfig.PathType == plan.PlannerPathObject
+	if requiresEntityFetch {
+		postProcessing = SingleEntityPostProcessingConfiguration
+	}
+
+	return resolve.FetchConfiguration{
+		Input:                                 fmt.Sprintf(`{"entities":%s,"representations":[%s]}`, data, variable),
+		Variables:                             p.variables,
+		DataSource:                            &Source{httpClient: p.httpClient},
+		RequiresEntityFetch:                   requiresEntityFetch,
+		RequiresEntityBatchFetch:              !requiresEntityFetch,
+		PostProcessing:                        postProcessing,
+		SetTemplateOutputToNullOnVariableNull: true,
+	}
+}
+
+// representationVariable builds the representation of the entities of an
+// entity fetch, the __typename and the required fields. The values of
+// templates are taken from the representation, so nested fields are not
+// supported.
+//
+// It returns the required fields, which templates can reference, and the
+// key fields of every entity.
+func (p *Planner[T]) representationVariable() (requiredFields, keyFields map[string][]string, variable resolve.Variable, err error) {
+	requiredFields = make(map[string][]string)
+	keyFields = make(map[string][]string)
+	object := &resolve.Object{Nullable: true}
+	for _, cfg := range p.plannerConfig.RequiredFields {
+		key, report := plan.RequiredFieldsFragment(cfg.TypeName, cfg.SelectionSet, true)
+		if report.HasErrors() {
+			return nil, nil, nil, report
+		}
+		onTypeNames := [][]byte{[]byte(cfg.TypeName)}
+		for _, fieldRef := range key.SelectionSetFieldRefs(key.FragmentDefinitions[0].SelectionSet) {
+			fieldName := key.FieldNameString(fieldRef)
+			if key.FieldHasSelections(fieldRef) {
+				return nil, nil, nil, fmt.Errorf("required field %s.%s has a selection set, nested keys are not supported", cfg.TypeName, fieldName)
+			}
+			if fieldName != "__typename" {
+				if !slices.Contains(requiredFields[cfg.TypeName], fieldName) {
+					requiredFields[cfg.TypeName] = append(requiredFields[cfg.TypeName], fieldName)
+				}
+				if cfg.FieldName == "" && !slices.Contains(keyFields[cfg.TypeName], fieldName) {
+					keyFields[cfg.TypeName] = append(keyFields[cfg.TypeName], fieldName)
+				}
+			}
+			if idx := slices.IndexFunc(object.Fields, func(field *resolve.Field) bool { return string(field.Name) == fieldName }); idx != -1 {
+				if !slices.ContainsFunc(object.Fields[idx].OnTypeNames, func(typeName []byte) bool { return string(typeName) == cfg.TypeName }) {
+					object.Fields[idx].OnTypeNames = append(object.Fields[idx].OnTypeNames, onTypeNames[0])
+				}
+				continue
+			}
+			field := &resolve.Field{
+				Name:        []byte(fieldName),
+				OnTypeNames: onTypeNames,
+			}
+			if fieldName == "__typename" {
+				field.Value = &resolve.String{Path: []string{fieldName}}
+			} else {
+				field.Value = &resolve.Scalar{Path: []string{fieldName}, Nullable: true}
+			}
+			object.Fields = append(object.Fields, field)
+		}
+	}
+	return requiredFields, keyFields, resolve.NewResolvableObjectVariable(object), nil
+}
+
+// batchKeys returns the key fields of an entity with the path of their
+// values in the response of a batch endpoint.
+func (p *Planner[T]) batchKeys(typeName string, keyFields []string) []batchKey {
+	keys := make([]batchKey, 0, len(keyFields))
+	for _, fieldName := range keyFields {
+		path := []string{fieldName}
+		if fieldConfiguration := p.v.Config.Fields.ForTypeField(typeName, fieldName); fieldConfiguration != nil && len(fieldConfiguration.Path) != 0 {
+			path = fieldConfiguration.Path
+		}
+		keys = append(keys, batchKey{Name: fieldName, Path: path})
+	}
+	return keys
+}
+
+func (p *Planner[T]) ConfigureSubscription() plan.SubscriptionConfiguration {
+	// the REST DataSourcePlanner doesn't have subscriptions
+	return plan.SubscriptionConfiguration{}
+}
+
+func (p *Planner[T]) stopWithError(err error) {
+	p.v.Walker.StopWithInternalErr(err)
+}
+
+// write writes the request with the placeholders of its arguments.
+func (r *plannedRequest) write(buf *bytes.Buffer) error {
+	data, err := json.Marshal(r.request)
+	if err != nil {
+		return err
+	}
+	if len(r.argPaths) == 0 {
+		buf.Write(data)
+		return nil
+	}
+	buf.Write(data[:len(data)-1])
+	buf.WriteString(`,"args":{`)
+	for i, argumentPath := range r.argPaths {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%q:%s", argumentPath, r.args[argumentPath])
+	}
+	buf.WriteString(`}}`)
+	return nil
+}
+
+type endpointTemplate struct {
+	value string
+	// allowObjects is true for templates rendering JSON values.
+	allowObjects bool
+}
+
+func (e Endpoint) templates() []endpointTemplate {
+	templates := make([]endpointTemplate, 0, len(e.Query)+2)
+	templates = append(templates, endpointTemplate{value: e.Path})
+	for _, param := range e.Query {
+		templates = append(templates, endpointTemplate{value: param.Value})
+	}
+	return append(templates, endpointTemplate{value: e.Body, allowObjects: true})
+}
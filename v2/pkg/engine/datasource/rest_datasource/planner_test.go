@@ -0,0 +1,129 @@
+package rest_datasource
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasourcetesting"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/plan"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+const usersSchema = `
+	type Query {
+		user(id: ID!, include: [String!]): User
+	}
+
+	type User {
+		id: ID!
+		name: String!
+	}
+`
+
+func TestRESTDataSourcePlanning(t *testing.T) {
+	factory, err := NewFactory(context.Background(), http.DefaultClient)
+	require.NoError(t, err)
+
+	dataSource, err := plan.NewDataSourceConfiguration[Configuration](
+		"users",
+		factory,
+		&plan.DataSourceMetadata{
+			RootNodes: []plan.TypeField{
+				{TypeName: "Query", FieldNames: []string{"user"}},
+			},
+			ChildNodes: []plan.TypeField{
+				{TypeName: "User", FieldNames: []string{"id", "name"}},
+			},
+		},
+		Configuration{
+			BaseURL: "http://users.service/",
+			RootFields: []RootFieldConfiguration{
+				{
+					TypeName:  "Query",
+					FieldName: "user",
+					Endpoint: Endpoint{
+						Path:  "/users/{{ args.id }}",
+						Query: []QueryParameter{{Name: "include", Value: "{{ args.include }}"}},
+					},
+				},
+			},
+		},
+	)
+	require.NoError(t, err)
+
+	planConfiguration := plan.Configuration{
+		DataSources: []plan.DataSource{dataSource},
+		Fields: plan.FieldConfigurations{
+			{TypeName: "User", FieldName: "name", Path: []string{"profile", "full_name"}},
+		},
+		DisableResolveFieldPositions: true,
+	}
+
+	t.Run("root fields are called with the arguments of the operation", func(t *testing.T) {
+		datasourcetesting.RunTest(usersSchema, `
+			query User($id: ID!) {
+				user(id: $id) {
+					id
+					fullName: name
+					__typename
+				}
+			}`, "User",
+			&plan.SynchronousResponsePlan{
+				Response: &resolve.GraphQLResponse{
+					RawFetches: []*resolve.FetchItem{
+						{
+							Fetch: &resolve.SingleFetch{
+								DataSourceIdentifier: []byte("rest_datasource.Source"),
+								FetchConfiguration: resolve.FetchConfiguration{
+									Input: `{"requests":[{"key":"user","method":"GET","url":"http://users.service/users/{{ args.id }}",` +
+										`"query":[{"name":"include","value":"{{ args.include }}"}],` +
+										`"select":[{"key":"id","path":["id"]},{"key":"fullName","path":["profile","full_name"]},{"key":"__typename","typename":"User"}],` +
+										`"args":{"id":$$0$$,"include":null}}]}`,
+									DataSource: &Source{httpClient: http.DefaultClient},
+									Variables: resolve.NewVariables(
+										&resolve.ContextVariable{
+											Path:     []string{"id"},
+											Renderer: resolve.NewJSONVariableRenderer(),
+										},
+									),
+									PostProcessing: PostProcessingConfiguration,
+								},
+							},
+						},
+					},
+					Data: &resolve.Object{
+						Fields: []*resolve.Field{
+							{
+								Name: []byte("user"),
+								Value: &resolve.Object{
+									Path:          []string{"user"},
+									Nullable:      true,
+									PossibleTypes: map[string]struct{}{"User": {}},
+									TypeName:      "User",
+									Fields: []*resolve.Field{
+										{
+											Name:  []byte("id"),
+											Value: &resolve.Scalar{Path: []string{"id"}},
+										},
+										{
+											Name:  []byte("fullName"),
+											Value: &resolve.String{Path: []string{"fullName"}},
+										},
+										{
+											Name:  []byte("__typename"),
+											Value: &resolve.String{Path: []string{"__typename"}, IsTypeName: true},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			planConfiguration,
+		)(t)
+	})
+}
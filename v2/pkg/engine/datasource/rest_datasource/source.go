@@ -0,0 +1,534 @@
+package rest_datasource
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/buger/jsonparser"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/argument_templates"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+)
+
+// fetchInput is the input of a fetch, it either calls the endpoints of root
+// fields or the endpoints of entities, once for every representation or once
+// per batch of representations.
+type fetchInput struct {
+	Requests        []request          `json:"requests,omitempty"`
+	Entities        map[string]request `json:"entities,omitempty"`
+	Representations []json.RawMessage  `json:"representations,omitempty"`
+}
+
+// request is an endpoint call with the templates still to be rendered with
+// the arguments of a field or the key fields of a representation.
+type request struct {
+	// Key is the response key of the root field.
+	Key          string           `json:"key,omitempty"`
+	Method       string           `json:"method"`
+	URL          string           `json:"url"`
+	Query        []QueryParameter `json:"query,omitempty"`
+	Body         string           `json:"body,omitempty"`
+	Header       http.Header      `json:"header,omitempty"`
+	ResponsePath []string         `json:"response_path,omitempty"`
+	// Select maps the response to the selection set of the field, it is empty
+	// for fields of a scalar type.
+	Select []*selection `json:"select,omitempty"`
+	// Args are the values of the argument templates, keyed by the argument
+	// path, e.g. "filter.name" for {{ args.filter.name }}.
+	Args map[string]json.RawMessage `json:"args,omitempty"`
+	// Batch is set for the endpoint of an entity fetching many entities with
+	// a single call.
+	Batch *batch `json:"batch,omitempty"`
+}
+
+// batch configures the call of a batch endpoint.
+type batch struct {
+	Separator    string     `json:"separator,omitempty"`
+	MaxBatchSize int        `json:"max_batch_size,omitempty"`
+	Keys         []batchKey `json:"keys"`
+}
+
+// batchKey is a key field of the entity, Path selects it from an entity of
+// the response.
+type batchKey struct {
+	Name string   `json:"name"`
+	Path []string `json:"path"`
+}
+
+// selection maps a field of the response to a field of the selection set.
+type selection struct {
+	Key  string   `json:"key"`
+	Path []string `json:"path,omitempty"`
+	// TypeName is the value of a __typename field of a concrete type.
+	TypeName string       `json:"typename,omitempty"`
+	Select   []*selection `json:"select,omitempty"`
+}
+
+type responseError struct {
+	Message    string                  `json:"message"`
+	Extensions responseErrorExtensions `json:"extensions"`
+}
+
+type responseErrorExtensions struct {
+	StatusCode int `json:"statusCode"`
+}
+
+// callResult is the response of a call mapped to the selection set, or the
+// error of an unsuccessful status code.
+type callResult struct {
+	data []byte
+	err  *responseError
+}
+
+type Source struct {
+	httpClient *http.Client
+}
+
+// Load calls the endpoints of the input concurrently. A 404 Not Found
+// response resolves the field or entity to null, any other unsuccessful
+// status code to null with an error.
+func (s *Source) Load(ctx context.Context, headers http.Header, input []byte) (data []byte, err error) {
+	var in fetchInput
+	if err := json.Unmarshal(input, &in); err != nil {
+		return nil, err
+	}
+	if in.Entities != nil {
+		return s.loadEntities(ctx, headers, in)
+	}
+	return s.loadRootFields(ctx, headers, in)
+}
+
+func (s *Source) LoadWithFiles(ctx context.Context, headers http.Header, input []byte, files []*httpclient.FileUpload) (data []byte, err error) {
+	return nil, errors.New("rest data source does not support file uploads")
+}
+
+func (s *Source) loadRootFields(ctx context.Context, headers http.Header, in fetchInput) ([]byte, error) {
+	results := make([]callResult, len(in.Requests))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i := range in.Requests {
+		group.Go(func() (err error) {
+			results[i], err = s.call(groupCtx, headers, &in.Requests[i], in.Requests[i].Args)
+			return err
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	out.WriteString(`{"data":{`)
+	for i := range in.Requests {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.WriteByte('"')
+		out.WriteString(in.Requests[i].Key)
+		out.WriteString(`":`)
+		out.Write(results[i].data)
+	}
+	out.WriteByte('}')
+	if err := writeErrors(out, results); err != nil {
+		return nil, err
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+func (s *Source) loadEntities(ctx context.Context, headers http.Header, in fetchInput) ([]byte, error) {
+	// Every representation is checked before any endpoint is called, so that
+	// no call is left running when one of them cannot be loaded.
+	type single struct {
+		index  int
+		entity request
+		args   map[string]json.RawMessage
+	}
+	var singles []single
+	// the representations of entities with a batch endpoint by type name,
+	// with the index of each representation
+	batches := make(map[string][]int)
+	for i, representation := range in.Representations {
+		typeName, _ := jsonparser.GetString(representation, "__typename")
+		entity, ok := in.Entities[typeName]
+		if !ok {
+			return nil, fmt.Errorf("no endpoint configured for entity %q", typeName)
+		}
+		if entity.Batch != nil {
+			batches[typeName] = append(batches[typeName], i)
+			continue
+		}
+		var args map[string]json.RawMessage
+		if err := json.Unmarshal(representation, &args); err != nil {
+			return nil, err
+		}
+		singles = append(singles, single{index: i, entity: entity, args: args})
+	}
+
+	results := make([]callResult, len(in.Representations))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for _, single := range singles {
+		group.Go(func() (err error) {
+			results[single.index], err = s.call(groupCtx, headers, &single.entity, single.args)
+			return err
+		})
+	}
+	for typeName, indices := range batches {
+		entity := in.Entities[typeName]
+		for chunk := range slices.Chunk(indices, entity.Batch.maxBatchSize(len(indices))) {
+			group.Go(func() error {
+				return s.callBatch(groupCtx, headers, &entity, in.Representations, chunk, results)
+			})
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	out.WriteString(`{"data":{"_entities":[`)
+	for i := range results {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		out.Write(results[i].data)
+	}
+	out.WriteString(`]}`)
+	if err := writeErrors(out, results); err != nil {
+		return nil, err
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// call renders the templates of the request with args, calls the endpoint
+// and maps its response to the selection set.
+func (s *Source) call(ctx context.Context, headers http.Header, r *request, args map[string]json.RawMessage) (callResult, error) {
+	value, dataType, result, err := s.fetch(ctx, headers, r, args)
+	if err != nil || result != nil {
+		return callResult{data: []byte("null"), err: result}, err
+	}
+
+	out := &bytes.Buffer{}
+	writeValue(out, value, dataType, r.Select)
+	return callResult{data: out.Bytes()}, nil
+}
+
+// callBatch calls a batch endpoint for the representations at indices and
+// maps the entities of the response to them by their key fields, in any
+// order. The key of representations sharing a key is sent once, a
+// representation without entity in the response is null.
+func (s *Source) callBatch(ctx context.Context, headers http.Header, r *request, representations []json.RawMessage, indices []int, results []callResult) error {
+	keys := make([]string, len(indices))
+	values := make(map[string][][]byte, len(r.Batch.Keys))
+	seen := make(map[string]struct{}, len(indices))
+	for i, idx := range indices {
+		keys[i] = r.Batch.key(representations[idx], false)
+		if _, ok := seen[keys[i]]; ok {
+			continue
+		}
+		seen[keys[i]] = struct{}{}
+		for _, key := range r.Batch.Keys {
+			value, dataType, _, _ := jsonparser.Get(representations[idx], key.Name)
+			switch dataType {
+			case jsonparser.String:
+				value = append(append([]byte(`"`), value...), '"')
+			case jsonparser.NotExist:
+				value = []byte("null")
+			}
+			values[key.Name] = append(values[key.Name], value)
+		}
+	}
+
+	value, dataType, result, err := s.fetch(ctx, headers, r, r.Batch.args(values))
+	if err != nil {
+		return err
+	}
+	for i, idx := range indices {
+		results[idx] = callResult{data: []byte("null")}
+		// a failed call is reported once per batch
+		if i == 0 {
+			results[idx].err = result
+		}
+	}
+	if result != nil || dataType != jsonparser.Array {
+		return nil
+	}
+
+	entities := make(map[string][]byte, len(indices))
+	_, _ = jsonparser.ArrayEach(value, func(item []byte, itemType jsonparser.ValueType, _ int, _ error) {
+		if itemType != jsonparser.Object {
+			return
+		}
+		out := &bytes.Buffer{}
+		writeValue(out, item, itemType, r.Select)
+		entities[r.Batch.key(item, true)] = out.Bytes()
+	})
+	for i, idx := range indices {
+		if entity, ok := entities[keys[i]]; ok {
+			results[idx].data = entity
+		}
+	}
+	return nil
+}
+
+// fetch renders the templates of the request with args and calls the
+// endpoint. It returns the value at the response path of a successful
+// call, and a result for a call with a 404 Not Found or an unsuccessful
+// status code, with an error for the latter.
+func (s *Source) fetch(ctx context.Context, headers http.Header, r *request, args map[string]json.RawMessage) (value []byte, dataType jsonparser.ValueType, result *responseError, err error) {
+	requestInput, err := r.render(args)
+	if err != nil {
+		return nil, jsonparser.NotExist, nil, err
+	}
+
+	// the base headers are extended with the headers of the request,
+	// every concurrent call needs its own copy
+	ctx, responseContext := httpclient.InjectResponseContext(ctx)
+	data, err := httpclient.Do(s.httpClient, ctx, headers.Clone(), requestInput)
+	if err != nil {
+		return nil, jsonparser.NotExist, nil, err
+	}
+
+	switch status := responseContext.StatusCode; {
+	case status == http.StatusNotFound:
+		return nil, jsonparser.Null, nil, nil
+	case status < 200 || status > 299:
+		return nil, jsonparser.Null, &responseError{
+			Message:    fmt.Sprintf("%s %s: unexpected status code %d", r.Method, responseContext.Request.URL.Path, status),
+			Extensions: responseErrorExtensions{StatusCode: status},
+		}, nil
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, jsonparser.Null, nil, nil
+	}
+	if !json.Valid(data) {
+		return nil, jsonparser.NotExist, nil, fmt.Errorf("%s %s: response is not valid JSON", r.Method, responseContext.Request.URL.Path)
+	}
+
+	value, dataType, _, _ = jsonparser.Get(data, r.ResponsePath...)
+	return value, dataType, nil, nil
+}
+
+func (b *batch) maxBatchSize(size int) int {
+	if b.MaxBatchSize > 0 {
+		return b.MaxBatchSize
+	}
+	return size
+}
+
+// key returns the values of the key fields of a representation, or of an
+// entity of the response.
+func (b *batch) key(entity []byte, fromResponse bool) string {
+	var key strings.Builder
+	for i, k := range b.Keys {
+		if i > 0 {
+			key.WriteByte(0)
+		}
+		path := []string{k.Name}
+		if fromResponse {
+			path = k.Path
+		}
+		value, _, _, _ := jsonparser.Get(entity, path...)
+		key.Write(value)
+	}
+	return key.String()
+}
+
+// args returns the values of the key fields of a batch as the args of the
+// templates, lists or, with a separator, strings of the joined values.
+func (b *batch) args(values map[string][][]byte) map[string]json.RawMessage {
+	args := make(map[string]json.RawMessage, len(values))
+	for name, list := range values {
+		if b.Separator == "" {
+			args[name] = append(append([]byte("["), bytes.Join(list, []byte(","))...), ']')
+			continue
+		}
+		joined := make([]string, len(list))
+		for i := range list {
+			joined[i] = plainValue(list[i])
+		}
+		args[name], _ = json.Marshal(strings.Join(joined, b.Separator))
+	}
+	return args
+}
+
+// render builds the input of httpclient.Do for the request.
+func (r *request) render(args map[string]json.RawMessage) ([]byte, error) {
+	input := httpclient.SetInputMethod(nil, []byte(r.Method))
+	input = httpclient.SetInputURL(input, []byte(renderTemplate(r.URL, args, pathValue)))
+
+	if len(r.Query) != 0 {
+		queryParams, err := renderQueryParams(r.Query, args)
+		if err != nil {
+			return nil, err
+		}
+		input = httpclient.SetInputQueryParams(input, queryParams)
+	}
+	if r.Body != "" {
+		body := renderTemplate(r.Body, args, jsonValue)
+		if !json.Valid([]byte(body)) {
+			return nil, fmt.Errorf("%s %s: rendered body is not valid JSON", r.Method, r.URL)
+		}
+		input = httpclient.SetInputBody(input, []byte(body))
+	}
+	if len(r.Header) != 0 {
+		header, err := json.Marshal(r.Header)
+		if err != nil {
+			return nil, err
+		}
+		input = httpclient.SetInputHeader(input, header)
+	}
+	return input, nil
+}
+
+type renderedQueryParameter struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// renderQueryParams renders the query parameters in the format of the
+// httpclient input. A value consisting of a single template keeps the JSON
+// value of the argument, so that list arguments add the parameter once per
+// item, and null arguments omit it.
+func renderQueryParams(query []QueryParameter, args map[string]json.RawMessage) ([]byte, error) {
+	params := make([]renderedQueryParameter, 0, len(query))
+	for _, param := range query {
+		if match := argument_templates.ArgumentTemplateRegex.FindStringSubmatch(param.Value); match != nil && match[0] == strings.TrimSpace(param.Value) {
+			value := args[match[1]]
+			if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+				continue
+			}
+			params = append(params, renderedQueryParameter{Name: param.Name, Value: value})
+			continue
+		}
+		value, err := json.Marshal(renderTemplate(param.Value, args, plainValue))
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, renderedQueryParameter{Name: param.Name, Value: value})
+	}
+	return json.Marshal(params)
+}
+
+// renderTemplate replaces the argument templates of template with the
+// values of args, formatted by format.
+func renderTemplate(template string, args map[string]json.RawMessage, format func(json.RawMessage) string) string {
+	if !argument_templates.ContainsArgumentTemplateString([]byte(template)) {
+		return template
+	}
+	return argument_templates.ArgumentTemplateRegex.ReplaceAllStringFunc(template, func(match string) string {
+		argumentPath := argument_templates.ArgumentTemplateRegex.FindStringSubmatch(match)[1]
+		return format(args[argumentPath])
+	})
+}
+
+func pathValue(value json.RawMessage) string {
+	return url.PathEscape(plainValue(value))
+}
+
+func plainValue(value json.RawMessage) string {
+	if len(value) == 0 || bytes.Equal(value, []byte("null")) {
+		return ""
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	return string(value)
+}
+
+func jsonValue(value json.RawMessage) string {
+	if len(value) == 0 {
+		return "null"
+	}
+	return string(value)
+}
+
+// writeValue writes value mapped to selections. Lists are mapped item by
+// item, missing fields are null.
+func writeValue(out *bytes.Buffer, value []byte, dataType jsonparser.ValueType, selections []*selection) {
+	switch dataType {
+	case jsonparser.Object:
+		if len(selections) == 0 {
+			// a custom scalar such as JSON
+			out.Write(value)
+			return
+		}
+		out.WriteByte('{')
+		for i, s := range selections {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			out.WriteByte('"')
+			out.WriteString(s.Key)
+			out.WriteString(`":`)
+			if s.TypeName != "" {
+				out.WriteByte('"')
+				out.WriteString(s.TypeName)
+				out.WriteByte('"')
+				continue
+			}
+			fieldValue, fieldType, _, _ := jsonparser.Get(value, s.Path...)
+			writeValue(out, fieldValue, fieldType, s.Select)
+		}
+		out.WriteByte('}')
+	case jsonparser.Array:
+		if len(selections) == 0 {
+			out.Write(value)
+			return
+		}
+		out.WriteByte('[')
+		first := true
+		_, _ = jsonparser.ArrayEach(value, func(item []byte, itemType jsonparser.ValueType, _ int, _ error) {
+			if !first {
+				out.WriteByte(',')
+			}
+			first = false
+			writeValue(out, item, itemType, selections)
+		})
+		out.WriteByte(']')
+	case jsonparser.String:
+		if len(selections) != 0 {
+			out.WriteString("null")
+			return
+		}
+		out.WriteByte('"')
+		out.Write(value)
+		out.WriteByte('"')
+	case jsonparser.Number, jsonparser.Boolean:
+		if len(selections) != 0 {
+			out.WriteString("null")
+			return
+		}
+		out.Write(value)
+	default:
+		out.WriteString("null")
+	}
+}
+
+func writeErrors(out *bytes.Buffer, results []callResult) error {
+	var responseErrors []*responseError
+	for i := range results {
+		if results[i].err != nil {
+			responseErrors = append(responseErrors, results[i].err)
+		}
+	}
+	if len(responseErrors) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(responseErrors)
+	if err != nil {
+		return err
+	}
+	out.WriteString(`,"errors":`)
+	out.Write(data)
+	return nil
+}
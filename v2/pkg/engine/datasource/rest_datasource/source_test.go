@@ -0,0 +1,143 @@
+package rest_datasource
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer answers every request with a fixed response per path and
+// records the requests it received.
+func newTestServer(t *testing.T, responses map[string]string) (*httptest.Server, *[]string) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request := r.Method + " " + r.URL.RequestURI()
+		if len(body) != 0 {
+			request += " " + string(body)
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/error":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "/header":
+			_, _ = io.WriteString(w, `{"value":"`+r.Header.Get("X-Api-Key")+`"}`)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.WriteString(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestSource_Load(t *testing.T) {
+	server, requests := newTestServer(t, map[string]string{
+		"/users/a b": `{"id":"a b","profile":{"full_name":"Jane"},"tags":[{"name":"x","extra":1},{"name":"y"}],"data":{"nested":true}}`,
+		"/users":     `{"items":[{"id":"1"},{"id":"2"}]}`,
+	})
+	source := &Source{httpClient: http.DefaultClient}
+
+	load := func(t *testing.T, input string) string {
+		t.Helper()
+		*requests = (*requests)[:0]
+		data, err := source.Load(context.Background(), nil, []byte(input))
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("the response is mapped to the selection set", func(t *testing.T) {
+		data := load(t, `{"requests":[{"key":"user","method":"GET","url":"`+server.URL+`/users/{{ args.id }}",`+
+			`"select":[{"key":"id","path":["id"]},{"key":"name","path":["profile","full_name"]},{"key":"__typename","typename":"User"},`+
+			`{"key":"tags","path":["tags"],"select":[{"key":"name","path":["name"]}]},{"key":"missing","path":["missing"]},{"key":"json","path":["data"]}]`+
+			`,"args":{"id":"a b"}}]}`)
+		assert.Equal(t, `{"data":{"user":{"id":"a b","name":"Jane","__typename":"User","tags":[{"name":"x"},{"name":"y"}],"missing":null,"json":{"nested":true}}}}`, data)
+		assert.Equal(t, []string{"GET /users/a%20b"}, *requests)
+	})
+
+	t.Run("root fields are called with query parameters and a body", func(t *testing.T) {
+		data := load(t, `{"requests":[`+
+			`{"key":"users","method":"GET","url":"`+server.URL+`/users","response_path":["items"],`+
+			`"query":[{"name":"id","value":"{{ args.ids }}"},{"name":"sort","value":"{{ args.sort }}"},{"name":"q","value":"name:{{ args.name }}"}],`+
+			`"select":[{"key":"id","path":["id"]}],"args":{"ids":["1","2"],"sort":null,"name":"Jane"}},`+
+			`{"key":"created","method":"POST","url":"`+server.URL+`/users","body":"{\"user\":{{ args.input }}}",`+
+			`"select":[{"key":"items","path":["items"],"select":[{"key":"id","path":["id"]}]}],"args":{"input":{"name":"Jane"}}}`+
+			`]}`)
+		assert.Equal(t, `{"data":{"users":[{"id":"1"},{"id":"2"}],"created":{"items":[{"id":"1"},{"id":"2"}]}}}`, data)
+		assert.ElementsMatch(t, []string{
+			"GET /users?id=1&id=2&q=name%3AJane",
+			`POST /users {"user":{"name":"Jane"}}`,
+		}, *requests)
+	})
+
+	t.Run("the headers of the configuration are sent", func(t *testing.T) {
+		data := load(t, `{"requests":[{"key":"header","method":"GET","url":"`+server.URL+`/header","header":{"X-Api-Key":["secret"]}}]}`)
+		assert.Equal(t, `{"data":{"header":{"value":"secret"}}}`, data)
+	})
+
+	t.Run("not found is null, any other unsuccessful status code is an error", func(t *testing.T) {
+		data := load(t, `{"requests":[`+
+			`{"key":"missing","method":"GET","url":"`+server.URL+`/users/unknown","select":[{"key":"id","path":["id"]}]},`+
+			`{"key":"failing","method":"GET","url":"`+server.URL+`/error","select":[{"key":"id","path":["id"]}]}`+
+			`]}`)
+		assert.Equal(t, `{"data":{"missing":null,"failing":null},"errors":[{"message":"GET /error: unexpected status code 500","extensions":{"statusCode":500}}]}`, data)
+	})
+
+	t.Run("an entity endpoint is called once per representation", func(t *testing.T) {
+		data := load(t, `{"entities":{"User":{"method":"GET","url":"`+server.URL+`/users/{{ args.id }}","select":[{"key":"name","path":["profile","full_name"]}]}},`+
+			`"representations":[{"__typename":"User","id":"a b"},{"__typename":"User","id":"unknown"}]}`)
+		assert.Equal(t, `{"data":{"_entities":[{"name":"Jane"},null]}}`, data)
+		assert.ElementsMatch(t, []string{"GET /users/a%20b", "GET /users/unknown"}, *requests)
+	})
+
+	t.Run("a batch endpoint is called once for all representations and mapped by key", func(t *testing.T) {
+		data := load(t, `{"entities":{"User":{"method":"GET","url":"`+server.URL+`/users","response_path":["items"],`+
+			`"query":[{"name":"ids","value":"{{ args.id }}"}],"select":[{"key":"id","path":["id"]}],`+
+			`"batch":{"separator":",","keys":[{"name":"id","path":["id"]}]}}},"representations":[`+
+			`{"__typename":"User","id":"2"},{"__typename":"User","id":"1"},{"__typename":"User","id":"3"},{"__typename":"User","id":"2"}]}`)
+		assert.Equal(t, `{"data":{"_entities":[{"id":"2"},{"id":"1"},null,{"id":"2"}]}}`, data)
+		assert.Equal(t, []string{"GET /users?ids=2%2C1%2C3"}, *requests)
+	})
+
+	t.Run("a batch endpoint is called with a list of keys in batches of the max batch size", func(t *testing.T) {
+		data := load(t, `{"entities":{"User":{"method":"POST","url":"`+server.URL+`/users","response_path":["items"],`+
+			`"body":"{\"ids\":{{ args.id }}}","select":[{"key":"id","path":["id"]}],`+
+			`"batch":{"max_batch_size":2,"keys":[{"name":"id","path":["id"]}]}}},"representations":[`+
+			`{"__typename":"User","id":"1"},{"__typename":"User","id":"2"},{"__typename":"User","id":"3"}]}`)
+		assert.Equal(t, `{"data":{"_entities":[{"id":"1"},{"id":"2"},null]}}`, data)
+		assert.ElementsMatch(t, []string{`POST /users {"ids":["1","2"]}`, `POST /users {"ids":["3"]}`}, *requests)
+	})
+
+	t.Run("a failed batch call is reported once", func(t *testing.T) {
+		data := load(t, `{"entities":{"User":{"method":"GET","url":"`+server.URL+`/error","select":[{"key":"id","path":["id"]}],`+
+			`"batch":{"keys":[{"name":"id","path":["id"]}]}}},"representations":[{"__typename":"User","id":"1"},{"__typename":"User","id":"2"}]}`)
+		assert.Equal(t, `{"data":{"_entities":[null,null]},"errors":[{"message":"GET /error: unexpected status code 500","extensions":{"statusCode":500}}]}`, data)
+	})
+
+	t.Run("a representation of an entity without endpoint fails before any endpoint is called", func(t *testing.T) {
+		*requests = (*requests)[:0]
+		_, err := source.Load(context.Background(), nil, []byte(`{"entities":{"User":{"method":"GET","url":"`+server.URL+`/users/{{ args.id }}"}},`+
+			`"representations":[{"__typename":"User","id":"a b"},{"__typename":"Post","id":"1"}]}`))
+		require.EqualError(t, err, `no endpoint configured for entity "Post"`)
+		assert.Empty(t, *requests)
+	})
+}
@@ -0,0 +1,110 @@
+package plan
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+var (
+	cacheControlDirectiveName = []byte("cacheControl")
+	cacheControlMaxAge        = []byte("maxAge")
+	cacheControlScope         = []byte("scope")
+	cacheControlScopePrivate  = []byte("PRIVATE")
+)
+
+// foldCacheControl folds the @cacheControl hint of a field the fetch of the
+// planner selects into the policy of the fetch.
+//
+// A root field or a field returning a composite type that has no hint counts
+// as a maxAge of zero once any other field of the fetch has one, the way a
+// hint on a single field must not make the fields next to it cacheable. A leaf
+// field without a hint inherits the policy of its parent and leaves the policy
+// as it is. A fetch none of whose fields has a hint keeps no policy, which
+// leaves caching to the Cache-Control headers of the subgraph.
+func (v *Visitor) foldCacheControl(plannerID int, typeName, fieldName string) {
+	hint := v.cacheControlHint(typeName, fieldName)
+	if hint == nil {
+		if v.cacheControlInheritsHint(typeName, fieldName) {
+			return
+		}
+		v.plannerUnhintedFields[plannerID] = true
+	}
+	v.plannerCacheControl[plannerID] = v.plannerCacheControl[plannerID].Merge(hint)
+}
+
+// fetchCacheControl returns the policy folded from the fields of the fetch of
+// the planner.
+func (v *Visitor) fetchCacheControl(plannerID int) *resolve.CacheControlPolicy {
+	policy := v.plannerCacheControl[plannerID]
+	if policy == nil || !v.plannerUnhintedFields[plannerID] {
+		return policy
+	}
+	var zero time.Duration
+	return policy.Merge(&resolve.CacheControlPolicy{MaxAge: &zero})
+}
+
+// cacheControlInheritsHint reports whether a field without a hint inherits the
+// one of its parent, which a field of a root operation type has none of. Only
+// fields returning a scalar or an enum do.
+func (v *Visitor) cacheControlInheritsHint(typeName, fieldName string) bool {
+	if v.Definition.Index.IsRootOperationTypeNameString(typeName) {
+		return false
+	}
+	fieldDefRef := v.fieldDefinitionRef(typeName, fieldName)
+	if fieldDefRef == ast.InvalidRef {
+		// __typename and the like
+		return true
+	}
+	typeNode, ok := v.Definition.Index.FirstNodeByNameBytes(v.Definition.ResolveTypeNameBytes(v.Definition.FieldDefinitionType(fieldDefRef)))
+	if !ok {
+		// a built-in scalar
+		return true
+	}
+	return typeNode.Kind == ast.NodeKindScalarTypeDefinition || typeNode.Kind == ast.NodeKindEnumTypeDefinition
+}
+
+// cacheControlHint returns the @cacheControl hint of a field: the one set on
+// its FieldConfiguration, otherwise the directive on its definition, otherwise
+// the directive on the type it returns. It returns nil for a field without one.
+func (v *Visitor) cacheControlHint(typeName, fieldName string) *resolve.CacheControlPolicy {
+	if fieldConfig := v.Config.Fields.ForTypeField(typeName, fieldName); fieldConfig != nil && fieldConfig.CacheControl != nil {
+		return fieldConfig.CacheControl
+	}
+
+	fieldDefRef := v.fieldDefinitionRef(typeName, fieldName)
+	if fieldDefRef == ast.InvalidRef {
+		return nil
+	}
+	if directiveRef, ok := v.Definition.FieldDefinitionDirectiveByName(fieldDefRef, cacheControlDirectiveName); ok {
+		return cacheControlDirectivePolicy(v.Definition, directiveRef)
+	}
+
+	typeNode, ok := v.Definition.Index.FirstNodeByNameBytes(v.Definition.ResolveTypeNameBytes(v.Definition.FieldDefinitionType(fieldDefRef)))
+	if !ok {
+		return nil
+	}
+	for _, directiveRef := range v.Definition.NodeDirectives(typeNode) {
+		if bytes.Equal(v.Definition.DirectiveNameBytes(directiveRef), cacheControlDirectiveName) {
+			return cacheControlDirectivePolicy(v.Definition, directiveRef)
+		}
+	}
+	return nil
+}
+
+// cacheControlDirectivePolicy reads a @cacheControl(maxAge: Int, scope:
+// CacheControlScope) directive. An argument of the wrong kind is ignored
+// rather than rejected, the schema having been validated long before.
+func cacheControlDirectivePolicy(definition *ast.Document, directiveRef int) *resolve.CacheControlPolicy {
+	policy := &resolve.CacheControlPolicy{}
+	if value, ok := definition.DirectiveArgumentValueByName(directiveRef, cacheControlMaxAge); ok && value.Kind == ast.ValueKindInteger {
+		maxAge := time.Duration(definition.IntValueAsInt(value.Ref)) * time.Second
+		policy.MaxAge = &maxAge
+	}
+	if value, ok := definition.DirectiveArgumentValueByName(directiveRef, cacheControlScope); ok && value.Kind == ast.ValueKindEnum {
+		policy.Private = bytes.Equal(definition.EnumValueNameBytes(value.Ref), cacheControlScopePrivate)
+	}
+	return policy
+}
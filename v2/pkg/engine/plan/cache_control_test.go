@@ -0,0 +1,162 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jensneuse/abstractlogger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astnormalization"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/asttransform"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/internal/unsafeparser"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/operationreport"
+)
+
+func TestVisitorCacheControlHint(t *testing.T) {
+	definition := unsafeparser.ParseGraphqlDocumentString(`
+		enum CacheControlScope { PUBLIC PRIVATE }
+		directive @cacheControl(maxAge: Int, scope: CacheControlScope) on FIELD_DEFINITION | OBJECT | INTERFACE | UNION
+
+		type Query {
+			me: User @cacheControl(maxAge: 10, scope: PRIVATE)
+			product: Product
+			review: Review @cacheControl(maxAge: 5)
+			plain: String
+		}
+		type User { id: ID }
+		type Product @cacheControl(maxAge: 60) { upc: ID }
+		type Review @cacheControl(maxAge: 60) { body: String }
+	`)
+	require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&definition))
+
+	seconds := func(n int) *time.Duration {
+		d := time.Duration(n) * time.Second
+		return &d
+	}
+	configured := &resolve.CacheControlPolicy{MaxAge: seconds(1)}
+
+	v := &Visitor{
+		Definition: &definition,
+		Config: Configuration{
+			Fields: FieldConfigurations{
+				{TypeName: "Query", FieldName: "plain", CacheControl: configured},
+			},
+		},
+	}
+
+	assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(10), Private: true}, v.cacheControlHint("Query", "me"),
+		"the directive on the field")
+	assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(60)}, v.cacheControlHint("Query", "product"),
+		"the directive on the type the field returns")
+	assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(5)}, v.cacheControlHint("Query", "review"),
+		"the field outranks its type")
+	assert.Same(t, configured, v.cacheControlHint("Query", "plain"),
+		"the field configuration")
+	assert.Nil(t, v.cacheControlHint("User", "id"))
+	assert.Nil(t, v.cacheControlHint("Query", "missing"))
+}
+
+func TestPlanner_CacheControl(t *testing.T) {
+	const schema = `
+		enum CacheControlScope { PUBLIC PRIVATE }
+		directive @cacheControl(maxAge: Int, scope: CacheControlScope) on FIELD_DEFINITION | OBJECT | INTERFACE | UNION
+
+		schema { query: Query }
+		type Query {
+			product: Product @cacheControl(maxAge: 60)
+			plain: Product
+			name: String @cacheControl(maxAge: 30)
+			count: Int
+		}
+		type Product {
+			upc: ID
+			price: Int @cacheControl(maxAge: 10)
+			reviews: [Review]
+			related: Product
+		}
+		type Review @cacheControl(maxAge: 20) { body: String }
+	`
+
+	fetchCacheControl := func(t *testing.T, operation string) *resolve.CacheControlPolicy {
+		t.Helper()
+
+		def := unsafeparser.ParseGraphqlDocumentString(schema)
+		require.NoError(t, asttransform.MergeDefinitionWithBaseSchema(&def))
+		op := unsafeparser.ParseGraphqlDocumentString(operation)
+		var report operationreport.Report
+		astnormalization.NewNormalizer(true, true).NormalizeOperation(&op, &def, &report)
+		require.False(t, report.HasErrors(), report.Error())
+
+		ds := dsb().
+			Schema(schema).
+			RootNode("Query", "product", "plain", "name", "count").
+			ChildNode("Product", "upc", "price", "reviews", "related").
+			ChildNode("Review", "body")
+		ds.ds.factory = &fieldVisitingFactory{FakeFactory: ds.ds.factory.(*FakeFactory[any])}
+		planner, err := NewPlanner(Configuration{
+			DisableResolveFieldPositions: true,
+			DataSources:                  []DataSource{ds.DS()},
+		})
+		require.NoError(t, err)
+		p := planner.Plan(&op, &def, "", &report)
+		require.False(t, report.HasErrors(), report.Error())
+
+		fetches := p.(*SynchronousResponsePlan).Response.RawFetches
+		require.Len(t, fetches, 1)
+		return fetches[0].Fetch.(*resolve.SingleFetch).CacheControl
+	}
+
+	seconds := func(n int) *time.Duration {
+		d := time.Duration(n) * time.Second
+		return &d
+	}
+
+	t.Run("leaf fields without a hint inherit the one of their parent", func(t *testing.T) {
+		assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(10)}, fetchCacheControl(t, `{ product { upc price } }`))
+	})
+
+	t.Run("a composite field inherits the hint of the type it returns", func(t *testing.T) {
+		assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(20)}, fetchCacheControl(t, `{ product { reviews { body } } }`))
+	})
+
+	t.Run("a root field without a hint counts as a max age of zero", func(t *testing.T) {
+		assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(0)}, fetchCacheControl(t, `{ plain { upc price } }`))
+	})
+
+	t.Run("a composite field without a hint counts as a max age of zero", func(t *testing.T) {
+		assert.Equal(t, &resolve.CacheControlPolicy{MaxAge: seconds(0)}, fetchCacheControl(t, `{ product { related { upc } } }`))
+	})
+
+	t.Run("a fetch without any hint has no policy", func(t *testing.T) {
+		assert.Nil(t, fetchCacheControl(t, `{ plain { upc related { upc } } }`))
+		assert.Nil(t, fetchCacheControl(t, `{ count }`))
+	})
+}
+
+// fieldVisitingFactory is a FakeFactory whose planners visit fields the way the
+// planner of a real data source does, which is what the hints of the fields
+// are folded on.
+type fieldVisitingFactory struct {
+	*FakeFactory[any]
+}
+
+func (f *fieldVisitingFactory) Planner(logger abstractlogger.Logger) DataSourcePlanner[any] {
+	return &fieldVisitingPlanner{FakePlanner: f.FakeFactory.Planner(logger).(*FakePlanner[any])}
+}
+
+type fieldVisitingPlanner struct {
+	*FakePlanner[any]
+}
+
+func (p *fieldVisitingPlanner) Register(visitor *Visitor, configuration DataSourceConfiguration[any], plannerConfiguration DataSourcePlannerConfiguration) error {
+	visitor.Walker.RegisterEnterFieldVisitor(p)
+	visitor.Walker.RegisterLeaveFieldVisitor(p)
+	return p.FakePlanner.Register(visitor, configuration, plannerConfiguration)
+}
+
+func (p *fieldVisitingPlanner) EnterField(int) {}
+
+func (p *fieldVisitingPlanner) LeaveField(int) {}
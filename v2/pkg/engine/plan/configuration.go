@@ -155,6 +155,10 @@ type FieldConfiguration struct {
 	UnescapeResponseJson bool
 	// HasAuthorizationRule needs to be set to true if the Authorizer should be called for this field
 	HasAuthorizationRule bool
+	// CacheControl stands in for a @cacheControl directive on the field, for
+	// subgraphs whose schema carries none. It takes precedence over a directive
+	// the schema does have.
+	CacheControl *resolve.CacheControlPolicy
 
 	SubscriptionFilterCondition *SubscriptionFilterCondition
 }
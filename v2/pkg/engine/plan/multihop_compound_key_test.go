@@ -397,6 +397,7 @@ const multiHopCompoundKeyExpectedPlan = `
       QueryPlan: nil,
       OperationName: "",
       SubgraphOperation: nil,
+      CacheControl: nil,
      },
      FetchDependencies: {
       FetchID: 0,
@@ -441,6 +442,7 @@ const multiHopCompoundKeyExpectedPlan = `
       QueryPlan: nil,
       OperationName: "",
       SubgraphOperation: nil,
+      CacheControl: nil,
      },
      FetchDependencies: {
       FetchID: 1,
@@ -504,6 +506,7 @@ const multiHopCompoundKeyExpectedPlan = `
       QueryPlan: nil,
       OperationName: "",
       SubgraphOperation: nil,
+      CacheControl: nil,
      },
      FetchDependencies: {
       FetchID: 2,
@@ -25,6 +25,7 @@ type Planner struct {
 
 	prepareOperationWalker *astvisitor.Walker
 	deferInfoCollector     *deferInfoCollector
+	streamInfoCollector    *streamInfoCollector
 }
 
 // NewPlanner creates a new Planner from the Configuration.
@@ -58,6 +59,7 @@ func NewPlanner(config Configuration) (*Planner, error) {
 	prepareOperationWalker := astvisitor.NewWalkerWithID(48, "PrepareOperationWalker")
 	astnormalization.InlineFragmentAddOnType(&prepareOperationWalker)
 	deferInfoCollector := registerDeferInfoCollector(&prepareOperationWalker)
+	streamInfoCollector := registerStreamInfoCollector(&prepareOperationWalker)
 
 	// planning
 
@@ -72,6 +74,7 @@ func NewPlanner(config Configuration) (*Planner, error) {
 		planningVisitor:        planningVisitor,
 		prepareOperationWalker: &prepareOperationWalker,
 		deferInfoCollector:     deferInfoCollector,
+		streamInfoCollector:    streamInfoCollector,
 	}
 
 	return p, nil
@@ -108,6 +111,7 @@ func (p *Planner) Plan(operation, definition *ast.Document, operationName string
 	}
 
 	p.planningVisitor.deferDescriptors = p.deferInfoCollector.descriptors
+	p.planningVisitor.streamFields, p.planningVisitor.streamDescriptors = p.streamInfoCollector.assignIDs(p.deferInfoCollector.descriptors)
 
 	// assign hash to each datasource
 	for i := range p.config.DataSources {
@@ -0,0 +1,145 @@
+package plan
+
+import (
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/astvisitor"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/lexer/literal"
+)
+
+// streamInfoCollector records a StreamField and a descriptor for every list
+// field with an enabled @stream directive.
+//
+// Only a list field that is neither nested in another list nor part of a
+// deferred fragment is streamed. A list inside a list would need one stream
+// per item of the outer list, and a deferred list is sent in one piece with its
+// fragment anyway; the @stream directive of such a field is ignored and the
+// list is delivered whole.
+//
+// Streaming does not make the list load incrementally: its fetches, including
+// the entity fetches for its items, are planned into the initial fetch tree
+// like those of any other field, see resolve.StreamField.
+//
+// Stream ids are assigned in document order once the walk is done, after the
+// highest defer id, because streams and defers share the pending/completed ids
+// of a response.
+type streamInfoCollector struct {
+	*astvisitor.Walker
+
+	operation  *ast.Document
+	definition *ast.Document
+
+	// ancestors holds, per entered field, whether it rules out streaming its
+	// descendants.
+	ancestors []bool
+
+	fieldRefs   []int
+	fields      map[int]*resolve.StreamField
+	descriptors map[int]resolve.DeferDescriptor
+}
+
+func registerStreamInfoCollector(walker *astvisitor.Walker) *streamInfoCollector {
+	c := &streamInfoCollector{Walker: walker}
+	walker.RegisterEnterDocumentVisitor(c)
+	walker.RegisterFieldVisitor(c)
+	return c
+}
+
+func (c *streamInfoCollector) EnterDocument(operation, definition *ast.Document) {
+	c.operation = operation
+	c.definition = definition
+	c.ancestors = c.ancestors[:0]
+	c.fieldRefs = c.fieldRefs[:0]
+	c.fields = make(map[int]*resolve.StreamField)
+	c.descriptors = make(map[int]resolve.DeferDescriptor)
+}
+
+func (c *streamInfoCollector) EnterField(ref int) {
+	_, _, _, deferred := c.operation.FieldDeferInfo(ref)
+	isList := false
+	if definitionRef, ok := c.Walker.FieldDefinition(ref); ok {
+		isList = c.definition.TypeIsList(c.definition.FieldDefinitionType(definitionRef))
+	}
+
+	if isList && !deferred && !c.hasListOrDeferredAncestor() {
+		if initialCount, label, ok := c.streamDirective(ref); ok {
+			c.fieldRefs = append(c.fieldRefs, ref)
+			c.fields[ref] = &resolve.StreamField{InitialBatchSize: initialCount}
+			c.descriptors[ref] = resolve.DeferDescriptor{Label: label, Path: c.fieldPath(ref)}
+		}
+	}
+
+	c.ancestors = append(c.ancestors, isList || deferred)
+}
+
+func (c *streamInfoCollector) LeaveField(_ int) {
+	c.ancestors = c.ancestors[:len(c.ancestors)-1]
+}
+
+func (c *streamInfoCollector) hasListOrDeferredAncestor() bool {
+	for _, rulesOut := range c.ancestors {
+		if rulesOut {
+			return true
+		}
+	}
+	return false
+}
+
+// streamDirective returns the initialCount and label of the field's @stream
+// directive, and false when the field has none or it is disabled.
+func (c *streamInfoCollector) streamDirective(ref int) (initialCount int, label string, ok bool) {
+	directiveRef, exists := c.operation.Fields[ref].Directives.HasDirectiveByNameBytes(c.operation, literal.STREAM)
+	if !exists {
+		return 0, "", false
+	}
+	if ifValue, hasIf := c.operation.DirectiveArgumentValueByName(directiveRef, literal.IF); hasIf {
+		enabled, valid := c.operation.GetBooleanValue(ifValue)
+		if !valid || !enabled {
+			return 0, "", false
+		}
+	}
+	if countValue, hasCount := c.operation.DirectiveArgumentValueByName(directiveRef, literal.INITIAL_COUNT); hasCount {
+		if count, valid := c.operation.GetIntValue(countValue); valid {
+			initialCount = max(count, 0)
+		}
+	}
+	if labelValue, hasLabel := c.operation.DirectiveArgumentValueByName(directiveRef, literal.LABEL); hasLabel && labelValue.Kind == ast.ValueKindString {
+		label = c.operation.StringValueContentString(labelValue.Ref)
+	}
+	return initialCount, label, true
+}
+
+// fieldPath returns the response path of the field, without the
+// operation-type root segment.
+func (c *streamInfoCollector) fieldPath(ref int) []string {
+	path := make([]string, 0, len(c.Walker.Path))
+	for i := 1; i < len(c.Walker.Path); i++ {
+		if c.Walker.Path[i].Kind != ast.FieldName {
+			continue
+		}
+		path = append(path, string(c.Walker.Path[i].FieldName))
+	}
+	return append(path, c.operation.FieldAliasOrNameString(ref))
+}
+
+// assignIDs numbers the streams in document order, starting after the
+// highest of the given defer descriptors' ids, and returns the StreamFields
+// keyed by field ref and the descriptors keyed by stream id.
+func (c *streamInfoCollector) assignIDs(deferDescriptors map[int]resolve.DeferDescriptor) (map[int]*resolve.StreamField, map[int]resolve.DeferDescriptor) {
+	if len(c.fieldRefs) == 0 {
+		return nil, nil
+	}
+	nextID := 1
+	for id := range deferDescriptors {
+		nextID = max(nextID, id+1)
+	}
+	descriptors := make(map[int]resolve.DeferDescriptor, len(c.fieldRefs))
+	for _, ref := range c.fieldRefs {
+		descriptor := c.descriptors[ref]
+		descriptor.ID = nextID
+		c.fields[ref].ID = nextID
+		descriptors[nextID] = descriptor
+		nextID++
+	}
+	return c.fields, descriptors
+}
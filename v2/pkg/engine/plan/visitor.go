@@ -56,6 +56,8 @@ type Visitor struct {
 	disableResolveFieldPositions bool
 	includeQueryPlans            bool
 	deferDescriptors             map[int]resolve.DeferDescriptor
+	streamFields                 map[int]*resolve.StreamField // keyed by field ref
+	streamDescriptors            map[int]resolve.DeferDescriptor
 	indirectInterfaceFields      map[int]indirectInterfaceField
 	pathCache                    map[astvisitor.VisitorKind]map[int]string
 
@@ -69,6 +71,13 @@ type Visitor struct {
 
 	// fieldEnclosingTypeNames maps fieldRef to the enclosing type name.
 	fieldEnclosingTypeNames map[int]string
+
+	// plannerCacheControl maps plannerID to the cache control policy folded from
+	// the fields selected by its fetch. Values added in AllowVisitor callback.
+	plannerCacheControl map[int]*resolve.CacheControlPolicy
+	// plannerUnhintedFields maps plannerID to whether its fetch selects a field
+	// without a hint that does not inherit one, see foldCacheControl.
+	plannerUnhintedFields map[int]bool
 }
 
 func NewVisitor(w *astvisitor.Walker) *Visitor {
@@ -220,6 +229,12 @@ func (v *Visitor) AllowVisitor(kind astvisitor.VisitorKind, ref int, visitor any
 			}
 		}
 
+		// Only the fields the fetch selects make up its policy, not the parent
+		// fields walked to reach them.
+		if kind == astvisitor.LeaveField && shouldWalkFieldsOnPath && config.HasPathWithFieldRef(ref) {
+			v.foldCacheControl(visitorID, enclosingTypeName, fieldName)
+		}
+
 		if !v.Config.DisableCalculateFieldDependencies && kind == astvisitor.LeaveField {
 			// we don't need to do this twice, so we only do it on leave
 
@@ -597,6 +612,7 @@ func (v *Visitor) LeaveField(fieldRef int) {
 	}
 
 	v.assignDefer(fieldRef)
+	v.assignStream(fieldRef)
 
 	// remove the current field from the current fields stack
 	v.fieldStack = v.fieldStack[:len(v.fieldStack)-1]
@@ -638,6 +654,14 @@ func (v *Visitor) assignDefer(fieldRef int) {
 	}
 }
 
+func (v *Visitor) assignStream(fieldRef int) {
+	streamField, ok := v.streamFields[fieldRef]
+	if !ok {
+		return
+	}
+	v.fieldStack[len(v.fieldStack)-1].Stream = streamField
+}
+
 // skipField returns true if the field was added by the query planner as a dependency.
 // For another field and should not be included in the response.
 // If it returns false, the user requests the field.
@@ -1004,7 +1028,8 @@ func (v *Visitor) EnterOperationDefinition(opRef int) {
 	})
 
 	isSubscription := false
-	isDefer := false
+	// a streamed list is delivered incrementally like a deferred fragment
+	isDefer := len(v.streamDescriptors) > 0
 
 	for i := range v.planners {
 		if v.planners[i].ObjectFetchConfiguration().isSubscription {
@@ -1041,8 +1066,9 @@ func (v *Visitor) EnterOperationDefinition(opRef int) {
 	case isDefer:
 		v.plan = &DeferResponsePlan{
 			Response: &resolve.GraphQLDeferResponse{
-				Response:         v.response,
-				DeferDescriptors: v.deferDescriptors,
+				Response:          v.response,
+				DeferDescriptors:  v.deferDescriptors,
+				StreamDescriptors: v.streamDescriptors,
 			},
 		}
 	default:
@@ -1054,6 +1080,8 @@ func (v *Visitor) EnterOperationDefinition(opRef int) {
 
 func (v *Visitor) EnterDocument(operation, definition *ast.Document) {
 	v.Operation, v.Definition = operation, definition
+	v.plannerCacheControl = map[int]*resolve.CacheControlPolicy{}
+	v.plannerUnhintedFields = map[int]bool{}
 }
 
 func (v *Visitor) LeaveDocument(_, _ *ast.Document) {
@@ -1282,6 +1310,9 @@ func (v *Visitor) configureFetch(internal *objectFetchConfiguration, external re
 		},
 		DataSourceIdentifier: []byte(dataSourceType),
 	}
+	// The policy is part of the fetch rather than its info, as caching depends
+	// on it whether or not info is included.
+	singleFetch.CacheControl = v.fetchCacheControl(internal.fetchID)
 
 	if v.Config.DisableIncludeInfo {
 		return singleFetch
@@ -0,0 +1,159 @@
+// Package ratelimit implements resolve.RateLimiter with token buckets.
+//
+// A Limiter takes tokens from the bucket of every fetch of an operation, or
+// the estimated cost of the operation at once when it limits by cost. The
+// buckets are kept in a Store: MemoryStore limits the rate per process, other
+// implementations share the buckets between the nodes of a deployment.
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/buger/jsonparser"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+// ErrRateLimitExceeded is returned for a fetch over the limit when
+// RateLimitOptions.RejectExceedingRequests is set, which fails the whole
+// operation instead of the fetch only.
+var ErrRateLimitExceeded = errors.New("rate limit exceeded")
+
+// Options configure a Limiter.
+type Options struct {
+	// ByCost takes the estimated cost of an operation from the bucket of the
+	// RateLimitKey once, before its fetches, instead of a token per fetch. It
+	// needs the cost of the operation, see resolve.Context.SetOperationCost.
+	ByCost bool
+}
+
+// Stats is rendered in the rateLimit extension of the response. Remaining is
+// the lowest and RetryAfterMs and ResetAfterMs the highest over the buckets
+// the operation took tokens from.
+type Stats struct {
+	// RequestRate is the number of tokens the operation asked for.
+	RequestRate  int   `json:"requestRate"`
+	Remaining    int   `json:"remaining"`
+	RetryAfterMs int64 `json:"retryAfterMs"`
+	ResetAfterMs int64 `json:"resetAfterMs"`
+}
+
+// Limiter limits the fetches of an operation by the RateLimitOptions of its
+// resolve.Context. The bucket of a fetch is keyed by the RateLimitKey and the
+// subgraph, and an entity fetch takes a token per entity it fetches.
+//
+// A Limiter collects the stats of one operation, create one per operation
+// around a Store shared between them.
+type Limiter struct {
+	store   Store
+	options Options
+
+	mu    sync.Mutex
+	stats Stats
+	taken bool
+}
+
+// New returns a Limiter taking tokens from store, a MemoryStore when nil.
+func New(store Store, options Options) *Limiter {
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Limiter{
+		store:   store,
+		options: options,
+	}
+}
+
+func (l *Limiter) RateLimitPreFetch(ctx *resolve.Context, info *resolve.FetchInfo, input json.RawMessage) (*resolve.RateLimitDeny, error) {
+	if l.options.ByCost {
+		return nil, nil
+	}
+	deny, err := l.take(ctx, fetchKey(ctx, info), fetchWeight(input))
+	if err != nil || deny == nil {
+		return deny, err
+	}
+	if ctx.RateLimitOptions.RejectExceedingRequests {
+		return nil, ErrRateLimitExceeded
+	}
+	return deny, nil
+}
+
+func (l *Limiter) RateLimitOperation(ctx *resolve.Context, cost *resolve.OperationCost) (*resolve.RateLimitDeny, error) {
+	if !l.options.ByCost || cost.Estimated <= 0 {
+		return nil, nil
+	}
+	return l.take(ctx, ctx.RateLimitOptions.RateLimitKey, cost.Estimated)
+}
+
+func (l *Limiter) take(ctx *resolve.Context, key string, n int) (*resolve.RateLimitDeny, error) {
+	options := ctx.RateLimitOptions
+	result, err := l.store.Take(ctx.Context(), key, n, Limit{
+		Rate:   options.Rate,
+		Burst:  options.Burst,
+		Period: options.Period,
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.record(n, result)
+	if result.Allowed {
+		return nil, nil
+	}
+	return &resolve.RateLimitDeny{}, nil
+}
+
+func (l *Limiter) record(n int, result Result) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stats.RequestRate += n
+	if !l.taken || result.Remaining < l.stats.Remaining {
+		l.stats.Remaining = result.Remaining
+	}
+	l.stats.RetryAfterMs = max(l.stats.RetryAfterMs, result.RetryAfter.Milliseconds())
+	l.stats.ResetAfterMs = max(l.stats.ResetAfterMs, result.ResetAfter.Milliseconds())
+	l.taken = true
+}
+
+// Stats returns the stats of the operation so far.
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+func (l *Limiter) RenderResponseExtension(_ *resolve.Context, out io.Writer) error {
+	data, err := json.Marshal(l.Stats())
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// fetchKey is the key of the bucket of the subgraph of a fetch.
+func fetchKey(ctx *resolve.Context, info *resolve.FetchInfo) string {
+	subgraph := info.DataSourceName
+	if subgraph == "" {
+		subgraph = info.DataSourceID
+	}
+	return ctx.RateLimitOptions.RateLimitKey + ":" + subgraph
+}
+
+// fetchWeight is the number of entities an entity fetch sends as
+// representations, and one for any other fetch.
+func fetchWeight(input []byte) int {
+	entities := 0
+	_, _ = jsonparser.ArrayEach(input, func([]byte, jsonparser.ValueType, int, error) {
+		entities++
+	}, "body", "variables", "representations")
+	return max(entities, 1)
+}
+
+var (
+	_ resolve.RateLimiter     = (*Limiter)(nil)
+	_ resolve.CostRateLimiter = (*Limiter)(nil)
+)
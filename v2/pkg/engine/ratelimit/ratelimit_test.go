@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func newTestContext(options resolve.RateLimitOptions) *resolve.Context {
+	ctx := resolve.NewContext(context.Background())
+	options.Enable = true
+	ctx.RateLimitOptions = options
+	return ctx
+}
+
+func TestLimiter_RateLimitPreFetch(t *testing.T) {
+	users := &resolve.FetchInfo{DataSourceID: "0", DataSourceName: "users"}
+	reviews := &resolve.FetchInfo{DataSourceID: "1", DataSourceName: "reviews"}
+	rootInput := json.RawMessage(`{"method":"POST","url":"http://users","body":{"query":"{me {id}}"}}`)
+	entityInput := json.RawMessage(`{"method":"POST","url":"http://reviews","body":{"query":"...","variables":{"representations":[{"__typename":"User","id":"1"},{"__typename":"User","id":"2"}]}}}`)
+
+	t.Run("takes a token per fetch from the bucket of the subgraph", func(t *testing.T) {
+		store := NewMemoryStore()
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 2, Period: time.Minute, RateLimitKey: "client"})
+
+		limiter := New(store, Options{})
+		for range 2 {
+			deny, err := limiter.RateLimitPreFetch(ctx, users, rootInput)
+			require.NoError(t, err)
+			assert.Nil(t, deny)
+		}
+
+		deny, err := limiter.RateLimitPreFetch(ctx, users, rootInput)
+		require.NoError(t, err)
+		assert.NotNil(t, deny)
+
+		deny, err = limiter.RateLimitPreFetch(ctx, reviews, rootInput)
+		require.NoError(t, err)
+		assert.Nil(t, deny)
+
+		deny, err = New(store, Options{}).RateLimitPreFetch(newTestContext(resolve.RateLimitOptions{Rate: 2, Period: time.Minute, RateLimitKey: "other"}), users, rootInput)
+		require.NoError(t, err)
+		assert.Nil(t, deny)
+	})
+
+	t.Run("an entity fetch takes a token per entity", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 3, Period: time.Minute})
+
+		limiter := New(store, Options{})
+		deny, err := limiter.RateLimitPreFetch(ctx, reviews, entityInput)
+		require.NoError(t, err)
+		assert.Nil(t, deny)
+
+		deny, err = limiter.RateLimitPreFetch(ctx, reviews, entityInput)
+		require.NoError(t, err)
+		assert.NotNil(t, deny)
+
+		assert.Equal(t, Stats{RequestRate: 4, Remaining: 1, RetryAfterMs: 20000, ResetAfterMs: 40000}, limiter.Stats())
+	})
+
+	t.Run("rejecting exceeding requests fails the operation", func(t *testing.T) {
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 1, Period: time.Minute, RejectExceedingRequests: true})
+
+		limiter := New(nil, Options{})
+		_, err := limiter.RateLimitPreFetch(ctx, users, rootInput)
+		require.NoError(t, err)
+
+		_, err = limiter.RateLimitPreFetch(ctx, users, rootInput)
+		assert.ErrorIs(t, err, ErrRateLimitExceeded)
+	})
+
+	t.Run("fetches are not limited by cost", func(t *testing.T) {
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 1, Period: time.Minute})
+
+		limiter := New(nil, Options{ByCost: true})
+		for range 3 {
+			deny, err := limiter.RateLimitPreFetch(ctx, users, rootInput)
+			require.NoError(t, err)
+			assert.Nil(t, deny)
+		}
+	})
+}
+
+func TestLimiter_RateLimitOperation(t *testing.T) {
+	t.Run("takes the estimated cost from the bucket of the key", func(t *testing.T) {
+		store := NewMemoryStore()
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 10, Period: time.Minute, RateLimitKey: "client"})
+		ctx.SetOperationCost(&resolve.OperationCost{Estimated: 6})
+
+		ctx.SetRateLimiter(New(store, Options{ByCost: true}))
+		deny, err := ctx.RateLimitOperation()
+		require.NoError(t, err)
+		assert.Nil(t, deny)
+
+		ctx.SetRateLimiter(New(store, Options{ByCost: true}))
+		deny, err = ctx.RateLimitOperation()
+		require.NoError(t, err)
+		assert.NotNil(t, deny)
+	})
+
+	t.Run("operations are not limited by cost without the option", func(t *testing.T) {
+		ctx := newTestContext(resolve.RateLimitOptions{Rate: 1, Period: time.Minute})
+
+		limiter := New(nil, Options{})
+		deny, err := limiter.RateLimitOperation(ctx, &resolve.OperationCost{Estimated: 100})
+		require.NoError(t, err)
+		assert.Nil(t, deny)
+		assert.Equal(t, Stats{}, limiter.Stats())
+	})
+}
+
+func TestLimiter_RenderResponseExtension(t *testing.T) {
+	store, _ := newTestMemoryStore()
+	ctx := newTestContext(resolve.RateLimitOptions{Rate: 10, Period: time.Second})
+
+	limiter := New(store, Options{})
+	_, err := limiter.RateLimitPreFetch(ctx, &resolve.FetchInfo{DataSourceName: "users"}, nil)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	require.NoError(t, limiter.RenderResponseExtension(ctx, out))
+	assert.Equal(t, `{"requestRate":1,"remaining":9,"retryAfterMs":0,"resetAfterMs":100}`, out.String())
+}
@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Limit is the rate at which a bucket refills: Rate tokens per Period, up to
+// Burst tokens. A Burst of zero is the Rate.
+type Limit struct {
+	Rate   int
+	Burst  int
+	Period time.Duration
+}
+
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Rate
+}
+
+// Result is the outcome of taking tokens from a bucket.
+type Result struct {
+	// Allowed is true when the tokens were taken.
+	Allowed bool
+	// Remaining is the number of tokens left in the bucket.
+	Remaining int
+	// RetryAfter is how long until the tokens can be taken when they were not,
+	// -1 when they never can because they are more than the Burst.
+	RetryAfter time.Duration
+	// ResetAfter is how long until the bucket is full again.
+	ResetAfter time.Duration
+}
+
+// Store keeps the buckets of a Limiter. MemoryStore keeps them in the memory of
+// the process, an implementation backed by e.g. Redis shares them between the
+// nodes of a deployment.
+type Store interface {
+	// Take takes n tokens from the bucket of key, which refills at limit. The
+	// tokens are only taken when all of them are available.
+	Take(ctx context.Context, key string, n int, limit Limit) (Result, error)
+}
+
+const (
+	memoryStoreShards = 32
+	// minSweepSize is the size a shard of a MemoryStore grows to before full
+	// buckets are swept from it.
+	minSweepSize = 1024
+)
+
+// MemoryStore is a Store that keeps the buckets in memory, which limits the
+// rate per process. It implements the generic cell rate algorithm (GCRA): a
+// bucket is the time at which it is full again, so it takes no background work
+// to refill. Full buckets are swept as the store grows. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	shards [memoryStoreShards]memoryStoreShard
+	now    func() time.Time
+}
+
+type memoryStoreShard struct {
+	mu sync.Mutex
+	// tats holds the theoretical arrival time of every bucket, the time at
+	// which it is full again.
+	tats    map[string]time.Time
+	sweepAt int
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{now: time.Now}
+	for i := range s.shards {
+		s.shards[i].tats = make(map[string]time.Time)
+		s.shards[i].sweepAt = minSweepSize
+	}
+	return s
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, n int, limit Limit) (Result, error) {
+	if limit.Rate <= 0 || limit.Period <= 0 {
+		return Result{Allowed: true}, nil
+	}
+
+	interval := limit.Period / time.Duration(limit.Rate)
+	tolerance := interval * time.Duration(limit.burst())
+	increment := interval * time.Duration(n)
+
+	shard := &s.shards[xxhash.Sum64String(key)%memoryStoreShards]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	now := s.now()
+	tat := shard.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	newTat := tat.Add(increment)
+	allowAt := newTat.Add(-tolerance)
+	if allowAt.After(now) {
+		result := Result{
+			Remaining:  int(now.Sub(tat.Add(-tolerance)) / interval),
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}
+		if increment > tolerance {
+			result.RetryAfter = -1
+		}
+		return result, nil
+	}
+
+	shard.tats[key] = newTat
+	if len(shard.tats) >= shard.sweepAt {
+		shard.sweep(now)
+	}
+
+	return Result{
+		Allowed:    true,
+		Remaining:  int(now.Sub(allowAt) / interval),
+		ResetAfter: newTat.Sub(now),
+	}, nil
+}
+
+// sweep deletes the buckets that are full, which are the same as no bucket.
+func (s *memoryStoreShard) sweep(now time.Time) {
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+	s.sweepAt = max(minSweepSize, 2*len(s.tats))
+}
+
+var _ Store = (*MemoryStore)(nil)
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMemoryStore returns a MemoryStore whose clock is moved by advance.
+func newTestMemoryStore() (store *MemoryStore, advance func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	store = NewMemoryStore()
+	store.now = func() time.Time { return now }
+	return store, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	ctx := context.Background()
+	limit := Limit{Rate: 10, Burst: 3, Period: time.Second}
+
+	t.Run("allows up to the burst", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		for remaining := 2; remaining >= 0; remaining-- {
+			result, err := store.Take(ctx, "key", 1, limit)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+			assert.Equal(t, remaining, result.Remaining)
+		}
+
+		result, err := store.Take(ctx, "key", 1, limit)
+		require.NoError(t, err)
+		assert.Equal(t, Result{Remaining: 0, RetryAfter: 100 * time.Millisecond, ResetAfter: 300 * time.Millisecond}, result)
+	})
+
+	t.Run("refills at the rate", func(t *testing.T) {
+		store, advance := newTestMemoryStore()
+
+		result, err := store.Take(ctx, "key", 3, limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+
+		advance(200 * time.Millisecond)
+		result, err = store.Take(ctx, "key", 3, limit)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, 2, result.Remaining)
+		assert.Equal(t, 100*time.Millisecond, result.RetryAfter)
+
+		result, err = store.Take(ctx, "key", 2, limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 0, result.Remaining)
+	})
+
+	t.Run("never allows more than the burst", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		result, err := store.Take(ctx, "key", 4, limit)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Equal(t, time.Duration(-1), result.RetryAfter)
+	})
+
+	t.Run("the burst defaults to the rate", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		result, err := store.Take(ctx, "key", 10, Limit{Rate: 10, Period: time.Second})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, time.Second, result.ResetAfter)
+	})
+
+	t.Run("keys have buckets of their own", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		result, err := store.Take(ctx, "a", 3, limit)
+		require.NoError(t, err)
+		require.True(t, result.Allowed)
+
+		result, err = store.Take(ctx, "b", 3, limit)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("no limit without a rate", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		result, err := store.Take(ctx, "key", 100, Limit{})
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		store, advance := newTestMemoryStore()
+
+		for i := range memoryStoreShards * minSweepSize {
+			_, err := store.Take(ctx, fmt.Sprintf("key-%d", i), 1, limit)
+			require.NoError(t, err)
+		}
+		advance(time.Second)
+		for i := range memoryStoreShards * minSweepSize {
+			_, err := store.Take(ctx, fmt.Sprintf("other-%d", i), 1, limit)
+			require.NoError(t, err)
+		}
+
+		size := 0
+		for i := range store.shards {
+			size += len(store.shards[i].tats)
+		}
+		assert.Less(t, size, 2*memoryStoreShards*minSweepSize)
+	})
+
+	t.Run("concurrent takes never exceed the burst", func(t *testing.T) {
+		store, _ := newTestMemoryStore()
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for range 100 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := store.Take(ctx, "key", 1, limit)
+				assert.NoError(t, err)
+				if result.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 3, allowed)
+	})
+}
@@ -0,0 +1,233 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+
+// CircuitBreakerState is the state of the circuit breaker of one data source.
+type CircuitBreakerState int
+
+const (
+	// CircuitBreakerClosed lets every fetch through.
+	CircuitBreakerClosed CircuitBreakerState = iota
+	// CircuitBreakerOpen fails every fetch without sending it.
+	CircuitBreakerOpen
+	// CircuitBreakerHalfOpen lets a few probe fetches through, to find out
+	// whether the data source has recovered.
+	CircuitBreakerHalfOpen
+)
+
+func (s CircuitBreakerState) String() string {
+	switch s {
+	case CircuitBreakerClosed:
+		return "closed"
+	case CircuitBreakerOpen:
+		return "open"
+	case CircuitBreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// CircuitBreakerOptions configures a circuit breaker per data source, keyed by
+// DataSourceInfo.ID. A breaker opens once either threshold is reached. While
+// open, the fetches of its data source fail right away with a SubgraphError
+// instead of waiting on a data source that is down, and once OpenDuration has
+// passed it lets HalfOpenProbes fetches through to decide whether to close.
+type CircuitBreakerOptions struct {
+	// ConsecutiveFailures opens the breaker after this many failed fetches in a
+	// row. Zero disables the threshold.
+	ConsecutiveFailures int
+	// ErrorRate opens the breaker once this fraction of the fetches within
+	// Window failed, between 0 and 1. Zero disables the threshold.
+	ErrorRate float64
+	// MinRequests is how many fetches Window must have seen before ErrorRate is
+	// applied, so that one failure out of one fetch does not open the breaker.
+	MinRequests int
+	// Window is the span ErrorRate is measured over. Zero means 10 seconds.
+	Window time.Duration
+	// OpenDuration is how long the breaker stays open before it probes. Zero
+	// means 5 seconds.
+	OpenDuration time.Duration
+	// HalfOpenProbes is how many fetches a half-open breaker lets through, all
+	// of which have to succeed for it to close. Zero means 1.
+	HalfOpenProbes int
+	// IsFailure decides whether a fetch failed. Nil counts transport errors,
+	// 5xx statuses and gRPC UNAVAILABLE responses. A fetch canceled by its own
+	// request is never counted, either way.
+	IsFailure RetryCondition
+	// OnStateChange is called on every transition of a breaker, outside of its
+	// lock.
+	OnStateChange func(ds DataSourceInfo, from, to CircuitBreakerState)
+}
+
+func defaultCircuitBreakerFailure(statusCode int, err error, response []byte) bool {
+	if err != nil {
+		return true
+	}
+	return statusCode >= http.StatusInternalServerError || grpcUnavailable(response)
+}
+
+// circuitBreakers holds the breaker of every data source seen so far.
+type circuitBreakers struct {
+	options  CircuitBreakerOptions
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	now      func() time.Time
+}
+
+func newCircuitBreakers(options *CircuitBreakerOptions) *circuitBreakers {
+	if options == nil {
+		return nil
+	}
+	b := &circuitBreakers{
+		options:  *options,
+		breakers: make(map[string]*circuitBreaker),
+		now:      time.Now,
+	}
+	if b.options.Window <= 0 {
+		b.options.Window = 10 * time.Second
+	}
+	if b.options.OpenDuration <= 0 {
+		b.options.OpenDuration = 5 * time.Second
+	}
+	if b.options.HalfOpenProbes <= 0 {
+		b.options.HalfOpenProbes = 1
+	}
+	if b.options.IsFailure == nil {
+		b.options.IsFailure = defaultCircuitBreakerFailure
+	}
+	return b
+}
+
+func (b *circuitBreakers) get(ds DataSourceInfo) *circuitBreaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker, ok := b.breakers[ds.ID]
+	if !ok {
+		breaker = &circuitBreaker{breakers: b, ds: ds, windowStart: b.now()}
+		b.breakers[ds.ID] = breaker
+	}
+	return breaker
+}
+
+type circuitBreaker struct {
+	breakers *circuitBreakers
+	ds       DataSourceInfo
+
+	mu    sync.Mutex
+	state CircuitBreakerState
+
+	consecutiveFailures int
+	windowStart         time.Time
+	requests            int
+	failures            int
+
+	openedAt        time.Time
+	probesInFlight  int
+	probesSucceeded int
+}
+
+// allow reports whether a fetch may be sent. A fetch that is allowed must be
+// followed by exactly one call of done.
+func (c *circuitBreaker) allow() bool {
+	c.mu.Lock()
+	from := c.state
+	allowed := true
+	switch c.state {
+	case CircuitBreakerOpen:
+		if c.breakers.now().Sub(c.openedAt) < c.breakers.options.OpenDuration {
+			allowed = false
+			break
+		}
+		c.state = CircuitBreakerHalfOpen
+		c.probesInFlight, c.probesSucceeded = 0, 0
+		fallthrough
+	case CircuitBreakerHalfOpen:
+		if c.probesInFlight+c.probesSucceeded >= c.breakers.options.HalfOpenProbes {
+			allowed = false
+			break
+		}
+		c.probesInFlight++
+	}
+	to := c.state
+	c.mu.Unlock()
+
+	c.report(from, to)
+	return allowed
+}
+
+// done records the outcome of a fetch that allow let through.
+func (c *circuitBreaker) done(res *result) {
+	options := &c.breakers.options
+	canceled := errors.Is(res.err, context.Canceled)
+	failed := !canceled && options.IsFailure(res.statusCode, res.err, res.out)
+
+	c.mu.Lock()
+	from := c.state
+	switch c.state {
+	case CircuitBreakerHalfOpen:
+		c.probesInFlight--
+		switch {
+		case canceled:
+		case failed:
+			c.open()
+		default:
+			c.probesSucceeded++
+			if c.probesSucceeded >= options.HalfOpenProbes {
+				c.close()
+			}
+		}
+	case CircuitBreakerClosed:
+		if canceled {
+			break
+		}
+		now := c.breakers.now()
+		if now.Sub(c.windowStart) >= options.Window {
+			c.windowStart, c.requests, c.failures = now, 0, 0
+		}
+		c.requests++
+		if !failed {
+			c.consecutiveFailures = 0
+			break
+		}
+		c.failures++
+		c.consecutiveFailures++
+		if options.ConsecutiveFailures > 0 && c.consecutiveFailures >= options.ConsecutiveFailures {
+			c.open()
+			break
+		}
+		if options.ErrorRate > 0 && c.requests >= options.MinRequests && float64(c.failures)/float64(c.requests) >= options.ErrorRate {
+			c.open()
+		}
+	}
+	to := c.state
+	c.mu.Unlock()
+
+	c.report(from, to)
+}
+
+func (c *circuitBreaker) open() {
+	c.state = CircuitBreakerOpen
+	c.openedAt = c.breakers.now()
+}
+
+func (c *circuitBreaker) close() {
+	c.state = CircuitBreakerClosed
+	c.consecutiveFailures = 0
+	c.windowStart, c.requests, c.failures = c.breakers.now(), 0, 0
+}
+
+func (c *circuitBreaker) report(from, to CircuitBreakerState) {
+	if from != to && c.breakers.options.OnStateChange != nil {
+		c.breakers.options.OnStateChange(c.ds, from, to)
+	}
+}
@@ -0,0 +1,161 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+)
+
+type stateChange struct {
+	ds       string
+	from, to CircuitBreakerState
+}
+
+type stateChangeRecorder struct {
+	mu      sync.Mutex
+	changes []stateChange
+}
+
+func (r *stateChangeRecorder) record(ds DataSourceInfo, from, to CircuitBreakerState) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, stateChange{ds: ds.ID, from: from, to: to})
+}
+
+func newTestCircuitBreaker(options CircuitBreakerOptions) (*circuitBreaker, *time.Time) {
+	now := time.Unix(1_700_000_000, 0)
+	breakers := newCircuitBreakers(&options)
+	breakers.now = func() time.Time { return now }
+	return breakers.get(DataSourceInfo{ID: "users", Name: "users"}), &now
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	failure := &result{err: errors.New("connection refused")}
+	success := &result{statusCode: http.StatusOK}
+
+	fetch := func(t *testing.T, breaker *circuitBreaker, res *result) {
+		t.Helper()
+		require.True(t, breaker.allow())
+		breaker.done(res)
+	}
+
+	t.Run("consecutive failures open the breaker", func(t *testing.T) {
+		breaker, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 3})
+
+		fetch(t, breaker, failure)
+		fetch(t, breaker, failure)
+		fetch(t, breaker, success)
+		fetch(t, breaker, failure)
+		fetch(t, breaker, failure)
+		assert.Equal(t, CircuitBreakerClosed, breaker.state, "a success resets the count")
+
+		fetch(t, breaker, failure)
+		assert.Equal(t, CircuitBreakerOpen, breaker.state)
+		assert.False(t, breaker.allow(), "an open breaker fails fast")
+	})
+
+	t.Run("the error rate opens the breaker once the window has seen enough fetches", func(t *testing.T) {
+		breaker, now := newTestCircuitBreaker(CircuitBreakerOptions{ErrorRate: 0.5, MinRequests: 4, Window: time.Minute})
+
+		fetch(t, breaker, failure)
+		fetch(t, breaker, failure)
+		fetch(t, breaker, success)
+		assert.Equal(t, CircuitBreakerClosed, breaker.state, "3 fetches are fewer than MinRequests")
+
+		*now = now.Add(time.Minute)
+		fetch(t, breaker, failure)
+		fetch(t, breaker, success)
+		fetch(t, breaker, success)
+		fetch(t, breaker, success)
+		assert.Equal(t, CircuitBreakerClosed, breaker.state, "the failures of the last window are forgotten")
+
+		fetch(t, breaker, failure)
+		assert.Equal(t, CircuitBreakerClosed, breaker.state, "2 of 5 fetches failed")
+		fetch(t, breaker, failure)
+		assert.Equal(t, CircuitBreakerOpen, breaker.state, "3 of 6 fetches failed")
+	})
+
+	t.Run("a successful probe closes the breaker", func(t *testing.T) {
+		recorder := &stateChangeRecorder{}
+		breaker, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, OpenDuration: time.Second, OnStateChange: recorder.record})
+
+		fetch(t, breaker, failure)
+		*now = now.Add(999 * time.Millisecond)
+		assert.False(t, breaker.allow())
+
+		*now = now.Add(time.Millisecond)
+		require.True(t, breaker.allow())
+		assert.False(t, breaker.allow(), "only one probe is in flight at a time")
+		breaker.done(success)
+
+		assert.Equal(t, CircuitBreakerClosed, breaker.state)
+		assert.Equal(t, []stateChange{
+			{ds: "users", from: CircuitBreakerClosed, to: CircuitBreakerOpen},
+			{ds: "users", from: CircuitBreakerOpen, to: CircuitBreakerHalfOpen},
+			{ds: "users", from: CircuitBreakerHalfOpen, to: CircuitBreakerClosed},
+		}, recorder.changes)
+	})
+
+	t.Run("a failed probe opens the breaker again", func(t *testing.T) {
+		breaker, now := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1, OpenDuration: time.Second, HalfOpenProbes: 2})
+
+		fetch(t, breaker, failure)
+		*now = now.Add(time.Second)
+		fetch(t, breaker, success)
+		assert.Equal(t, CircuitBreakerHalfOpen, breaker.state, "both probes have to succeed")
+
+		fetch(t, breaker, failure)
+		assert.Equal(t, CircuitBreakerOpen, breaker.state)
+		assert.False(t, breaker.allow(), "the open duration starts over")
+	})
+
+	t.Run("a canceled fetch is not counted", func(t *testing.T) {
+		breaker, _ := newTestCircuitBreaker(CircuitBreakerOptions{ConsecutiveFailures: 1})
+
+		fetch(t, breaker, &result{err: fmt.Errorf("aborted: %w", context.Canceled)})
+		assert.Equal(t, CircuitBreakerClosed, breaker.state)
+	})
+}
+
+func TestLoader_CircuitBreaker(t *testing.T) {
+	unavailable := scriptedAttempt{status: http.StatusServiceUnavailable, response: `{"errors":[{"message":"unavailable"}]}`}
+
+	t.Run("an open breaker fails the fetch without sending it", func(t *testing.T) {
+		recorder := &stateChangeRecorder{}
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable}}
+		r := New(t.Context(), ResolverOptions{MaxConcurrency: 1, CircuitBreaker: &CircuitBreakerOptions{ConsecutiveFailures: 2, OpenDuration: time.Hour, OnStateChange: recorder.record}})
+
+		for range 2 {
+			out, _ := resolveSingleFetchWith(t, r, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+			assert.NotContains(t, out, "circuit breaker")
+		}
+		out, _ := resolveSingleFetchWith(t, r, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 2, ds.calls, "the third fetch is never sent")
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'users' at Path 'query', Reason: circuit breaker is open.`)
+		assert.Equal(t, []stateChange{{ds: "0", from: CircuitBreakerClosed, to: CircuitBreakerOpen}}, recorder.changes)
+	})
+
+	t.Run("a retry is not sent once the breaker opens", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable}}
+		options := ResolverOptions{
+			RetryPolicy:    &RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond},
+			CircuitBreaker: &CircuitBreakerOptions{ConsecutiveFailures: 2, OpenDuration: time.Hour},
+		}
+
+		out, _ := resolveSingleFetch(t, options, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 2, ds.calls)
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'users'`)
+		assert.NotContains(t, out, "circuit breaker", "the last attempt that was sent is the answer")
+	})
+}
@@ -30,6 +30,10 @@ var (
 	literalQueryPlan          = []byte("queryPlan")
 	literalValueCompletion    = []byte("valueCompletion")
 	literalRateLimit          = []byte("rateLimit")
+	literalCost               = []byte("cost")
+	literalEstimated          = []byte("estimated")
+	literalActual             = []byte("actual")
+	literalLimit              = []byte("limit")
 	literalInlineArguments    = []byte("inlineArguments")
 	literalCount              = []byte("count")
 	literalArguments          = []byte("arguments")
@@ -41,6 +45,7 @@ var (
 	literalCompleted          = []byte("completed")
 	literalId                 = []byte("id")
 	literalSubPath            = []byte("subPath")
+	literalItems              = []byte("items")
 
 	emptyArray  = []byte("[]")
 	emptyObject = []byte("{}")
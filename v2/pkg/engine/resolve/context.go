@@ -52,8 +52,14 @@ type Context struct {
 	rateLimiter             RateLimiter
 	fieldRenderer           FieldValueRenderer
 
+	operationCost *OperationCost
+
 	responseCache *responseCache
 
+	// operationDeadline is when the budget of ResolverOptions.OperationTimeout runs out. It is
+	// set by the first Loader that runs for the operation, and zero without a budget.
+	operationDeadline time.Time
+
 	subgraphErrors map[string]error
 
 	SubgraphHeadersBuilder SubgraphHeadersBuilder
@@ -129,6 +135,8 @@ type ExecutionOptions struct {
 	SkipLoader bool
 	// IncludeQueryPlanInResponse generates a QueryPlan as part of the response in Resolvable
 	IncludeQueryPlanInResponse bool
+	// IncludeCostInResponse renders the OperationCost set on the Context in the cost extension of the response
+	IncludeCostInResponse bool
 	// SendHeartbeat sends regular HeartBeats for Subscriptions
 	SendHeartbeat bool
 	// DisableSubgraphRequestDeduplication disables deduplication of requests to the same subgraph with the same input within a single operation execution.
@@ -256,17 +264,136 @@ func (c *Context) SetRateLimiter(limiter RateLimiter) {
 	c.rateLimiter = limiter
 }
 
+// CostRateLimiter is implemented by a RateLimiter that consumes its budget by
+// the cost of operations instead of by the number of requests.
+type CostRateLimiter interface {
+	RateLimiter
+	// RateLimitOperation is called once per operation with its cost, before any
+	// of its fetches. A deny rejects the whole operation.
+	RateLimitOperation(ctx *Context, cost *OperationCost) (result *RateLimitDeny, err error)
+}
+
+// RateLimitOperation has a CostRateLimiter consume the cost of the operation.
+// It allows the operation when rate limiting is off, the rate limiter does not
+// limit by cost or the operation has no cost.
+func (c *Context) RateLimitOperation() (*RateLimitDeny, error) {
+	if !c.RateLimitOptions.Enable || c.operationCost == nil {
+		return nil, nil
+	}
+	limiter, ok := c.rateLimiter.(CostRateLimiter)
+	if !ok {
+		return nil, nil
+	}
+	return limiter.RateLimitOperation(c, c.operationCost)
+}
+
+// OperationCost is the cost of an operation, computed from the @cost and
+// @listSize directives of the schema.
+type OperationCost struct {
+	// Estimated is the static cost of the operation, known before any fetch.
+	Estimated int
+	// Limit is the maximum estimated cost of an operation, zero without a limit.
+	Limit int
+	// Actual computes the cost of the operation from the response, given the
+	// TypeNameStats collected while rendering it with
+	// ResolvableOptions.EnableCostControl. It is nil when the actual cost is not
+	// computed.
+	Actual func(typeNameStats map[string]TypeNameStats) int
+}
+
+// SetOperationCost sets the cost of the operation. It is available to the
+// RateLimiter, and rendered in the cost extension of the response when
+// ExecutionOptions.IncludeCostInResponse is set.
+func (c *Context) SetOperationCost(cost *OperationCost) {
+	c.operationCost = cost
+}
+
+func (c *Context) OperationCost() *OperationCost {
+	return c.operationCost
+}
+
 type responseCache struct {
 	store      caching.Cache
 	defaultTTL time.Duration
 	onError    func(error)
+
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	authKey              string
+	rootFetches          bool
+	revalidationTimeout  time.Duration
+}
+
+func (c *responseCache) reportError(err error) {
+	if c.onError != nil {
+		c.onError(err)
+	}
 }
 
-func (c *Context) SetResponseCache(cache caching.Cache, defaultTTL time.Duration, onError func(error)) {
+// ResponseCacheOption configures the response cache set by SetResponseCache.
+type ResponseCacheOption func(*responseCache)
+
+// WithStaleWhileRevalidate lets an entity be served for up to window past its
+// TTL while it is refetched in the background. A subgraph that sends its own
+// stale-while-revalidate directive overrides the window for its responses.
+func WithStaleWhileRevalidate(window time.Duration) ResponseCacheOption {
+	return func(c *responseCache) {
+		c.staleWhileRevalidate = window
+	}
+}
+
+// WithStaleIfError lets an entity be served for up to window past its TTL in
+// place of a failed subgraph fetch. A subgraph that sends its own
+// stale-if-error directive overrides the window for its responses.
+func WithStaleIfError(window time.Duration) ResponseCacheOption {
+	return func(c *responseCache) {
+		c.staleIfError = window
+	}
+}
+
+// WithAuthKey names the viewer of the request, typically a hash of its
+// credentials. Entities fetched under a PRIVATE @cacheControl hint are cached
+// per auth key, and not at all for a request without one.
+func WithAuthKey(key string) ResponseCacheOption {
+	return func(c *responseCache) {
+		c.authKey = key
+	}
+}
+
+// WithRootFetches caches the whole response of the root fetches of a query
+// as well as entities, keyed by the rendered subgraph input and the headers
+// from the SubgraphHeadersBuilder. It is opt-in because a root field such as
+// me answers differently per viewer, which a subgraph does not always say
+// with a private Cache-Control directive.
+func WithRootFetches() ResponseCacheOption {
+	return func(c *responseCache) {
+		c.rootFetches = true
+	}
+}
+
+// DefaultResponseCacheRevalidationTimeout bounds the background refetch of
+// entities served stale when neither WithRevalidationTimeout nor a fetch
+// timeout of the data source does.
+const DefaultResponseCacheRevalidationTimeout = 30 * time.Second
+
+// WithRevalidationTimeout bounds the background refetch of entities served
+// stale. The refetch outlives the request, so it is bounded by the fetch
+// timeout of the data source otherwise, or by
+// DefaultResponseCacheRevalidationTimeout.
+func WithRevalidationTimeout(timeout time.Duration) ResponseCacheOption {
+	return func(c *responseCache) {
+		c.revalidationTimeout = timeout
+	}
+}
+
+func (c *Context) SetResponseCache(cache caching.Cache, defaultTTL time.Duration, onError func(error), options ...ResponseCacheOption) {
 	if cache == nil {
 		return
 	}
 	c.responseCache = &responseCache{store: cache, defaultTTL: defaultTTL, onError: onError}
+	for _, option := range options {
+		option(c.responseCache)
+	}
 }
 
 func (c *Context) SubgraphErrors() error {
@@ -364,7 +491,9 @@ func (c *Context) Free() {
 	c.GetDeduplicationData = nil
 	c.SetDeduplicationData = nil
 	c.TypeNameStats = nil
+	c.operationCost = nil
 	c.responseCache = nil
+	c.operationDeadline = time.Time{}
 }
 
 func (c *Context) VariablesView() VariablesView {
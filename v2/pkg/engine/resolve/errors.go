@@ -2,9 +2,11 @@ package resolve
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"slices"
+	"time"
 
 	"github.com/wundergraph/astjson"
 )
@@ -129,6 +131,46 @@ func (e *SubgraphError) Error() string {
 	return bf.String()
 }
 
+// FetchTimeoutError is the error of a fetch that ran out of time, either its
+// own FetchTimeout or what was left of the operation's OperationTimeout.
+type FetchTimeoutError struct {
+	SubgraphName string
+	Path         string
+	// Timeout is the time the fetch was given. It is zero for a fetch that was
+	// not sent at all, because the operation's budget had run out before it.
+	Timeout time.Duration
+	// Elapsed is the time the fetch took until it was given up on, and zero for
+	// a fetch that was not sent.
+	Elapsed time.Duration
+	// OperationDeadline is true when the operation's budget ran out, rather
+	// than the timeout of the fetch itself.
+	OperationDeadline bool
+}
+
+func (e *FetchTimeoutError) Error() string {
+	if e.OperationDeadline && e.Timeout == 0 {
+		return fmt.Sprintf("Fetch from Subgraph '%s' at Path '%s' was not sent, the operation deadline was exceeded.", e.SubgraphName, e.Path)
+	}
+	if e.OperationDeadline {
+		return fmt.Sprintf("Fetch from Subgraph '%s' at Path '%s' exceeded the operation deadline after %s.", e.SubgraphName, e.Path, e.Elapsed)
+	}
+	return fmt.Sprintf("Fetch from Subgraph '%s' at Path '%s' timed out after %s.", e.SubgraphName, e.Path, e.Elapsed)
+}
+
+// Unwrap makes a FetchTimeoutError match context.DeadlineExceeded.
+func (e *FetchTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// reason is the reason rendered into the error the client gets. It states the
+// timeout rather than Elapsed, so that the same timeout renders the same error.
+func (e *FetchTimeoutError) reason() string {
+	if e.OperationDeadline {
+		return operationDeadlineExceeded
+	}
+	return fmt.Sprintf(fetchTimedOut, e.Timeout)
+}
+
 func NewRateLimitError(subgraphName, path, reason string) *RateLimitError {
 	return &RateLimitError{
 		SubgraphName: subgraphName,
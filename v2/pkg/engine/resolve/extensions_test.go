@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestExtensions(t *testing.T) {
@@ -178,4 +179,39 @@ func TestExtensions(t *testing.T) {
 			`{"errors":[{"message":"Rate limit exceeded for Subgraph 'users' at Path 'query', Reason: rate limit exceeded."},{"message":"Failed to fetch from Subgraph 'reviews' at Path 'query.me'.","extensions":{"errors":[{"message":"Failed to render Fetch Input","path":["me"]}]}},{"message":"Failed to fetch from Subgraph 'products' at Path 'query.me.reviews.@.product'.","extensions":{"errors":[{"message":"Failed to render Fetch Input","path":["me","reviews","@","product"]}]}}],"data":{"me":null},"extensions":{"rateLimit":{"Policy":"policy","Allowed":0,"Used":1},"inlineArguments":{"count":2,"arguments":["user.filter","@include.if"]}}}`,
 			func(t *testing.T) {}
 	}))
+	t.Run("cost", testFnWithPostEvaluationAndOptions(ResolverOptions{
+		MaxConcurrency:    1024,
+		ResolvableOptions: ResolvableOptions{EnableCostControl: true},
+	}, func(t *testing.T, ctrl *gomock.Controller) (node *GraphQLResponse, ctx *Context, expectedOutput string, postEvaluation func(t *testing.T)) {
+
+		res := generateTestFederationGraphQLResponse(t, ctrl)
+
+		var stats map[string]TypeNameStats
+		resolveCtx := NewContext(context.Background())
+		resolveCtx.ExecutionOptions.IncludeCostInResponse = true
+		resolveCtx.SetOperationCost(&OperationCost{
+			Estimated: 42,
+			Limit:     100,
+			Actual: func(typeNameStats map[string]TypeNameStats) int {
+				stats = typeNameStats
+				return 7
+			},
+		})
+		return res, resolveCtx,
+			`{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A highly effective form of birth control.","product":{"upc":"top-1","name":"Trilby"}},{"body":"Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product":{"upc":"top-2","name":"Fedora"}}]}},"extensions":{"cost":{"estimated":42,"actual":7,"limit":100}}}`,
+			func(t *testing.T) {
+				assert.NotEmpty(t, stats)
+			}
+	}))
+	t.Run("cost without actual cost and limit", testFnWithPostEvaluation(func(t *testing.T, ctrl *gomock.Controller) (node *GraphQLResponse, ctx *Context, expectedOutput string, postEvaluation func(t *testing.T)) {
+
+		res := generateTestFederationGraphQLResponse(t, ctrl)
+
+		resolveCtx := NewContext(context.Background())
+		resolveCtx.ExecutionOptions.IncludeCostInResponse = true
+		resolveCtx.SetOperationCost(&OperationCost{Estimated: 42})
+		return res, resolveCtx,
+			`{"data":{"me":{"id":"1234","username":"Me","reviews":[{"body":"A highly effective form of birth control.","product":{"upc":"top-1","name":"Trilby"}},{"body":"Fedoras are one of the most fashionable hats around and can look great with a variety of outfits.","product":{"upc":"top-2","name":"Fedora"}}]}},"extensions":{"cost":{"estimated":42}}}`,
+			func(t *testing.T) {}
+	}))
 }
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
 )
@@ -18,6 +19,21 @@ const (
 	FetchKindMultiEntity
 )
 
+func (k FetchKind) String() string {
+	switch k {
+	case FetchKindSingle:
+		return "single"
+	case FetchKindEntity:
+		return "entity"
+	case FetchKindEntityBatch:
+		return "entity_batch"
+	case FetchKindMultiEntity:
+		return "multi_entity"
+	default:
+		return "unknown"
+	}
+}
+
 type Fetch interface {
 	FetchKind() FetchKind
 	Dependencies() *FetchDependencies
@@ -174,6 +190,7 @@ type BatchEntityFetch struct {
 	DataSourceIdentifier []byte
 	Trace                *DataSourceLoadTrace
 	Info                 *FetchInfo
+	CacheControl         *CacheControlPolicy
 }
 
 func (b *BatchEntityFetch) Dependencies() *FetchDependencies {
@@ -214,6 +231,7 @@ type EntityFetch struct {
 	DataSourceIdentifier []byte
 	Trace                *DataSourceLoadTrace
 	Info                 *FetchInfo
+	CacheControl         *CacheControlPolicy
 }
 
 func (e *EntityFetch) Dependencies() *FetchDependencies {
@@ -285,6 +303,11 @@ type FetchConfiguration struct {
 	// MultiFetch stage; it is cleared during postprocessing and never reaches
 	// the executable plan. Nil unless plan.Configuration.EnableMultiFetch is set.
 	SubgraphOperation *SubgraphOperation
+
+	// CacheControl is the response cache policy folded from the @cacheControl
+	// hints of every field the fetch selects. It is nil when none of them
+	// carries a hint.
+	CacheControl *CacheControlPolicy
 }
 
 func (fc *FetchConfiguration) Equals(other *FetchConfiguration) bool {
@@ -431,6 +454,37 @@ type FetchInfo struct {
 	PropagatedFetchReasons []FetchReason
 }
 
+// CacheControlPolicy is a @cacheControl(maxAge:, scope:) hint. On a field it
+// is the hint as given, and on a fetch it is the fold of the hints of all its
+// fields: the smallest max age, and private as soon as one of them is. A root
+// field or a field returning a composite type that has no hint counts as a max
+// age of zero in the fold, a leaf field without one inherits the hint of its
+// parent.
+type CacheControlPolicy struct {
+	// MaxAge is how long the response cache keeps what the fetch returns, which
+	// Cache-Control headers of the subgraph can only shorten. Nil leaves it to
+	// the headers, the way a hint that only names a scope does.
+	MaxAge *time.Duration
+	// Private keys every entry by the auth key of the request, so that it is
+	// only ever served to requests carrying the same one.
+	Private bool
+}
+
+// Merge folds other into p and returns the result. Either may be nil.
+func (p *CacheControlPolicy) Merge(other *CacheControlPolicy) *CacheControlPolicy {
+	if p == nil {
+		return other
+	}
+	if other == nil {
+		return p
+	}
+	merged := &CacheControlPolicy{MaxAge: p.MaxAge, Private: p.Private || other.Private}
+	if merged.MaxAge == nil || (other.MaxAge != nil && *other.MaxAge < *merged.MaxAge) {
+		merged.MaxAge = other.MaxAge
+	}
+	return merged
+}
+
 type GraphCoordinate struct {
 	TypeName             string `json:"typeName"`
 	FieldName            string `json:"fieldName"`
@@ -451,6 +505,18 @@ type DataSourceLoadTrace struct {
 	LoadSkipped                bool            `json:"load_skipped"`
 	LoadStats                  *LoadStats      `json:"load_stats,omitempty"`
 	Path                       string          `json:"-"`
+	// Retries lists the attempts before the one the rest of the trace describes,
+	// see RetryPolicy.
+	Retries []DataSourceLoadRetry `json:"retries,omitempty"`
+}
+
+// DataSourceLoadRetry is an attempt of a fetch that failed and was retried.
+type DataSourceLoadRetry struct {
+	Attempt       int    `json:"attempt"`
+	StatusCode    int    `json:"status_code,omitempty"`
+	Error         string `json:"error,omitempty"`
+	BackoffNano   int64  `json:"backoff_nanoseconds"`
+	BackoffPretty string `json:"backoff_pretty"`
 }
 
 type LoadStats struct {
@@ -2,6 +2,7 @@ package resolve
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -132,3 +133,17 @@ func TestSubgraphOperation_PrintedQuery(t *testing.T) {
 		require.Nil(t, printed)
 	})
 }
+
+func TestCacheControlPolicy_Merge(t *testing.T) {
+	short, long := time.Second, time.Minute
+
+	require.Nil(t, (*CacheControlPolicy)(nil).Merge(nil))
+	require.Equal(t, &CacheControlPolicy{MaxAge: &long},
+		(*CacheControlPolicy)(nil).Merge(&CacheControlPolicy{MaxAge: &long}))
+	require.Equal(t, &CacheControlPolicy{MaxAge: &short, Private: true},
+		(&CacheControlPolicy{MaxAge: &long, Private: true}).Merge(&CacheControlPolicy{MaxAge: &short}),
+		"the shortest maxAge and any private scope win")
+	require.Equal(t, &CacheControlPolicy{MaxAge: &long, Private: true},
+		(&CacheControlPolicy{MaxAge: &long}).Merge(&CacheControlPolicy{Private: true}),
+		"a hint without a maxAge does not constrain it")
+}
@@ -0,0 +1,217 @@
+package resolve
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+)
+
+const (
+	defaultHedgingMinSamples = 20
+	// minHedgingDelay is the shortest delay a percentile hedges after, so a data
+	// source answering faster than the clock can tell is not hedged at once.
+	minHedgingDelay = time.Millisecond
+	// hedgingLatencySamples is how many of the latest latencies of a data
+	// source its percentile is taken over.
+	hedgingLatencySamples = 128
+)
+
+// HedgingPolicy sends a second, identical request for a query fetch that has
+// not been answered in time, and takes whichever answer comes first. The other
+// request is canceled. Mutation fetches are never hedged.
+//
+// A hedge is sent by the leader of a deduplicated fetch, so the fetches that
+// wait on the leader share its first answer, and a hedge never counts as a
+// fetch of its own. Fetches that upload files are not hedged, and neither are
+// fetches traced with load stats, which time a single request.
+type HedgingPolicy struct {
+	// Delay is how long to wait for an answer before hedging. With Percentile
+	// set, it applies until the data source has answered MinSamples fetches.
+	// Zero does not hedge until then.
+	Delay time.Duration
+	// Percentile hedges once a fetch has taken longer than this percentile of
+	// the latest latencies of its data source, between 0 and 1. 0.95 hedges the
+	// slowest 5% of fetches. Zero hedges after Delay only. A percentile below a
+	// millisecond hedges after a millisecond.
+	Percentile float64
+	// MinSamples is how many latencies a data source needs before Percentile is
+	// used. Zero means 20.
+	MinSamples int
+}
+
+// hedgingLatencies holds the latest latencies of every data source seen so far.
+type hedgingLatencies struct {
+	mu        sync.Mutex
+	latencies map[string]*latencyWindow
+}
+
+func newHedgingLatencies() *hedgingLatencies {
+	return &hedgingLatencies{latencies: make(map[string]*latencyWindow)}
+}
+
+func (h *hedgingLatencies) get(ds DataSourceInfo) *latencyWindow {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	window, ok := h.latencies[ds.ID]
+	if !ok {
+		window = &latencyWindow{}
+		h.latencies[ds.ID] = window
+	}
+	return window
+}
+
+// latencyWindow is a ring buffer of the latest latencies of a data source.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples [hedgingLatencySamples]time.Duration
+	count   int
+	next    int
+}
+
+func (w *latencyWindow) record(latency time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.samples[w.next] = latency
+	w.next = (w.next + 1) % len(w.samples)
+	w.count = min(w.count+1, len(w.samples))
+}
+
+// percentile returns the latency p of the recorded ones were at most, and false
+// while fewer than minSamples are recorded.
+func (w *latencyWindow) percentile(p float64, minSamples int) (time.Duration, bool) {
+	w.mu.Lock()
+	if w.count == 0 || w.count < minSamples {
+		w.mu.Unlock()
+		return 0, false
+	}
+	samples := slices.Clone(w.samples[:w.count])
+	w.mu.Unlock()
+
+	slices.Sort(samples)
+	index := int(min(max(p, 0), 1)*float64(len(samples)-1) + 0.5)
+	return samples[index], true
+}
+
+// hedgingPolicy returns the policy for the fetch: the one configured for its
+// data source, otherwise the resolver-wide one, and nil for anything that is
+// not hedged.
+func (l *Loader) hedgingPolicy(fetchItem *FetchItem, res *result) *HedgingPolicy {
+	if l.hedgingLatencies == nil || l.ctx.Files != nil {
+		return nil
+	}
+	if l.ctx.TracingOptions.Enable && !l.ctx.TracingOptions.ExcludeLoadStats {
+		return nil
+	}
+	if fetchItem == nil || fetchItem.Fetch == nil {
+		return nil
+	}
+	info := fetchItem.Fetch.FetchInfo()
+	if info == nil || info.OperationType != ast.OperationTypeQuery {
+		return nil
+	}
+	policy := l.hedgingPolicyDefault
+	if override, ok := l.dataSourceHedgingPolicies[res.ds.ID]; ok {
+		policy = override
+	}
+	return policy
+}
+
+// hedgeDelay returns how long to wait before hedging, and false when the fetch
+// is not hedged.
+func (p *HedgingPolicy) hedgeDelay(window *latencyWindow) (time.Duration, bool) {
+	if p.Percentile > 0 {
+		minSamples := p.MinSamples
+		if minSamples <= 0 {
+			minSamples = defaultHedgingMinSamples
+		}
+		if delay, ok := window.percentile(p.Percentile, minSamples); ok {
+			return max(delay, minHedgingDelay), true
+		}
+	}
+	return p.Delay, p.Delay > 0
+}
+
+type hedgedAnswer struct {
+	res             *result
+	responseContext *httpclient.ResponseContext
+	latency         time.Duration
+}
+
+// loadHedged is loadByContextDirect under the fetch's HedgingPolicy. The first
+// answer without an error wins. An error only wins once no other request is
+// left to answer, and an error before the hedge was sent is not hedged at all;
+// failures are what retries are for.
+//
+// Every request is a loadByContextDirect of its own, in the context of the
+// fetch, with a result and a ResponseContext of its own. The winner's are what
+// the fetch answers with, so its status code and headers are the ones the
+// LoaderHooks and the response cache see.
+func (l *Loader) loadHedged(ctx context.Context, fetchItem *FetchItem, source DataSource, headers http.Header, input []byte, res *result) error {
+	policy := l.hedgingPolicy(fetchItem, res)
+	if policy == nil {
+		return l.loadByContextDirect(ctx, source, headers, input, res)
+	}
+	window := l.hedgingLatencies.get(res.ds)
+	delay, ok := policy.hedgeDelay(window)
+	if !ok {
+		start := time.Now()
+		err := l.loadByContextDirect(ctx, source, headers, input, res)
+		if err == nil {
+			window.record(time.Since(start))
+		}
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answers := make(chan hedgedAnswer, 2)
+	send := func() {
+		go func() {
+			requestCtx, responseContext := httpclient.InjectResponseContext(ctx)
+			request := &result{ds: res.ds}
+			start := time.Now()
+			_ = l.loadByContextDirect(requestCtx, source, headers, input, request)
+			answers <- hedgedAnswer{res: request, responseContext: responseContext, latency: time.Since(start)}
+		}()
+	}
+
+	send()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	pending := 1
+	var answer hedgedAnswer
+	for {
+		select {
+		case <-timer.C:
+			send()
+			pending++
+			res.hedged = true
+			continue
+		case answer = <-answers:
+			pending--
+		}
+		if answer.res.err == nil || pending == 0 || !res.hedged {
+			break
+		}
+	}
+
+	if rc := httpclient.GetResponseContext(ctx); rc != nil {
+		*rc = *answer.responseContext
+	}
+	res.out, res.err = answer.res.out, answer.res.err
+	if res.err != nil {
+		return errors.WithStack(res.err)
+	}
+	window.record(answer.latency)
+	return nil
+}
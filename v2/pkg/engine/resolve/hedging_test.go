@@ -0,0 +1,194 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+)
+
+// hedgedDataSource answers its n-th request after delays[n], with the answer
+// of that request, or fails once the request is canceled.
+type hedgedDataSource struct {
+	delays   []time.Duration
+	answers  []scriptedAttempt
+	mu       sync.Mutex
+	calls    int
+	canceled chan int
+}
+
+func newHedgedDataSource(delays []time.Duration, answers ...scriptedAttempt) *hedgedDataSource {
+	return &hedgedDataSource{delays: delays, answers: answers, canceled: make(chan int, len(delays))}
+}
+
+func (s *hedgedDataSource) Load(ctx context.Context, _ http.Header, _ []byte) ([]byte, error) {
+	s.mu.Lock()
+	call := s.calls
+	s.calls++
+	s.mu.Unlock()
+
+	answer := s.answers[min(call, len(s.answers)-1)]
+	select {
+	case <-ctx.Done():
+		s.canceled <- call
+		return nil, ctx.Err()
+	case <-time.After(s.delays[min(call, len(s.delays)-1)]):
+	}
+	if rc := httpclient.GetResponseContext(ctx); rc != nil {
+		rc.StatusCode = answer.status
+	}
+	return []byte(answer.response), answer.err
+}
+
+func (s *hedgedDataSource) LoadWithFiles(ctx context.Context, headers http.Header, input []byte, _ []*httpclient.FileUpload) ([]byte, error) {
+	return s.Load(ctx, headers, input)
+}
+
+func (s *hedgedDataSource) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+type hedgeRecordingHooks struct {
+	mu       sync.Mutex
+	hedged   []bool
+	statuses []int
+}
+
+func (h *hedgeRecordingHooks) OnLoad(ctx context.Context, _ DataSourceInfo) context.Context {
+	return ctx
+}
+
+func (h *hedgeRecordingHooks) OnFinished(_ context.Context, _ DataSourceInfo, info *ResponseInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hedged = append(h.hedged, info.Hedged)
+	h.statuses = append(h.statuses, info.StatusCode)
+}
+
+func TestLoader_Hedging(t *testing.T) {
+	const ok = `{"data":{"name":"Jens"}}`
+	answer := scriptedAttempt{status: http.StatusOK, response: ok}
+	policy := &HedgingPolicy{Delay: 10 * time.Millisecond}
+
+	t.Run("a slow fetch is hedged and the first answer wins", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{time.Minute, 0}, answer)
+		hooks := &hedgeRecordingHooks{}
+		ctx := NewContext(t.Context())
+		ctx.LoaderHooks = hooks
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: policy}, ast.OperationTypeQuery, ds, ctx)
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, 2, ds.callCount())
+		assert.Equal(t, 0, <-ds.canceled, "the slow request is canceled")
+		assert.Equal(t, []bool{true}, hooks.hedged)
+	})
+
+	t.Run("the winning hedge's response is the one reported", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{time.Minute, 0}, answer, scriptedAttempt{status: http.StatusNonAuthoritativeInfo, response: ok})
+		hooks := &hedgeRecordingHooks{}
+		ctx := NewContext(t.Context())
+		ctx.LoaderHooks = hooks
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: policy}, ast.OperationTypeQuery, ds, ctx)
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, []int{http.StatusNonAuthoritativeInfo}, hooks.statuses)
+	})
+
+	t.Run("a fast fetch is not hedged", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{0}, answer)
+		hooks := &hedgeRecordingHooks{}
+		ctx := NewContext(t.Context())
+		ctx.LoaderHooks = hooks
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: &HedgingPolicy{Delay: time.Minute}}, ast.OperationTypeQuery, ds, ctx)
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, 1, ds.callCount())
+		assert.Equal(t, []bool{false}, hooks.hedged)
+	})
+
+	t.Run("a failed hedge leaves the first request to answer", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{50 * time.Millisecond, 0}, answer, scriptedAttempt{err: errors.New("connection reset")})
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: policy}, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, 2, ds.callCount())
+	})
+
+	t.Run("a failure before the delay is not hedged", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{0}, scriptedAttempt{err: errors.New("connection refused")})
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: &HedgingPolicy{Delay: time.Minute}}, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 1, ds.callCount())
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'users'`)
+	})
+
+	t.Run("a mutation is never hedged", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{50 * time.Millisecond, 0}, answer)
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{Hedging: policy}, ast.OperationTypeMutation, ds, NewContext(t.Context()))
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, 1, ds.callCount())
+	})
+
+	t.Run("a data source can opt out", func(t *testing.T) {
+		ds := newHedgedDataSource([]time.Duration{50 * time.Millisecond, 0}, answer)
+		options := ResolverOptions{Hedging: policy, DataSourceHedgingPolicies: map[string]*HedgingPolicy{"0": nil}}
+
+		out, _ := resolveSingleFetch(t, options, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, ok, out)
+		assert.Equal(t, 1, ds.callCount())
+	})
+}
+
+func TestHedgingPolicy_hedgeDelay(t *testing.T) {
+	window := &latencyWindow{}
+	policy := &HedgingPolicy{Delay: time.Second, Percentile: 0.9, MinSamples: 10}
+
+	for i := 1; i <= 9; i++ {
+		window.record(time.Duration(i) * time.Millisecond)
+	}
+	delay, ok := policy.hedgeDelay(window)
+	require.True(t, ok)
+	assert.Equal(t, time.Second, delay, "Delay applies until MinSamples latencies are recorded")
+
+	for i := 10; i <= 100; i++ {
+		window.record(time.Duration(i) * time.Millisecond)
+	}
+	delay, ok = policy.hedgeDelay(window)
+	require.True(t, ok)
+	assert.Equal(t, 90*time.Millisecond, delay)
+
+	_, ok = (&HedgingPolicy{Percentile: 0.9, MinSamples: 1000}).hedgeDelay(window)
+	assert.False(t, ok, "without a Delay, nothing is hedged until the percentile is known")
+
+	for i := range hedgingLatencySamples {
+		window.record(time.Duration(i+1) * time.Second)
+	}
+	delay, _ = policy.hedgeDelay(window)
+	assert.Equal(t, 115*time.Second, delay, "only the latest latencies count")
+
+	fast := &latencyWindow{}
+	for range 10 {
+		fast.record(0)
+	}
+	delay, ok = policy.hedgeDelay(fast)
+	require.True(t, ok)
+	assert.Equal(t, minHedgingDelay, delay, "a percentile of no latency does not hedge at once")
+}
@@ -45,9 +45,20 @@ type LoaderHooks interface {
 	OnLoad(ctx context.Context, ds DataSourceInfo) context.Context
 	// OnFinished is called after a fetch has been executed and the response has been processed and merged.
 	// It is only called when OnLoad was called, i.e. when the fetch was not skipped.
+	// A fetch retried under a RetryPolicy calls OnLoad and OnFinished once per attempt, and
+	// OnFinished of an attempt that is retried comes before the retry, with an unmerged response.
 	OnFinished(ctx context.Context, ds DataSourceInfo, info *ResponseInfo)
 }
 
+// LoaderCacheHooks can be implemented by LoaderHooks to be notified of a fetch
+// served entirely from the response cache, for which neither OnLoad nor
+// OnFinished is called as nothing is loaded.
+type LoaderCacheHooks interface {
+	// OnCacheHit is called with a ResponseInfo that has CacheHit set and the
+	// cached response as body.
+	OnCacheHit(ctx context.Context, ds DataSourceInfo, info *ResponseInfo)
+}
+
 type DataSourceInfo struct {
 	ID   string
 	Name string
@@ -61,6 +72,16 @@ type ResponseInfo struct {
 	Request *http.Request
 	// ResponseHeaders contains a clone of the headers of the response from the subgraph.
 	ResponseHeaders http.Header
+	// Attempt counts the attempts of the fetch from 1. It is above 1 only for a
+	// fetch retried under a RetryPolicy, which reports every attempt.
+	Attempt int
+	// Hedged is true when a second request was sent for the attempt under a HedgingPolicy.
+	Hedged bool
+	// FetchKind is the kind of the fetch, e.g. an entity fetch.
+	FetchKind FetchKind
+	// CacheHit is true when the response cache served the fetch, in part or as a
+	// whole, or served stale entities in place of a failed fetch.
+	CacheHit bool
 	// This should be private as we do not want user's to access the raw responseBody directly
 	responseBody []byte
 }
@@ -73,6 +94,10 @@ func newResponseInfo(res *result) *ResponseInfo {
 	responseInfo := &ResponseInfo{
 		StatusCode:   res.statusCode,
 		Err:          res.subgraphError,
+		Attempt:      res.attempt,
+		Hedged:       res.hedged,
+		FetchKind:    res.fetchKind,
+		CacheHit:     res.cacheHit,
 		responseBody: res.out,
 	}
 	if res.httpResponseContext != nil {
@@ -122,6 +147,9 @@ type result struct {
 	// OnFinished reports only this fetch's error, not the request-wide aggregate.
 	subgraphError error
 	ds            DataSourceInfo
+	fetchKind     FetchKind
+	// cacheHit is set when the response cache served the fetch, see ResponseInfo.CacheHit.
+	cacheHit bool
 
 	authorizationRejected        bool
 	authorizationRejectedReasons []string
@@ -135,6 +163,10 @@ type result struct {
 	loaderHookContext context.Context
 
 	httpResponseContext *httpclient.ResponseContext
+	// attempt counts the attempts of the fetch from 1, see RetryPolicy.
+	attempt int
+	// hedged is set once a hedge was sent for the attempt, see HedgingPolicy.
+	hedged bool
 	// out is the subgraph response body
 	out               []byte
 	singleFlightStats *singleFlightStats
@@ -276,6 +308,23 @@ type Loader struct {
 
 	validateRequiredExternalFields bool
 
+	retryPolicyDefault      *RetryPolicy
+	dataSourceRetryPolicies map[string]*RetryPolicy
+
+	fetchTimeout            time.Duration
+	dataSourceFetchTimeouts map[string]time.Duration
+	operationTimeout        time.Duration
+
+	hedgingPolicyDefault      *HedgingPolicy
+	dataSourceHedgingPolicies map[string]*HedgingPolicy
+	// hedgingLatencies is the Resolver's record of data source latencies. It is nil when
+	// hedging is disabled, and for a Loader built outside a Resolver.
+	hedgingLatencies *hedgingLatencies
+
+	// circuitBreakers is the Resolver's set of circuit breakers. It is nil when they are
+	// disabled, and for a Loader built outside a Resolver.
+	circuitBreakers *circuitBreakers
+
 	taintedObjs taintedObjects
 
 	erroredFetchIDs map[int]struct{}
@@ -294,6 +343,13 @@ type Loader struct {
 	// singleFlight is the SubgraphRequestSingleFlight object shared across all client requests.
 	// It's thread safe and can be used to de-duplicate subgraph requests.
 	singleFlight *SubgraphRequestSingleFlight
+
+	// responseCacheIndex is the Resolver's record of what the response cache holds,
+	// kept for Resolver.InvalidateEntity. It is nil for a Loader built outside a Resolver.
+	responseCacheIndex *responseCacheIndex
+	// responseCacheRevalidations is the Resolver's set of background revalidations
+	// in flight. It is nil for a Loader built outside a Resolver.
+	responseCacheRevalidations *responseCacheRevalidations
 }
 
 func (l *Loader) Free() {
@@ -319,6 +375,7 @@ func (l *Loader) Init(ctx *Context, responseInfo *GraphQLResponseInfo) {
 	l.info = responseInfo
 	l.taintedObjs = make(taintedObjects)
 	l.erroredFetchIDs = nil
+	l.startOperationBudget()
 }
 
 func (l *Loader) ensureErrorsInitialized() {
@@ -389,7 +446,7 @@ func (l *Loader) preparePhase(item *FetchItem) (*preparedFetch, error) {
 	}
 
 	items := l.selectItemsForPath(item.FetchPath)
-	res := &result{}
+	res := &result{fetchKind: item.Fetch.FetchKind()}
 	prepared := &preparedFetch{
 		item:  item,
 		items: items,
@@ -420,13 +477,20 @@ func (l *Loader) loadPhase(ctx context.Context, prepared *preparedFetch) error {
 	}
 	if l.responseCacheLookup(prepared) {
 		prepared.responseCacheHit = true
+		prepared.res.cacheHit = true
 		if prepared.trace != nil {
 			prepared.trace.LoadSkipped = true
 		}
+		l.callOnCacheHit(ctx, prepared.res)
 		return nil
 	}
 
-	l.executeSourceLoad(ctx, prepared.item, prepared.source, prepared.input, prepared.res, prepared.trace)
+	l.loadWithRetries(ctx, prepared)
+	if l.responseCacheServeStale(prepared) {
+		prepared.responseCacheHit = true
+		prepared.res.cacheHit = true
+		return nil
+	}
 	if prepared.res.err != nil {
 		l.recordErroredFetchID(prepared.item)
 	}
@@ -446,6 +510,10 @@ func (l *Loader) mergePhase(prepared *preparedFetch) error {
 		return l.mergeMultiEntityResult(prepared)
 	}
 
+	if err := l.responseCacheMergePartial(prepared); err != nil {
+		l.reportResponseCacheError(fmt.Errorf("response cache partial merge error: %w", err))
+	}
+
 	if err := l.responseCacheCollect(prepared); err != nil {
 		l.reportResponseCacheError(fmt.Errorf("response cache collect error: %w", err))
 	}
@@ -493,9 +561,30 @@ type preparedFetch struct {
 	batchFetch bool
 
 	responseCacheKeys []string
+	// responseCacheTypeNames holds the typename of every entity in
+	// responseCacheKeys, and responseCacheSelectionHash the selection hash they
+	// share, for the Resolver's record of what the cache holds.
+	responseCacheTypeNames     []string
+	responseCacheSelectionHash uint64
+	// responseCacheRoot is set for a root fetch, whose one key holds the whole
+	// response rather than an entity.
+	responseCacheRoot bool
 
 	responseCacheHit bool
 
+	// responseCacheBatch is what a batch entity fetch input was rendered from,
+	// kept so a partial hit can render it again without the cached entities.
+	responseCacheBatch *responseCacheBatchInput
+
+	// responseCacheCached is set on a partial hit. It holds the cached entity of
+	// every unique representation left out of the fetch, and nil for the ones
+	// the subgraph was still asked for.
+	responseCacheCached [][]byte
+
+	// responseCacheFallback is the answer to serve in place of a failed fetch,
+	// built from stale-if-error entries. It is nil unless every entity has one.
+	responseCacheFallback [][]byte
+
 	responseCacheItems []caching.Item
 
 	multiEntries []preparedMultiEntry
@@ -545,6 +634,12 @@ func (l *Loader) callOnFinished(res *result) {
 	}
 }
 
+func (l *Loader) callOnCacheHit(ctx context.Context, res *result) {
+	if hooks, ok := l.ctx.LoaderHooks.(LoaderCacheHooks); ok {
+		hooks.OnCacheHit(ctx, res.ds, newResponseInfo(res))
+	}
+}
+
 // recordSubgraphError is the Loader-local analog of Context.appendSubgraphErrors: it keeps
 // the error on res (for OnFinished) and in l.subgraphErrors, which appendSubgraphErrorsToContext later
 // merges into the Context.
@@ -689,6 +784,13 @@ func (l *Loader) setSkipErrors(res *result, items []*astjson.Value) {
 
 func (l *Loader) mergeResult(fetchItem *FetchItem, res *result, items []*astjson.Value) error {
 	if res.err != nil {
+		if goerrors.Is(res.err, ErrCircuitBreakerOpen) {
+			return l.renderErrorsFailedToFetch(fetchItem, res, circuitBreakerOpen)
+		}
+		var timeoutErr *FetchTimeoutError
+		if goerrors.As(res.err, &timeoutErr) {
+			return l.renderErrorsFailedToFetch(fetchItem, res, timeoutErr.reason())
+		}
 		return l.renderErrorsFailedToFetch(fetchItem, res, failedToFetchNoReason)
 	}
 	if res.authorizationRejected {
@@ -1295,6 +1397,9 @@ func (l *Loader) setSubgraphStatusCode(values []*astjson.Value, statusCode int)
 
 const (
 	failedToFetchNoReason       = ""
+	circuitBreakerOpen          = "circuit breaker is open"
+	fetchTimedOut               = "timed out after %s"
+	operationDeadlineExceeded   = "operation deadline exceeded"
 	emptyGraphQLResponse        = "empty response"
 	invalidGraphQLResponse      = "invalid JSON"
 	invalidGraphQLResponseShape = "no data or errors in response"
@@ -1653,6 +1758,7 @@ func (l *Loader) prepareSingleFetch(fetchItem *FetchItem, fetch *SingleFetch, it
 	prepared.source = fetch.DataSource
 	prepared.input = fetchInput
 	prepared.trace = fetch.Trace
+	l.responseCacheSetRootKey(fetchItem, fetch.Info, prepared, fetchInput)
 	return nil
 }
 
@@ -1723,14 +1829,14 @@ func (l *Loader) prepareEntityFetch(fetchItem *FetchItem, fetch *EntityFetch, it
 
 	// Built before SetInputUndefinedVariables rewrites the buffer in place, so
 	// the offsets above still point at what they were taken from.
-	if l.responseCacheEnabled() {
+	if scope, ok := l.responseCacheScope(fetch.CacheControl); ok {
 		rendered := preparedInput.Bytes()
 		selectionHash := responseCacheSelectionHash(
 			rendered[:responseCacheHeaderEnd],
 			rendered[responseCacheFooterStart:],
+			scope,
 		)
-		responseCacheItemHash := xxhash.Sum64(renderedItem)
-		prepared.responseCacheKeys = []string{caching.Key(responseCacheItemHash, selectionHash)}
+		responseCacheSetKeys(prepared, [][]byte{renderedItem}, selectionHash)
 	}
 
 	err = SetInputUndefinedVariables(preparedInput, undefinedVariables)
@@ -1842,7 +1948,7 @@ func (l *Loader) prepareBatchEntityFetch(fetchItem *FetchItem, fetch *BatchEntit
 		return errors.WithStack(err)
 	}
 	responseCacheHeaderEnd := preparedInput.Len()
-	var responseCacheItemHashes []uint64
+	var responseCacheItemBounds [][2]int
 
 	batchItemIndex := 0
 	addSeparator := false
@@ -1881,11 +1987,12 @@ WithNextItem:
 						return errors.WithStack(err)
 					}
 				}
+				itemStart := preparedInput.Len()
 				_, _ = itemInput.WriteTo(preparedInput)
 				// new unique representation
 				res.tools.batchHashToIndex[itemHash] = batchItemIndex
 				if l.responseCacheEnabled() {
-					responseCacheItemHashes = append(responseCacheItemHashes, itemHash)
+					responseCacheItemBounds = append(responseCacheItemBounds, [2]int{itemStart, preparedInput.Len()})
 				}
 				// A new targets bucket for the unique index must be allocated on the arena:
 				// a heap-allocated bucket would only be referenced from arena memory,
@@ -1917,16 +2024,19 @@ WithNextItem:
 		return errors.WithStack(err)
 	}
 
-	if l.responseCacheEnabled() && len(responseCacheItemHashes) > 0 {
+	if scope, ok := l.responseCacheScope(fetch.CacheControl); ok && len(responseCacheItemBounds) > 0 {
 		rendered := preparedInput.Bytes()
 		selectionHash := responseCacheSelectionHash(
 			rendered[:responseCacheHeaderEnd],
 			rendered[responseCacheFooterStart:],
+			scope,
 		)
-		prepared.responseCacheKeys = make([]string, len(responseCacheItemHashes))
-		for i, itemHash := range responseCacheItemHashes {
-			prepared.responseCacheKeys[i] = caching.Key(itemHash, selectionHash)
+		representations := make([][]byte, len(responseCacheItemBounds))
+		for i, bounds := range responseCacheItemBounds {
+			representations[i] = rendered[bounds[0]:bounds[1]]
 		}
+		responseCacheSetKeys(prepared, representations, selectionHash)
+		prepared.responseCacheBatch = newResponseCacheBatchInput(rendered, responseCacheHeaderEnd, responseCacheFooterStart, responseCacheItemBounds, undefinedVariables)
 	}
 
 	err = SetInputUndefinedVariables(preparedInput, undefinedVariables)
@@ -2081,7 +2191,7 @@ func (l *Loader) loadByContext(ctx context.Context, source DataSource, fetchItem
 
 	if !l.singleFlightAllowed(fetchItem) {
 		// Disable single flight for mutations
-		return l.loadByContextDirect(ctx, source, headers, input, res)
+		return l.loadHedged(ctx, fetchItem, source, headers, input, res)
 	}
 
 	item, shared := l.singleFlight.GetOrCreateItem(fetchItem, input, extraKey)
@@ -2126,7 +2236,7 @@ func (l *Loader) loadByContext(ctx context.Context, source DataSource, fetchItem
 	defer l.singleFlight.Finish(item)
 
 	// Perform the actual load
-	err := l.loadByContextDirect(ctx, source, headers, input, res)
+	err := l.loadHedged(ctx, fetchItem, source, headers, input, res)
 	if err != nil {
 		item.err = err
 		return err
@@ -136,12 +136,17 @@ func (f *Field) Copy() *Field {
 		cp := *f.Defer
 		deferField = &cp
 	}
+	var streamField *StreamField
+	if f.Stream != nil {
+		cp := *f.Stream
+		streamField = &cp
+	}
 	return &Field{
 		Name:        f.Name,
 		Value:       f.Value.Copy(),
 		Position:    f.Position,
 		Defer:       deferField,
-		Stream:      f.Stream,
+		Stream:      streamField,
 		OnTypeNames: f.OnTypeNames,
 		Info:        f.Info,
 	}
@@ -208,7 +213,19 @@ type Position struct {
 	Column uint32
 }
 
+// StreamField marks a list field delivered incrementally (@stream). Only the
+// first InitialBatchSize items are part of the initial payload, the rest follow
+// in an incremental payload each once it is flushed.
+//
+// Streaming changes how the list is delivered, not when it is loaded: the
+// upstream answers with the whole list, so every item, including the entity
+// fetches nested in it, is fetched and resolved before the initial payload is
+// flushed. Only a list field outside of other lists and deferred fragments is
+// streamed. The @stream directive of a nested list, or of a list in a deferred
+// fragment, is ignored and the list is delivered whole.
 type StreamField struct {
+	// ID is the id of the stream's descriptor in GraphQLDeferResponse.StreamDescriptors.
+	ID               int
 	InitialBatchSize int
 }
 
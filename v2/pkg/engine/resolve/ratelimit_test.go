@@ -241,3 +241,69 @@ func TestRateLimiter(t *testing.T) {
 		return res, *resolveCtx, ""
 	}))
 }
+
+type testCostRateLimiter struct {
+	testRateLimiter
+	budget int
+}
+
+func (t *testCostRateLimiter) RateLimitOperation(ctx *Context, cost *OperationCost) (*RateLimitDeny, error) {
+	if cost.Estimated > t.budget {
+		return &RateLimitDeny{Reason: "cost budget exhausted"}, nil
+	}
+	t.budget -= cost.Estimated
+	return nil, nil
+}
+
+func TestContext_RateLimitOperation(t *testing.T) {
+	newContext := func(limiter RateLimiter) *Context {
+		ctx := NewContext(context.Background())
+		ctx.RateLimitOptions = RateLimitOptions{Enable: true}
+		ctx.SetRateLimiter(limiter)
+		ctx.SetOperationCost(&OperationCost{Estimated: 6})
+		return ctx
+	}
+
+	t.Run("consumes the budget by cost", func(t *testing.T) {
+		limiter := &testCostRateLimiter{budget: 10}
+		ctx := newContext(limiter)
+
+		deny, err := ctx.RateLimitOperation()
+		assert.NoError(t, err)
+		assert.Nil(t, deny)
+		assert.Equal(t, 4, limiter.budget)
+
+		deny, err = ctx.RateLimitOperation()
+		assert.NoError(t, err)
+		assert.Equal(t, &RateLimitDeny{Reason: "cost budget exhausted"}, deny)
+		assert.Equal(t, 4, limiter.budget)
+	})
+
+	t.Run("allows without cost", func(t *testing.T) {
+		limiter := &testCostRateLimiter{}
+		ctx := newContext(limiter)
+		ctx.SetOperationCost(nil)
+
+		deny, err := ctx.RateLimitOperation()
+		assert.NoError(t, err)
+		assert.Nil(t, deny)
+	})
+
+	t.Run("allows when rate limiting is disabled", func(t *testing.T) {
+		limiter := &testCostRateLimiter{}
+		ctx := newContext(limiter)
+		ctx.RateLimitOptions.Enable = false
+
+		deny, err := ctx.RateLimitOperation()
+		assert.NoError(t, err)
+		assert.Nil(t, deny)
+	})
+
+	t.Run("allows with a rate limiter that does not limit by cost", func(t *testing.T) {
+		ctx := newContext(&testRateLimiter{})
+
+		deny, err := ctx.RateLimitOperation()
+		assert.NoError(t, err)
+		assert.Nil(t, deny)
+	})
+}
@@ -127,6 +127,14 @@ type Resolvable struct {
 	// deferDescriptors holds every defer descriptor for the operation, keyed by defer id.
 	deferDescriptors map[int]DeferDescriptor
 
+	// streamDescriptors holds every stream descriptor for the operation, keyed by stream id.
+	// A list field with a descriptor here is cut to its initial batch in the initial frame.
+	streamDescriptors map[int]DeferDescriptor
+
+	// currentStream is the StreamField of the field currently being walked, handed
+	// from walkFields to walkArray like currentFieldInfo.
+	currentStream *StreamField
+
 	// typeNames is a stack of the runtime `__typename` at each object layer; it is
 	// indexed by depth to evaluate `... on Type` fragment type conditions.
 	typeNames [][]byte
@@ -262,6 +270,8 @@ func (r *Resolvable) Reset() {
 	r.deferMode = false
 	r.currentDefer = nil
 	r.deferDescriptors = nil
+	r.streamDescriptors = nil
+	r.currentStream = nil
 	r.enableDeferRender = false
 	r.deferIncrementalItemWritten = false
 	r.deferItemDataNull = false
@@ -427,7 +437,14 @@ func (r *Resolvable) Resolve(ctx context.Context, rootData *Object, fetchTree *F
 		// Announce only the top-level defers whose anchor survived. Nested defers
 		// are announced lazily when their parent is released. A recoverable error
 		// that null-propagated onto a defer's own anchor cancels just that defer.
+		// Streams are announced alongside, when their list has items left to send.
 		live := r.liveChildDescriptors(0)
+		for id, d := range r.liveStreamDescriptors(rootData) {
+			if live == nil {
+				live = make(map[int]DeferDescriptor)
+			}
+			live[id] = d
+		}
 		r.printPendingEntries(live)
 		r.printHasNext(len(live) > 0)
 	}
@@ -525,7 +542,7 @@ func (r *Resolvable) ResolveDeferBatch(rootData *Object, out io.Writer, outstand
 	// Always emit completed for this defer id. Errors are attached only when the
 	// fragment had no deliverable incremental data (they ride in incremental[]
 	// otherwise).
-	r.renderCompleted(shouldSkipIncremental && r.hasErrors())
+	r.renderCompleted(r.currentDefer.ID, shouldSkipIncremental && r.hasErrors())
 
 	// Announce the surviving direct children (lazy nested pending). No-op when
 	// there are none.
@@ -541,10 +558,10 @@ func (r *Resolvable) ResolveDeferBatch(rootData *Object, out io.Writer, outstand
 }
 
 // renderCompleted writes `"completed":[{"id":"<n>"[,"errors":[...]]}]` for the
-// current defer. When withErrors is true the accumulated r.errors are attached
+// defer or stream with the given id. When withErrors is true the accumulated r.errors are attached
 // to the completed entry (used when the fragment had no deliverable incremental
 // data, e.g. it null-bubbled or failed before/around its render).
-func (r *Resolvable) renderCompleted(withErrors bool) {
+func (r *Resolvable) renderCompleted(id int, withErrors bool) {
 	r.printBytes(quote)
 	r.printBytes(literalCompleted)
 	r.printBytes(quote)
@@ -557,7 +574,7 @@ func (r *Resolvable) renderCompleted(withErrors bool) {
 	r.printBytes(quote)
 	r.printBytes(colon)
 	r.printBytes(quote)
-	r.printBytes([]byte(strconv.Itoa(r.currentDefer.ID)))
+	r.printBytes([]byte(strconv.Itoa(id)))
 	r.printBytes(quote)
 	if withErrors {
 		r.printBytes(comma)
@@ -591,7 +608,7 @@ func (r *Resolvable) ResolveDeferError(out io.Writer, message string, outstandin
 
 	// {"completed":[{"id":"<n>","errors":[...]}],"hasNext":<bool>}
 	r.printBytes(lBrace)
-	r.renderCompleted(true)
+	r.renderCompleted(r.currentDefer.ID, true)
 	r.printHasNext(!isLast)
 	r.printBytes(rBrace)
 
@@ -900,6 +917,14 @@ func (r *Resolvable) printExtensions(ctx context.Context, fetchTree *FetchTreeNo
 		}
 	}
 
+	if r.ctx.ExecutionOptions.IncludeCostInResponse && r.ctx.operationCost != nil {
+		if writeComma {
+			r.printBytes(comma)
+		}
+		writeComma = true
+		r.printCostExtension()
+	}
+
 	if r.ctx.ExecutionOptions.IncludeQueryPlanInResponse {
 		if writeComma {
 			r.printBytes(comma)
@@ -982,6 +1007,45 @@ func (r *Resolvable) printRateLimitingExtension() error {
 	return r.ctx.rateLimiter.RenderResponseExtension(r.ctx, r.out)
 }
 
+// printCostExtension prints the estimated cost of the operation, its actual
+// cost when cost control collects the stats to compute it, and the limit when
+// there is one.
+func (r *Resolvable) printCostExtension() {
+	cost := r.ctx.operationCost
+
+	r.printBytes(quote)
+	r.printBytes(literalCost)
+	r.printBytes(quote)
+	r.printBytes(colon)
+	r.printBytes(lBrace)
+
+	r.printBytes(quote)
+	r.printBytes(literalEstimated)
+	r.printBytes(quote)
+	r.printBytes(colon)
+	r.printBytes(strconv.AppendInt(nil, int64(cost.Estimated), 10))
+
+	if cost.Actual != nil && r.options.EnableCostControl {
+		r.printBytes(comma)
+		r.printBytes(quote)
+		r.printBytes(literalActual)
+		r.printBytes(quote)
+		r.printBytes(colon)
+		r.printBytes(strconv.AppendInt(nil, int64(cost.Actual(r.typeNameStats)), 10))
+	}
+
+	if cost.Limit > 0 {
+		r.printBytes(comma)
+		r.printBytes(quote)
+		r.printBytes(literalLimit)
+		r.printBytes(quote)
+		r.printBytes(colon)
+		r.printBytes(strconv.AppendInt(nil, int64(cost.Limit), 10))
+	}
+
+	r.printBytes(rBrace)
+}
+
 func (r *Resolvable) printTraceExtension(ctx context.Context, fetchTree *FetchTreeNode) error {
 	trace := GetTrace(ctx, fetchTree)
 	content, err := json.Marshal(trace)
@@ -1023,6 +1087,7 @@ func getDefaultReservedExtensions() map[string]struct{} {
 	return map[string]struct{}{
 		string(literalAuthorization):   {},
 		string(literalRateLimit):       {},
+		string(literalCost):            {},
 		string(literalQueryPlan):       {},
 		string(literalTrace):           {},
 		string(literalValueCompletion): {},
@@ -1073,6 +1138,9 @@ func (r *Resolvable) hasExtensions() bool {
 	if r.ctx.TracingOptions.Enable && r.ctx.TracingOptions.IncludeTraceOutputInResponseExtensions {
 		return true
 	}
+	if r.ctx.ExecutionOptions.IncludeCostInResponse && r.ctx.operationCost != nil {
+		return true
+	}
 	if r.ctx.ExecutionOptions.IncludeQueryPlanInResponse {
 		return true
 	}
@@ -1614,6 +1682,7 @@ func (r *Resolvable) walkFields(obj *Object, value *astjson.Value, parent *astjs
 			r.recordFieldReached(value, obj.Fields[i])
 		}
 		r.currentFieldInfo = obj.Fields[i].Info
+		r.currentStream = obj.Fields[i].Stream
 		err := r.walkNode(obj.Fields[i].Value, value)
 		if err {
 			if r.render() {
@@ -1835,6 +1904,8 @@ func (r *Resolvable) skipFieldOnTypeNames(field *Field) bool {
 }
 
 func (r *Resolvable) walkArray(arr *Array, value *astjson.Value) bool {
+	stream := r.currentStream
+	r.currentStream = nil
 	parent := value
 	value = value.Get(arr.Path...)
 	if astjson.ValueIsNull(value) {
@@ -1896,6 +1967,11 @@ func (r *Resolvable) walkArray(arr *Array, value *astjson.Value) bool {
 		r.typeNameStats[fieldPath] = stats
 	}
 
+	if r.streamedInInitialFrame(stream) {
+		// the rest of the list is sent by ResolveStreamItem
+		values = values[:min(len(values), stream.InitialBatchSize)]
+	}
+
 	hasPrintedValue := false
 	for i, arrayValue := range values {
 		skip := false
@@ -0,0 +1,245 @@
+package resolve
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/wundergraph/astjson"
+	"github.com/wundergraph/go-arena"
+)
+
+// streamedInInitialFrame reports whether a list field with the given stream is
+// being rendered into the initial frame of a deferred response, and so has to
+// be cut to its initial batch.
+func (r *Resolvable) streamedInInitialFrame(stream *StreamField) bool {
+	if stream == nil || !r.deferMode || r.currentDefer != nil {
+		return false
+	}
+	_, ok := r.streamDescriptors[stream.ID]
+	return ok
+}
+
+// findStreamField returns the field of the stream with the given id, and the
+// objects from rootData down to the one holding it. Streams are planned for
+// list fields outside of other lists and deferred fragments only, so the
+// search does not descend into either.
+func findStreamField(obj *Object, id int, objects []*Object) ([]*Object, *Field) {
+	objects = append(slices.Clip(objects), obj)
+	for _, field := range obj.Fields {
+		if field.Defer != nil {
+			continue
+		}
+		if field.Stream != nil && field.Stream.ID == id {
+			return objects, field
+		}
+		if child, ok := field.Value.(*Object); ok {
+			if found, streamField := findStreamField(child, id, objects); streamField != nil {
+				return found, streamField
+			}
+		}
+	}
+	return nil, nil
+}
+
+// streamList returns the data of the list field, and nil when it did not make
+// it into the initial frame: a parent object was null, or the field's type
+// condition did not match.
+func (r *Resolvable) streamList(objects []*Object, field *Field) *astjson.Value {
+	value := r.data
+	for _, obj := range objects {
+		value = value.Get(obj.Path...)
+		if value == nil || value.Type() != astjson.TypeObject {
+			return nil
+		}
+	}
+	if len(field.OnTypeNames) > 0 {
+		typeName := value.GetStringBytes("__typename")
+		if !slices.ContainsFunc(field.OnTypeNames, func(name []byte) bool { return bytes.Equal(name, typeName) }) {
+			return nil
+		}
+	}
+	list := value.Get(field.Value.NodePath()...)
+	if list == nil || list.Type() != astjson.TypeArray {
+		return nil
+	}
+	return list
+}
+
+// liveStreamDescriptors returns the descriptors of the streams whose list made
+// it into the initial frame with items left to send after its initial batch. A
+// stream with nothing left to send is complete with the initial frame and is
+// never announced.
+func (r *Resolvable) liveStreamDescriptors(rootData *Object) map[int]DeferDescriptor {
+	var live map[int]DeferDescriptor
+	for id, d := range r.streamDescriptors {
+		if len(r.streamItems(rootData, id)) == 0 {
+			continue
+		}
+		if live == nil {
+			live = make(map[int]DeferDescriptor)
+		}
+		live[id] = d
+	}
+	return live
+}
+
+// streamItems returns the indices of the items of the stream with the given id
+// that were held back from the initial frame, leaving out the items the list
+// skips.
+func (r *Resolvable) streamItems(rootData *Object, id int) []int {
+	objects, field := findStreamField(rootData, id, nil)
+	if field == nil {
+		return nil
+	}
+	list := r.streamList(objects, field)
+	if list == nil {
+		return nil
+	}
+	arr := field.Value.(*Array)
+	values := list.GetArray()
+	var items []int
+	for i := field.Stream.InitialBatchSize; i < len(values); i++ {
+		if arr.SkipItem != nil && arr.SkipItem(r.ctx, values[i]) {
+			continue
+		}
+		items = append(items, i)
+	}
+	return items
+}
+
+// ResolveStreamItem renders the incremental payload with the item at index of
+// the stream with the given id: `{"incremental":[{"items":[...],"id":"<n>"}],
+// "hasNext":true}`. The payload of the last item completes the stream. An item
+// that fails a non-null check completes the stream with its errors instead, as
+// the items before it are already on the wire. It reports whether the stream
+// completed with the payload. The stream must be live (see
+// liveStreamDescriptors).
+func (r *Resolvable) ResolveStreamItem(rootData *Object, id, index int, last bool, out io.Writer, outstanding *int64) (completed bool, err error) {
+	r.out = out
+	r.printErr = nil
+	r.authorizationError = nil
+	r.errors = nil
+
+	objects, field := findStreamField(rootData, id, nil)
+	arr := field.Value.(*Array)
+	list := r.streamList(objects, field)
+
+	// Restore the walk state of the list in the initial frame: its path, depth
+	// and the type names of the objects above it.
+	r.deferMode = true
+	r.currentDefer = nil
+	r.enableDeferRender = true
+	value := r.data
+	for _, obj := range objects {
+		value = value.Get(obj.Path...)
+		r.pushNodePathElement(obj.Path)
+		r.typeNames = append(r.typeNames, value.GetStringBytes("__typename"))
+		r.enclosingTypeNames = append(r.enclosingTypeNames, obj.TypeName)
+	}
+	r.pushNodePathElement(arr.Path)
+	defer func() {
+		r.popNodePathElement(arr.Path)
+		for i := len(objects) - 1; i >= 0; i-- {
+			r.popNodePathElement(objects[i].Path)
+		}
+		r.typeNames = r.typeNames[:len(r.typeNames)-len(objects)]
+		r.enclosingTypeNames = r.enclosingTypeNames[:len(r.enclosingTypeNames)-len(objects)]
+		r.enableDeferRender = false
+	}()
+
+	// First pass (pre-walk): validate the item, collect errors and apply nulls.
+	r.enableRender = false
+	failed := r.walkStreamItem(arr, list, index)
+	if r.authorizationError != nil {
+		r.addError(r.authorizationError.Error(), nil)
+		r.authorizationError = nil
+		failed = true
+	}
+
+	// Second pass: render the item into a scratch buffer, so a render-phase
+	// error never leaves a partial frame on the wire.
+	var item []byte
+	if !failed {
+		savedOut := r.out
+		scratch := arena.NewArenaBuffer(r.astjsonArena)
+		r.out = scratch
+		r.enableRender = true
+		_ = r.walkStreamItem(arr, list, index)
+		r.out = savedOut
+		if r.printErr != nil {
+			r.addError(r.printErr.Error(), nil)
+			r.printErr = nil
+			failed = true
+		} else {
+			item = scratch.Bytes()
+		}
+	}
+
+	// Serialised with the defer frames by the caller, like ResolveDeferBatch.
+	completed = last || failed
+	if completed {
+		*outstanding--
+	}
+
+	r.printBytes(lBrace)
+	if !failed {
+		r.printBytes(quote)
+		r.printBytes(literalIncremental)
+		r.printBytes(quote)
+		r.printBytes(colon)
+		r.printBytes(lBrack)
+		r.printBytes(lBrace)
+		r.printBytes(quote)
+		r.printBytes(literalItems)
+		r.printBytes(quote)
+		r.printBytes(colon)
+		r.printBytes(lBrack)
+		r.printBytes(item)
+		r.printBytes(rBrack)
+		r.printBytes(comma)
+		r.printBytes(quote)
+		r.printBytes(literalId)
+		r.printBytes(quote)
+		r.printBytes(colon)
+		r.printBytes(quote)
+		r.printBytes([]byte(strconv.Itoa(id)))
+		r.printBytes(quote)
+		if r.hasErrors() {
+			r.printBytes(comma)
+			r.printBytes(quote)
+			r.printBytes(literalErrors)
+			r.printBytes(quote)
+			r.printBytes(colon)
+			r.printNode(r.errors)
+		}
+		r.printBytes(rBrace)
+		r.printBytes(rBrack)
+		if completed {
+			r.printBytes(comma)
+		}
+	}
+	if completed {
+		r.renderCompleted(id, failed && r.hasErrors())
+	}
+	r.printHasNext(*outstanding > 0)
+	r.printBytes(rBrace)
+
+	return completed, r.printErr
+}
+
+// walkStreamItem walks the item at index of list, the way walkArray walks the
+// items of a whole list, and reports whether it failed a non-null check.
+func (r *Resolvable) walkStreamItem(arr *Array, list *astjson.Value, index int) bool {
+	r.pushArrayPathElement(index)
+	defer r.popArrayPathElement()
+	if !r.walkNode(arr.Item, list.GetArray()[index]) {
+		return false
+	}
+	if arr.Item.NodeKind() == NodeKindObject && arr.Item.NodeNullable() {
+		list.SetArrayItem(r.astjsonArena, index, astjson.NullValue)
+		return false
+	}
+	return true
+}
@@ -8,8 +8,10 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"runtime"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -102,6 +104,14 @@ type Resolver struct {
 	subgraphRequestSingleFlight *SubgraphRequestSingleFlight
 	// inboundRequestSingleFlight is used to de-duplicate subgraph requests
 	inboundRequestSingleFlight *InboundRequestSingleFlight
+	// responseCacheIndex remembers what the response cache holds for InvalidateEntity
+	responseCacheIndex *responseCacheIndex
+	// responseCacheRevalidations holds the background revalidations in flight
+	responseCacheRevalidations *responseCacheRevalidations
+	// circuitBreakers is nil unless ResolverOptions.CircuitBreaker is set
+	circuitBreakers *circuitBreakers
+	// hedgingLatencies is nil unless a HedgingPolicy is set
+	hedgingLatencies *hedgingLatencies
 }
 
 func (r *Resolver) SetAsyncErrorWriter(w AsyncErrorWriter) {
@@ -212,6 +222,31 @@ type ResolverOptions struct {
 
 	ValidateRequiredExternalFields bool
 
+	// RetryPolicy retries the query fetches that fail in a transient way. Nil disables retries.
+	RetryPolicy *RetryPolicy
+	// DataSourceRetryPolicies overrides RetryPolicy per data source, keyed by data source ID.
+	// A nil policy disables retries for its data source.
+	DataSourceRetryPolicies map[string]*RetryPolicy
+
+	// CircuitBreaker fails the fetches of a data source fast while it keeps failing. Nil disables it.
+	CircuitBreaker *CircuitBreakerOptions
+
+	// FetchTimeout bounds every attempt of a fetch. Zero leaves fetches bounded by the request context only.
+	FetchTimeout time.Duration
+	// DataSourceFetchTimeouts overrides FetchTimeout per data source, keyed by data source ID.
+	// A zero timeout leaves the fetches of its data source unbounded.
+	DataSourceFetchTimeouts map[string]time.Duration
+	// OperationTimeout is the budget all fetches of a query or mutation share, starting with the first one.
+	// A fetch is bounded by what is left of it, so a late fetch gets the remaining budget rather than a fresh
+	// FetchTimeout. Zero disables the budget.
+	OperationTimeout time.Duration
+
+	// Hedging sends a second request for a query fetch that is slow to answer. Nil disables hedging.
+	Hedging *HedgingPolicy
+	// DataSourceHedgingPolicies overrides Hedging per data source, keyed by data source ID.
+	// A nil policy disables hedging for its data source.
+	DataSourceHedgingPolicies map[string]*HedgingPolicy
+
 	// SubgraphRequestDeduplicationShardCount defines the number of shards to use for subgraph request deduplication
 	SubgraphRequestDeduplicationShardCount int
 	// InboundRequestDeduplicationShardCount defines the number of shards to use for inbound request deduplication
@@ -302,6 +337,12 @@ func New(ctx context.Context, options ResolverOptions) *Resolver {
 		responseBufferPool:           arena.NewArenaPool(),
 		subgraphRequestSingleFlight:  NewSingleFlight(options.SubgraphRequestDeduplicationShardCount),
 		inboundRequestSingleFlight:   NewRequestSingleFlight(options.InboundRequestDeduplicationShardCount),
+		responseCacheIndex:           newResponseCacheIndex(),
+		responseCacheRevalidations:   &responseCacheRevalidations{},
+		circuitBreakers:              newCircuitBreakers(options.CircuitBreaker),
+	}
+	if options.Hedging != nil || len(options.DataSourceHedgingPolicies) > 0 {
+		resolver.hedgingLatencies = newHedgingLatencies()
 	}
 	resolver.maxConcurrency = make(chan struct{}, options.MaxConcurrency)
 	for i := 0; i < options.MaxConcurrency; i++ {
@@ -337,11 +378,29 @@ func NewLoader(options ResolverOptions, allowedExtensionFields map[string]struct
 		apolloRouterCompatibilitySubrequestHTTPError:   options.ApolloRouterCompatibilitySubrequestHTTPError,
 		propagateFetchReasons:                          options.PropagateFetchReasons,
 		validateRequiredExternalFields:                 options.ValidateRequiredExternalFields,
+		retryPolicyDefault:                             options.RetryPolicy,
+		dataSourceRetryPolicies:                        options.DataSourceRetryPolicies,
+		fetchTimeout:                                   options.FetchTimeout,
+		dataSourceFetchTimeouts:                        options.DataSourceFetchTimeouts,
+		operationTimeout:                               options.OperationTimeout,
+		hedgingPolicyDefault:                           options.Hedging,
+		dataSourceHedgingPolicies:                      options.DataSourceHedgingPolicies,
 		singleFlight:                                   sf,
 		jsonArena:                                      a,
 	}
 }
 
+// newLoader is NewLoader with the resolver's own options, for every loader
+// the resolver runs itself.
+func (r *Resolver) newLoader(a arena.Arena, db *DataBuffer, authorization *FieldAuthorization) *Loader {
+	loader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, a, db, authorization)
+	loader.responseCacheIndex = r.responseCacheIndex
+	loader.responseCacheRevalidations = r.responseCacheRevalidations
+	loader.circuitBreakers = r.circuitBreakers
+	loader.hedgingLatencies = r.hedgingLatencies
+	return loader
+}
+
 type GraphQLResolveInfo struct {
 	// ResolveAcquireWaitTime is the time spent waiting to acquire the resolver semaphore
 	// the semaphore limits the number of concurrent resolve operations
@@ -383,7 +442,7 @@ func (r *Resolver) ResolveGraphQLResponse(ctx *Context, response *GraphQLRespons
 	// The DataBuffer wraps the base tree produced by Init (which may already
 	// contain initialData). The loader fetches/merges into it.
 	db := &DataBuffer{data: resolvable.data}
-	loader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, nil, db, authorization)
+	loader := r.newLoader(nil, db, authorization)
 
 	if !ctx.ExecutionOptions.SkipLoader {
 		// Pre-fetch field authorization only matters when fetches actually run. When the loader is
@@ -460,7 +519,7 @@ func (r *Resolver) ArenaResolveGraphQLResponse(ctx *Context, response *GraphQLRe
 
 	// The DataBuffer wraps the base tree produced by Init. The loader merges into it.
 	db := &DataBuffer{data: resolvable.data}
-	loader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, resolveArena.Arena, db, authorization)
+	loader := r.newLoader(resolveArena.Arena, db, authorization)
 
 	if !ctx.ExecutionOptions.SkipLoader {
 		// Pre-fetch field authorization only matters when fetches actually run. When the loader is
@@ -559,7 +618,7 @@ func (r *Resolver) ResolveGraphQLDeferResponse(ctx *Context, response *GraphQLDe
 	// The DataBuffer wraps the base tree produced by Init. The loader and every
 	// defer group merge into it.
 	db := &DataBuffer{data: resolvable.data}
-	loader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, resolveArena.Arena, db, authorization)
+	loader := r.newLoader(resolveArena.Arena, db, authorization)
 
 	if !ctx.ExecutionOptions.SkipLoader {
 		// Pre-fetch field authorization: seed the batch decisions before the initial fetch, so denied
@@ -589,6 +648,7 @@ func (r *Resolver) ResolveGraphQLDeferResponse(ctx *Context, response *GraphQLDe
 		resolvable.deferMode = true
 		resolvable.currentDefer = nil
 		resolvable.deferDescriptors = response.DeferDescriptors
+		resolvable.streamDescriptors = response.StreamDescriptors
 
 		// render initial response
 		err = resolvable.Resolve(ctx.ctx, response.Response.Data, response.Response.Fetches, writer)
@@ -612,30 +672,62 @@ func (r *Resolver) ResolveGraphQLDeferResponse(ctx *Context, response *GraphQLDe
 			writer.Complete()
 		}()
 
+		// Streams and defers share the outstanding count of announced-but-not-
+		// completed payloads; the frame that drives it to zero writes
+		// hasNext:false. A stream is announced only when its list has items left
+		// to send.
+		liveStreams := resolvable.liveStreamDescriptors(response.Response.Data)
+		outstanding := int64(len(liveStreams))
+
 		// Fetch deferred responses using the parallel execution tree. Each top-level
 		// defer is gated on its anchor surviving the initial render; a defer whose
 		// anchor null-propagated is pruned away here. Nested defers are announced
 		// lazily as their parent is released (see ResolveDeferBatch).
+		var liveTree *DeferTreeNode
+		liveTop := resolvable.liveChildDescriptors(0)
 		if response.DeferTree != nil {
-			liveTop := resolvable.liveChildDescriptors(0)
-			liveTree := pruneDeadDefers(response.DeferTree, liveTop)
-			if liveTree != nil {
-				// outstanding tracks announced-but-not-completed defers: it starts at
-				// the top-level live count and is adjusted per frame as parents
-				// announce children and defers complete. The frame that drives it to
-				// zero writes hasNext:false.
-				outstanding := int64(len(liveTop))
-				dc := &deferContext{
-					response:   response,
-					info:       response.Response.Info,
-					db:         db,
-					resolvable: resolvable,
-					writer:     writer,
-					arena:      resolveArena.Arena,
+			liveTree = pruneDeadDefers(response.DeferTree, liveTop)
+		}
+		if liveTree != nil {
+			// outstanding starts at the top-level live count and is adjusted per
+			// frame as parents announce children and defers complete.
+			outstanding += int64(len(liveTop))
+		}
+
+		// The upstream sends the whole list, so the items of a stream, entity
+		// fetches nested in them included, were fetched and resolved by the
+		// initial ResolveFetchNode, before the initial frame was flushed. Only
+		// their rendering is left: the streams complete first, in the order they
+		// were announced, before any deferred fetch, each item flushed in a
+		// payload of its own.
+		streamIDs := slices.Sorted(maps.Keys(liveStreams))
+		for _, id := range streamIDs {
+			items := resolvable.streamItems(response.Response.Data, id)
+			for i, index := range items {
+				completed, err := resolvable.ResolveStreamItem(response.Response.Data, id, index, i == len(items)-1, writer, &outstanding)
+				if err != nil {
+					return nil, err
 				}
-				if err := r.resolveDeferTree(dc, ctx, liveTree, &outstanding); err != nil {
+				if err := writer.Flush(); err != nil {
 					return nil, err
 				}
+				if completed {
+					break
+				}
+			}
+		}
+
+		if liveTree != nil {
+			dc := &deferContext{
+				response:   response,
+				info:       response.Response.Info,
+				db:         db,
+				resolvable: resolvable,
+				writer:     writer,
+				arena:      resolveArena.Arena,
+			}
+			if err := r.resolveDeferTree(dc, ctx, liveTree, &outstanding); err != nil {
+				return nil, err
 			}
 		}
 	}
@@ -675,7 +767,7 @@ func (r *Resolver) resolveDeferSingle(dc *deferContext, ctx *Context, group *Def
 	// the arena only in its prepare and merge phases, both of which hold
 	// dc.db.Lock(), and the off-lock network phase allocates nothing from it. The
 	// lock therefore serialises every arena allocation across all groups.
-	groupLoader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, dc.arena, dc.db, nil)
+	groupLoader := r.newLoader(dc.arena, dc.db, nil)
 	groupLoader.Init(ctx, dc.info) // fresh taintedObjs; errors=nil
 
 	if fetchErr := groupLoader.ResolveFetchNode(group.Fetches); fetchErr != nil {
@@ -1024,7 +1116,7 @@ func (r *Resolver) executeSubscriptionUpdate(resolveCtx *Context, sub *subscript
 	// The DataBuffer wraps the base tree produced by InitSubscription (the
 	// subscription event payload). The loader merges fetched data into it.
 	db := &DataBuffer{data: resolvable.data}
-	loader := NewLoader(r.options, r.allowedErrorExtensionFields, r.allowedErrorFields, r.subgraphRequestSingleFlight, resolveArena.Arena, db, authorization)
+	loader := r.newLoader(resolveArena.Arena, db, authorization)
 
 	if err := loader.LoadGraphQLResponseData(resolveCtx, sub.resolve.Response); err != nil {
 		r.resolveArenaPool.Release(resolveArena)
@@ -0,0 +1,173 @@
+package resolve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// streamedFeed builds the root field `feed`, a list of objects with an id,
+// streamed by the stream with the given id.
+func streamedFeed(stream *StreamField, itemNullable bool) *Field {
+	return &Field{
+		Name:   []byte("feed"),
+		Stream: stream,
+		Value: &Array{
+			Path: []string{"feed"},
+			Item: &Object{
+				Nullable: itemNullable,
+				Fields: []*Field{
+					{Name: []byte("id"), Value: &Integer{Path: []string{"id"}}},
+				},
+			},
+		},
+	}
+}
+
+func TestStream(t *testing.T) {
+	t.Parallel()
+
+	// { feed @stream(initialCount: 2) { id } }
+	feedResponse := func(fetchJSON string, itemNullable bool) *GraphQLDeferResponse {
+		return &GraphQLDeferResponse{
+			StreamDescriptors: map[int]DeferDescriptor{
+				1: {ID: 1, Path: []string{"feed"}},
+			},
+			Response: &GraphQLResponse{
+				Info:    deferQueryInfo(),
+				Fetches: simpleFetch(fetchJSON),
+				Data: &Object{
+					Fields: []*Field{streamedFeed(&StreamField{ID: 1, InitialBatchSize: 2}, itemNullable)},
+				},
+			},
+		}
+	}
+
+	t.Run("the items after the initial count follow in an incremental payload each", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), feedResponse(`{"feed":[{"id":1},{"id":2},{"id":3},{"id":4}]}`, false), w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"feed":[{"id":1},{"id":2}]},"pending":[{"id":"1","path":["feed"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":3}],"id":"1"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":4}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, w.payloads)
+		require.True(t, w.complete)
+	})
+
+	t.Run("a list within its initial count is not streamed", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), feedResponse(`{"feed":[{"id":1},{"id":2}]}`, false), w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"feed":[{"id":1},{"id":2}]},"hasNext":false}`,
+		}, w.payloads)
+	})
+
+	t.Run("a nullable item that fails is streamed as null", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), feedResponse(`{"feed":[{"id":1},{"id":2},{"id":null},{"id":4}]}`, true), w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"feed":[{"id":1},{"id":2}]},"pending":[{"id":"1","path":["feed"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[null],"id":"1","errors":[{"message":"Cannot return null for non-nullable field 'Query.feed.id'.","path":["feed",2,"id"]}]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":4}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, w.payloads)
+	})
+
+	t.Run("a non-null item that fails completes the stream with errors", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), feedResponse(`{"feed":[{"id":1},{"id":2},{"id":3},{"id":null},{"id":5}]}`, false), w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"feed":[{"id":1},{"id":2}]},"pending":[{"id":"1","path":["feed"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":3}],"id":"1"}],"hasNext":true}`,
+			`{"completed":[{"id":"1","errors":[{"message":"Cannot return null for non-nullable field 'Query.feed.id'.","path":["feed",3,"id"]}]}],"hasNext":false}`,
+		}, w.payloads)
+	})
+
+	t.Run("a stream nested in an object keeps its path and label", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		// { me { feed @stream(initialCount: 1, label: "feed") { id } } }
+		response := &GraphQLDeferResponse{
+			StreamDescriptors: map[int]DeferDescriptor{
+				1: {ID: 1, Label: "feed", Path: []string{"me", "feed"}},
+			},
+			Response: &GraphQLResponse{
+				Info:    deferQueryInfo(),
+				Fetches: simpleFetch(`{"me":{"__typename":"User","feed":[{"id":1},{"id":2},{"id":3}]}}`),
+				Data: &Object{
+					Fields: []*Field{
+						{
+							Name: []byte("me"),
+							Value: &Object{
+								Path:     []string{"me"},
+								TypeName: "User",
+								Fields:   []*Field{streamedFeed(&StreamField{ID: 1, InitialBatchSize: 1}, false)},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), response, w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"me":{"feed":[{"id":1}]}},"pending":[{"id":"1","path":["me","feed"],"label":"feed"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":2}],"id":"1"}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":3}],"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, w.payloads)
+	})
+
+	t.Run("streams complete before deferred fragments", func(t *testing.T) {
+		t.Parallel()
+		r := newResolver(t.Context())
+
+		// { feed @stream(initialCount: 2) { id } ... @defer { f1 } }
+		response := &GraphQLDeferResponse{
+			DeferDescriptors: map[int]DeferDescriptor{
+				1: {ID: 1, Path: nil},
+			},
+			StreamDescriptors: map[int]DeferDescriptor{
+				2: {ID: 2, Path: []string{"feed"}},
+			},
+			DeferTree: DeferSingle(simpleGroup(1, `{"f1":"hello"}`)),
+			Response: &GraphQLResponse{
+				Info:    deferQueryInfo(),
+				Fetches: simpleFetch(`{"feed":[{"id":1},{"id":2},{"id":3}]}`),
+				Data: &Object{
+					Fields: []*Field{
+						streamedFeed(&StreamField{ID: 2, InitialBatchSize: 2}, false),
+						deferredField("f1", 1, &String{Path: []string{"f1"}, Nullable: true}, nil),
+					},
+				},
+			},
+		}
+
+		w := &testDeferWriter{}
+		_, err := r.ResolveGraphQLDeferResponse(NewContext(context.Background()), response, w)
+		require.NoError(t, err)
+		require.Equal(t, []string{
+			`{"data":{"feed":[{"id":1},{"id":2}]},"pending":[{"id":"1","path":[]},{"id":"2","path":["feed"]}],"hasNext":true}`,
+			`{"incremental":[{"items":[{"id":3}],"id":"2"}],"completed":[{"id":"2"}],"hasNext":true}`,
+			`{"incremental":[{"data":{"f1":"hello"},"id":"1"}],"completed":[{"id":"1"}],"hasNext":false}`,
+		}, w.payloads)
+	})
+}
@@ -65,6 +65,11 @@ type GraphQLDeferResponse struct {
 	// path / label of a defer at envelope-render time.
 	DeferDescriptors map[int]DeferDescriptor
 
+	// StreamDescriptors lists every @stream list field in the operation, keyed by
+	// ID. A stream is announced and completed like a top-level defer, so its ids
+	// follow the defer ids and its Path is the response path of the list.
+	StreamDescriptors map[int]DeferDescriptor
+
 	// DeferTree is the execution tree built from DeferDescriptors during post-processing.
 	// Nil until the buildDeferTree post-processor runs.
 	DeferTree *DeferTreeNode
@@ -1,11 +1,20 @@
 package resolve
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/cespare/xxhash/v2"
 	"github.com/wundergraph/astjson"
 
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
 	"github.com/wundergraph/graphql-go-tools/v2/pkg/pool"
 )
@@ -17,12 +26,29 @@ func (l *Loader) responseCacheEnabled() bool {
 }
 
 func (l *Loader) reportResponseCacheError(err error) {
-	if l.responseCacheEnabled() && l.ctx.responseCache.onError != nil {
-		l.ctx.responseCache.onError(err)
+	if l.responseCacheEnabled() {
+		l.ctx.responseCache.reportError(err)
 	}
 }
 
-func responseCacheSelectionHash(header, footer []byte) uint64 {
+// responseCacheScope returns the auth key to fold into the keys of a fetch
+// whose @cacheControl hint is PRIVATE, and nil for any other fetch. It returns
+// false when the fetch is not to be cached at all: when there is no cache, or
+// when the fetch is private and the request names no viewer.
+func (l *Loader) responseCacheScope(policy *CacheControlPolicy) ([]byte, bool) {
+	if !l.responseCacheEnabled() {
+		return nil, false
+	}
+	if policy == nil || !policy.Private {
+		return nil, true
+	}
+	if l.ctx.responseCache.authKey == "" {
+		return nil, false
+	}
+	return []byte(l.ctx.responseCache.authKey), true
+}
+
+func responseCacheSelectionHash(header, footer, scope []byte) uint64 {
 	d := pool.Hash64.Get()
 	defer pool.Hash64.Put(d)
 	_, _ = d.Write(header)
@@ -30,9 +56,60 @@ func responseCacheSelectionHash(header, footer []byte) uint64 {
 	// start of the footer cannot go unnoticed.
 	_, _ = d.Write([]byte{0})
 	_, _ = d.Write(footer)
+	if scope != nil {
+		_, _ = d.Write([]byte{1})
+		_, _ = d.Write(scope)
+	}
 	return d.Sum64()
 }
 
+// responseCacheSetKeys sets the cache key of every representation of the
+// fetch. A representation that is not an entity, such as the null rendered
+// for a skipped fetch under tracing, leaves the fetch uncached.
+func responseCacheSetKeys(prepared *preparedFetch, representations [][]byte, selectionHash uint64) {
+	keys := make([]string, len(representations))
+	typeNames := make([]string, len(representations))
+	for i, representation := range representations {
+		typeName, entityHash, err := caching.EntityHash(representation)
+		if err != nil {
+			return
+		}
+		keys[i] = caching.Key(entityHash, selectionHash)
+		typeNames[i] = typeName
+	}
+	prepared.responseCacheKeys = keys
+	prepared.responseCacheTypeNames = typeNames
+	prepared.responseCacheSelectionHash = selectionHash
+}
+
+// responseCacheSetRootKey sets the cache key of a root fetch, which is made
+// of the rendered input and the headers the request to the subgraph carries.
+// Only query fetches are cached, only when their FetchInfo says so, and only
+// for a cache set up WithRootFetches.
+func (l *Loader) responseCacheSetRootKey(fetchItem *FetchItem, info *FetchInfo, prepared *preparedFetch, input []byte) {
+	if info == nil || info.OperationType != ast.OperationTypeQuery {
+		return
+	}
+	scope, ok := l.responseCacheScope(responseCachePolicy(fetchItem))
+	if !ok || !l.ctx.responseCache.rootFetches {
+		return
+	}
+	_, headersHash := l.headersForSubgraphRequest(fetchItem)
+	headers := binary.BigEndian.AppendUint64(nil, headersHash)
+
+	prepared.responseCacheKeys = []string{caching.RootKey(xxhash.Sum64(input), responseCacheSelectionHash(headers, nil, scope))}
+	prepared.responseCacheRoot = true
+}
+
+// responseCacheLookup reports whether the fetch was answered from the cache in
+// full. When only some entities of a batch are cached, it trims the fetch input
+// down to the missing ones and returns false, leaving responseCacheMergePartial
+// to put the cached entities back once the subgraph has answered the rest.
+//
+// Entities past their TTL but within their stale-while-revalidate window count
+// as cached, and are refetched in the background. Those within only their
+// stale-if-error window count as missing, and are kept for
+// responseCacheServeStale in case the fetch that replaces them fails.
 func (l *Loader) responseCacheLookup(prepared *preparedFetch) bool {
 	if !l.responseCacheEnabled() {
 		return false
@@ -48,34 +125,215 @@ func (l *Loader) responseCacheLookup(prepared *preparedFetch) bool {
 		l.reportResponseCacheError(fmt.Errorf("response cache lookup of %d keys: %w", len(keys), err))
 		return false
 	}
-	if len(found) != len(keys) {
+	if len(found) == 0 {
 		return false
 	}
 
-	size := len(entitiesResponsePrefix) + len(entitiesResponseSuffix) + len(keys) - 1
-	for _, key := range keys {
+	cached := make([][]byte, len(keys))
+	var (
+		fallback   [][]byte
+		revalidate []int
+		missing    int
+	)
+	for i, key := range keys {
 		item, ok := found[key]
-		if !ok || len(item.Value) == 0 {
-			return false
+		if !ok {
+			missing++
+			continue
+		}
+		value, freshness := caching.ReadStale(item)
+		if len(value) == 0 {
+			missing++
+			continue
+		}
+		switch freshness {
+		case caching.StaleIfError:
+			if fallback == nil {
+				fallback = make([][]byte, len(keys))
+			}
+			fallback[i] = value
+			missing++
+			continue
+		case caching.StaleWhileRevalidate:
+			revalidate = append(revalidate, i)
+		}
+		cached[i] = value
+	}
+
+	if len(revalidate) > 0 {
+		l.responseCacheRevalidate(prepared, revalidate)
+	}
+
+	if missing > 0 {
+		prepared.responseCacheFallback = responseCacheFallback(cached, fallback)
+		if missing < len(keys) && prepared.responseCacheBatch != nil {
+			l.responseCacheTrim(prepared, cached)
 		}
-		size += len(item.Value)
+		return false
+	}
+
+	res := prepared.res
+	res.out = prepared.responseCacheAnswer(cached)
+	res.statusCode = http.StatusOK
+
+	return true
+}
+
+// responseCacheFallback merges the usable and the stale-if-error entities into
+// the answer to serve should the fetch fail. It is nil unless every entity has
+// one or the other, because a fallback that still misses an entity is no
+// better than the error it would replace.
+func responseCacheFallback(cached, staleIfError [][]byte) [][]byte {
+	if staleIfError == nil {
+		return nil
+	}
+	fallback := make([][]byte, len(cached))
+	for i := range cached {
+		switch {
+		case cached[i] != nil:
+			fallback[i] = cached[i]
+		case staleIfError[i] != nil:
+			fallback[i] = staleIfError[i]
+		default:
+			return nil
+		}
+	}
+	return fallback
+}
+
+// responseCacheServeStale answers a failed fetch from the stale-if-error
+// entities responseCacheLookup kept for it, and reports whether it did. Only a
+// transport error or a 5xx counts as failed, the statuses RFC 5861 names.
+func (l *Loader) responseCacheServeStale(prepared *preparedFetch) bool {
+	fallback := prepared.responseCacheFallback
+	if fallback == nil {
+		return false
+	}
+	res := prepared.res
+	if res.err == nil && res.statusCode < http.StatusInternalServerError {
+		return false
+	}
+
+	res.err = nil
+	res.out = prepared.responseCacheAnswer(fallback)
+	res.statusCode = http.StatusOK
+	res.parsed = nil
+	prepared.responseCacheCached = nil
+	return true
+}
+
+// responseCacheAnswer is the response a subgraph would have given for the
+// cached values: the one cached response of a root fetch as it is, and the
+// entities of an entity fetch wrapped the way entitiesResponse wraps them.
+func (p *preparedFetch) responseCacheAnswer(values [][]byte) []byte {
+	if p.responseCacheRoot {
+		return values[0]
+	}
+	return entitiesResponse(values)
+}
+
+// entitiesResponse renders entities as the _entities response a subgraph would
+// have sent for them.
+func entitiesResponse(entities [][]byte) []byte {
+	size := len(entitiesResponsePrefix) + len(entitiesResponseSuffix) + len(entities) - 1
+	for i := range entities {
+		size += len(entities[i])
 	}
 
 	out := make([]byte, 0, size)
 	out = append(out, entitiesResponsePrefix...)
-	for i, key := range keys {
+	for i := range entities {
 		if i > 0 {
 			out = append(out, ',')
 		}
-		out = append(out, found[key].Value...)
+		out = append(out, entities[i]...)
+	}
+	return append(out, entitiesResponseSuffix...)
+}
+
+// responseCacheTrim renders the batch input again with only the representations
+// that have no cached entity. On failure the fetch keeps its full input, which
+// costs the subgraph the cached entities but is still a correct answer.
+func (l *Loader) responseCacheTrim(prepared *preparedFetch, cached [][]byte) {
+	input, err := prepared.responseCacheBatch.render(cached)
+	if err != nil {
+		l.reportResponseCacheError(fmt.Errorf("response cache partial hit: %w", err))
+		return
+	}
+	prepared.input = input
+	prepared.responseCacheCached = cached
+	prepared.res.cacheHit = true
+}
+
+// responseCacheMergePartial rebuilds a trimmed batch's answer into the one the
+// untrimmed fetch would have had: the fetched entities and the cached ones back
+// in the original _entities order, with the subgraph's error paths pointed at
+// the positions their entities now occupy. A response without an _entities
+// array of the trimmed length is left alone for mergeResult to report.
+func (l *Loader) responseCacheMergePartial(prepared *preparedFetch) error {
+	cached := prepared.responseCacheCached
+	if cached == nil {
+		return nil
 	}
-	out = append(out, entitiesResponseSuffix...)
 
 	res := prepared.res
-	res.out = out
-	res.statusCode = http.StatusOK
+	if res.err != nil || len(res.out) == 0 {
+		return nil
+	}
 
-	return true
+	response, err := res.parsedResponse(l)
+	if err != nil {
+		return nil //nolint:nilerr // The parse error is reported by mergeResult.
+	}
+
+	data := response.Get("data")
+	entities := response.Get("data", "_entities")
+	if entities == nil || entities.Type() != astjson.TypeArray {
+		return nil
+	}
+	fetched := entities.GetArray()
+
+	// positions maps an index of the trimmed response to the one it answers
+	// in the untrimmed batch.
+	positions := make([]int, 0, len(fetched))
+	for i := range cached {
+		if cached[i] == nil {
+			positions = append(positions, i)
+		}
+	}
+	if len(fetched) != len(positions) {
+		return nil
+	}
+
+	merged := astjson.ArrayValue(l.jsonArena)
+	for i := range cached {
+		if cached[i] == nil {
+			continue
+		}
+		value, err := astjson.ParseBytesWithArena(l.jsonArena, cached[i])
+		if err != nil {
+			return fmt.Errorf("cached entity %d: %w", i, err)
+		}
+		merged.SetArrayItem(l.jsonArena, i, value)
+	}
+	for i, value := range fetched {
+		merged.SetArrayItem(l.jsonArena, positions[i], value)
+	}
+	data.Set(l.jsonArena, "_entities", merged)
+
+	for _, responseError := range response.GetArray(responseCacheErrorsPath(res)...) {
+		path := responseError.GetArray("path")
+		if len(path) < 2 || string(path[0].GetStringBytes()) != "_entities" || path[1].Type() != astjson.TypeNumber {
+			continue
+		}
+		index := path[1].GetInt()
+		if index < 0 || index >= len(positions) {
+			continue
+		}
+		responseError.Get("path").SetArrayItem(l.jsonArena, 1, astjson.IntValue(l.jsonArena, positions[index]))
+	}
+
+	return nil
 }
 
 func (l *Loader) responseCacheCollect(prepared *preparedFetch) error {
@@ -103,45 +361,307 @@ func (l *Loader) responseCacheCollect(prepared *preparedFetch) error {
 		return fmt.Errorf("parse error: %w", err)
 	}
 
-	errorsPath := res.postProcessing.SelectResponseErrorsPath
-	if errorsPath == nil {
-		errorsPath = defaultResponseCacheErrorsPath
+	items, err := l.ctx.responseCache.responseItems(prepared.responseCacheRoot, response, responseCacheErrorsPath(res),
+		prepared.responseCacheKeys, prepared.responseCacheCached, responseCacheHeaders(res), responseCachePolicy(prepared.item))
+	if err != nil {
+		return err
 	}
 
-	if errs := response.Get(errorsPath...); astjson.ValueIsNonNull(errs) && len(errs.GetArray()) > 0 {
+	prepared.responseCacheItems = items
+	return nil
+}
+
+// responseCachePolicy returns the @cacheControl policy the planner folded
+// from the fields of the fetch, or nil if none of them carried a hint.
+func responseCachePolicy(fetchItem *FetchItem) *CacheControlPolicy {
+	if fetchItem == nil {
+		return nil
+	}
+	switch fetch := fetchItem.Fetch.(type) {
+	case *SingleFetch:
+		return fetch.CacheControl
+	case *EntityFetch:
+		return fetch.CacheControl
+	case *BatchEntityFetch:
+		return fetch.CacheControl
+	default:
 		return nil
 	}
+}
+
+// ttl is how long a response with the given headers may be cached. A policy
+// with a maxAge caches it for that long without the subgraph opting in, while
+// without one the subgraph has to mark the response public.
+func (c *responseCache) ttl(headers http.Header, policy *CacheControlPolicy) (time.Duration, bool) {
+	if policy != nil && policy.MaxAge != nil {
+		return caching.HintedTTL(headers, *policy.MaxAge)
+	}
+	return caching.TTL(headers, c.defaultTTL)
+}
 
-	ttl, ok := caching.TTL(responseCacheHeaders(res), l.ctx.responseCache.defaultTTL)
+// responseItems builds the entries for a response: rootItems for the response
+// of a root fetch, and entityItems for the _entities response of any other.
+func (c *responseCache) responseItems(root bool, response *astjson.Value, errorsPath, keys []string, skip [][]byte, headers http.Header, policy *CacheControlPolicy) ([]caching.Item, error) {
+	if root {
+		return c.rootItems(response, errorsPath, keys, headers, policy)
+	}
+	return c.entityItems(response, errorsPath, keys, skip, headers, policy)
+}
+
+// rootItems builds the single entry of a root fetch, which holds the whole
+// response. A response carrying errors, one without data, or one its headers
+// and policy do not allow to be cached, yields no entry.
+func (c *responseCache) rootItems(response *astjson.Value, errorsPath, keys []string, headers http.Header, policy *CacheControlPolicy) ([]caching.Item, error) {
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("unexpected number of root fetch keys %d", len(keys))
+	}
+	if errs := response.Get(errorsPath...); astjson.ValueIsNonNull(errs) && len(errs.GetArray()) > 0 {
+		return nil, nil
+	}
+	if data := response.Get("data"); data == nil || data.Type() != astjson.TypeObject {
+		return nil, nil
+	}
+
+	ttl, ok := c.ttl(headers, policy)
 	if !ok {
-		return nil
+		return nil, nil
+	}
+	whileRevalidate, ifError := caching.StaleWindows(headers, c.staleWhileRevalidate, c.staleIfError)
+
+	return []caching.Item{caching.StaleItem(keys[0], response.MarshalTo(nil), ttl, whileRevalidate, ifError)}, nil
+}
+
+// entityItems builds an entry for every entity of an _entities response that
+// is an object and whose entry in skip is nil. A response carrying errors, or
+// one its headers and policy do not allow to be cached, yields no entries at
+// all.
+func (c *responseCache) entityItems(response *astjson.Value, errorsPath, keys []string, skip [][]byte, headers http.Header, policy *CacheControlPolicy) ([]caching.Item, error) {
+	if errs := response.Get(errorsPath...); astjson.ValueIsNonNull(errs) && len(errs.GetArray()) > 0 {
+		return nil, nil
 	}
 
+	ttl, ok := c.ttl(headers, policy)
+	if !ok {
+		return nil, nil
+	}
+	whileRevalidate, ifError := caching.StaleWindows(headers, c.staleWhileRevalidate, c.staleIfError)
+
 	entities := response.Get("data", "_entities")
 	if entities == nil || entities.Type() != astjson.TypeArray {
-		return fmt.Errorf("_entities not found or invalid type")
+		return nil, fmt.Errorf("_entities not found or invalid type")
 	}
 	values := entities.GetArray()
 
 	// In case the entity does not exist on the foreign key we should get null in place
-	if len(values) != len(prepared.responseCacheKeys) {
-		return fmt.Errorf("unexpected number of _entities values found %d", len(values))
+	if len(values) != len(keys) {
+		return nil, fmt.Errorf("unexpected number of _entities values found %d", len(values))
 	}
 
-	items := make([]caching.Item, 0, len(prepared.responseCacheKeys))
+	items := make([]caching.Item, 0, len(keys))
 	for i, value := range values {
 		if value.Type() != astjson.TypeObject {
 			continue
 		}
-		items = append(items, caching.Item{
-			Key:   prepared.responseCacheKeys[i],
-			Value: value.MarshalTo(nil),
-			TTL:   ttl,
-		})
+		if skip != nil && skip[i] != nil {
+			// Served from the cache on a partial hit. Writing it back would
+			// only stretch its TTL past what the subgraph gave it.
+			continue
+		}
+		items = append(items, caching.StaleItem(keys[i], value.MarshalTo(nil), ttl, whileRevalidate, ifError))
 	}
 
-	prepared.responseCacheItems = items
-	return nil
+	return items, nil
+}
+
+// responseCacheRevalidations holds the keys of every background revalidation
+// of a Resolver in flight, so that entities served stale to many requests at
+// once are only refetched once.
+type responseCacheRevalidations struct {
+	inFlight sync.Map
+}
+
+// start returns whether a revalidation of the given keys may start, which it
+// may not while another one is in flight. A nil set does not deduplicate.
+func (r *responseCacheRevalidations) start(flight string) bool {
+	if r == nil {
+		return true
+	}
+	_, inFlight := r.inFlight.LoadOrStore(flight, struct{}{})
+	return !inFlight
+}
+
+func (r *responseCacheRevalidations) done(flight string) {
+	if r == nil {
+		return
+	}
+	r.inFlight.Delete(flight)
+}
+
+// responseCacheRevalidate refetches the entities at the given indices in the
+// background and writes them back, leaving the fetch at hand to be answered
+// from their stale entries. The refetch outlives the request, so it captures
+// everything it needs up front, see revalidationLoader, and reports its errors
+// only through the cache's onError.
+func (l *Loader) responseCacheRevalidate(prepared *preparedFetch, indices []int) {
+	keys := prepared.responseCacheKeys
+	input := prepared.input
+	if len(indices) < len(keys) {
+		if prepared.responseCacheBatch == nil {
+			return
+		}
+		skip := make([][]byte, len(keys))
+		for i := range skip {
+			skip[i] = responseCacheSkip
+		}
+		for _, i := range indices {
+			skip[i] = nil
+		}
+		var err error
+		input, err = prepared.responseCacheBatch.render(skip)
+		if err != nil {
+			l.reportResponseCacheError(fmt.Errorf("response cache revalidation: %w", err))
+			return
+		}
+		subset := make([]string, len(indices))
+		for j, i := range indices {
+			subset[j] = keys[i]
+		}
+		keys = subset
+	}
+
+	flight := strings.Join(keys, ",")
+	revalidations := l.responseCacheRevalidations
+	if !revalidations.start(flight) {
+		return
+	}
+
+	cache := l.ctx.responseCache
+	index := l.responseCacheIndex
+	typeNames, selectionHash := prepared.responseCacheTypeNames, prepared.responseCacheSelectionHash
+	root := prepared.responseCacheRoot
+	errorsPath := responseCacheErrorsPath(prepared.res)
+	policy := responseCachePolicy(prepared.item)
+	timeout := cache.revalidationTimeout
+	if timeout <= 0 {
+		timeout = l.dataSourceFetchTimeout(prepared.res)
+	}
+	if timeout <= 0 {
+		timeout = DefaultResponseCacheRevalidationTimeout
+	}
+	loader := l.revalidationLoader(prepared.item)
+	refetch := &preparedFetch{
+		item:   prepared.item,
+		source: prepared.source,
+		input:  input,
+		res: &result{
+			ds:        prepared.res.ds,
+			fetchKind: prepared.res.fetchKind,
+		},
+	}
+
+	go func() {
+		defer revalidations.done(flight)
+
+		ctx, cancel := context.WithTimeout(loader.ctx.ctx, timeout)
+		defer cancel()
+		loader.loadWithRetries(ctx, refetch)
+		res := refetch.res
+		defer loader.callOnFinished(res)
+		if res.err != nil {
+			cache.reportError(fmt.Errorf("response cache revalidation of %d entities: %w", len(keys), res.err))
+			return
+		}
+		if len(res.out) == 0 || res.statusCode >= 400 {
+			return
+		}
+		response, err := astjson.ParseBytes(res.out)
+		if err != nil {
+			cache.reportError(fmt.Errorf("response cache revalidation parse error: %w", err))
+			return
+		}
+		var responseHeaders http.Header
+		if res.httpResponseContext != nil && res.httpResponseContext.Response != nil {
+			responseHeaders = res.httpResponseContext.Response.Header
+		}
+		items, err := cache.responseItems(root, response, errorsPath, keys, nil, responseHeaders, policy)
+		if err != nil {
+			cache.reportError(fmt.Errorf("response cache revalidation collect error: %w", err))
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		index.record(typeNames, selectionHash, longestTTL(items))
+		if err := cache.store.SetMany(ctx, items); err != nil {
+			cache.reportError(fmt.Errorf("response cache write of %d revalidated entities: %w", len(items), err))
+		}
+	}()
+}
+
+// revalidationLoader returns a Loader for a background revalidation, so that it
+// is loaded the way any fetch is: through the circuit breaker, retries and
+// timeouts of the data source, the LoaderHooks, and with the request's
+// extensions. It shares the Resolver's state with l, but copies what it reads of
+// the request Context, which is freed once the request is done, including the
+// headers for the data source. It is bound by the revalidation timeout instead of
+// the operation's budget, and is not traced.
+func (l *Loader) revalidationLoader(item *FetchItem) *Loader {
+	header, hash := l.headersForSubgraphRequest(item)
+	requestCtx := &Context{
+		ctx:                    context.WithoutCancel(l.ctx.ctx),
+		ExecutionOptions:       l.ctx.ExecutionOptions,
+		Extensions:             bytes.Clone(l.ctx.Extensions),
+		LoaderHooks:            l.ctx.LoaderHooks,
+		SubgraphHeadersBuilder: revalidationHeaders{header: header.Clone(), hash: hash},
+	}
+	var info *GraphQLResponseInfo
+	if l.info != nil {
+		info = &GraphQLResponseInfo{OperationType: l.info.OperationType}
+	}
+	return &Loader{
+		ctx:                       requestCtx,
+		info:                      info,
+		propagateFetchReasons:     l.propagateFetchReasons,
+		retryPolicyDefault:        l.retryPolicyDefault,
+		dataSourceRetryPolicies:   l.dataSourceRetryPolicies,
+		fetchTimeout:              l.fetchTimeout,
+		dataSourceFetchTimeouts:   l.dataSourceFetchTimeouts,
+		hedgingPolicyDefault:      l.hedgingPolicyDefault,
+		dataSourceHedgingPolicies: l.dataSourceHedgingPolicies,
+		hedgingLatencies:          l.hedgingLatencies,
+		circuitBreakers:           l.circuitBreakers,
+		singleFlight:              l.singleFlight,
+	}
+}
+
+// revalidationHeaders is the SubgraphHeadersBuilder of a revalidation Loader. It
+// answers with the headers the request had for the data source revalidated.
+type revalidationHeaders struct {
+	header http.Header
+	hash   uint64
+}
+
+func (h revalidationHeaders) HeadersForSubgraph(string) (http.Header, uint64) {
+	return h.header, h.hash
+}
+
+func (h revalidationHeaders) HashAll() uint64 {
+	return h.hash
+}
+
+func longestTTL(items []caching.Item) time.Duration {
+	var ttl time.Duration
+	for _, item := range items {
+		ttl = max(ttl, item.TTL)
+	}
+	return ttl
+}
+
+func responseCacheErrorsPath(res *result) []string {
+	if res.postProcessing.SelectResponseErrorsPath != nil {
+		return res.postProcessing.SelectResponseErrorsPath
+	}
+	return defaultResponseCacheErrorsPath
 }
 
 // responseCacheFlush writes what responseCacheCollect gathered. It is called with
@@ -158,11 +678,65 @@ func (l *Loader) responseCacheFlush(prepared *preparedFetch) {
 	items := prepared.responseCacheItems
 	prepared.responseCacheItems = nil
 
+	l.responseCacheIndex.record(prepared.responseCacheTypeNames, prepared.responseCacheSelectionHash, longestTTL(items))
 	if err := l.ctx.responseCache.store.SetMany(l.ctx.ctx, items); err != nil {
 		l.reportResponseCacheError(fmt.Errorf("response cache write of %d entities: %w", len(items), err))
 	}
 }
 
+// responseCacheBatchInput is a rendered batch entity fetch input taken apart
+// into the pieces it was rendered from. Every piece is a copy, because the
+// buffer it was taken from is rewritten in place once undefined variables are
+// set.
+type responseCacheBatchInput struct {
+	header             []byte
+	separator          []byte
+	items              [][]byte
+	footer             []byte
+	undefinedVariables []string
+}
+
+// newResponseCacheBatchInput splits rendered at the given offsets. A batch of
+// a single representation is either cached or not, so it is never split.
+func newResponseCacheBatchInput(rendered []byte, headerEnd, footerStart int, itemBounds [][2]int, undefinedVariables []string) *responseCacheBatchInput {
+	if len(itemBounds) < 2 {
+		return nil
+	}
+	b := &responseCacheBatchInput{
+		header:             bytes.Clone(rendered[:headerEnd]),
+		separator:          bytes.Clone(rendered[itemBounds[0][1]:itemBounds[1][0]]),
+		items:              make([][]byte, len(itemBounds)),
+		footer:             bytes.Clone(rendered[footerStart:]),
+		undefinedVariables: slices.Clone(undefinedVariables),
+	}
+	for i, bounds := range itemBounds {
+		b.items[i] = bytes.Clone(rendered[bounds[0]:bounds[1]])
+	}
+	return b
+}
+
+// render assembles the input again from every item whose entry in skip is nil.
+func (b *responseCacheBatchInput) render(skip [][]byte) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, len(b.header)+len(b.footer)))
+	buf.Write(b.header)
+	addSeparator := false
+	for i, item := range b.items {
+		if skip[i] != nil {
+			continue
+		}
+		if addSeparator {
+			buf.Write(b.separator)
+		}
+		buf.Write(item)
+		addSeparator = true
+	}
+	buf.Write(b.footer)
+	if err := SetInputUndefinedVariables(buf, b.undefinedVariables); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
 func responseCacheHeaders(res *result) http.Header {
 	if res.httpResponseContext == nil || res.httpResponseContext.Response == nil {
 		return nil
@@ -174,4 +748,6 @@ var (
 	entitiesResponsePrefix         = []byte(`{"data":{"_entities":[`)
 	entitiesResponseSuffix         = []byte(`]}}`)
 	defaultResponseCacheErrorsPath = []string{"errors"}
+	// responseCacheSkip marks an entity as left out of a rendered batch input.
+	responseCacheSkip = []byte{}
 )
@@ -0,0 +1,145 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/caching"
+)
+
+var ErrResponseCacheNotDeletable = errors.New("response cache does not implement caching.Deleter")
+
+// minIndexSweepSize is the number of selection hashes the responseCacheIndex
+// grows to before the expired ones are swept from it.
+const minIndexSweepSize = 1024
+
+// responseCacheIndex remembers, for every entity typename, the selection
+// hashes its entities have been cached under. A cache key is made of an
+// entity hash and a selection hash, and while the first can be computed from
+// a typename and a @key, the second depends on the operation that fetched
+// the entity, so InvalidateEntity has to look the second up here.
+//
+// A selection hash whose entries have all expired is swept as the index grows,
+// so the index holds no more than twice the selection hashes that are still
+// cached, or minIndexSweepSize.
+type responseCacheIndex struct {
+	mu         sync.Mutex
+	selections map[string]map[uint64]time.Time
+	// size is the number of selection hashes over all typenames.
+	size    int
+	sweepAt int
+	now     func() time.Time
+}
+
+func newResponseCacheIndex() *responseCacheIndex {
+	return &responseCacheIndex{
+		selections: make(map[string]map[uint64]time.Time),
+		sweepAt:    minIndexSweepSize,
+		now:        time.Now,
+	}
+}
+
+// record notes that entities of the given typenames are about to be written
+// under selectionHash, the longest lived of them with the given TTL. It is
+// called before the write, so that an invalidation racing the write cannot
+// miss the entry.
+func (x *responseCacheIndex) record(typeNames []string, selectionHash uint64, ttl time.Duration) {
+	if x == nil || len(typeNames) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := x.now()
+	expiresAt := now.Add(ttl)
+	for _, typeName := range typeNames {
+		selections, ok := x.selections[typeName]
+		if !ok {
+			selections = make(map[uint64]time.Time)
+			x.selections[typeName] = selections
+		}
+		previous, ok := selections[selectionHash]
+		if !ok {
+			x.size++
+		}
+		if expiresAt.After(previous) {
+			selections[selectionHash] = expiresAt
+		}
+	}
+	if x.size >= x.sweepAt {
+		x.sweep(now)
+	}
+}
+
+// sweep deletes the selection hashes whose entries have all expired.
+func (x *responseCacheIndex) sweep(now time.Time) {
+	for typeName := range x.selections {
+		x.expire(typeName, now)
+	}
+	x.sweepAt = max(minIndexSweepSize, 2*x.size)
+}
+
+// expire deletes the selection hashes of typeName whose entries have all
+// expired.
+func (x *responseCacheIndex) expire(typeName string, now time.Time) {
+	selections := x.selections[typeName]
+	for hash, expiresAt := range selections {
+		if !expiresAt.After(now) {
+			delete(selections, hash)
+			x.size--
+		}
+	}
+	if len(selections) == 0 {
+		delete(x.selections, typeName)
+	}
+}
+
+// selectionsOf returns the selection hashes entities of typeName may still be
+// cached under, dropping the ones whose entries have all expired.
+func (x *responseCacheIndex) selectionsOf(typeName string) []uint64 {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.expire(typeName, x.now())
+	selections := x.selections[typeName]
+	hashes := make([]uint64, 0, len(selections))
+	for hash := range selections {
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
+
+// InvalidateEntity deletes the response cache entries of the entity of the
+// given typename whose @key fields are the JSON object key, e.g. {"upc":"1"},
+// under every selection set it has been cached with. cache is the one handed
+// to SetResponseCache, and it must implement caching.Deleter.
+//
+// Only selections cached through this resolver are known to it, so a cache
+// shared between several processes needs the invalidation on each of them.
+// Entities whose representations carry @requires fields beside the key are
+// not found, see caching.EntityKeyHash.
+func (r *Resolver) InvalidateEntity(ctx context.Context, cache caching.Cache, typeName string, key []byte) error {
+	deleter, ok := cache.(caching.Deleter)
+	if !ok {
+		return ErrResponseCacheNotDeletable
+	}
+	entityHash, err := caching.EntityKeyHash(typeName, key)
+	if err != nil {
+		return err
+	}
+	selections := r.responseCacheIndex.selectionsOf(typeName)
+	if len(selections) == 0 {
+		return nil
+	}
+	keys := make([]string, len(selections))
+	for i, selectionHash := range selections {
+		keys[i] = caching.Key(entityHash, selectionHash)
+	}
+	if err := deleter.Delete(ctx, keys); err != nil {
+		return fmt.Errorf("response cache invalidation of %s: %w", typeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package resolve
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCacheIndex(t *testing.T) {
+	newTestIndex := func() (*responseCacheIndex, func(time.Duration)) {
+		index := newResponseCacheIndex()
+		now := time.Unix(0, 0)
+		index.now = func() time.Time { return now }
+		return index, func(d time.Duration) { now = now.Add(d) }
+	}
+
+	t.Run("expired selections are not returned", func(t *testing.T) {
+		index, advance := newTestIndex()
+
+		index.record([]string{"Product"}, 1, time.Second)
+		index.record([]string{"Product"}, 2, time.Minute)
+		advance(2 * time.Second)
+
+		assert.Equal(t, []uint64{2}, index.selectionsOf("Product"))
+		assert.Equal(t, 1, index.size)
+	})
+
+	t.Run("the index is bounded without invalidations", func(t *testing.T) {
+		index, advance := newTestIndex()
+
+		for i := range 10 * minIndexSweepSize {
+			index.record([]string{"Product", "Review"}, uint64(i), time.Second)
+			advance(time.Second)
+		}
+
+		size := 0
+		for _, selections := range index.selections {
+			size += len(selections)
+		}
+		assert.Equal(t, index.size, size)
+		assert.LessOrEqual(t, size, minIndexSweepSize)
+	})
+
+	t.Run("selections that are still cached are kept", func(t *testing.T) {
+		index, _ := newTestIndex()
+
+		for i := range 2 * minIndexSweepSize {
+			index.record([]string{"Product"}, uint64(i), time.Minute)
+		}
+
+		assert.Len(t, index.selectionsOf("Product"), 2*minIndexSweepSize)
+	})
+}
@@ -0,0 +1,212 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/tidwall/gjson"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+)
+
+// RetryPolicy retries a failed query fetch with exponential backoff. Mutation
+// fetches are never retried, whatever the policy says, because a mutation that
+// failed on the way back may well have been applied.
+type RetryPolicy struct {
+	// MaxAttempts is the number of attempts including the first one. A value of
+	// one or less disables retries.
+	MaxAttempts int
+	// InitialBackoff is the wait before the second attempt. Every further wait is
+	// Multiplier times the one before, capped at MaxBackoff.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between two attempts. Zero leaves it uncapped.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait between attempts. Values below one mean 2.
+	Multiplier float64
+	// Jitter is the fraction of every wait that is randomized, between 0 and 1,
+	// so that the clients of a subgraph that failed at once do not all come
+	// back at once. 0.2 waits anywhere between 80% and 100% of the backoff.
+	Jitter float64
+	// RetryOn decides whether an attempt failed in a way worth retrying. Nil
+	// means DefaultRetryCondition.
+	RetryOn RetryCondition
+}
+
+// RetryCondition reports whether an attempt should be retried, given the status
+// code and body the subgraph answered with, or the error the fetch failed with.
+type RetryCondition func(statusCode int, err error, response []byte) bool
+
+// DefaultRetryCondition retries network errors, the 502, 503 and 504 statuses
+// of a gateway in front of the subgraph that could not reach it, and a gRPC
+// subgraph that answered UNAVAILABLE without any data.
+func DefaultRetryCondition(statusCode int, err error, response []byte) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch statusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return grpcUnavailable(response)
+}
+
+// grpcUnavailable reports whether response is the error response the gRPC
+// datasource renders for a call that failed with UNAVAILABLE.
+func grpcUnavailable(response []byte) bool {
+	if len(response) == 0 {
+		return false
+	}
+	if data := gjson.GetBytes(response, "data"); data.Exists() && data.Type != gjson.Null {
+		return false
+	}
+	for _, code := range gjson.GetBytes(response, "errors.#.extensions.code").Array() {
+		if code.String() == "Unavailable" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryable(res *result) bool {
+	retryOn := p.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryCondition
+	}
+	return retryOn(res.statusCode, res.err, res.out)
+}
+
+// backoff returns how long to wait after the given attempt failed.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	backoff := float64(p.InitialBackoff)
+	for range attempt - 1 {
+		backoff *= multiplier
+		if p.MaxBackoff > 0 && backoff >= float64(p.MaxBackoff) {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 {
+		backoff = min(backoff, float64(p.MaxBackoff))
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		backoff -= backoff * jitter * rand.Float64()
+	}
+	return time.Duration(backoff)
+}
+
+// retryPolicy returns the policy for the fetch: the one configured for its data
+// source, otherwise the resolver-wide one, and nil for anything but a query.
+func (l *Loader) retryPolicy(fetchItem *FetchItem, res *result) *RetryPolicy {
+	if fetchItem == nil || fetchItem.Fetch == nil {
+		return nil
+	}
+	info := fetchItem.Fetch.FetchInfo()
+	if info == nil || info.OperationType != ast.OperationTypeQuery {
+		return nil
+	}
+	policy := l.retryPolicyDefault
+	if override, ok := l.dataSourceRetryPolicies[res.ds.ID]; ok {
+		policy = override
+	}
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return nil
+	}
+	return policy
+}
+
+// loadWithRetries runs executeSourceLoad until an attempt succeeds, fails in a
+// way the retry policy does not retry, or the policy runs out of attempts. The
+// result of the last attempt is left in prepared.res.
+//
+// Every attempt is reported to the LoaderHooks: a retried attempt gets its
+// OnFinished here, right when it failed, and the last one the usual way once
+// it has been merged.
+//
+// Every attempt also has to get past the circuit breaker of the data source,
+// and to find some of the operation's budget left. A first attempt turned away
+// fails with ErrCircuitBreakerOpen or a FetchTimeoutError, and a retry turned
+// away leaves the attempt before it as the answer.
+func (l *Loader) loadWithRetries(ctx context.Context, prepared *preparedFetch) {
+	res := prepared.res
+	policy := l.retryPolicy(prepared.item, res)
+	var breaker *circuitBreaker
+	if l.circuitBreakers != nil {
+		breaker = l.circuitBreakers.get(res.ds)
+	}
+	for attempt := 1; ; attempt++ {
+		if err := l.operationDeadlineExceeded(prepared); err != nil {
+			if attempt == 1 {
+				res.err = err
+			}
+			return
+		}
+		if breaker != nil && !breaker.allow() {
+			if attempt == 1 {
+				res.err = ErrCircuitBreakerOpen
+			}
+			return
+		}
+		if attempt > 1 {
+			res.err = nil
+			res.out = nil
+			res.statusCode = 0
+			res.httpResponseContext = nil
+			res.parsed = nil
+			res.hedged = false
+		}
+		res.attempt = attempt
+		l.loadWithTimeout(ctx, prepared)
+		if breaker != nil {
+			breaker.done(res)
+		}
+		if policy == nil || attempt >= policy.MaxAttempts || !policy.retryable(res) {
+			return
+		}
+
+		backoff := policy.backoff(attempt)
+		if budget, ok := l.operationBudget(); ok && budget <= backoff {
+			return
+		}
+		if prepared.trace != nil {
+			prepared.trace.Retries = append(prepared.trace.Retries, l.retryTrace(res, backoff))
+		}
+		l.callOnFinished(res)
+		res.loaderHookContext = nil
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *Loader) retryTrace(res *result, backoff time.Duration) DataSourceLoadRetry {
+	retry := DataSourceLoadRetry{
+		Attempt:    res.attempt,
+		StatusCode: res.statusCode,
+	}
+	if res.err != nil {
+		retry.Error = res.err.Error()
+	}
+	if l.ctx.TracingOptions.EnablePredictableDebugTimings {
+		backoff = 1
+	}
+	retry.BackoffNano = int64(backoff)
+	retry.BackoffPretty = backoff.String()
+	return retry
+}
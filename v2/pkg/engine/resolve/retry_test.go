@@ -0,0 +1,214 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+)
+
+// scriptedAttempt is one answer of a scriptedDataSource.
+type scriptedAttempt struct {
+	status   int
+	response string
+	err      error
+}
+
+// scriptedDataSource answers every Load with the next attempt of its script,
+// and with the last one once the script has run out.
+type scriptedDataSource struct {
+	mu     sync.Mutex
+	script []scriptedAttempt
+	calls  int
+}
+
+func (s *scriptedDataSource) Load(ctx context.Context, _ http.Header, _ []byte) ([]byte, error) {
+	s.mu.Lock()
+	attempt := s.script[min(s.calls, len(s.script)-1)]
+	s.calls++
+	s.mu.Unlock()
+
+	if rc := httpclient.GetResponseContext(ctx); rc != nil {
+		rc.StatusCode = attempt.status
+	}
+	return []byte(attempt.response), attempt.err
+}
+
+func (s *scriptedDataSource) LoadWithFiles(ctx context.Context, headers http.Header, input []byte, _ []*httpclient.FileUpload) ([]byte, error) {
+	return s.Load(ctx, headers, input)
+}
+
+type attemptRecordingHooks struct {
+	mu       sync.Mutex
+	loads    int
+	attempts []int
+}
+
+func (h *attemptRecordingHooks) OnLoad(ctx context.Context, _ DataSourceInfo) context.Context {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loads++
+	return ctx
+}
+
+func (h *attemptRecordingHooks) OnFinished(_ context.Context, _ DataSourceInfo, info *ResponseInfo) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.attempts = append(h.attempts, info.Attempt)
+}
+
+// resolveSingleFetch resolves a query or mutation of one field, name, that a
+// single fetch from ds answers.
+func resolveSingleFetch(t *testing.T, options ResolverOptions, operationType ast.OperationType, ds DataSource, ctx *Context) (string, *SingleFetch) {
+	t.Helper()
+	options.MaxConcurrency = 1
+	return resolveSingleFetchWith(t, New(t.Context(), options), operationType, ds, ctx)
+}
+
+// resolveSingleFetchWith is resolveSingleFetch against a resolver that is kept
+// across calls.
+func resolveSingleFetchWith(t *testing.T, r *Resolver, operationType ast.OperationType, ds DataSource, ctx *Context) (string, *SingleFetch) {
+	t.Helper()
+	fetch := &SingleFetch{
+		InputTemplate: InputTemplate{
+			Segments: []TemplateSegment{{SegmentType: StaticSegmentType, Data: []byte(`{"method":"POST","url":"http://users","body":{"query":"{name}"}}`)}},
+		},
+		FetchConfiguration: FetchConfiguration{
+			DataSource: ds,
+			PostProcessing: PostProcessingConfiguration{
+				SelectResponseDataPath:   []string{"data"},
+				SelectResponseErrorsPath: []string{"errors"},
+			},
+		},
+		Info: &FetchInfo{DataSourceID: "0", DataSourceName: "users", OperationType: operationType},
+	}
+	response := &GraphQLResponse{
+		Info:    &GraphQLResponseInfo{OperationType: operationType},
+		Fetches: SingleWithPath(fetch, "query"),
+		Data: &Object{
+			Fields: []*Field{{Name: []byte("name"), Value: &String{Path: []string{"name"}, Nullable: true}}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	_, err := r.ResolveGraphQLResponse(ctx, response, nil, buf)
+	require.NoError(t, err)
+	return buf.String(), fetch
+}
+
+func TestLoader_Retries(t *testing.T) {
+	const ok = `{"data":{"name":"Jens"}}`
+	unavailable := scriptedAttempt{status: http.StatusServiceUnavailable, response: `{"errors":[{"message":"unavailable"}]}`}
+	policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	t.Run("a transient failure is retried until it succeeds", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable, {status: http.StatusOK, response: ok}}}
+		hooks := &attemptRecordingHooks{}
+		ctx := NewContext(t.Context())
+		ctx.LoaderHooks = hooks
+		ctx.TracingOptions = TraceOptions{Enable: true, ExcludeLoadStats: true, EnablePredictableDebugTimings: true}
+
+		out, fetch := resolveSingleFetch(t, ResolverOptions{RetryPolicy: policy}, ast.OperationTypeQuery, ds, ctx)
+
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, out)
+		assert.Equal(t, 2, ds.calls)
+		assert.Equal(t, 2, hooks.loads)
+		assert.Equal(t, []int{1, 2}, hooks.attempts, "every attempt is reported")
+		require.NotNil(t, fetch.Trace)
+		assert.Equal(t, []DataSourceLoadRetry{{Attempt: 1, StatusCode: http.StatusServiceUnavailable, BackoffNano: 1, BackoffPretty: "1ns"}}, fetch.Trace.Retries)
+	})
+
+	t.Run("the last attempt is the answer once the policy gives up", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable}}
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{RetryPolicy: policy}, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 3, ds.calls)
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'users'`)
+	})
+
+	t.Run("an error that is not transient is not retried", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{{status: http.StatusBadRequest, response: `{"errors":[{"message":"bad"}]}`}}}
+
+		resolveSingleFetch(t, ResolverOptions{RetryPolicy: policy}, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 1, ds.calls)
+	})
+
+	t.Run("a mutation is never retried", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable, {status: http.StatusOK, response: ok}}}
+
+		resolveSingleFetch(t, ResolverOptions{RetryPolicy: policy}, ast.OperationTypeMutation, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 1, ds.calls)
+	})
+
+	t.Run("a data source can opt out", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable, {status: http.StatusOK, response: ok}}}
+
+		resolveSingleFetch(t, ResolverOptions{RetryPolicy: policy, DataSourceRetryPolicies: map[string]*RetryPolicy{"0": nil}},
+			ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, 1, ds.calls)
+	})
+
+	t.Run("a data source can opt in", func(t *testing.T) {
+		ds := &scriptedDataSource{script: []scriptedAttempt{unavailable, {status: http.StatusOK, response: ok}}}
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{DataSourceRetryPolicies: map[string]*RetryPolicy{"0": policy}},
+			ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, `{"data":{"name":"Jens"}}`, out)
+		assert.Equal(t, 2, ds.calls)
+	})
+}
+
+func TestDefaultRetryCondition(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		status   int
+		err      error
+		response string
+		want     bool
+	}{
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}, want: true},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "other error", err: errors.New("invalid input"), want: false},
+		{name: "502", status: http.StatusBadGateway, want: true},
+		{name: "503", status: http.StatusServiceUnavailable, want: true},
+		{name: "504", status: http.StatusGatewayTimeout, want: true},
+		{name: "500", status: http.StatusInternalServerError, want: false},
+		{name: "grpc unavailable", status: http.StatusOK, response: `{"errors":[{"message":"down","extensions":{"code":"Unavailable"}}]}`, want: true},
+		{name: "grpc unavailable with data", status: http.StatusOK, response: `{"data":{"a":1},"errors":[{"message":"down","extensions":{"code":"Unavailable"}}]}`, want: false},
+		{name: "grpc internal", status: http.StatusOK, response: `{"errors":[{"message":"boom","extensions":{"code":"Internal"}}]}`, want: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, DefaultRetryCondition(tc.status, tc.err, []byte(tc.response)))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(4), "capped at MaxBackoff")
+	assert.Equal(t, 50*time.Millisecond, policy.backoff(40))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		backoff := policy.backoff(1)
+		assert.GreaterOrEqual(t, backoff, 5*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 10*time.Millisecond)
+	}
+}
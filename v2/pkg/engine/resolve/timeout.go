@@ -0,0 +1,93 @@
+package resolve
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+)
+
+// startOperationBudget starts the clock of ResolverOptions.OperationTimeout. A
+// deferred part of the operation resolves with the same Context, and so keeps
+// the deadline the first part started. Subscriptions have no budget, their
+// fetches are bounded by MaxSubscriptionFetchTimeout instead.
+func (l *Loader) startOperationBudget() {
+	if l.operationTimeout <= 0 || !l.ctx.operationDeadline.IsZero() {
+		return
+	}
+	if l.info != nil && l.info.OperationType == ast.OperationTypeSubscription {
+		return
+	}
+	l.ctx.operationDeadline = time.Now().Add(l.operationTimeout)
+}
+
+// operationBudget returns what is left of the operation's budget, and false
+// when the operation has none.
+func (l *Loader) operationBudget() (time.Duration, bool) {
+	if l.ctx.operationDeadline.IsZero() {
+		return 0, false
+	}
+	return time.Until(l.ctx.operationDeadline), true
+}
+
+// attemptTimeout returns the time an attempt of the fetch is given, and whether
+// the operation's budget is what limits it. Zero leaves the attempt unbounded.
+func (l *Loader) attemptTimeout(res *result) (time.Duration, bool) {
+	timeout := l.dataSourceFetchTimeout(res)
+	budget, ok := l.operationBudget()
+	if ok && (timeout <= 0 || budget < timeout) {
+		return max(budget, time.Nanosecond), true
+	}
+	return max(timeout, 0), false
+}
+
+// dataSourceFetchTimeout returns the fetch timeout of the data source of the
+// fetch, regardless of the operation's budget.
+func (l *Loader) dataSourceFetchTimeout(res *result) time.Duration {
+	if override, ok := l.dataSourceFetchTimeouts[res.ds.ID]; ok {
+		return override
+	}
+	return l.fetchTimeout
+}
+
+// loadWithTimeout is executeSourceLoad bounded by attemptTimeout. A load that
+// runs out of time fails with a FetchTimeoutError, unless the request itself
+// was done by then.
+func (l *Loader) loadWithTimeout(ctx context.Context, prepared *preparedFetch) {
+	res := prepared.res
+	timeout, operationDeadline := l.attemptTimeout(res)
+	if timeout == 0 {
+		l.executeSourceLoad(ctx, prepared.item, prepared.source, prepared.input, res, prepared.trace)
+		return
+	}
+
+	loadCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	l.executeSourceLoad(loadCtx, prepared.item, prepared.source, prepared.input, res, prepared.trace)
+	if res.err == nil || ctx.Err() != nil || !errors.Is(loadCtx.Err(), context.DeadlineExceeded) {
+		return
+	}
+	res.err = &FetchTimeoutError{
+		SubgraphName:      res.ds.Name,
+		Path:              prepared.item.ResponsePath,
+		Timeout:           timeout,
+		Elapsed:           time.Since(start),
+		OperationDeadline: operationDeadline,
+	}
+}
+
+// operationDeadlineExceeded returns the error of a fetch that is not sent at
+// all, because the operation's budget ran out before it.
+func (l *Loader) operationDeadlineExceeded(prepared *preparedFetch) error {
+	budget, ok := l.operationBudget()
+	if !ok || budget > 0 {
+		return nil
+	}
+	return &FetchTimeoutError{
+		SubgraphName:      prepared.res.ds.Name,
+		Path:              prepared.item.ResponsePath,
+		OperationDeadline: true,
+	}
+}
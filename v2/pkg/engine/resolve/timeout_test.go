@@ -0,0 +1,168 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/ast"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+)
+
+// slowDataSource answers after delay, or fails once its context is done.
+type slowDataSource struct {
+	delay    time.Duration
+	response string
+	calls    atomic.Int32
+}
+
+func (s *slowDataSource) Load(ctx context.Context, _ http.Header, _ []byte) ([]byte, error) {
+	s.calls.Add(1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(s.delay):
+		return []byte(s.response), nil
+	}
+}
+
+func (s *slowDataSource) LoadWithFiles(ctx context.Context, headers http.Header, input []byte, _ []*httpclient.FileUpload) ([]byte, error) {
+	return s.Load(ctx, headers, input)
+}
+
+func requireFetchTimeoutError(t *testing.T, ctx *Context) *FetchTimeoutError {
+	t.Helper()
+	var timeoutErr *FetchTimeoutError
+	require.True(t, errors.As(ctx.SubgraphErrors(), &timeoutErr), "expected a FetchTimeoutError, got %v", ctx.SubgraphErrors())
+	return timeoutErr
+}
+
+func TestLoader_FetchTimeout(t *testing.T) {
+	const ok = `{"data":{"name":"Jens"}}`
+
+	t.Run("a fetch that takes longer than its timeout fails with a timeout error", func(t *testing.T) {
+		ds := &slowDataSource{delay: time.Minute, response: ok}
+		ctx := NewContext(t.Context())
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{FetchTimeout: 20 * time.Millisecond}, ast.OperationTypeQuery, ds, ctx)
+
+		assert.Equal(t, `{"errors":[{"message":"Failed to fetch from Subgraph 'users' at Path 'query', Reason: timed out after 20ms."}],"data":{"name":null}}`, out)
+		timeoutErr := requireFetchTimeoutError(t, ctx)
+		assert.Equal(t, "users", timeoutErr.SubgraphName)
+		assert.Equal(t, 20*time.Millisecond, timeoutErr.Timeout)
+		assert.GreaterOrEqual(t, timeoutErr.Elapsed, 20*time.Millisecond)
+		assert.False(t, timeoutErr.OperationDeadline)
+		assert.ErrorIs(t, timeoutErr, context.DeadlineExceeded)
+	})
+
+	t.Run("a fetch within its timeout succeeds", func(t *testing.T) {
+		ds := &slowDataSource{response: ok}
+
+		out, _ := resolveSingleFetch(t, ResolverOptions{FetchTimeout: time.Minute}, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, ok, out)
+	})
+
+	t.Run("a data source can override the timeout", func(t *testing.T) {
+		ds := &slowDataSource{delay: 30 * time.Millisecond, response: ok}
+		options := ResolverOptions{
+			FetchTimeout:            10 * time.Millisecond,
+			DataSourceFetchTimeouts: map[string]time.Duration{"0": 0},
+		}
+
+		out, _ := resolveSingleFetch(t, options, ast.OperationTypeQuery, ds, NewContext(t.Context()))
+
+		assert.Equal(t, ok, out)
+	})
+
+	t.Run("a request that is canceled is not a timeout", func(t *testing.T) {
+		ds := &slowDataSource{delay: time.Minute, response: ok}
+		requestCtx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		ctx := NewContext(requestCtx)
+
+		resolveSingleFetch(t, ResolverOptions{FetchTimeout: time.Minute}, ast.OperationTypeQuery, ds, ctx)
+
+		var timeoutErr *FetchTimeoutError
+		assert.False(t, errors.As(ctx.SubgraphErrors(), &timeoutErr))
+	})
+}
+
+func TestLoader_OperationTimeout(t *testing.T) {
+	// resolveSequence resolves two fetches, one after the other: first answers
+	// name and second answers email.
+	resolveSequence := func(t *testing.T, options ResolverOptions, first, second DataSource, ctx *Context) string {
+		t.Helper()
+		options.MaxConcurrency = 1
+		r := New(t.Context(), options)
+		fetch := func(ds DataSource, name string, id int) *SingleFetch {
+			return &SingleFetch{
+				FetchDependencies: FetchDependencies{FetchID: id},
+				InputTemplate: InputTemplate{
+					Segments: []TemplateSegment{{SegmentType: StaticSegmentType, Data: []byte(`{"method":"POST","url":"http://` + name + `","body":{"query":"{` + name + `}"}}`)}},
+				},
+				FetchConfiguration: FetchConfiguration{
+					DataSource: ds,
+					PostProcessing: PostProcessingConfiguration{
+						SelectResponseDataPath:   []string{"data"},
+						SelectResponseErrorsPath: []string{"errors"},
+					},
+				},
+				Info: &FetchInfo{DataSourceID: name, DataSourceName: name, OperationType: ast.OperationTypeQuery},
+			}
+		}
+		response := &GraphQLResponse{
+			Info: &GraphQLResponseInfo{OperationType: ast.OperationTypeQuery},
+			Fetches: Sequence(
+				SingleWithPath(fetch(first, "users", 0), "query"),
+				SingleWithPath(fetch(second, "accounts", 1), "query"),
+			),
+			Data: &Object{
+				Fields: []*Field{
+					{Name: []byte("name"), Value: &String{Path: []string{"name"}, Nullable: true}},
+					{Name: []byte("email"), Value: &String{Path: []string{"email"}, Nullable: true}},
+				},
+			},
+		}
+		buf := &bytes.Buffer{}
+		_, err := r.ResolveGraphQLResponse(ctx, response, nil, buf)
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("a late fetch only gets what is left of the budget", func(t *testing.T) {
+		first := &slowDataSource{delay: 50 * time.Millisecond, response: `{"data":{"name":"Jens"}}`}
+		second := &slowDataSource{delay: time.Minute, response: `{"data":{"email":"jens@example.com"}}`}
+		ctx := NewContext(t.Context())
+
+		start := time.Now()
+		out := resolveSequence(t, ResolverOptions{FetchTimeout: time.Minute, OperationTimeout: 100 * time.Millisecond}, first, second, ctx)
+
+		assert.Less(t, time.Since(start), time.Minute)
+		assert.Equal(t, `{"errors":[{"message":"Failed to fetch from Subgraph 'accounts' at Path 'query', Reason: operation deadline exceeded."}],"data":{"name":"Jens","email":null}}`, out)
+		timeoutErr := requireFetchTimeoutError(t, ctx)
+		assert.Equal(t, "accounts", timeoutErr.SubgraphName)
+		assert.True(t, timeoutErr.OperationDeadline)
+		assert.Less(t, timeoutErr.Timeout, 100*time.Millisecond, "the first fetch used part of the budget")
+	})
+
+	t.Run("a fetch is not sent once the budget has run out", func(t *testing.T) {
+		first := &slowDataSource{delay: 50 * time.Millisecond, response: `{"data":{"name":"Jens"}}`}
+		second := &slowDataSource{response: `{"data":{"email":"jens@example.com"}}`}
+		ctx := NewContext(t.Context())
+
+		out := resolveSequence(t, ResolverOptions{OperationTimeout: 10 * time.Millisecond}, first, second, ctx)
+
+		assert.Equal(t, int32(0), second.calls.Load())
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'users' at Path 'query', Reason: operation deadline exceeded.`)
+		assert.Contains(t, out, `Failed to fetch from Subgraph 'accounts' at Path 'query', Reason: operation deadline exceeded.`)
+		assert.ErrorContains(t, ctx.SubgraphErrors(), `Fetch from Subgraph 'accounts' at Path 'query' was not sent, the operation deadline was exceeded.`)
+	})
+}
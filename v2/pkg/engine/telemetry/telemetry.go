@@ -0,0 +1,271 @@
+// Package telemetry integrates the engine with OpenTelemetry.
+//
+// It records a span per operation, with child spans for the parse, normalize,
+// validate and plan phases and for every fetch, and histograms of the latency
+// of fetches and operations. Telemetry implements resolve.LoaderHooks to
+// record the fetches, and propagates the trace context of a fetch to the
+// subgraph, in the headers of HTTP requests and the metadata of gRPC calls.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+const instrumentationName = "github.com/wundergraph/graphql-go-tools/v2/pkg/engine/telemetry"
+
+const (
+	OperationSpanName = "graphql.operation"
+	FetchSpanName     = "graphql.fetch"
+
+	OperationDurationName = "graphql.operation.duration"
+	FetchDurationName     = "graphql.fetch.duration"
+)
+
+const (
+	OperationNameKey = attribute.Key("graphql.operation.name")
+	OperationTypeKey = attribute.Key("graphql.operation.type")
+	SubgraphIDKey    = attribute.Key("graphql.subgraph.id")
+	SubgraphNameKey  = attribute.Key("graphql.subgraph.name")
+	FetchKindKey     = attribute.Key("graphql.fetch.kind")
+	CacheHitKey      = attribute.Key("graphql.fetch.cache_hit")
+	AttemptKey       = attribute.Key("graphql.fetch.attempt")
+	HedgedKey        = attribute.Key("graphql.fetch.hedged")
+	StatusCodeKey    = attribute.Key("http.response.status_code")
+	ErrorKey         = attribute.Key("graphql.error")
+)
+
+// durationBuckets are the histogram boundaries in seconds, as recommended by
+// the OpenTelemetry semantic conventions for request durations.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Phase is a phase of the execution of an operation before it is resolved.
+type Phase string
+
+const (
+	PhaseParse     Phase = "parse"
+	PhaseNormalize Phase = "normalize"
+	PhaseValidate  Phase = "validate"
+	PhasePlan      Phase = "plan"
+)
+
+// Options configure Telemetry.
+type Options struct {
+	// TracerProvider defaults to the global TracerProvider.
+	TracerProvider trace.TracerProvider
+	// MeterProvider defaults to the global MeterProvider.
+	MeterProvider metric.MeterProvider
+	// Propagator injects the trace context of a fetch into the subgraph
+	// request, it defaults to the W3C trace context (traceparent).
+	Propagator propagation.TextMapPropagator
+}
+
+// Telemetry records the spans and metrics of operations and their fetches.
+// It is safe for concurrent use.
+type Telemetry struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+
+	operationDuration metric.Float64Histogram
+	fetchDuration     metric.Float64Histogram
+}
+
+func New(options Options) (*Telemetry, error) {
+	if options.TracerProvider == nil {
+		options.TracerProvider = otel.GetTracerProvider()
+	}
+	if options.MeterProvider == nil {
+		options.MeterProvider = otel.GetMeterProvider()
+	}
+	if options.Propagator == nil {
+		options.Propagator = propagation.TraceContext{}
+	}
+
+	meter := options.MeterProvider.Meter(instrumentationName)
+	operationDuration, err := meter.Float64Histogram(OperationDurationName,
+		metric.WithDescription("Duration of GraphQL operations."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	fetchDuration, err := meter.Float64Histogram(FetchDurationName,
+		metric.WithDescription("Duration of fetches from subgraphs."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(durationBuckets...),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Telemetry{
+		tracer:            options.TracerProvider.Tracer(instrumentationName),
+		propagator:        options.Propagator,
+		operationDuration: operationDuration,
+		fetchDuration:     fetchDuration,
+	}, nil
+}
+
+// Operation is the span of an operation started by StartOperation.
+type Operation struct {
+	telemetry     *Telemetry
+	span          trace.Span
+	start         time.Time
+	operationType string
+}
+
+// StartOperation starts the span of an operation. The spans of its phases and
+// fetches are started from the returned context and are children of it.
+func (t *Telemetry) StartOperation(ctx context.Context, operationName string) (context.Context, *Operation) {
+	var attributes []attribute.KeyValue
+	if operationName != "" {
+		attributes = append(attributes, OperationNameKey.String(operationName))
+	}
+	ctx, span := t.tracer.Start(ctx, OperationSpanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attributes...),
+	)
+	return ctx, &Operation{
+		telemetry: t,
+		span:      span,
+		start:     time.Now(),
+	}
+}
+
+// SetType sets the type of the operation, e.g. query, once it is parsed.
+func (o *Operation) SetType(operationType string) {
+	o.operationType = operationType
+	o.span.SetAttributes(OperationTypeKey.String(operationType))
+}
+
+// End ends the span of the operation and records its duration. A non-nil err
+// marks the operation as failed.
+func (o *Operation) End(err error) {
+	attributes := make([]attribute.KeyValue, 0, 2)
+	if o.operationType != "" {
+		attributes = append(attributes, OperationTypeKey.String(o.operationType))
+	}
+	attributes = append(attributes, ErrorKey.Bool(err != nil))
+
+	recordError(o.span, err)
+	o.telemetry.operationDuration.Record(context.Background(), time.Since(o.start).Seconds(), metric.WithAttributes(attributes...))
+	o.span.End()
+}
+
+// StartPhase starts the span of a phase of the operation in ctx. The returned
+// function ends it, a non-nil error marks the phase as failed.
+func (t *Telemetry) StartPhase(ctx context.Context, phase Phase) func(err error) {
+	_, span := t.tracer.Start(ctx, "graphql."+string(phase))
+	return func(err error) {
+		recordError(span, err)
+		span.End()
+	}
+}
+
+type fetchContextKey struct{}
+
+type fetch struct {
+	span  trace.Span
+	start time.Time
+}
+
+// OnLoad starts the span of a fetch and injects its trace context into the
+// headers of HTTP requests and the outgoing metadata of gRPC calls.
+func (t *Telemetry) OnLoad(ctx context.Context, ds resolve.DataSourceInfo) context.Context {
+	ctx, span := t.tracer.Start(ctx, FetchSpanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(SubgraphIDKey.String(ds.ID), SubgraphNameKey.String(ds.Name)),
+	)
+	ctx = context.WithValue(ctx, fetchContextKey{}, &fetch{span: span, start: time.Now()})
+	return t.inject(ctx)
+}
+
+// OnFinished ends the span of the fetch started by OnLoad and records its duration.
+func (t *Telemetry) OnFinished(ctx context.Context, ds resolve.DataSourceInfo, info *resolve.ResponseInfo) {
+	f, ok := ctx.Value(fetchContextKey{}).(*fetch)
+	if !ok {
+		return
+	}
+	t.endFetch(f, ds, info)
+}
+
+// OnCacheHit records a fetch served from the response cache as a span of its own.
+func (t *Telemetry) OnCacheHit(ctx context.Context, ds resolve.DataSourceInfo, info *resolve.ResponseInfo) {
+	_, span := t.tracer.Start(ctx, FetchSpanName,
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(SubgraphIDKey.String(ds.ID), SubgraphNameKey.String(ds.Name)),
+	)
+	t.endFetch(&fetch{span: span, start: time.Now()}, ds, info)
+}
+
+func (t *Telemetry) endFetch(f *fetch, ds resolve.DataSourceInfo, info *resolve.ResponseInfo) {
+	attributes := make([]attribute.KeyValue, 0, 5)
+	attributes = append(attributes,
+		SubgraphNameKey.String(ds.Name),
+		FetchKindKey.String(info.FetchKind.String()),
+		CacheHitKey.Bool(info.CacheHit),
+	)
+	if info.StatusCode != 0 {
+		attributes = append(attributes, StatusCodeKey.Int(info.StatusCode))
+	}
+
+	f.span.SetAttributes(attributes...)
+	if info.Attempt > 0 {
+		f.span.SetAttributes(AttemptKey.Int(info.Attempt))
+	}
+	if info.Hedged {
+		f.span.SetAttributes(HedgedKey.Bool(true))
+	}
+	recordError(f.span, info.Err)
+
+	attributes = append(attributes, ErrorKey.Bool(info.Err != nil))
+	t.fetchDuration.Record(context.Background(), time.Since(f.start).Seconds(), metric.WithAttributes(attributes...))
+	f.span.End()
+}
+
+// inject propagates the trace context of ctx to the subgraph. The headers are
+// used by HTTP requests and the metadata by gRPC calls, each transport ignores
+// the other.
+func (t *Telemetry) inject(ctx context.Context) context.Context {
+	header := make(http.Header)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(header))
+	if len(header) == 0 {
+		return ctx
+	}
+
+	pairs := make([]string, 0, len(header)*2)
+	for key, values := range header {
+		for _, value := range values {
+			pairs = append(pairs, strings.ToLower(key), value)
+		}
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+	return httpclient.WithRequestHeader(ctx, header)
+}
+
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+var (
+	_ resolve.LoaderHooks      = (*Telemetry)(nil)
+	_ resolve.LoaderCacheHooks = (*Telemetry)(nil)
+)
@@ -0,0 +1,226 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/datasource/httpclient"
+	"github.com/wundergraph/graphql-go-tools/v2/pkg/engine/resolve"
+)
+
+func newTestTelemetry(t *testing.T) (*Telemetry, *tracetest.InMemoryExporter, *sdkmetric.ManualReader) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	telemetry, err := New(Options{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+	})
+	require.NoError(t, err)
+	return telemetry, exporter, reader
+}
+
+func histogram(t *testing.T, reader *sdkmetric.ManualReader, name string) metricdata.Histogram[float64] {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			if m.Name == name {
+				return m.Data.(metricdata.Histogram[float64])
+			}
+		}
+	}
+	require.Failf(t, "histogram not recorded", "name: %s", name)
+	return metricdata.Histogram[float64]{}
+}
+
+func attributeValue(attributes []attribute.KeyValue, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTelemetry_Operation(t *testing.T) {
+	telemetry, exporter, reader := newTestTelemetry(t)
+
+	ctx, operation := telemetry.StartOperation(context.Background(), "MyQuery")
+	telemetry.StartPhase(ctx, PhaseParse)(nil)
+	telemetry.StartPhase(ctx, PhaseValidate)(errors.New("invalid"))
+	operation.SetType("query")
+	operation.End(nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	assert.Equal(t, "graphql.parse", spans[0].Name)
+	assert.Equal(t, "graphql.validate", spans[1].Name)
+	assert.Equal(t, codes.Error, spans[1].Status.Code)
+	assert.Equal(t, "invalid", spans[1].Status.Description)
+
+	assert.Equal(t, OperationSpanName, spans[2].Name)
+	assert.Equal(t, spans[2].SpanContext.SpanID(), spans[0].Parent.SpanID())
+	assert.Equal(t, spans[2].SpanContext.SpanID(), spans[1].Parent.SpanID())
+	name, _ := attributeValue(spans[2].Attributes, OperationNameKey)
+	assert.Equal(t, "MyQuery", name.AsString())
+	operationType, _ := attributeValue(spans[2].Attributes, OperationTypeKey)
+	assert.Equal(t, "query", operationType.AsString())
+
+	durations := histogram(t, reader, OperationDurationName)
+	require.Len(t, durations.DataPoints, 1)
+	assert.Equal(t, uint64(1), durations.DataPoints[0].Count)
+	operationType, _ = durations.DataPoints[0].Attributes.Value(OperationTypeKey)
+	assert.Equal(t, "query", operationType.AsString())
+	failed, _ := durations.DataPoints[0].Attributes.Value(ErrorKey)
+	assert.False(t, failed.AsBool())
+}
+
+func TestTelemetry_Fetch(t *testing.T) {
+	ds := resolve.DataSourceInfo{ID: "0", Name: "accounts"}
+
+	t.Run("fetch span is a child of the operation", func(t *testing.T) {
+		telemetry, exporter, reader := newTestTelemetry(t)
+
+		ctx, operation := telemetry.StartOperation(context.Background(), "")
+		fetchCtx := telemetry.OnLoad(ctx, ds)
+		telemetry.OnFinished(fetchCtx, ds, &resolve.ResponseInfo{
+			StatusCode: http.StatusOK,
+			FetchKind:  resolve.FetchKindEntity,
+			Attempt:    1,
+		})
+		operation.End(nil)
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 2)
+		fetch := spans[0]
+		assert.Equal(t, FetchSpanName, fetch.Name)
+		assert.Equal(t, spans[1].SpanContext.SpanID(), fetch.Parent.SpanID())
+		assert.Equal(t, codes.Unset, fetch.Status.Code)
+
+		expected := map[attribute.Key]attribute.Value{
+			SubgraphIDKey:   attribute.StringValue("0"),
+			SubgraphNameKey: attribute.StringValue("accounts"),
+			FetchKindKey:    attribute.StringValue("entity"),
+			CacheHitKey:     attribute.BoolValue(false),
+			StatusCodeKey:   attribute.IntValue(http.StatusOK),
+			AttemptKey:      attribute.IntValue(1),
+		}
+		for key, value := range expected {
+			actual, ok := attributeValue(fetch.Attributes, key)
+			assert.True(t, ok, key)
+			assert.Equal(t, value, actual, key)
+		}
+
+		durations := histogram(t, reader, FetchDurationName)
+		require.Len(t, durations.DataPoints, 1)
+		subgraph, _ := durations.DataPoints[0].Attributes.Value(SubgraphNameKey)
+		assert.Equal(t, "accounts", subgraph.AsString())
+		statusCode, _ := durations.DataPoints[0].Attributes.Value(StatusCodeKey)
+		assert.Equal(t, int64(http.StatusOK), statusCode.AsInt64())
+	})
+
+	t.Run("failed fetch", func(t *testing.T) {
+		telemetry, exporter, reader := newTestTelemetry(t)
+
+		fetchCtx := telemetry.OnLoad(context.Background(), ds)
+		telemetry.OnFinished(fetchCtx, ds, &resolve.ResponseInfo{
+			StatusCode: http.StatusBadGateway,
+			FetchKind:  resolve.FetchKindSingle,
+			Err:        errors.New("bad gateway"),
+		})
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		require.Len(t, spans[0].Events, 1)
+		assert.Equal(t, "exception", spans[0].Events[0].Name)
+
+		durations := histogram(t, reader, FetchDurationName)
+		require.Len(t, durations.DataPoints, 1)
+		failed, _ := durations.DataPoints[0].Attributes.Value(ErrorKey)
+		assert.True(t, failed.AsBool())
+	})
+
+	t.Run("cache hit", func(t *testing.T) {
+		telemetry, exporter, reader := newTestTelemetry(t)
+
+		telemetry.OnCacheHit(context.Background(), ds, &resolve.ResponseInfo{
+			FetchKind: resolve.FetchKindEntityBatch,
+			CacheHit:  true,
+		})
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		cacheHit, _ := attributeValue(spans[0].Attributes, CacheHitKey)
+		assert.True(t, cacheHit.AsBool())
+		_, ok := attributeValue(spans[0].Attributes, StatusCodeKey)
+		assert.False(t, ok)
+
+		durations := histogram(t, reader, FetchDurationName)
+		require.Len(t, durations.DataPoints, 1)
+		fetchKind, _ := durations.DataPoints[0].Attributes.Value(FetchKindKey)
+		assert.Equal(t, "entity_batch", fetchKind.AsString())
+	})
+
+	t.Run("OnFinished without OnLoad is ignored", func(t *testing.T) {
+		telemetry, exporter, _ := newTestTelemetry(t)
+
+		telemetry.OnFinished(context.Background(), ds, &resolve.ResponseInfo{})
+		assert.Empty(t, exporter.GetSpans())
+	})
+}
+
+func TestTelemetry_Propagation(t *testing.T) {
+	ds := resolve.DataSourceInfo{ID: "0", Name: "accounts"}
+
+	t.Run("traceparent is sent with HTTP requests", func(t *testing.T) {
+		telemetry, exporter, _ := newTestTelemetry(t)
+
+		var traceparent string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			traceparent = r.Header.Get("traceparent")
+			_, _ = w.Write([]byte(`{"data":{}}`))
+		}))
+		defer server.Close()
+
+		fetchCtx := telemetry.OnLoad(context.Background(), ds)
+		input := httpclient.SetInputURL(nil, []byte(server.URL))
+		input = httpclient.SetInputMethod(input, []byte("POST"))
+		_, err := httpclient.Do(http.DefaultClient, fetchCtx, nil, input)
+		require.NoError(t, err)
+		telemetry.OnFinished(fetchCtx, ds, &resolve.ResponseInfo{StatusCode: http.StatusOK})
+
+		spans := exporter.GetSpans()
+		require.Len(t, spans, 1)
+		spanContext := spans[0].SpanContext
+		assert.Equal(t, "00-"+spanContext.TraceID().String()+"-"+spanContext.SpanID().String()+"-01", traceparent)
+	})
+
+	t.Run("traceparent is set in the outgoing gRPC metadata", func(t *testing.T) {
+		telemetry, _, _ := newTestTelemetry(t)
+
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "token")
+		fetchCtx := telemetry.OnLoad(ctx, ds)
+
+		md, ok := metadata.FromOutgoingContext(fetchCtx)
+		require.True(t, ok)
+		assert.Equal(t, []string{"token"}, md.Get("authorization"))
+		require.Len(t, md.Get("traceparent"), 1)
+		assert.Regexp(t, `^00-[0-9a-f]{32}-[0-9a-f]{16}-01$`, md.Get("traceparent")[0])
+	})
+}
@@ -7,9 +7,16 @@ const (
 	InternalServerError     = "INTERNAL_SERVER_ERROR"
 	InvalidGraphql          = "INVALID_GRAPHQL"
 
+	// Apollo Automatic Persisted Queries
+	PersistedQueryNotFound     = "PERSISTED_QUERY_NOT_FOUND"
+	PersistedQueryNotSupported = "PERSISTED_QUERY_NOT_SUPPORTED"
+
 	// Apollo Router Compatibility
 	ValidationInvalidTypeVariable = "VALIDATION_INVALID_TYPE_VARIABLE"
 
+	// Demand Control
+	CostEstimatedTooExpensive = "COST_ESTIMATED_TOO_EXPENSIVE"
+
 	// Flag unrelated
 	UnauthorizedFieldOrType = "UNAUTHORIZED_FIELD_OR_TYPE"
 )